@@ -36,11 +36,64 @@ type Config struct {
 	KafkaGroupID  string   `mapstructure:"KAFKA_GROUP_ID"`
 
 	// JWT configuration
-	JWTSecret string
-	JWTExpiry int
+	JWTSecret           string
+	JWTExpiry           int    // Access token lifetime in seconds
+	RefreshTokenExpiry  int    // Refresh token lifetime in seconds
+	JWTSigningAlg       string // HS256 (shared secret), RS256 or EdDSA
+	JWTKeyRotation      int    // Signing key rotation interval in seconds
+	JWKSURL             string // Verify tokens with keys from this JWKS URL instead of the secret
+	JWTKeyEncryptionKey string // Base64 32-byte key encrypting the stored signing keys, auth service only
 
 	// Service discovery
 	ServiceDiscoveryURL string
+
+	// gRPC endpoints of other services
+	ProductGrpcAddr string
+	AuthGrpcAddr    string
+	OrderGrpcAddr   string
+
+	// Payment configuration
+	PaymentGateway string
+
+	// Account configuration
+	RequireEmailVerification bool     // Refuse login until the email address is verified
+	AppBaseURL               string   // Base URL of links in emails
+	LoginAttemptStore        string   // mongo or memory
+	LoginMaxAccountFailures  int      // Failed logins that lock an account
+	LoginMaxIPFailures       int      // Failed logins that lock a client IP
+	LoginLockoutDuration     int      // Lockout duration in seconds
+	TrustedProxies           []string // Proxies whose X-Forwarded-For is trusted for the client IP
+	TOTPIssuer               string   // Name shown for the account in authenticator apps
+	PrivacyServices          []string // Other services that handle data export and erasure requests
+	ExportRetention          int      // Download period of data exports in seconds
+
+	// Cart configuration
+	CartTokenSecret string // Signs the cart token cookie of guest carts
+	GuestCartTTL    int    // Lifetime of an unused guest cart in seconds
+	CartMergeRule   string // sum, newest or stock, how a guest cart merges into the user's cart
+
+	// Currency configuration
+	ExchangeRates         string // file, http or none, where rates for currencies without a list price come from
+	ExchangeRatesFile     string // Rates file of the file provider
+	ExchangeRatesURL      string // Rates endpoint of the http provider
+	ExchangeRatesTTL      int    // How long the http provider caches rates in seconds
+	ExchangeRatesMaxStale int    // How long the http provider uses rates it cannot refresh in seconds
+
+	// Tax configuration
+	TaxRulesFile string // YAML tax rules table; empty uses the built-in rules
+
+	// Shipping configuration
+	ShippingCarrier   string // Carrier quoting rates and creating shipments, only table for now
+	ShippingRatesFile string // YAML rate table of the table carrier; empty uses the built-in rates
+
+	// Mail configuration
+	Mailer       string // smtp, file or memory
+	MailFrom     string
+	MailDir      string // Output directory of the file mailer
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
 }
 
 // LoadConfig loads configuration from environment variables and .env file
@@ -74,11 +127,70 @@ func LoadConfig(serviceName string) (*Config, error) {
 		KafkaGroupID: getEnv("KAFKA_GROUP_ID", "my-group"),
 
 		// JWT configuration
-		JWTSecret: getEnv("JWT_SECRET", "your_jwt_secret_key"),
-		JWTExpiry: getEnvAsInt("JWT_EXPIRY", 24*60*60), // 24 hours in seconds
+		JWTSecret:           getEnv("JWT_SECRET", "your_jwt_secret_key"),
+		JWTExpiry:           getEnvAsInt("JWT_EXPIRY", 15*60),                 // 15 minutes in seconds
+		RefreshTokenExpiry:  getEnvAsInt("REFRESH_TOKEN_EXPIRY", 30*24*60*60), // 30 days in seconds
+		JWTSigningAlg:       getEnv("JWT_SIGNING_ALG", "HS256"),
+		JWTKeyRotation:      getEnvAsInt("JWT_KEY_ROTATION", 7*24*60*60), // 7 days in seconds
+		JWKSURL:             getEnv("JWT_JWKS_URL", ""),
+		JWTKeyEncryptionKey: getEnv("JWT_KEY_ENCRYPTION_KEY", ""),
 
 		// Service discovery
 		ServiceDiscoveryURL: getEnv("SERVICE_DISCOVERY_URL", "http://localhost:8500"),
+
+		// gRPC endpoints of other services
+		ProductGrpcAddr: getEnv("PRODUCT_GRPC_ADDR", "localhost:9090"),
+		AuthGrpcAddr:    getEnv("AUTH_GRPC_ADDR", "localhost:9091"),
+		OrderGrpcAddr:   getEnv("ORDER_GRPC_ADDR", "localhost:50051"),
+
+		// Payment configuration
+		PaymentGateway: getEnv("PAYMENT_GATEWAY", "fake"),
+
+		// Account configuration
+		RequireEmailVerification: getEnvAsBool("REQUIRE_EMAIL_VERIFICATION", false),
+		AppBaseURL:               getEnv("APP_BASE_URL", "http://localhost:3000"),
+		LoginAttemptStore:        getEnv("LOGIN_ATTEMPT_STORE", "mongo"),
+		LoginMaxAccountFailures:  getEnvAsInt("LOGIN_MAX_ACCOUNT_FAILURES", 5),
+		LoginMaxIPFailures:       getEnvAsInt("LOGIN_MAX_IP_FAILURES", 20),
+		LoginLockoutDuration:     getEnvAsInt("LOGIN_LOCKOUT_DURATION", 15*60), // 15 minutes in seconds
+		TrustedProxies:           getEnvAsList("TRUSTED_PROXIES"),
+		TOTPIssuer:               getEnv("TOTP_ISSUER", "E-commerce"),
+		PrivacyServices:          strings.Split(getEnv("PRIVACY_SERVICES", "cart-service,order-service,fulfillment-service"), ","),
+		ExportRetention:          getEnvAsInt("EXPORT_RETENTION", 7*24*60*60), // 7 days in seconds
+
+		// Cart configuration
+		CartTokenSecret: getEnv("CART_TOKEN_SECRET", "your_cart_token_secret"),
+		GuestCartTTL:    getEnvAsInt("GUEST_CART_TTL", 30*24*60*60), // 30 days in seconds
+		CartMergeRule:   getEnv("CART_MERGE_RULE", "sum"),
+
+		// Currency configuration
+		ExchangeRates:         getEnv("EXCHANGE_RATES", "none"),
+		ExchangeRatesFile:     getEnv("EXCHANGE_RATES_FILE", "exchange-rates.json"),
+		ExchangeRatesURL:      getEnv("EXCHANGE_RATES_URL", "http://localhost:8086/exchange-rates.json"),
+		ExchangeRatesTTL:      getEnvAsInt("EXCHANGE_RATES_TTL", 15*60),          // 15 minutes in seconds
+		ExchangeRatesMaxStale: getEnvAsInt("EXCHANGE_RATES_MAX_STALE", 24*60*60), // 1 day in seconds
+
+		// Tax configuration
+		TaxRulesFile: getEnv("TAX_RULES_FILE", ""),
+
+		// Shipping configuration
+		ShippingCarrier:   getEnv("SHIPPING_CARRIER", "table"),
+		ShippingRatesFile: getEnv("SHIPPING_RATES_FILE", ""),
+
+		// Mail configuration
+		Mailer:       getEnv("MAILER", "file"),
+		MailFrom:     getEnv("MAIL_FROM", "no-reply@example.com"),
+		MailDir:      getEnv("MAIL_DIR", "mail-outbox"),
+		SMTPHost:     getEnv("SMTP_HOST", "localhost"),
+		SMTPPort:     getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+	}
+
+	// Services verifying tokens with the JWKS never need the shared secret,
+	// so do not hand them the default one
+	if config.JWKSURL != "" {
+		config.JWTSecret = ""
 	}
 
 	// Also load from config file if available
@@ -128,3 +240,34 @@ func getEnvAsInt(key string, defaultValue int) int {
 
 	return value
 }
+
+// getEnvAsBool gets an environment variable as a boolean or returns a default value
+func getEnvAsBool(key string, defaultValue bool) bool {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return defaultValue
+	}
+
+	value, err := strconv.ParseBool(valueStr)
+	if err != nil {
+		return defaultValue
+	}
+
+	return value
+}
+
+// getEnvAsList gets a comma-separated environment variable as a list, or nil if it is not set
+func getEnvAsList(key string) []string {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return nil
+	}
+
+	var values []string
+	for _, value := range strings.Split(valueStr, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
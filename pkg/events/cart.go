@@ -0,0 +1,108 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// CartItem 結帳事件中的購物車項目
+//
+// ProductName、CategoryID、TaxCategory 與 UnitPrice 是結帳時的商品資訊快照，訂單以此計價與計稅，
+// 之後商品改價不影響訂單。舊版事件沒有商品資訊，單價為 0。
+type CartItem struct {
+	ProductID   string      `json:"product_id"`
+	Quantity    int         `json:"quantity"`
+	ProductName string      `json:"product_name,omitempty"`
+	CategoryID  string      `json:"category_id,omitempty"`
+	TaxCategory string      `json:"tax_category,omitempty"`
+	UnitPrice   money.Money `json:"unit_price"`
+}
+
+// CartCheckedOut 購物車結帳事件，訂單服務據此建立訂單
+type CartCheckedOut struct {
+	CartID       string     `json:"cart_id"`
+	UserID       string     `json:"user_id"`
+	Items        []CartItem `json:"items"`
+	CheckedOutAt time.Time  `json:"checked_out_at"`
+	// PaymentMethod 結帳時選擇的付款方式，空白時由用戶之後透過支付服務付款
+	PaymentMethod string `json:"payment_method,omitempty"`
+	// AddressID 地址簿中的收件地址，與 ShippingInfo 皆未提供時使用預設收件地址
+	AddressID    string        `json:"address_id,omitempty"`
+	ShippingInfo *ShippingInfo `json:"shipping_info,omitempty"`
+	// Subtotal 結帳時購物車的商品總金額，舊版事件為 0
+	Subtotal money.Money `json:"subtotal"`
+	// Discounts 結帳時套用的優惠折扣，FreeShipping 表示有免運優惠
+	Discounts    []Discount `json:"discounts,omitempty"`
+	FreeShipping bool       `json:"free_shipping,omitempty"`
+}
+
+// Discount 結帳事件中的優惠折扣
+type Discount struct {
+	PromotionID string      `json:"promotion_id"`
+	Code        string      `json:"code"`
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Amount      money.Money `json:"amount"`
+}
+
+// ShippingInfo 結帳時直接填寫的收件資訊
+type ShippingInfo struct {
+	FullName     string `json:"full_name"`
+	AddressLine1 string `json:"address_line_1"`
+	AddressLine2 string `json:"address_line_2,omitempty"`
+	City         string `json:"city"`
+	State        string `json:"state,omitempty"`
+	PostalCode   string `json:"postal_code,omitempty"`
+	Country      string `json:"country"`
+	PhoneNumber  string `json:"phone_number,omitempty"`
+}
+
+// EventType 實作 Event 介面
+func (CartCheckedOut) EventType() string { return TypeCartCheckedOut }
+
+// EventKey 以用戶ID作為分區 key
+func (e CartCheckedOut) EventKey() string { return e.UserID }
+
+// legacyCartItem 舊版 CHECKOUT 訊息中的購物車項目
+type legacyCartItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// upcastLegacyCheckout 將沒有信封的舊版 CHECKOUT 訊息轉換為版本 1 的負載
+func upcastLegacyCheckout(payload json.RawMessage) (json.RawMessage, error) {
+	var legacy struct {
+		UserID    string           `json:"user_id"`
+		CartID    string           `json:"cart_id"`
+		Items     []legacyCartItem `json:"items"`
+		Timestamp time.Time        `json:"timestamp"`
+	}
+	if err := json.Unmarshal(payload, &legacy); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		CartID       string           `json:"cart_id"`
+		UserID       string           `json:"user_id"`
+		Items        []legacyCartItem `json:"items"`
+		CheckedOutAt time.Time        `json:"checked_out_at"`
+	}{
+		CartID:       legacy.CartID,
+		UserID:       legacy.UserID,
+		Items:        legacy.Items,
+		CheckedOutAt: legacy.Timestamp,
+	})
+}
+
+// upcastCheckoutAmounts 將版本 1 結帳事件的金額轉換為 money.Money，版本 1 皆為預設幣別
+var upcastCheckoutAmounts = amountUpcaster(func(doc map[string]json.RawMessage) error {
+	if err := upcastAmounts(doc, money.DefaultCurrency, "subtotal"); err != nil {
+		return err
+	}
+	if err := upcastListAmounts(doc, money.DefaultCurrency, "items", "unit_price"); err != nil {
+		return err
+	}
+	return upcastListAmounts(doc, money.DefaultCurrency, "discounts", "amount")
+})
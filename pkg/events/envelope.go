@@ -0,0 +1,151 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrMalformed 事件無法解析，重試也不會成功
+var ErrMalformed = errors.New("無法解析的事件")
+
+// Envelope 所有 Kafka 事件共用的標準信封
+type Envelope struct {
+	ID            string          `json:"event_id"`
+	Type          string          `json:"event_type"`
+	SchemaVersion int             `json:"schema_version"`
+	OccurredAt    time.Time       `json:"occurred_at"`
+	Producer      string          `json:"producer"`
+	CorrelationID string          `json:"correlation_id,omitempty"`
+	Payload       json.RawMessage `json:"payload"`
+}
+
+// legacyNamespace 由舊格式訊息內容推導事件ID時使用的 UUID 命名空間
+var legacyNamespace = uuid.MustParse("b3e0c6d4-8f2a-4c1e-9a57-61d2f4e8c930")
+
+// Event 事件負載介面，每種負載對應一個事件類型
+type Event interface {
+	EventType() string
+}
+
+// Keyed 可選介面，負載提供分區 key 以保持同一實體的事件順序
+type Keyed interface {
+	EventKey() string
+}
+
+// Encoded 序列化後可直接發布的事件
+type Encoded struct {
+	Topic    string
+	Key      string
+	Data     []byte
+	Envelope *Envelope
+}
+
+type correlationKey struct{}
+
+// WithCorrelationID 將關聯 ID 放入 context，之後建立的事件都會帶上此 ID
+func WithCorrelationID(ctx context.Context, correlationID string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, correlationID)
+}
+
+// CorrelationID 取得 context 中的關聯 ID
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// Encode 使用預設註冊表將負載包裝成信封並序列化
+func Encode(ctx context.Context, producer string, payload Event) (*Encoded, error) {
+	return Default.Encode(ctx, producer, payload)
+}
+
+// Decode 使用預設註冊表解析信封，並將負載升級到目前的結構版本
+func Decode(data []byte) (*Envelope, Event, error) {
+	return Default.Decode(data)
+}
+
+// Encode 將負載包裝成信封並序列化，主題與結構版本取自註冊表
+func (r *Registry) Encode(ctx context.Context, producer string, payload Event) (*Encoded, error) {
+	def, ok := r.lookup(payload.EventType())
+	if !ok {
+		return nil, fmt.Errorf("未註冊的事件類型: %s", payload.EventType())
+	}
+
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("序列化事件負載失敗: %w", err)
+	}
+
+	env := &Envelope{
+		ID:            uuid.New().String(),
+		Type:          def.Type,
+		SchemaVersion: def.Version,
+		OccurredAt:    time.Now().UTC(),
+		Producer:      producer,
+		CorrelationID: CorrelationID(ctx),
+		Payload:       raw,
+	}
+	if env.CorrelationID == "" {
+		env.CorrelationID = env.ID
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("序列化事件信封失敗: %w", err)
+	}
+
+	var key string
+	if keyed, ok := payload.(Keyed); ok {
+		key = keyed.EventKey()
+	}
+
+	return &Encoded{Topic: def.Topic, Key: key, Data: data, Envelope: env}, nil
+}
+
+// Decode 解析信封，並將負載升級到目前的結構版本
+//
+// 沒有信封的舊格式訊息 (只有 event_type 與平鋪欄位) 會被視為結構版本 0，
+// 透過別名對應到事件類型後由升級器轉換。舊格式訊息沒有事件ID，
+// 以訊息內容推導，重複投遞的相同訊息得到相同的事件ID。
+func (r *Registry) Decode(data []byte) (*Envelope, Event, error) {
+	var env Envelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrMalformed, err)
+	}
+	if env.Type == "" {
+		return nil, nil, fmt.Errorf("%w: 缺少 event_type", ErrMalformed)
+	}
+
+	// 舊格式訊息
+	if env.Payload == nil {
+		env.SchemaVersion = 0
+		env.Payload = json.RawMessage(data)
+		if env.ID == "" {
+			env.ID = uuid.NewSHA1(legacyNamespace, data).String()
+		}
+	}
+	env.Type = r.resolveAlias(env.Type)
+
+	def, ok := r.lookup(env.Type)
+	if !ok {
+		return &env, nil, nil
+	}
+
+	payload, err := r.upcast(def, env.SchemaVersion, env.Payload)
+	if err != nil {
+		return nil, nil, err
+	}
+	env.Payload = payload
+	env.SchemaVersion = def.Version
+
+	event, err := def.decode(payload)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrMalformed, env.Type, err)
+	}
+
+	return &env, event, nil
+}
@@ -0,0 +1,43 @@
+package events
+
+// ShipmentItem 出貨事件中的訂單項目與本次出貨數量
+type ShipmentItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// ShipmentCreated 出貨建立事件，訂單服務據此將訂單標記為已出貨
+//
+// 訂單可以分批出貨，AllShipped 表示訂單的所有項目都已出貨。
+type ShipmentCreated struct {
+	ShipmentID     string         `json:"shipment_id"`
+	OrderID        string         `json:"order_id"`
+	UserID         string         `json:"user_id"`
+	Carrier        string         `json:"carrier"`
+	Service        string         `json:"service"`
+	TrackingNumber string         `json:"tracking_number"`
+	Items          []ShipmentItem `json:"items"`
+	AllShipped     bool           `json:"all_shipped"`
+}
+
+// EventType 實作 Event 介面
+func (ShipmentCreated) EventType() string { return TypeShipmentCreated }
+
+// EventKey 以訂單ID作為分區 key，同一訂單的出貨事件依序處理
+func (e ShipmentCreated) EventKey() string { return e.OrderID }
+
+// ShipmentDelivered 出貨送達事件，AllDelivered 表示訂單的所有項目都已出貨且送達，
+// 訂單服務據此將訂單標記為已送達
+type ShipmentDelivered struct {
+	ShipmentID     string `json:"shipment_id"`
+	OrderID        string `json:"order_id"`
+	UserID         string `json:"user_id"`
+	TrackingNumber string `json:"tracking_number"`
+	AllDelivered   bool   `json:"all_delivered"`
+}
+
+// EventType 實作 Event 介面
+func (ShipmentDelivered) EventType() string { return TypeShipmentDelivered }
+
+// EventKey 以訂單ID作為分區 key，同一訂單的出貨事件依序處理
+func (e ShipmentDelivered) EventKey() string { return e.OrderID }
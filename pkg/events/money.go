@@ -0,0 +1,85 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// 版本 1 的事件以主要單位的浮點數表示金額，版本 2 改為 money.Money，
+// 以下函數將版本 1 負載中的金額欄位轉換為版本 2 的格式。
+
+// upcastAmounts 將負載物件中的金額欄位轉換為 money.Money
+//
+// 缺少的欄位視為 0。已經是物件的欄位維持原狀，因此升級器可以重複套用。
+func upcastAmounts(doc map[string]json.RawMessage, currency string, fields ...string) error {
+	for _, field := range fields {
+		raw, err := upcastAmount(doc[field], currency)
+		if err != nil {
+			return fmt.Errorf("%s: %w", field, err)
+		}
+		doc[field] = raw
+	}
+	return nil
+}
+
+// upcastListAmounts 將負載物件中陣列欄位每個元素的金額欄位轉換為 money.Money
+func upcastListAmounts(doc map[string]json.RawMessage, currency, list string, fields ...string) error {
+	raw, ok := doc[list]
+	if !ok || bytes.Equal(bytes.TrimSpace(raw), []byte("null")) {
+		return nil
+	}
+
+	var elems []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &elems); err != nil {
+		return fmt.Errorf("%s: %w", list, err)
+	}
+	for _, elem := range elems {
+		if err := upcastAmounts(elem, currency, fields...); err != nil {
+			return fmt.Errorf("%s: %w", list, err)
+		}
+	}
+
+	converted, err := json.Marshal(elems)
+	if err != nil {
+		return err
+	}
+	doc[list] = converted
+	return nil
+}
+
+// upcastAmount 將以主要單位表示的數字轉換為 money.Money 的 JSON
+func upcastAmount(raw json.RawMessage, currency string) (json.RawMessage, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) > 0 && raw[0] == '{' {
+		return raw, nil
+	}
+
+	var amount float64
+	if len(raw) > 0 && !bytes.Equal(raw, []byte("null")) {
+		if err := json.Unmarshal(raw, &amount); err != nil {
+			return nil, err
+		}
+	}
+	m, err := money.FromFloat(amount, currency)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(m)
+}
+
+// amountUpcaster 以轉換負載物件的函數建立版本 1 到版本 2 的升級器
+func amountUpcaster(convert func(doc map[string]json.RawMessage) error) Upcaster {
+	return func(payload json.RawMessage) (json.RawMessage, error) {
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(payload, &doc); err != nil {
+			return nil, err
+		}
+		if err := convert(doc); err != nil {
+			return nil, err
+		}
+		return json.Marshal(doc)
+	}
+}
@@ -0,0 +1,58 @@
+package events
+
+import (
+	"context"
+	"fmt"
+)
+
+// HandlerFunc 處理已解析事件的函數
+type HandlerFunc func(ctx context.Context, env *Envelope, event Event) error
+
+// Mux 依事件類型將訊息分派給對應的處理函數
+type Mux struct {
+	registry *Registry
+	handlers map[string]HandlerFunc
+}
+
+// NewMux 創建事件分派器，registry 為 nil 時使用預設註冊表
+func NewMux(registry *Registry) *Mux {
+	if registry == nil {
+		registry = Default
+	}
+	return &Mux{
+		registry: registry,
+		handlers: make(map[string]HandlerFunc),
+	}
+}
+
+// Handle 為負載型別 T 註冊型別安全的處理函數
+func Handle[T Event](m *Mux, handler func(ctx context.Context, env *Envelope, event T) error) {
+	var zero T
+	m.handlers[zero.EventType()] = func(ctx context.Context, env *Envelope, event Event) error {
+		typed, ok := event.(T)
+		if !ok {
+			return fmt.Errorf("%w: %s 負載型別不符", ErrMalformed, env.Type)
+		}
+		return handler(ctx, env, typed)
+	}
+}
+
+// Dispatch 解析訊息並呼叫對應的處理函數，沒有處理函數的事件類型會被略過
+//
+// 處理函數收到的 ctx 帶有事件的關聯 ID，之後建立的事件會沿用此 ID。
+func (m *Mux) Dispatch(ctx context.Context, data []byte) error {
+	env, event, err := m.registry.Decode(data)
+	if err != nil {
+		return err
+	}
+
+	handler, ok := m.handlers[env.Type]
+	if !ok || event == nil {
+		return nil
+	}
+
+	if env.CorrelationID != "" {
+		ctx = WithCorrelationID(ctx, env.CorrelationID)
+	}
+	return handler(ctx, env, event)
+}
@@ -0,0 +1,76 @@
+package events
+
+import (
+	"encoding/json"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// OrderItem 訂單事件中的訂單項目
+type OrderItem struct {
+	ProductID   string      `json:"product_id"`
+	ProductName string      `json:"product_name"`
+	Quantity    int         `json:"quantity"`
+	UnitPrice   money.Money `json:"unit_price"`
+	Subtotal    money.Money `json:"subtotal"`
+}
+
+// OrderCreated 訂單建立事件
+type OrderCreated struct {
+	OrderID    string      `json:"order_id"`
+	UserID     string      `json:"user_id"`
+	Status     string      `json:"status"`
+	TotalPrice money.Money `json:"total_price"`
+	Items      []OrderItem `json:"items"`
+	// PaymentMethod 非空白時支付服務會自動授權並請款
+	PaymentMethod string `json:"payment_method,omitempty"`
+}
+
+// EventType 實作 Event 介面
+func (OrderCreated) EventType() string { return TypeOrderCreated }
+
+// EventKey 以訂單ID作為分區 key
+func (e OrderCreated) EventKey() string { return e.OrderID }
+
+// upcastOrderCreatedAmounts 將版本 1 訂單建立事件的金額轉換為 money.Money
+var upcastOrderCreatedAmounts = amountUpcaster(func(doc map[string]json.RawMessage) error {
+	if err := upcastAmounts(doc, money.DefaultCurrency, "total_price"); err != nil {
+		return err
+	}
+	return upcastListAmounts(doc, money.DefaultCurrency, "items", "unit_price", "subtotal")
+})
+
+// OrderStatusChanged 訂單狀態變更事件
+type OrderStatusChanged struct {
+	OrderID    string      `json:"order_id"`
+	UserID     string      `json:"user_id"`
+	FromStatus string      `json:"from_status"`
+	ToStatus   string      `json:"to_status"`
+	TotalPrice money.Money `json:"total_price"`
+	Actor      string      `json:"actor"`
+	Reason     string      `json:"reason,omitempty"`
+}
+
+// EventType 實作 Event 介面
+func (OrderStatusChanged) EventType() string { return TypeOrderStatusChanged }
+
+// EventKey 以訂單ID作為分區 key
+func (e OrderStatusChanged) EventKey() string { return e.OrderID }
+
+// upcastOrderStatusAmounts 將版本 1 訂單狀態變更事件的金額轉換為 money.Money
+var upcastOrderStatusAmounts = amountUpcaster(func(doc map[string]json.RawMessage) error {
+	return upcastAmounts(doc, money.DefaultCurrency, "total_price")
+})
+
+// PaymentReversalRequested 訂單已取消但仍收到付款成功時，要求支付服務撤銷或退款
+type PaymentReversalRequested struct {
+	OrderID   string `json:"order_id"`
+	PaymentID string `json:"payment_id"`
+	Reason    string `json:"reason"`
+}
+
+// EventType 實作 Event 介面
+func (PaymentReversalRequested) EventType() string { return TypePaymentReversalRequested }
+
+// EventKey 以訂單ID作為分區 key
+func (e PaymentReversalRequested) EventKey() string { return e.OrderID }
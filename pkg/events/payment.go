@@ -0,0 +1,56 @@
+package events
+
+import (
+	"encoding/json"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// OrderPaymentKey 支付服務依訂單建立事件自動付款時使用的冪等鍵，訂單 saga 據此辨識自己發起的支付
+func OrderPaymentKey(orderID string) string {
+	return "order:" + orderID
+}
+
+// PaymentSucceeded 支付請款成功事件，訂單服務據此將訂單標記為已支付
+type PaymentSucceeded struct {
+	PaymentID      string      `json:"payment_id"`
+	OrderID        string      `json:"order_id"`
+	UserID         string      `json:"user_id"`
+	Amount         money.Money `json:"amount"`
+	IdempotencyKey string      `json:"idempotency_key,omitempty"`
+}
+
+// EventType 實作 Event 介面
+func (PaymentSucceeded) EventType() string { return TypePaymentSucceeded }
+
+// EventKey 以訂單ID作為分區 key，同一訂單的支付事件依序處理
+func (e PaymentSucceeded) EventKey() string { return e.OrderID }
+
+// PaymentFailed 支付授權遭拒或失敗事件，訂單服務據此取消訂單
+type PaymentFailed struct {
+	PaymentID      string      `json:"payment_id"`
+	OrderID        string      `json:"order_id"`
+	UserID         string      `json:"user_id"`
+	Amount         money.Money `json:"amount"`
+	FailureCode    string      `json:"failure_code"`
+	FailureMessage string      `json:"failure_message"`
+	IdempotencyKey string      `json:"idempotency_key,omitempty"`
+}
+
+// EventType 實作 Event 介面
+func (PaymentFailed) EventType() string { return TypePaymentFailed }
+
+// EventKey 以訂單ID作為分區 key，同一訂單的支付事件依序處理
+func (e PaymentFailed) EventKey() string { return e.OrderID }
+
+// upcastPaymentAmount 將版本 1 支付事件的金額與幣別欄位合併為 money.Money
+var upcastPaymentAmount = amountUpcaster(func(doc map[string]json.RawMessage) error {
+	currency := money.DefaultCurrency
+	if raw, ok := doc["currency"]; ok {
+		if err := json.Unmarshal(raw, &currency); err != nil {
+			return err
+		}
+		delete(doc, "currency")
+	}
+	return upcastAmounts(doc, currency, "amount")
+})
@@ -0,0 +1,45 @@
+package events
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// 資料主體請求的種類
+const (
+	DataSubjectExport  = "export"
+	DataSubjectErasure = "erasure"
+)
+
+// DataSubjectRequested 用戶要求匯出或刪除個人資料，每個保存用戶資料的服務都需處理
+type DataSubjectRequested struct {
+	RequestID   string    `json:"request_id"`
+	UserID      string    `json:"user_id"`
+	Kind        string    `json:"kind"`
+	RequestedAt time.Time `json:"requested_at"`
+}
+
+// EventType 實作 Event 介面
+func (DataSubjectRequested) EventType() string { return TypeDataSubjectRequested }
+
+// EventKey 以用戶ID作為分區 key
+func (e DataSubjectRequested) EventKey() string { return e.UserID }
+
+// DataSubjectCompleted 單一服務處理完資料主體請求的回報，由認證服務彙整
+type DataSubjectCompleted struct {
+	RequestID string `json:"request_id"`
+	UserID    string `json:"user_id"`
+	Kind      string `json:"kind"`
+	Service   string `json:"service"`
+	// Data 匯出請求時該服務保存的用戶資料
+	Data json.RawMessage `json:"data,omitempty"`
+	// Error 無法完成時的原因，空白表示成功
+	Error       string    `json:"error,omitempty"`
+	CompletedAt time.Time `json:"completed_at"`
+}
+
+// EventType 實作 Event 介面
+func (DataSubjectCompleted) EventType() string { return TypeDataSubjectCompleted }
+
+// EventKey 以請求ID作為分區 key
+func (e DataSubjectCompleted) EventKey() string { return e.RequestID }
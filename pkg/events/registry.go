@@ -0,0 +1,135 @@
+package events
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Upcaster 將負載從某個結構版本轉換到下一個版本
+type Upcaster func(payload json.RawMessage) (json.RawMessage, error)
+
+// Definition 一種事件類型的註冊資訊
+type Definition struct {
+	Type        string
+	Topic       string
+	Version     int
+	payloadType reflect.Type
+}
+
+// decode 將負載解析為註冊的 Go 型別
+func (d Definition) decode(payload json.RawMessage) (Event, error) {
+	ptr := reflect.New(d.payloadType)
+	if err := json.Unmarshal(payload, ptr.Interface()); err != nil {
+		return nil, err
+	}
+	return ptr.Elem().Interface().(Event), nil
+}
+
+// Registry 事件類型、主題、Go 負載型別與升級器的註冊表
+type Registry struct {
+	mu        sync.RWMutex
+	defs      map[string]Definition
+	upcasters map[string]map[int]Upcaster
+	aliases   map[string]string
+}
+
+// Default 預設的事件註冊表，內建事件在 init 時註冊
+var Default = NewRegistry()
+
+// NewRegistry 創建空的事件註冊表
+func NewRegistry() *Registry {
+	return &Registry{
+		defs:      make(map[string]Definition),
+		upcasters: make(map[string]map[int]Upcaster),
+		aliases:   make(map[string]string),
+	}
+}
+
+// Register 註冊事件負載型別、所屬主題與目前的結構版本
+func (r *Registry) Register(sample Event, topic string, version int) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	t := reflect.TypeOf(sample)
+	if t.Kind() == reflect.Ptr {
+		panic(fmt.Sprintf("事件負載必須以值型別註冊: %s", t))
+	}
+
+	r.defs[sample.EventType()] = Definition{
+		Type:        sample.EventType(),
+		Topic:       topic,
+		Version:     version,
+		payloadType: t,
+	}
+}
+
+// RegisterUpcaster 註冊將 fromVersion 版本負載升級到 fromVersion+1 的轉換函數
+func (r *Registry) RegisterUpcaster(eventType string, fromVersion int, upcaster Upcaster) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.upcasters[eventType] == nil {
+		r.upcasters[eventType] = make(map[int]Upcaster)
+	}
+	r.upcasters[eventType][fromVersion] = upcaster
+}
+
+// RegisterAlias 將舊格式訊息中的事件類型名稱對應到目前的事件類型
+func (r *Registry) RegisterAlias(legacyType, eventType string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.aliases[legacyType] = eventType
+}
+
+// Topic 回傳事件類型所屬的主題
+func (r *Registry) Topic(eventType string) (string, bool) {
+	def, ok := r.lookup(eventType)
+	return def.Topic, ok
+}
+
+func (r *Registry) lookup(eventType string) (Definition, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	def, ok := r.defs[eventType]
+	return def, ok
+}
+
+func (r *Registry) resolveAlias(eventType string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if alias, ok := r.aliases[eventType]; ok {
+		return alias
+	}
+	return eventType
+}
+
+// upcast 依序套用升級器，將負載從 version 升級到註冊的目前版本
+func (r *Registry) upcast(def Definition, version int, payload json.RawMessage) (json.RawMessage, error) {
+	if version > def.Version {
+		return nil, fmt.Errorf("%w: %s 結構版本 %d 高於支援的版本 %d", ErrMalformed, def.Type, version, def.Version)
+	}
+
+	r.mu.RLock()
+	chain := r.upcasters[def.Type]
+	r.mu.RUnlock()
+
+	for v := version; v < def.Version; v++ {
+		upcaster, ok := chain[v]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s 缺少版本 %d 的升級器", ErrMalformed, def.Type, v)
+		}
+
+		var err error
+		payload, err = upcaster(payload)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s 從版本 %d 升級失敗: %v", ErrMalformed, def.Type, v, err)
+		}
+	}
+
+	return payload, nil
+}
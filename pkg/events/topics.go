@@ -0,0 +1,51 @@
+package events
+
+// Kafka 主題名稱，所有服務都應該使用這些常數而不是自行拼寫
+const (
+	TopicCartEvents        = "cart-events"
+	TopicOrderEvents       = "order-events"
+	TopicPaymentEvents     = "payment-events"
+	TopicFulfillmentEvents = "fulfillment-events"
+	// 資料主體請求由認證服務發出、各服務處理，處理結果另以主題回報給認證服務
+	TopicPrivacyRequests = "privacy-requests"
+	TopicPrivacyResults  = "privacy-results"
+)
+
+// 事件類型
+const (
+	TypeCartCheckedOut           = "cart.checked_out"
+	TypeOrderCreated             = "order.created"
+	TypeOrderStatusChanged       = "order.status_changed"
+	TypePaymentReversalRequested = "order.payment_reversal_requested"
+	TypePaymentSucceeded         = "payment.succeeded"
+	TypePaymentFailed            = "payment.failed"
+	TypeShipmentCreated          = "shipment.created"
+	TypeShipmentDelivered        = "shipment.delivered"
+	TypeDataSubjectRequested     = "privacy.data_subject_requested"
+	TypeDataSubjectCompleted     = "privacy.data_subject_completed"
+)
+
+// 版本 2 的金額欄位改為 money.Money，版本 1 以預設幣別的浮點數表示
+func init() {
+	Default.Register(CartCheckedOut{}, TopicCartEvents, 2)
+	Default.RegisterAlias("CHECKOUT", TypeCartCheckedOut)
+	Default.RegisterUpcaster(TypeCartCheckedOut, 0, upcastLegacyCheckout)
+	Default.RegisterUpcaster(TypeCartCheckedOut, 1, upcastCheckoutAmounts)
+
+	Default.Register(OrderCreated{}, TopicOrderEvents, 2)
+	Default.RegisterUpcaster(TypeOrderCreated, 1, upcastOrderCreatedAmounts)
+	Default.Register(OrderStatusChanged{}, TopicOrderEvents, 2)
+	Default.RegisterUpcaster(TypeOrderStatusChanged, 1, upcastOrderStatusAmounts)
+	Default.Register(PaymentReversalRequested{}, TopicOrderEvents, 1)
+
+	Default.Register(PaymentSucceeded{}, TopicPaymentEvents, 2)
+	Default.RegisterUpcaster(TypePaymentSucceeded, 1, upcastPaymentAmount)
+	Default.Register(PaymentFailed{}, TopicPaymentEvents, 2)
+	Default.RegisterUpcaster(TypePaymentFailed, 1, upcastPaymentAmount)
+
+	Default.Register(ShipmentCreated{}, TopicFulfillmentEvents, 1)
+	Default.Register(ShipmentDelivered{}, TopicFulfillmentEvents, 1)
+
+	Default.Register(DataSubjectRequested{}, TopicPrivacyRequests, 1)
+	Default.Register(DataSubjectCompleted{}, TopicPrivacyResults, 1)
+}
@@ -0,0 +1,142 @@
+// Package exchange provides currency exchange rates for converting prices
+// that have no list price in the requested currency.
+//
+// Rates are published as a RateTable document, which is the format of both
+// the static rates file and the HTTP rates endpoint:
+//
+//	{"base": "TWD", "updated_at": "2026-10-01T00:00:00Z", "rates": {"USD": "0.0312", "JPY": "4.65"}}
+//
+// Each rate is the number of major units of the currency that one major
+// unit of the base currency buys. Rates are decimal strings so that they
+// are used exactly as published.
+package exchange
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// ErrRateNotFound is returned when no rate is known between two currencies
+var ErrRateNotFound = errors.New("exchange: rate not found")
+
+// ExchangeRateProvider provides exchange rates between currencies
+type ExchangeRateProvider interface {
+	// Rate returns the number of major units of to that one major unit of from buys
+	Rate(ctx context.Context, from, to string) (*big.Rat, error)
+}
+
+// RateTable is a set of rates against a base currency
+type RateTable struct {
+	Base      string            `json:"base"`
+	UpdatedAt time.Time         `json:"updated_at"`
+	Rates     map[string]string `json:"rates"`
+}
+
+// parsedTable is a validated RateTable with the base currency included at 1
+type parsedTable struct {
+	base      string
+	updatedAt time.Time
+	rates     map[string]*big.Rat
+}
+
+// parse validates the table and parses its rates
+func (t RateTable) parse() (*parsedTable, error) {
+	if !money.IsKnownCurrency(t.Base) {
+		return nil, fmt.Errorf("exchange: unknown base currency %q", t.Base)
+	}
+
+	parsed := &parsedTable{
+		base:      t.Base,
+		updatedAt: t.UpdatedAt,
+		rates:     map[string]*big.Rat{t.Base: big.NewRat(1, 1)},
+	}
+	for currency, value := range t.Rates {
+		if !money.IsKnownCurrency(currency) {
+			return nil, fmt.Errorf("exchange: unknown currency %q", currency)
+		}
+		rate, ok := new(big.Rat).SetString(value)
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("exchange: invalid rate %q for %s", value, currency)
+		}
+		parsed.rates[currency] = rate
+	}
+	return parsed, nil
+}
+
+// rate returns the cross rate between two currencies through the base currency
+func (t *parsedTable) rate(from, to string) (*big.Rat, error) {
+	fromRate, ok := t.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+	}
+	toRate, ok := t.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+	}
+	return new(big.Rat).Quo(toRate, fromRate), nil
+}
+
+// Convert converts an amount to another currency at the provider's rate,
+// rounding half up to the minor unit of the currency
+func Convert(ctx context.Context, provider ExchangeRateProvider, amount money.Money, currency string) (money.Money, error) {
+	if amount.Currency == currency {
+		return amount, nil
+	}
+	rate, err := provider.Rate(ctx, amount.Currency, currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	return amount.Convert(currency, rate, money.HalfUp)
+}
+
+// None is a provider without rates, for deployments that only sell in
+// currencies with list prices
+type None struct{}
+
+// Rate implements ExchangeRateProvider
+func (None) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+	return nil, fmt.Errorf("%w: %s to %s", ErrRateNotFound, from, to)
+}
+
+// Config selects and configures an ExchangeRateProvider
+type Config struct {
+	// Driver is file, http or none
+	Driver string
+	// File is the rates file of the file driver
+	File string
+	// URL is the rates endpoint of the http driver
+	URL string
+	// TTL is how long the http driver uses fetched rates, DefaultCacheTTL if zero
+	TTL time.Duration
+	// MaxStale is how long the http driver keeps using rates it could not
+	// refresh, DefaultMaxStale if zero
+	MaxStale time.Duration
+}
+
+// New creates the ExchangeRateProvider selected by the config
+func New(cfg Config) (ExchangeRateProvider, error) {
+	switch cfg.Driver {
+	case "", "none":
+		return None{}, nil
+	case "file":
+		return LoadStaticFile(cfg.File)
+	case "http":
+		provider := NewHTTPProvider(cfg.URL)
+		if cfg.TTL > 0 {
+			provider.ttl = cfg.TTL
+		}
+		if cfg.MaxStale > 0 {
+			provider.maxStale = cfg.MaxStale
+		}
+		return provider, nil
+	}
+	return nil, fmt.Errorf("exchange: unknown driver %q", cfg.Driver)
+}
@@ -0,0 +1,129 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCacheTTL is how long HTTPProvider uses fetched rates before refetching
+	DefaultCacheTTL = 15 * time.Minute
+	// DefaultMinRetryInterval is how long HTTPProvider waits after a fetch
+	// before trying again while the endpoint is failing
+	DefaultMinRetryInterval = 30 * time.Second
+	// DefaultMaxStale is how long HTTPProvider keeps using rates it could not refresh
+	DefaultMaxStale = 24 * time.Hour
+)
+
+// maxTableSize limits the size of a fetched rates document
+const maxTableSize = 1 << 20
+
+// HTTPProvider fetches a RateTable document from a URL and caches it
+//
+// Any server that returns the rates file format works, including a static
+// file server in front of the same file the file driver reads. If a refetch
+// fails the cached rates keep being used, so a brief outage of the rates
+// endpoint does not stop checkout in other currencies. During an outage the
+// endpoint is retried at most once per minimum retry interval, and rates
+// older than the maximum staleness are no longer used.
+type HTTPProvider struct {
+	url              string
+	client           *http.Client
+	ttl              time.Duration
+	minRetryInterval time.Duration
+	maxStale         time.Duration
+
+	mu          sync.Mutex
+	table       *parsedTable
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+}
+
+// NewHTTPProvider creates a new HTTPProvider for the rates URL
+func NewHTTPProvider(url string) *HTTPProvider {
+	return &HTTPProvider{
+		url:              url,
+		client:           &http.Client{Timeout: 5 * time.Second},
+		ttl:              DefaultCacheTTL,
+		minRetryInterval: DefaultMinRetryInterval,
+		maxStale:         DefaultMaxStale,
+	}
+}
+
+// Rate implements ExchangeRateProvider
+func (p *HTTPProvider) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	table, err := p.rates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return table.rate(from, to)
+}
+
+// rates returns the cached table, refetching it when it has expired
+func (p *HTTPProvider) rates(ctx context.Context) (*parsedTable, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if p.table != nil && now.Sub(p.fetchedAt) < p.ttl {
+		return p.table, nil
+	}
+
+	// The cache expired: refetch unless the last attempt was too recent, so
+	// callers do not each wait for the client timeout while the endpoint is down
+	if now.Sub(p.lastAttempt) >= p.minRetryInterval {
+		p.lastAttempt = now
+		table, err := p.fetch(ctx)
+		if err == nil {
+			p.table = table
+			p.fetchedAt = now
+			p.lastErr = nil
+			return table, nil
+		}
+		p.lastErr = err
+	}
+
+	if p.table == nil {
+		return nil, p.lastErr
+	}
+	if now.Sub(p.fetchedAt) > p.maxStale {
+		return nil, fmt.Errorf("%w: rates fetched at %s are older than %s", ErrRateNotFound, p.fetchedAt.Format(time.RFC3339), p.maxStale)
+	}
+	return p.table, nil
+}
+
+// fetch downloads and parses the rates document
+func (p *HTTPProvider) fetch(ctx context.Context) (*parsedTable, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("exchange: fetch %s: %w", p.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("exchange: fetch %s: unexpected status %d", p.url, resp.StatusCode)
+	}
+
+	var table RateTable
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxTableSize)).Decode(&table); err != nil {
+		return nil, fmt.Errorf("exchange: decode %s: %w", p.url, err)
+	}
+	return table.parse()
+}
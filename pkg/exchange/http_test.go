@@ -0,0 +1,121 @@
+package exchange
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// ratesServer serves a rates document and can be switched to fail
+type ratesServer struct {
+	*httptest.Server
+	failing  atomic.Bool
+	requests atomic.Int32
+}
+
+func newRatesServer(t *testing.T) *ratesServer {
+	s := &ratesServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		if s.failing.Load() {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"base": "USD", "rates": {"TWD": "32", "JPY": "150.5"}}`))
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func TestHTTPProviderOutage(t *testing.T) {
+	server := newRatesServer(t)
+	provider := NewHTTPProvider(server.URL)
+
+	// Each step moves the clock forward by rewinding the provider's timestamps
+	tests := []struct {
+		name         string
+		failing      bool
+		elapsed      time.Duration
+		wantRequests int32
+		wantErr      error
+	}{
+		{name: "first call fetches", wantRequests: 1},
+		{name: "cached within the TTL", elapsed: time.Minute, wantRequests: 1},
+		{name: "expired cache refetches", elapsed: DefaultCacheTTL, wantRequests: 2},
+		{name: "failed refetch serves stale rates", failing: true, elapsed: DefaultCacheTTL, wantRequests: 3},
+		{name: "no retry within the minimum interval", failing: true, elapsed: time.Second, wantRequests: 3},
+		{name: "retries after the minimum interval", failing: true, elapsed: DefaultMinRetryInterval, wantRequests: 4},
+		{name: "rates too old are not used", failing: true, elapsed: DefaultMaxStale, wantRequests: 5, wantErr: ErrRateNotFound},
+		{name: "recovers when the endpoint is back", elapsed: DefaultMinRetryInterval, wantRequests: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.failing.Store(tt.failing)
+			provider.mu.Lock()
+			provider.fetchedAt = provider.fetchedAt.Add(-tt.elapsed)
+			provider.lastAttempt = provider.lastAttempt.Add(-tt.elapsed)
+			provider.mu.Unlock()
+
+			rate, err := provider.Rate(context.Background(), "USD", "TWD")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && rate.Cmp(big.NewRat(32, 1)) != 0 {
+				t.Errorf("Rate() = %s, want 32", rate.RatString())
+			}
+			if got := server.requests.Load(); got != tt.wantRequests {
+				t.Errorf("%d requests, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestHTTPProviderUnavailableFromStart(t *testing.T) {
+	server := newRatesServer(t)
+	server.failing.Store(true)
+	provider := NewHTTPProvider(server.URL)
+
+	for i := 0; i < 3; i++ {
+		if _, err := provider.Rate(context.Background(), "USD", "TWD"); err == nil {
+			t.Fatal("Rate() succeeded without rates")
+		}
+	}
+	if got := server.requests.Load(); got != 1 {
+		t.Errorf("%d requests, want 1 within the minimum retry interval", got)
+	}
+}
+
+func TestHTTPProviderRates(t *testing.T) {
+	provider := NewHTTPProvider(newRatesServer(t).URL)
+
+	tests := []struct {
+		from, to string
+		want     *big.Rat
+		wantErr  error
+	}{
+		{from: "USD", to: "TWD", want: big.NewRat(32, 1)},
+		{from: "TWD", to: "USD", want: big.NewRat(1, 32)},
+		{from: "TWD", to: "JPY", want: big.NewRat(1505, 320)},
+		{from: "EUR", to: "EUR", want: big.NewRat(1, 1)},
+		{from: "USD", to: "EUR", wantErr: ErrRateNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			rate, err := provider.Rate(context.Background(), tt.from, tt.to)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Rate() error = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && rate.Cmp(tt.want) != 0 {
+				t.Errorf("Rate() = %s, want %s", rate.RatString(), tt.want.RatString())
+			}
+		})
+	}
+}
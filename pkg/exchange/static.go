@@ -0,0 +1,44 @@
+package exchange
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// StaticProvider serves rates from a fixed RateTable
+type StaticProvider struct {
+	table *parsedTable
+}
+
+// NewStaticProvider creates a StaticProvider for the table
+func NewStaticProvider(table RateTable) (*StaticProvider, error) {
+	parsed, err := table.parse()
+	if err != nil {
+		return nil, err
+	}
+	return &StaticProvider{table: parsed}, nil
+}
+
+// LoadStaticFile creates a StaticProvider from a JSON rates file
+//
+// The file is read once; restart the service to pick up new rates.
+func LoadStaticFile(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("exchange: read %s: %w", path, err)
+	}
+
+	var table RateTable
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("exchange: decode %s: %w", path, err)
+	}
+	return NewStaticProvider(table)
+}
+
+// Rate implements ExchangeRateProvider
+func (p *StaticProvider) Rate(ctx context.Context, from, to string) (*big.Rat, error) {
+	return p.table.rate(from, to)
+}
@@ -0,0 +1,114 @@
+// Package jwks manages asymmetric JWT signing keys and publishes their public
+// halves as a JSON Web Key Set, so services can verify tokens without being
+// able to mint them.
+package jwks
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Supported signing algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// ErrKeyNotFound is returned when no key matches a kid
+var ErrKeyNotFound = errors.New("jwks: key not found")
+
+// JWK is a public JSON Web Key (RFC 7517) for RSA or Ed25519 keys
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+
+	// RSA public key
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519 public key
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// KeySet is a JSON Web Key Set as served from /.well-known/jwks.json
+type KeySet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// NewJWK encodes a public key as a JWK
+func NewJWK(kid string, pub crypto.PublicKey) (JWK, error) {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			KeyType:   "RSA",
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: AlgRS256,
+			N:         base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}, nil
+	case ed25519.PublicKey:
+		return JWK{
+			KeyType:   "OKP",
+			KeyID:     kid,
+			Use:       "sig",
+			Algorithm: AlgEdDSA,
+			Curve:     "Ed25519",
+			X:         base64.RawURLEncoding.EncodeToString(key),
+		}, nil
+	}
+	return JWK{}, fmt.Errorf("jwks: unsupported key type %T", pub)
+}
+
+// PublicKey decodes the public key of a JWK
+func (k JWK) PublicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: invalid modulus of key %s: %w", k.KeyID, err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: invalid exponent of key %s: %w", k.KeyID, err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("jwks: invalid exponent of key %s", k.KeyID)
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	case "OKP":
+		if k.Curve != "Ed25519" {
+			return nil, fmt.Errorf("jwks: unsupported curve %q of key %s", k.Curve, k.KeyID)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("jwks: invalid public key %s", k.KeyID)
+		}
+		return ed25519.PublicKey(x), nil
+	}
+	return nil, fmt.Errorf("jwks: unsupported key type %q of key %s", k.KeyType, k.KeyID)
+}
+
+// PublicKeys decodes every signing key of the set by kid, skipping keys it
+// cannot use instead of failing the whole set
+func (s KeySet) PublicKeys() map[string]crypto.PublicKey {
+	keys := make(map[string]crypto.PublicKey, len(s.Keys))
+	for _, k := range s.Keys {
+		if k.KeyID == "" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		if pub, err := k.PublicKey(); err == nil {
+			keys[k.KeyID] = pub
+		}
+	}
+	return keys
+}
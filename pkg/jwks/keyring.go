@@ -0,0 +1,250 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// rsaKeyBits is the size of generated RSA keys
+const rsaKeyBits = 2048
+
+// DefaultPrePublish is long enough for verifiers to pick up a new key through
+// their cache expiry before it signs anything
+const DefaultPrePublish = time.Hour
+
+// ErrNoSigningKey is returned when no key is valid for signing yet
+var ErrNoSigningKey = errors.New("jwks: no active signing key")
+
+// SigningKey is a private key with its validity windows
+//
+// A key signs tokens from NotBefore until SignUntil and is published for
+// verification until VerifyUntil, which leaves room for the longest-lived
+// token it signed to expire.
+type SigningKey struct {
+	ID          string
+	Algorithm   string
+	PrivateKey  crypto.Signer
+	NotBefore   time.Time
+	SignUntil   time.Time
+	VerifyUntil time.Time
+}
+
+// GenerateKey generates a new signing key for the algorithm
+func GenerateKey(alg string, notBefore, signUntil, verifyUntil time.Time) (*SigningKey, error) {
+	var private crypto.Signer
+	switch alg {
+	case AlgRS256:
+		key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	case AlgEdDSA:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("jwks: unsupported algorithm %q", alg)
+	}
+
+	return &SigningKey{
+		ID:          uuid.NewString(),
+		Algorithm:   alg,
+		PrivateKey:  private,
+		NotBefore:   notBefore,
+		SignUntil:   signUntil,
+		VerifyUntil: verifyUntil,
+	}, nil
+}
+
+// canSign checks if the key may sign tokens at the given time
+func (k *SigningKey) canSign(now time.Time) bool {
+	return !now.Before(k.NotBefore) && now.Before(k.SignUntil)
+}
+
+// KeyRingConfig holds the rotation settings of a KeyRing
+type KeyRingConfig struct {
+	// Algorithm of new keys, AlgRS256 or AlgEdDSA
+	Algorithm string
+	// RotationInterval is how long each key signs tokens
+	RotationInterval time.Duration
+	// PrePublish is how long a new key is published before it signs, so
+	// verifiers with a cached key set already know it
+	PrePublish time.Duration
+	// TokenTTL is the longest lifetime of a token signed by the ring
+	TokenTTL time.Duration
+}
+
+// KeyRing holds the signing keys of the auth service and rotates them
+//
+// Keys are persisted in a KeyStore shared by every auth service replica.
+// The newest key that is valid for signing signs new tokens, and every key
+// whose tokens may still be alive stays in the published key set.
+type KeyRing struct {
+	store  KeyStore
+	config KeyRingConfig
+	logger *zap.Logger
+
+	mu   sync.RWMutex
+	keys []*SigningKey
+}
+
+// NewKeyRing creates a new KeyRing
+func NewKeyRing(store KeyStore, config KeyRingConfig, logger *zap.Logger) (*KeyRing, error) {
+	if config.Algorithm != AlgRS256 && config.Algorithm != AlgEdDSA {
+		return nil, fmt.Errorf("jwks: unsupported algorithm %q", config.Algorithm)
+	}
+	if config.RotationInterval <= 0 {
+		return nil, errors.New("jwks: rotation interval must be positive")
+	}
+	if config.PrePublish > config.RotationInterval {
+		config.PrePublish = config.RotationInterval
+	}
+
+	return &KeyRing{
+		store:  store,
+		config: config,
+		logger: logger,
+	}, nil
+}
+
+// Rotate reloads the keys from the store and generates the next key when the
+// newest one stops signing within the pre-publish period
+func (r *KeyRing) Rotate(ctx context.Context) error {
+	keys, err := r.store.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var newest *SigningKey
+	for _, key := range keys {
+		if newest == nil || key.NotBefore.After(newest.NotBefore) {
+			newest = key
+		}
+	}
+
+	if newest == nil || !newest.SignUntil.After(now.Add(r.config.PrePublish)) {
+		// The next key takes over when the newest one stops signing, or
+		// right away if there is no usable key at all
+		notBefore := now
+		if newest != nil && newest.SignUntil.After(now) {
+			notBefore = newest.SignUntil
+		}
+		signUntil := notBefore.Add(r.config.RotationInterval)
+
+		key, err := GenerateKey(r.config.Algorithm, notBefore, signUntil, signUntil.Add(r.config.TokenTTL))
+		if err != nil {
+			return err
+		}
+		if err := r.store.Save(ctx, key); err != nil {
+			return err
+		}
+		keys = append(keys, key)
+
+		r.logger.Info("已產生新的簽名金鑰",
+			zap.String("kid", key.ID),
+			zap.String("algorithm", key.Algorithm),
+			zap.Time("not_before", key.NotBefore))
+	}
+
+	r.mu.Lock()
+	r.keys = keys
+	r.mu.Unlock()
+	return nil
+}
+
+// Run rotates the keys on the interval until the context is cancelled
+func (r *KeyRing) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Rotate(ctx); err != nil {
+				r.logger.Error("簽名金鑰輪替失敗", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Sign signs the claims with the current key and sets its kid header
+func (r *KeyRing) Sign(claims jwt.MapClaims) (string, error) {
+	key := r.current(time.Now())
+	if key == nil {
+		return "", ErrNoSigningKey
+	}
+
+	var method jwt.SigningMethod = jwt.SigningMethodRS256
+	if key.Algorithm == AlgEdDSA {
+		method = jwt.SigningMethodEdDSA
+	}
+
+	token := jwt.NewWithClaims(method, claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.PrivateKey)
+}
+
+// PublicKey returns the public key of a published key
+func (r *KeyRing) PublicKey(_ context.Context, kid string) (crypto.PublicKey, error) {
+	now := time.Now()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if key.ID == kid && now.Before(key.VerifyUntil) {
+			return key.PrivateKey.Public(), nil
+		}
+	}
+	return nil, ErrKeyNotFound
+}
+
+// KeySet returns the published keys, including keys that do not sign yet
+func (r *KeyRing) KeySet() KeySet {
+	now := time.Now()
+	set := KeySet{Keys: []JWK{}}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, key := range r.keys {
+		if !now.Before(key.VerifyUntil) {
+			continue
+		}
+		jwk, err := NewJWK(key.ID, key.PrivateKey.Public())
+		if err != nil {
+			continue
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// current returns the newest key that may sign at the given time
+func (r *KeyRing) current(now time.Time) *SigningKey {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var current *SigningKey
+	for _, key := range r.keys {
+		if key.canSign(now) && (current == nil || key.NotBefore.After(current.NotBefore)) {
+			current = key
+		}
+	}
+	return current
+}
@@ -0,0 +1,109 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Defaults of RemoteKeySet
+const (
+	// DefaultCacheTTL is how long a fetched key set is used before refetching
+	DefaultCacheTTL = 10 * time.Minute
+	// DefaultMinRefreshInterval limits refetches triggered by unknown kids
+	DefaultMinRefreshInterval = 30 * time.Second
+)
+
+// maxKeySetSize limits the size of a fetched key set document
+const maxKeySetSize = 1 << 20
+
+// RemoteKeySet fetches a JWKS document from a URL and caches its keys
+//
+// The set is refetched when the cache expires or when a token refers to an
+// unknown kid, which happens right after a key rotation. Unknown kids refetch
+// at most once per minimum refresh interval, so forged kids cannot be used to
+// flood the auth service. If a refetch fails the cached keys keep working.
+type RemoteKeySet struct {
+	url                string
+	client             *http.Client
+	ttl                time.Duration
+	minRefreshInterval time.Duration
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewRemoteKeySet creates a new RemoteKeySet for the JWKS URL
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:                url,
+		client:             &http.Client{Timeout: 5 * time.Second},
+		ttl:                DefaultCacheTTL,
+		minRefreshInterval: DefaultMinRefreshInterval,
+	}
+}
+
+// PublicKey returns the public key for a kid, fetching the set if needed
+func (s *RemoteKeySet) PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	key, ok := s.keys[kid]
+	if ok && now.Sub(s.fetchedAt) < s.ttl {
+		return key, nil
+	}
+
+	// The cache expired or the kid is unknown: refetch unless the last
+	// attempt was too recent. Concurrent callers wait for the same fetch.
+	if now.Sub(s.lastAttempt) >= s.minRefreshInterval {
+		s.lastAttempt = now
+		keys, err := s.fetch(ctx)
+		if err != nil {
+			if ok {
+				return key, nil
+			}
+			return nil, err
+		}
+		s.keys = keys
+		s.fetchedAt = now
+		key, ok = keys[kid]
+	}
+
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+	return key, nil
+}
+
+// fetch downloads and decodes the key set
+func (s *RemoteKeySet) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("jwks: fetch %s: %w", s.url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jwks: fetch %s: unexpected status %d", s.url, resp.StatusCode)
+	}
+
+	var set KeySet
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxKeySetSize)).Decode(&set); err != nil {
+		return nil, fmt.Errorf("jwks: decode %s: %w", s.url, err)
+	}
+	return set.PublicKeys(), nil
+}
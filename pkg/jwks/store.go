@@ -0,0 +1,212 @@
+package jwks
+
+import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// KeyStore persists signing keys
+//
+// List only returns keys that are still published, i.e. whose VerifyUntil
+// has not passed.
+type KeyStore interface {
+	List(ctx context.Context) ([]*SigningKey, error)
+	Save(ctx context.Context, key *SigningKey) error
+}
+
+// encryptionA256GCM marks private keys encrypted with AES-256-GCM; the
+// nonce is prepended to the ciphertext and the key ID is the additional data
+const encryptionA256GCM = "A256GCM"
+
+// KeyEncryptionKeySize is the size of the key that encrypts stored private keys
+const KeyEncryptionKeySize = 32
+
+// ErrInvalidKeyEncryptionKey is returned for a key encryption key that is not 32 bytes
+var ErrInvalidKeyEncryptionKey = errors.New("jwks: key encryption key must be 32 bytes")
+
+// MongoKeyStore implements KeyStore using MongoDB
+//
+// Private keys are stored as PKCS #8 encrypted with AES-256-GCM under a key
+// encryption key that only the auth service holds, so other services sharing
+// the database cannot sign tokens. Keys saved before encryption was added are
+// still read until they expire. Expired keys are removed by a TTL index on
+// verify_until.
+type MongoKeyStore struct {
+	collection *mongo.Collection
+	aead       cipher.AEAD
+}
+
+// NewMongoKeyStore creates a new MongoKeyStore encrypting private keys with kek
+func NewMongoKeyStore(db *mongo.Database, kek []byte) (*MongoKeyStore, error) {
+	aead, err := newKeyAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MongoKeyStore{
+		collection: db.Collection("signing_keys"),
+		aead:       aead,
+	}, nil
+}
+
+// keyDocument is the stored form of a SigningKey
+type keyDocument struct {
+	ID          string    `bson:"_id"`
+	Algorithm   string    `bson:"algorithm"`
+	PrivateKey  []byte    `bson:"private_key"`
+	Encryption  string    `bson:"encryption,omitempty"`
+	NotBefore   time.Time `bson:"not_before"`
+	SignUntil   time.Time `bson:"sign_until"`
+	VerifyUntil time.Time `bson:"verify_until"`
+}
+
+// List returns the published keys
+func (s *MongoKeyStore) List(ctx context.Context) ([]*SigningKey, error) {
+	cursor, err := s.collection.Find(ctx, bson.M{"verify_until": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var docs []keyDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	keys := make([]*SigningKey, 0, len(docs))
+	for _, doc := range docs {
+		der, err := s.decrypt(doc)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("jwks: invalid private key %s: %w", doc.ID, err)
+		}
+		private, ok := parsed.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("jwks: unsupported private key %s", doc.ID)
+		}
+
+		keys = append(keys, &SigningKey{
+			ID:          doc.ID,
+			Algorithm:   doc.Algorithm,
+			PrivateKey:  private,
+			NotBefore:   doc.NotBefore,
+			SignUntil:   doc.SignUntil,
+			VerifyUntil: doc.VerifyUntil,
+		})
+	}
+	return keys, nil
+}
+
+// Save stores a new key
+func (s *MongoKeyStore) Save(ctx context.Context, key *SigningKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key.PrivateKey)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := s.encrypt(key.ID, der)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.collection.InsertOne(ctx, keyDocument{
+		ID:          key.ID,
+		Algorithm:   key.Algorithm,
+		PrivateKey:  encrypted,
+		Encryption:  encryptionA256GCM,
+		NotBefore:   key.NotBefore,
+		SignUntil:   key.SignUntil,
+		VerifyUntil: key.VerifyUntil,
+	})
+	return err
+}
+
+// newKeyAEAD creates the AES-256-GCM cipher of a key encryption key
+func newKeyAEAD(kek []byte) (cipher.AEAD, error) {
+	if len(kek) != KeyEncryptionKeySize {
+		return nil, ErrInvalidKeyEncryptionKey
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// encrypt seals a PKCS #8 private key with a random nonce, bound to the key ID
+func (s *MongoKeyStore) encrypt(id string, der []byte) ([]byte, error) {
+	nonce := make([]byte, s.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return s.aead.Seal(nonce, nonce, der, []byte(id)), nil
+}
+
+// decrypt returns the PKCS #8 private key of a stored key
+func (s *MongoKeyStore) decrypt(doc keyDocument) ([]byte, error) {
+	switch doc.Encryption {
+	case "":
+		return doc.PrivateKey, nil
+	case encryptionA256GCM:
+		size := s.aead.NonceSize()
+		if len(doc.PrivateKey) < size {
+			return nil, fmt.Errorf("jwks: invalid private key %s: ciphertext too short", doc.ID)
+		}
+		der, err := s.aead.Open(nil, doc.PrivateKey[:size], doc.PrivateKey[size:], []byte(doc.ID))
+		if err != nil {
+			return nil, fmt.Errorf("jwks: cannot decrypt private key %s: %w", doc.ID, err)
+		}
+		return der, nil
+	}
+	return nil, fmt.Errorf("jwks: unsupported encryption %q of private key %s", doc.Encryption, doc.ID)
+}
+
+// MemoryKeyStore implements KeyStore in memory for a single auth service instance
+type MemoryKeyStore struct {
+	mu   sync.Mutex
+	keys []*SigningKey
+}
+
+// NewMemoryKeyStore creates a new MemoryKeyStore
+func NewMemoryKeyStore() *MemoryKeyStore {
+	return &MemoryKeyStore{}
+}
+
+// List returns the published keys
+func (s *MemoryKeyStore) List(_ context.Context) ([]*SigningKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	keys := make([]*SigningKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if now.Before(key.VerifyUntil) {
+			keys = append(keys, key)
+		}
+	}
+	s.keys = keys
+	return append([]*SigningKey(nil), keys...), nil
+}
+
+// Save stores a new key
+func (s *MemoryKeyStore) Save(_ context.Context, key *SigningKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.keys = append(s.keys, key)
+	return nil
+}
@@ -0,0 +1,96 @@
+package jwks
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestMongoKeyStoreEncryption(t *testing.T) {
+	kek := bytes.Repeat([]byte{7}, KeyEncryptionKeySize)
+	aead, err := newKeyAEAD(kek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &MongoKeyStore{aead: aead}
+
+	der := []byte("pkcs8 private key")
+	sealed, err := store.encrypt("key-1", der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, der) {
+		t.Fatal("encrypted key contains the plaintext")
+	}
+
+	otherAEAD, err := newKeyAEAD(bytes.Repeat([]byte{8}, KeyEncryptionKeySize))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		store   *MongoKeyStore
+		doc     keyDocument
+		want    []byte
+		wantErr bool
+	}{
+		{
+			name:  "encrypted key",
+			store: store,
+			doc:   keyDocument{ID: "key-1", PrivateKey: sealed, Encryption: encryptionA256GCM},
+			want:  der,
+		},
+		{
+			name:  "legacy unencrypted key",
+			store: store,
+			doc:   keyDocument{ID: "key-0", PrivateKey: der},
+			want:  der,
+		},
+		{
+			// the key ID is the additional data, so a ciphertext cannot be copied to another document
+			name:    "ciphertext moved to another key ID",
+			store:   store,
+			doc:     keyDocument{ID: "key-2", PrivateKey: sealed, Encryption: encryptionA256GCM},
+			wantErr: true,
+		},
+		{
+			name:    "wrong key encryption key",
+			store:   &MongoKeyStore{aead: otherAEAD},
+			doc:     keyDocument{ID: "key-1", PrivateKey: sealed, Encryption: encryptionA256GCM},
+			wantErr: true,
+		},
+		{
+			name:    "truncated ciphertext",
+			store:   store,
+			doc:     keyDocument{ID: "key-1", PrivateKey: sealed[:4], Encryption: encryptionA256GCM},
+			wantErr: true,
+		},
+		{
+			name:    "unknown encryption",
+			store:   store,
+			doc:     keyDocument{ID: "key-1", PrivateKey: sealed, Encryption: "ROT13"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.store.decrypt(tt.doc)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("decrypt() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("decrypt() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewKeyAEADKeySize(t *testing.T) {
+	for _, size := range []int{0, 16, 31, 33} {
+		if _, err := newKeyAEAD(make([]byte, size)); !errors.Is(err, ErrInvalidKeyEncryptionKey) {
+			t.Errorf("newKeyAEAD(%d bytes) error = %v, want ErrInvalidKeyEncryptionKey", size, err)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+// Package mailer sends transactional emails such as verification and
+// password reset links.
+package mailer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Message is a plain text email
+type Message struct {
+	To      string    `json:"to"`
+	Subject string    `json:"subject"`
+	Body    string    `json:"body"`
+	SentAt  time.Time `json:"sent_at"`
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// Config selects and configures a Mailer
+type Config struct {
+	// Driver is smtp, file or memory
+	Driver string
+	From   string
+
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+
+	// Dir is where the file mailer writes messages
+	Dir string
+}
+
+// New creates the Mailer selected by the config
+func New(cfg Config) (Mailer, error) {
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.From), nil
+	case "", "file":
+		return NewFileMailer(cfg.Dir)
+	case "memory":
+		return NewMemoryMailer(), nil
+	}
+	return nil, fmt.Errorf("mailer: unknown driver %q", cfg.Driver)
+}
@@ -0,0 +1,36 @@
+package mailer
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		want    string
+		wantErr bool
+	}{
+		{name: "default is file", cfg: Config{Dir: t.TempDir()}, want: "*mailer.FileMailer"},
+		{name: "file", cfg: Config{Driver: "file", Dir: t.TempDir()}, want: "*mailer.FileMailer"},
+		{name: "memory", cfg: Config{Driver: "memory"}, want: "*mailer.MemoryMailer"},
+		{name: "smtp", cfg: Config{Driver: "smtp", SMTPHost: "localhost", SMTPPort: 587}, want: "*mailer.SMTPMailer"},
+		{name: "unknown driver", cfg: Config{Driver: "carrier-pigeon"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("New() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got := fmt.Sprintf("%T", m); got != tt.want {
+				t.Errorf("New() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// FileMailer writes every message as a JSON file to a directory instead of
+// sending it, for local development
+type FileMailer struct {
+	dir string
+	seq atomic.Uint64
+}
+
+// NewFileMailer creates a new FileMailer, creating the directory if needed
+func NewFileMailer(dir string) (*FileMailer, error) {
+	if dir == "" {
+		dir = "mail-outbox"
+	}
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+	return &FileMailer{dir: dir}, nil
+}
+
+// Send writes the message to the directory
+func (m *FileMailer) Send(_ context.Context, msg Message) error {
+	msg.SentAt = time.Now()
+	data, err := json.MarshalIndent(msg, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%s-%06d.json", msg.SentAt.Format("20060102T150405"), m.seq.Add(1))
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o640)
+}
+
+// MemoryMailer keeps sent messages in memory for tests
+type MemoryMailer struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewMemoryMailer creates a new MemoryMailer
+func NewMemoryMailer() *MemoryMailer {
+	return &MemoryMailer{}
+}
+
+// Send records the message
+func (m *MemoryMailer) Send(_ context.Context, msg Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	msg.SentAt = time.Now()
+	m.messages = append(m.messages, msg)
+	return nil
+}
+
+// Messages returns the messages sent so far
+func (m *MemoryMailer) Messages() []Message {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return append([]Message(nil), m.messages...)
+}
+
+// Last returns the last message sent to the address
+func (m *MemoryMailer) Last(to string) (Message, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].To == to {
+			return m.messages[i], true
+		}
+	}
+	return Message{}, false
+}
@@ -0,0 +1,97 @@
+package mailer
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "outbox")
+	m, err := NewFileMailer(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sent := []Message{
+		{To: "alice@example.com", Subject: "Verify your email", Body: "https://example.com/verify?token=a"},
+		{To: "bob@example.com", Subject: "Reset your password", Body: "https://example.com/reset?token=b"},
+	}
+	for _, msg := range sent {
+		if err := m.Send(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// File names sort by send time and sequence, so messages sent within the same second do not overwrite each other
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(sent) {
+		t.Fatalf("got %d files, want %d", len(entries), len(sent))
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for i, name := range names {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got Message
+		if err := json.Unmarshal(data, &got); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if got.To != sent[i].To || got.Subject != sent[i].Subject || got.Body != sent[i].Body {
+			t.Errorf("%s = %+v, want %+v", name, got, sent[i])
+		}
+		if got.SentAt.IsZero() {
+			t.Errorf("%s has no sent_at", name)
+		}
+	}
+}
+
+func TestMemoryMailer(t *testing.T) {
+	m := NewMemoryMailer()
+	for _, msg := range []Message{
+		{To: "alice@example.com", Subject: "first"},
+		{To: "bob@example.com", Subject: "second"},
+		{To: "alice@example.com", Subject: "third"},
+	} {
+		if err := m.Send(context.Background(), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if got := len(m.Messages()); got != 3 {
+		t.Fatalf("got %d messages, want 3", got)
+	}
+
+	tests := []struct {
+		to          string
+		wantSubject string
+		wantFound   bool
+	}{
+		{to: "alice@example.com", wantSubject: "third", wantFound: true},
+		{to: "bob@example.com", wantSubject: "second", wantFound: true},
+		{to: "carol@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.to, func(t *testing.T) {
+			msg, ok := m.Last(tt.to)
+			if ok != tt.wantFound || msg.Subject != tt.wantSubject {
+				t.Errorf("Last(%s) = %q, %v, want %q, %v", tt.to, msg.Subject, ok, tt.wantSubject, tt.wantFound)
+			}
+			if ok && msg.SentAt.IsZero() {
+				t.Errorf("Last(%s) has no SentAt", tt.to)
+			}
+		})
+	}
+}
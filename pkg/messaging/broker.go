@@ -0,0 +1,37 @@
+package messaging
+
+import (
+	"context"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// MemoryBrokerURL 以此值設定 KAFKA_BROKERS 時使用行程內的記憶體代理
+const MemoryBrokerURL = "memory://"
+
+// Broker 消息代理介面，KafkaClient 與 MemoryBroker 皆實作此介面
+type Broker interface {
+	// PublishMessage 發布消息到指定的主題，相同 key 的消息保證順序
+	PublishMessage(ctx context.Context, topic string, key string, message []byte) error
+	// ConsumeMessages 以消費者群組身分在背景消費主題，同群組的成員分攤分區
+	ConsumeMessages(ctx context.Context, topic, groupID string, handler func([]byte) error, opts ...SubscribeOption) error
+	// Close 停止所有消費並釋放資源
+	Close() error
+}
+
+var (
+	_ Broker = (*KafkaClient)(nil)
+	_ Broker = (*MemoryBroker)(nil)
+)
+
+// NewBroker 依 broker 位址建立消息代理
+//
+// 位址為 memory:// 時回傳記憶體代理，供本機執行與測試使用；
+// 記憶體代理只存在於目前行程內，不同服務之間無法互通。
+func NewBroker(brokers []string, logger *zap.Logger) (Broker, error) {
+	if len(brokers) == 1 && strings.HasPrefix(brokers[0], MemoryBrokerURL) {
+		return NewMemoryBroker(logger), nil
+	}
+	return NewKafkaClient(brokers, logger)
+}
@@ -0,0 +1,145 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"go.uber.org/zap"
+)
+
+// 死信訊息的標頭
+const (
+	HeaderOriginalTopic     = "x-original-topic"
+	HeaderOriginalPartition = "x-original-partition"
+	HeaderOriginalOffset    = "x-original-offset"
+	HeaderError             = "x-error"
+	HeaderAttempts          = "x-attempts"
+	HeaderFailedAt          = "x-failed-at"
+)
+
+// deadLetterSuffix 死信主題的後綴
+const deadLetterSuffix = ".dlq"
+
+// DeadLetterTopic 回傳主題對應的死信主題名稱
+func DeadLetterTopic(topic string) string {
+	return topic + deadLetterSuffix
+}
+
+// sendToDeadLetter 將處理失敗的訊息轉送到死信主題，並附上原始位置與錯誤資訊
+func (c *KafkaClient) sendToDeadLetter(ctx context.Context, dlqTopic string, message kafka.Message, cause error, attempts int) error {
+	headers := append([]kafka.Header{}, message.Headers...)
+	headers = append(headers,
+		kafka.Header{Key: HeaderOriginalTopic, Value: []byte(message.Topic)},
+		kafka.Header{Key: HeaderOriginalPartition, Value: []byte(strconv.Itoa(message.Partition))},
+		kafka.Header{Key: HeaderOriginalOffset, Value: []byte(strconv.FormatInt(message.Offset, 10))},
+		kafka.Header{Key: HeaderError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(attempts))},
+		kafka.Header{Key: HeaderFailedAt, Value: []byte(time.Now().UTC().Format(time.RFC3339))},
+	)
+
+	err := c.Writer.WriteMessages(ctx, kafka.Message{
+		Topic:   dlqTopic,
+		Key:     message.Key,
+		Value:   message.Value,
+		Headers: headers,
+		Time:    time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("轉送死信訊息失敗: %w", err)
+	}
+
+	c.Logger.Warn("訊息已轉送到死信主題",
+		zap.String("topic", message.Topic),
+		zap.String("dlq_topic", dlqTopic),
+		zap.Int("partition", message.Partition),
+		zap.Int64("offset", message.Offset),
+		zap.Int("attempts", attempts),
+		zap.Error(cause),
+	)
+
+	return nil
+}
+
+// ReplayDeadLetters 將死信主題中的訊息重新發布回原始主題
+//
+// 以 groupID 消費死信主題，每則訊息依 x-original-topic 標頭決定目標主題，
+// 重新發布成功後才提交位移。連續 idleTimeout 沒有新訊息或已重播 limit 則
+// (limit <= 0 表示不限) 時結束，回傳重播的訊息數量。
+func (c *KafkaClient) ReplayDeadLetters(ctx context.Context, dlqTopic, groupID string, limit int, idleTimeout time.Duration) (int, error) {
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     c.BrokerURLs,
+		Topic:       dlqTopic,
+		GroupID:     groupID,
+		MinBytes:    1,
+		MaxBytes:    10e6, // 10MB
+		StartOffset: kafka.FirstOffset,
+	})
+	defer reader.Close()
+
+	replayed := 0
+	for limit <= 0 || replayed < limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, idleTimeout)
+		message, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				break
+			}
+			return replayed, fmt.Errorf("讀取死信訊息失敗: %w", err)
+		}
+
+		target := headerValue(message.Headers, HeaderOriginalTopic)
+		if target == "" {
+			target = strings.TrimSuffix(dlqTopic, deadLetterSuffix)
+		}
+
+		// 移除死信標頭，讓訊息以原始狀態重新進入處理流程
+		err = c.Writer.WriteMessages(ctx, kafka.Message{
+			Topic:   target,
+			Key:     message.Key,
+			Value:   message.Value,
+			Headers: stripDeadLetterHeaders(message.Headers),
+			Time:    time.Now(),
+		})
+		if err != nil {
+			return replayed, fmt.Errorf("重新發布死信訊息失敗: %w", err)
+		}
+
+		if err := reader.CommitMessages(ctx, message); err != nil {
+			return replayed, fmt.Errorf("提交死信位移失敗: %w", err)
+		}
+		replayed++
+	}
+
+	c.Logger.Info("死信訊息重播完成", zap.String("dlq_topic", dlqTopic), zap.Int("replayed", replayed))
+	return replayed, nil
+}
+
+// headerValue 取得指定標頭的值
+func headerValue(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+// stripDeadLetterHeaders 移除轉送死信時加上的標頭
+func stripDeadLetterHeaders(headers []kafka.Header) []kafka.Header {
+	kept := make([]kafka.Header, 0, len(headers))
+	for _, h := range headers {
+		switch h.Key {
+		case HeaderOriginalTopic, HeaderOriginalPartition, HeaderOriginalOffset,
+			HeaderError, HeaderAttempts, HeaderFailedAt:
+			continue
+		}
+		kept = append(kept, h)
+	}
+	return kept
+}
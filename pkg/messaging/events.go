@@ -0,0 +1,41 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+)
+
+// PublishEvent 將事件負載包裝成標準信封，發布到註冊表中對應的主題
+func PublishEvent(ctx context.Context, broker Broker, producer string, payload events.Event) error {
+	encoded, err := events.Encode(ctx, producer, payload)
+	if err != nil {
+		return err
+	}
+	return broker.PublishMessage(ctx, encoded.Topic, encoded.Key, encoded.Data)
+}
+
+// SubscribeEvents 消費主題中的標準信封事件，並交由 mux 依事件類型分派
+//
+// 無法解析的事件視為不可重試，直接轉送死信主題。
+func SubscribeEvents(ctx context.Context, broker Broker, topic, groupID string, mux *events.Mux, opts ...SubscribeOption) error {
+	handler := func(msg []byte) error {
+		err := mux.Dispatch(ctx, msg)
+		if errors.Is(err, events.ErrMalformed) {
+			return Fatal(err)
+		}
+		return err
+	}
+	return broker.ConsumeMessages(ctx, topic, groupID, handler, opts...)
+}
+
+// PublishEvent 將事件負載包裝成標準信封，發布到註冊表中對應的主題
+func (c *KafkaClient) PublishEvent(ctx context.Context, producer string, payload events.Event) error {
+	return PublishEvent(ctx, c, producer, payload)
+}
+
+// SubscribeEvents 消費主題中的標準信封事件，並交由 mux 依事件類型分派
+func (c *KafkaClient) SubscribeEvents(ctx context.Context, topic, groupID string, mux *events.Mux, opts ...SubscribeOption) error {
+	return SubscribeEvents(ctx, c, topic, groupID, mux, opts...)
+}
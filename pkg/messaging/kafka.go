@@ -94,7 +94,18 @@ func (c *KafkaClient) Publish(topic string, message []byte) error {
 }
 
 // ConsumeMessages 消費指定主題的消息
-func (c *KafkaClient) ConsumeMessages(ctx context.Context, topic, groupID string, handler func([]byte) error) error {
+//
+// 處理失敗的消息會依重試策略重試 (預設為 DefaultRetryPolicy)，不可重試的錯誤或
+// 重試次數用盡時轉送到死信主題 <topic>.dlq。消息處理完成或轉送死信後才提交位移。
+func (c *KafkaClient) ConsumeMessages(ctx context.Context, topic, groupID string, handler func([]byte) error, opts ...SubscribeOption) error {
+	options := subscribeOptions{
+		retry:           DefaultRetryPolicy(),
+		deadLetterTopic: DeadLetterTopic(topic),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	// 檢查是否已經有這個主題的讀取器
 	readerKey := fmt.Sprintf("%s-%s", topic, groupID)
 	reader, exists := c.Readers[readerKey]
@@ -123,7 +134,7 @@ func (c *KafkaClient) ConsumeMessages(ctx context.Context, topic, groupID string
 				c.Logger.Info("停止消費消息", zap.String("topic", topic), zap.String("group", groupID))
 				return
 			default:
-				message, err := reader.ReadMessage(ctx)
+				message, err := reader.FetchMessage(ctx)
 				if err != nil {
 					if ctx.Err() != context.Canceled {
 						c.Logger.Error("讀取消息失敗",
@@ -142,12 +153,17 @@ func (c *KafkaClient) ConsumeMessages(ctx context.Context, topic, groupID string
 					zap.Int("message_size", len(message.Value)),
 				)
 
-				// 處理消息
-				if err := handler(message.Value); err != nil {
-					c.Logger.Error("處理消息失敗",
+				// 處理消息，失敗時依重試策略重試或轉送死信主題
+				if err := c.handleWithRetry(ctx, message, handler, options); err != nil {
+					// 只有在 ctx 取消時才會走到這裡，未提交的消息會在重啟後重新消費
+					return
+				}
+
+				if err := reader.CommitMessages(ctx, message); err != nil {
+					c.Logger.Error("提交位移失敗",
 						zap.String("topic", topic),
 						zap.String("group", groupID),
-						zap.String("key", string(message.Key)),
+						zap.Int64("offset", message.Offset),
 						zap.Error(err),
 					)
 				}
@@ -158,6 +174,14 @@ func (c *KafkaClient) ConsumeMessages(ctx context.Context, topic, groupID string
 	return nil
 }
 
+// handleWithRetry 依重試策略處理單一消息，回傳非 nil 錯誤表示 ctx 已取消
+func (c *KafkaClient) handleWithRetry(ctx context.Context, message kafka.Message, handler func([]byte) error, options subscribeOptions) error {
+	return processWithRetry(ctx, c.Logger, message.Topic, message.Key, message.Value, handler, options.retry,
+		func(cause error, attempts int) error {
+			return c.sendToDeadLetter(ctx, options.deadLetterTopic, message, cause, attempts)
+		})
+}
+
 // Produce 發布結構化消息到指定的主題 (用於實作 KafkaProducer 介面)
 func (c *KafkaClient) Produce(topic string, message interface{}) error {
 	// 序列化消息
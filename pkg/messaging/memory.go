@@ -0,0 +1,362 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrBrokerClosed 消息代理已關閉
+var ErrBrokerClosed = errors.New("消息代理已關閉")
+
+// defaultMemoryPartitions 記憶體代理每個主題的分區數
+const defaultMemoryPartitions = 4
+
+// MemoryMessage 記憶體代理中的一則消息
+type MemoryMessage struct {
+	Topic     string
+	Partition int
+	Offset    int64
+	Key       []byte
+	Value     []byte
+	Headers   map[string]string
+	Time      time.Time
+
+	// seq 全域發布順序，用於決定跨分區的投遞順序
+	seq uint64
+}
+
+type memoryTopic struct {
+	partitions [][]MemoryMessage
+	nextRR     int
+}
+
+type memoryGroup struct {
+	topic    string
+	offsets  []int64
+	inflight []bool
+	members  []int
+}
+
+// MemoryBroker 行程內的記憶體消息代理，供測試與本機執行使用
+//
+// 行為與 Kafka 相近：主題依 key 雜湊分區，消費者群組各自記錄位移，
+// 同群組的成員依分區分攤消息。投遞順序是確定的：每個成員總是先處理
+// 所屬分區中最早發布的消息，處理完成後才提交位移。
+type MemoryBroker struct {
+	logger     *zap.Logger
+	partitions int
+
+	mu      sync.Mutex
+	topics  map[string]*memoryTopic
+	groups  map[string]*memoryGroup
+	changed chan struct{}
+	seq     uint64
+	members int
+	closed  bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewMemoryBroker 創建記憶體消息代理
+func NewMemoryBroker(logger *zap.Logger) *MemoryBroker {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &MemoryBroker{
+		logger:     logger,
+		partitions: defaultMemoryPartitions,
+		topics:     make(map[string]*memoryTopic),
+		groups:     make(map[string]*memoryGroup),
+		changed:    make(chan struct{}),
+		ctx:        ctx,
+		cancel:     cancel,
+	}
+}
+
+// PublishMessage 發布消息到指定的主題
+func (b *MemoryBroker) PublishMessage(ctx context.Context, topic string, key string, message []byte) error {
+	return b.publish(topic, []byte(key), message, nil)
+}
+
+func (b *MemoryBroker) publish(topic string, key, value []byte, headers map[string]string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrBrokerClosed
+	}
+
+	t := b.topicLocked(topic)
+	partition := b.partitionFor(t, key)
+	b.seq++
+
+	msg := MemoryMessage{
+		Topic:     topic,
+		Partition: partition,
+		Offset:    int64(len(t.partitions[partition])),
+		Key:       append([]byte(nil), key...),
+		Value:     append([]byte(nil), value...),
+		Headers:   headers,
+		Time:      time.Now(),
+		seq:       b.seq,
+	}
+	t.partitions[partition] = append(t.partitions[partition], msg)
+	b.broadcastLocked()
+
+	return nil
+}
+
+// ConsumeMessages 以消費者群組身分在背景消費主題
+//
+// 處理失敗時與 KafkaClient 相同，依重試策略重試並轉送到死信主題。
+func (b *MemoryBroker) ConsumeMessages(ctx context.Context, topic, groupID string, handler func([]byte) error, opts ...SubscribeOption) error {
+	options := subscribeOptions{
+		retry:           DefaultRetryPolicy(),
+		deadLetterTopic: DeadLetterTopic(topic),
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return ErrBrokerClosed
+	}
+	b.topicLocked(topic)
+	group := b.groupLocked(topic, groupID)
+	b.members++
+	member := b.members
+	group.members = append(group.members, member)
+	b.broadcastLocked()
+	b.mu.Unlock()
+
+	b.wg.Add(1)
+	go func() {
+		defer b.wg.Done()
+		defer b.leave(group, member)
+
+		for {
+			b.mu.Lock()
+			msg, ok := b.nextLocked(group, member)
+			changed := b.changed
+			b.mu.Unlock()
+
+			if !ok {
+				select {
+				case <-ctx.Done():
+					return
+				case <-b.ctx.Done():
+					return
+				case <-changed:
+				}
+				continue
+			}
+
+			err := processWithRetry(ctx, b.logger, topic, msg.Key, msg.Value, handler, options.retry,
+				func(cause error, attempts int) error {
+					return b.publish(options.deadLetterTopic, msg.Key, msg.Value, deadLetterHeaders(msg, cause, attempts))
+				})
+
+			b.mu.Lock()
+			group.inflight[msg.Partition] = false
+			if err == nil {
+				group.offsets[msg.Partition] = msg.Offset + 1
+			}
+			b.broadcastLocked()
+			b.mu.Unlock()
+
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+// WaitIdle 等待所有消費者群組處理完目前已發布的消息
+func (b *MemoryBroker) WaitIdle(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		idle := b.idleLocked()
+		changed := b.changed
+		b.mu.Unlock()
+
+		if idle {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Messages 依發布順序回傳主題中的所有消息，供測試檢查
+func (b *MemoryBroker) Messages(topic string) []MemoryMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	t, ok := b.topics[topic]
+	if !ok {
+		return nil
+	}
+
+	var all []MemoryMessage
+	for _, partition := range t.partitions {
+		all = append(all, partition...)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].seq < all[j].seq })
+	return all
+}
+
+// Close 停止所有消費者並關閉代理
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	b.mu.Unlock()
+
+	b.cancel()
+	b.wg.Wait()
+	return nil
+}
+
+// topicLocked 取得或建立主題
+func (b *MemoryBroker) topicLocked(name string) *memoryTopic {
+	t, ok := b.topics[name]
+	if !ok {
+		t = &memoryTopic{partitions: make([][]MemoryMessage, b.partitions)}
+		b.topics[name] = t
+	}
+	return t
+}
+
+// groupLocked 取得或建立消費者群組，新群組從最早的消息開始消費
+func (b *MemoryBroker) groupLocked(topic, groupID string) *memoryGroup {
+	key := topic + "\x00" + groupID
+	g, ok := b.groups[key]
+	if !ok {
+		g = &memoryGroup{
+			topic:    topic,
+			offsets:  make([]int64, b.partitions),
+			inflight: make([]bool, b.partitions),
+		}
+		b.groups[key] = g
+	}
+	return g
+}
+
+// partitionFor 依 key 雜湊選擇分區，沒有 key 時輪流分配
+func (b *MemoryBroker) partitionFor(t *memoryTopic, key []byte) int {
+	if len(key) == 0 {
+		p := t.nextRR
+		t.nextRR = (t.nextRR + 1) % len(t.partitions)
+		return p
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return int(h.Sum32() % uint32(len(t.partitions)))
+}
+
+// nextLocked 取出成員所屬分區中最早發布且尚未處理的消息
+func (b *MemoryBroker) nextLocked(g *memoryGroup, member int) (MemoryMessage, bool) {
+	index := -1
+	for i, m := range g.members {
+		if m == member {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return MemoryMessage{}, false
+	}
+
+	t := b.topics[g.topic]
+	var next MemoryMessage
+	found := false
+	for p := range t.partitions {
+		if p%len(g.members) != index || g.inflight[p] {
+			continue
+		}
+		if g.offsets[p] >= int64(len(t.partitions[p])) {
+			continue
+		}
+		candidate := t.partitions[p][g.offsets[p]]
+		if !found || candidate.seq < next.seq {
+			next, found = candidate, true
+		}
+	}
+
+	if found {
+		g.inflight[next.Partition] = true
+	}
+	return next, found
+}
+
+// idleLocked 檢查所有群組是否都已處理完已發布的消息
+func (b *MemoryBroker) idleLocked() bool {
+	for _, g := range b.groups {
+		if len(g.members) == 0 {
+			continue
+		}
+		t := b.topics[g.topic]
+		for p := range t.partitions {
+			if g.inflight[p] || g.offsets[p] < int64(len(t.partitions[p])) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// leave 將成員移出群組，其分區由其餘成員接手
+func (b *MemoryBroker) leave(g *memoryGroup, member int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, m := range g.members {
+		if m == member {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	b.broadcastLocked()
+}
+
+// broadcastLocked 通知等待中的消費者狀態已變更
+func (b *MemoryBroker) broadcastLocked() {
+	close(b.changed)
+	b.changed = make(chan struct{})
+}
+
+// deadLetterHeaders 產生與 KafkaClient 相同的死信標頭
+func deadLetterHeaders(msg MemoryMessage, cause error, attempts int) map[string]string {
+	headers := make(map[string]string, len(msg.Headers)+6)
+	for k, v := range msg.Headers {
+		headers[k] = v
+	}
+	headers[HeaderOriginalTopic] = msg.Topic
+	headers[HeaderOriginalPartition] = strconv.Itoa(msg.Partition)
+	headers[HeaderOriginalOffset] = strconv.FormatInt(msg.Offset, 10)
+	headers[HeaderError] = cause.Error()
+	headers[HeaderAttempts] = strconv.Itoa(attempts)
+	headers[HeaderFailedAt] = time.Now().UTC().Format(time.RFC3339)
+	return headers
+}
@@ -0,0 +1,187 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fastRetry 測試用的重試策略，不等待退避
+var fastRetry = RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 1}
+
+// recorder 記錄消費者收到的消息
+type recorder struct {
+	mu       sync.Mutex
+	received []string
+}
+
+func (r *recorder) add(value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.received = append(r.received, value)
+}
+
+func (r *recorder) values() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.received...)
+}
+
+func TestMemoryBrokerDelivery(t *testing.T) {
+	tests := []struct {
+		name    string
+		publish []string
+		// fail 回傳每次處理的錯誤，nil 表示處理成功
+		fail         func(value string, attempt int) error
+		wantReceived []string
+		wantDLQ      int
+	}{
+		{
+			name:         "delivers in publish order",
+			publish:      []string{"a", "b", "c"},
+			wantReceived: []string{"a", "b", "c"},
+		},
+		{
+			name:    "retries until the handler succeeds",
+			publish: []string{"a"},
+			fail: func(value string, attempt int) error {
+				if attempt < 3 {
+					return errors.New("temporary")
+				}
+				return nil
+			},
+			wantReceived: []string{"a", "a", "a"},
+		},
+		{
+			name:    "exhausted retries go to dead letter",
+			publish: []string{"a", "b"},
+			fail: func(value string, attempt int) error {
+				if value == "a" {
+					return errors.New("always")
+				}
+				return nil
+			},
+			wantReceived: []string{"a", "a", "a", "b"},
+			wantDLQ:      1,
+		},
+		{
+			name:    "fatal errors skip retries",
+			publish: []string{"a", "b"},
+			fail: func(value string, attempt int) error {
+				if value == "a" {
+					return Fatal(errors.New("malformed"))
+				}
+				return nil
+			},
+			wantReceived: []string{"a", "b"},
+			wantDLQ:      1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			broker := NewMemoryBroker(nil)
+			defer broker.Close()
+
+			rec := &recorder{}
+			attempts := map[string]int{}
+			err := broker.ConsumeMessages(ctx, "orders", "group", func(value []byte) error {
+				rec.add(string(value))
+				attempts[string(value)]++
+				if tt.fail != nil {
+					return tt.fail(string(value), attempts[string(value)])
+				}
+				return nil
+			}, WithRetryPolicy(fastRetry))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// 相同的 key 落在同一分區，保持發布順序
+			for _, value := range tt.publish {
+				if err := broker.PublishMessage(ctx, "orders", "order-1", []byte(value)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := broker.WaitIdle(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if got := rec.values(); fmt.Sprint(got) != fmt.Sprint(tt.wantReceived) {
+				t.Errorf("received %v, want %v", got, tt.wantReceived)
+			}
+			dlq := broker.Messages(DeadLetterTopic("orders"))
+			if len(dlq) != tt.wantDLQ {
+				t.Fatalf("got %d dead letters, want %d", len(dlq), tt.wantDLQ)
+			}
+			for _, msg := range dlq {
+				if msg.Headers[HeaderOriginalTopic] != "orders" || msg.Headers[HeaderError] == "" {
+					t.Errorf("dead letter headers = %v", msg.Headers)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryBrokerConsumerGroups(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	broker := NewMemoryBroker(nil)
+	defer broker.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := broker.PublishMessage(ctx, "events", fmt.Sprintf("key-%d", i), []byte(fmt.Sprint(i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 每個群組各自記錄位移，都會收到全部消息
+	groups := map[string]*recorder{"billing": {}, "shipping": {}}
+	for group, rec := range groups {
+		rec := rec
+		err := broker.ConsumeMessages(ctx, "events", group, func(value []byte) error {
+			rec.add(string(value))
+			return nil
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := broker.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for group, rec := range groups {
+		if got := len(rec.values()); got != 3 {
+			t.Errorf("group %s received %d messages, want 3", group, got)
+		}
+	}
+
+	// 重新加入的群組從已提交的位移繼續，不會重複收到消息
+	groupCtx, stop := context.WithCancel(ctx)
+	late := &recorder{}
+	if err := broker.ConsumeMessages(groupCtx, "events", "billing", func(value []byte) error {
+		late.add(string(value))
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.PublishMessage(ctx, "events", "key-9", []byte("9")); err != nil {
+		t.Fatal(err)
+	}
+	if err := broker.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+	stop()
+
+	billing := append(groups["billing"].values(), late.values()...)
+	if len(billing) != 4 {
+		t.Errorf("billing group received %v, want each message once", billing)
+	}
+}
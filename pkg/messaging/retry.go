@@ -0,0 +1,165 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// RetryPolicy 定義訊息處理失敗時的重試策略
+type RetryPolicy struct {
+	// MaxAttempts 最多處理次數 (包含第一次)，用盡後轉送到死信主題
+	MaxAttempts int
+	// InitialBackoff 第一次重試前的等待時間
+	InitialBackoff time.Duration
+	// MaxBackoff 每次重試等待時間的上限
+	MaxBackoff time.Duration
+	// Multiplier 每次重試後等待時間的倍數
+	Multiplier float64
+	// Retryable 判斷錯誤是否可重試，為 nil 時除 FatalError 外皆可重試
+	Retryable func(error) bool
+}
+
+// DefaultRetryPolicy 回傳預設的重試策略：最多 5 次，指數退避 500ms 到 30s
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: 500 * time.Millisecond,
+		MaxBackoff:     30 * time.Second,
+		Multiplier:     2,
+	}
+}
+
+// isRetryable 判斷錯誤是否應該重試
+func (p RetryPolicy) isRetryable(err error) bool {
+	if IsFatal(err) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return true
+}
+
+// backoff 回傳第 attempt 次失敗後的等待時間
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt; i++ {
+		delay = time.Duration(float64(delay) * p.Multiplier)
+		if p.MaxBackoff > 0 && delay >= p.MaxBackoff {
+			return p.MaxBackoff
+		}
+	}
+	return delay
+}
+
+// FatalError 表示不可重試的處理錯誤，訊息會直接轉送到死信主題
+type FatalError struct {
+	Err error
+}
+
+func (e *FatalError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *FatalError) Unwrap() error {
+	return e.Err
+}
+
+// Fatal 將錯誤標記為不可重試，例如訊息格式錯誤
+func Fatal(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &FatalError{Err: err}
+}
+
+// IsFatal 檢查錯誤是否被標記為不可重試
+func IsFatal(err error) bool {
+	var fatal *FatalError
+	return errors.As(err, &fatal)
+}
+
+// SubscribeOption 設定單一訂閱的選項
+type SubscribeOption func(*subscribeOptions)
+
+type subscribeOptions struct {
+	retry           RetryPolicy
+	deadLetterTopic string
+}
+
+// WithRetryPolicy 設定訂閱的重試策略
+func WithRetryPolicy(policy RetryPolicy) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.retry = policy
+	}
+}
+
+// WithDeadLetterTopic 覆寫預設的死信主題 (<topic>.dlq)
+func WithDeadLetterTopic(topic string) SubscribeOption {
+	return func(o *subscribeOptions) {
+		o.deadLetterTopic = topic
+	}
+}
+
+// processWithRetry 依重試策略處理單一消息，不可重試或次數用盡時呼叫 deadLetter
+//
+// 轉送死信失敗時會持續以退避重試直到成功或 ctx 取消，避免消息遺失。
+// 回傳非 nil 錯誤表示 ctx 已取消，消息未被處理完成。
+func processWithRetry(ctx context.Context, logger *zap.Logger, topic string, key, value []byte, handler func([]byte) error, policy RetryPolicy, deadLetter func(cause error, attempts int) error) error {
+	attempt := 0
+	for {
+		attempt++
+		err := handler(value)
+		if err == nil {
+			return nil
+		}
+
+		logger.Error("處理消息失敗",
+			zap.String("topic", topic),
+			zap.String("key", string(key)),
+			zap.Int("attempt", attempt),
+			zap.Error(err),
+		)
+
+		if !policy.isRetryable(err) || attempt >= policy.MaxAttempts {
+			return retryDeadLetter(ctx, logger, topic, func() error { return deadLetter(err, attempt) })
+		}
+
+		if err := sleepContext(ctx, policy.backoff(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+// retryDeadLetter 持續嘗試轉送死信直到成功或 ctx 取消
+func retryDeadLetter(ctx context.Context, logger *zap.Logger, topic string, send func() error) error {
+	policy := DefaultRetryPolicy()
+	for retry := 1; ; retry++ {
+		err := send()
+		if err == nil {
+			return nil
+		}
+
+		logger.Error("轉送死信主題失敗，稍後重試", zap.String("topic", topic), zap.Error(err))
+
+		if err := sleepContext(ctx, policy.backoff(retry)); err != nil {
+			return err
+		}
+	}
+}
+
+// sleepContext 等待指定時間，ctx 取消時提前返回
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -1,6 +1,8 @@
 package middleware
 
 import (
+	"context"
+	"crypto"
 	"errors"
 	"fmt"
 	"net/http"
@@ -11,8 +13,52 @@ import (
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Context keys set by JWTAuthMiddleware in addition to user_id and role
+const (
+	// ContextTokenID is the jti of the access token
+	ContextTokenID = "token_id"
+	// ContextTokenFamily is the refresh token family the access token was issued from
+	ContextTokenFamily = "token_family"
+	// ContextTokenExpiry is the expiry of the access token as a time.Time
+	ContextTokenExpiry = "token_expiry"
+)
+
+// AuthOption configures JWTAuthMiddleware
+type AuthOption func(*authOptions)
+
+type authOptions struct {
+	denylist TokenDenylist
+	keys     KeyProvider
+}
+
+// KeyProvider looks up the public key of an asymmetrically signed token by its kid
+type KeyProvider interface {
+	PublicKey(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// WithKeyProvider verifies RS256 and EdDSA tokens with public keys from the
+// provider, such as a jwks.RemoteKeySet. HMAC tokens are rejected and the
+// secret key is not used.
+func WithKeyProvider(keys KeyProvider) AuthOption {
+	return func(o *authOptions) {
+		o.keys = keys
+	}
+}
+
+// WithDenylist rejects tokens whose jti or token family has been revoked
+func WithDenylist(denylist TokenDenylist) AuthOption {
+	return func(o *authOptions) {
+		o.denylist = denylist
+	}
+}
+
 // JWTAuthMiddleware is a middleware for JWT authentication
-func JWTAuthMiddleware(secretKey string) gin.HandlerFunc {
+func JWTAuthMiddleware(secretKey string, opts ...AuthOption) gin.HandlerFunc {
+	options := authOptions{}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	return func(c *gin.Context) {
 		// Get the Authorization header
 		authHeader := c.GetHeader("Authorization")
@@ -34,13 +80,20 @@ func JWTAuthMiddleware(secretKey string) gin.HandlerFunc {
 		tokenString := parts[1]
 
 		// Parse and validate the token
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate the signing method
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("非預期的簽名方法: %v", token.Header["alg"])
-			}
-			return []byte(secretKey), nil
-		})
+		var token *jwt.Token
+		var err error
+		if options.keys != nil {
+			token, err = jwt.Parse(tokenString, publicKeyFunc(c.Request.Context(), options.keys),
+				jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}))
+		} else {
+			token, err = jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+				// Validate the signing method
+				if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+					return nil, fmt.Errorf("非預期的簽名方法: %v", token.Header["alg"])
+				}
+				return []byte(secretKey), nil
+			})
+		}
 
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "無效的令牌: " + err.Error()})
@@ -72,6 +125,29 @@ func JWTAuthMiddleware(secretKey string) gin.HandlerFunc {
 			if role, ok := claims["role"].(string); ok {
 				c.Set("role", role)
 			}
+			c.Set(ContextRoles, rolesFromClaims(claims))
+
+			// Reject revoked tokens and tokens of a revoked session
+			jti, _ := claims["jti"].(string)
+			family, _ := claims["fid"].(string)
+			if options.denylist != nil {
+				revoked, err := options.denylist.IsRevoked(c.Request.Context(), jti, family)
+				if err != nil {
+					c.JSON(http.StatusServiceUnavailable, gin.H{"error": "無法驗證令牌狀態"})
+					c.Abort()
+					return
+				}
+				if revoked {
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "令牌已撤銷"})
+					c.Abort()
+					return
+				}
+			}
+			c.Set(ContextTokenID, jti)
+			c.Set(ContextTokenFamily, family)
+			if exp, err := claims.GetExpirationTime(); err == nil && exp != nil {
+				c.Set(ContextTokenExpiry, exp.Time)
+			}
 
 			c.Next()
 		} else {
@@ -104,6 +180,96 @@ func GenerateJWT(userID, role, secretKey string, expiryHours int) (string, error
 	return tokenString, nil
 }
 
+// TokenSigner signs token claims
+//
+// The auth service uses an HMACSigner with the shared JWT secret, or a
+// jwks.KeyRing for RS256 and EdDSA tokens with a kid header.
+type TokenSigner interface {
+	Sign(claims jwt.MapClaims) (string, error)
+}
+
+// HMACSigner signs tokens with HS256 and a shared secret
+type HMACSigner struct {
+	secret []byte
+}
+
+// NewHMACSigner creates a new HMACSigner
+func NewHMACSigner(secretKey string) *HMACSigner {
+	return &HMACSigner{secret: []byte(secretKey)}
+}
+
+// Sign signs the claims
+func (s *HMACSigner) Sign(claims jwt.MapClaims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
+
+// GenerateAccessToken generates a short-lived access token
+//
+// The jti identifies this token and familyID the refresh token family it was
+// issued from, so either can be revoked through a TokenDenylist. The first
+// role is also written to the legacy role claim.
+func GenerateAccessToken(signer TokenSigner, userID string, roles []string, jti, familyID string, ttl time.Duration) (string, time.Time, error) {
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+
+	role := RoleUser
+	if len(roles) > 0 {
+		role = roles[0]
+	}
+
+	claims := jwt.MapClaims{
+		"user_id": userID,
+		"role":    role,
+		"roles":   roles,
+		"jti":     jti,
+		"fid":     familyID,
+		"exp":     expiresAt.Unix(),
+		"iat":     now.Unix(),
+	}
+
+	tokenString, err := signer.Sign(claims)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	return tokenString, expiresAt, nil
+}
+
+// publicKeyFunc resolves the verification key of a token from its kid header
+func publicKeyFunc(ctx context.Context, keys KeyProvider) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("令牌缺少 kid")
+		}
+		return keys.PublicKey(ctx, kid)
+	}
+}
+
+// rolesFromClaims merges the roles claim with the legacy single role claim
+func rolesFromClaims(claims jwt.MapClaims) []string {
+	var roles []string
+	seen := make(map[string]bool)
+	add := func(role string) {
+		if role != "" && !seen[role] {
+			seen[role] = true
+			roles = append(roles, role)
+		}
+	}
+
+	if role, ok := claims["role"].(string); ok {
+		add(role)
+	}
+	if list, ok := claims["roles"].([]interface{}); ok {
+		for _, r := range list {
+			if role, ok := r.(string); ok {
+				add(role)
+			}
+		}
+	}
+	return roles
+}
+
 // ExtractUserID extracts the user ID from the JWT token
 func ExtractUserID(tokenString, secretKey string) (string, error) {
 	// Parse the token
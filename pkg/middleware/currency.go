@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// CurrencyHeader is the request header that selects the currency of prices
+const CurrencyHeader = "X-Currency"
+
+// ContextCurrency is the context key of the currency selected by the request
+const ContextCurrency = "currency"
+
+// SelectCurrency reads the currency selected by the request
+//
+// The currency comes from the currency query parameter or, if there is
+// none, the X-Currency header. Requests selecting a currency that is not
+// supported are rejected with 400. Requests without a selection continue
+// without one, so handlers can fall back to their own default.
+func SelectCurrency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		currency := c.Query("currency")
+		if currency == "" {
+			currency = c.GetHeader(CurrencyHeader)
+		}
+		currency = strings.ToUpper(strings.TrimSpace(currency))
+
+		if currency != "" {
+			if !money.IsKnownCurrency(currency) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "不支援的幣別: " + currency})
+				c.Abort()
+				return
+			}
+			c.Set(ContextCurrency, currency)
+		}
+
+		c.Next()
+	}
+}
+
+// SelectedCurrency returns the currency selected by the request, or "" if none was selected
+func SelectedCurrency(c *gin.Context) string {
+	return c.GetString(ContextCurrency)
+}
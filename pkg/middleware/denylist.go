@@ -0,0 +1,114 @@
+package middleware
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// TokenDenylist stores revoked token identifiers
+//
+// An identifier is either the jti of a single access token or the ID of a
+// refresh token family, which revokes every access token issued from it.
+// Entries only need to live until the last token they cover has expired.
+type TokenDenylist interface {
+	Revoke(ctx context.Context, id string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, ids ...string) (bool, error)
+}
+
+// MongoTokenDenylist implements TokenDenylist using MongoDB
+//
+// Expired entries are removed by a TTL index on expires_at.
+type MongoTokenDenylist struct {
+	collection *mongo.Collection
+}
+
+// NewMongoTokenDenylist creates a new MongoTokenDenylist
+func NewMongoTokenDenylist(db *mongo.Database) *MongoTokenDenylist {
+	return &MongoTokenDenylist{
+		collection: db.Collection("revoked_tokens"),
+	}
+}
+
+// Revoke adds an identifier to the denylist
+func (d *MongoTokenDenylist) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	// Keep the latest expiry when the same identifier is revoked twice
+	_, err := d.collection.UpdateOne(ctx,
+		bson.M{"_id": id},
+		bson.M{
+			"$max":         bson.M{"expires_at": expiresAt},
+			"$setOnInsert": bson.M{"revoked_at": time.Now()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// IsRevoked reports whether any of the identifiers has been revoked
+func (d *MongoTokenDenylist) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	ids = nonEmpty(ids)
+	if len(ids) == 0 {
+		return false, nil
+	}
+
+	count, err := d.collection.CountDocuments(ctx, bson.M{
+		"_id":        bson.M{"$in": ids},
+		"expires_at": bson.M{"$gt": time.Now()},
+	}, options.Count().SetLimit(1))
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// MemoryTokenDenylist implements TokenDenylist in memory for tests and local development
+type MemoryTokenDenylist struct {
+	mu      sync.RWMutex
+	entries map[string]time.Time
+}
+
+// NewMemoryTokenDenylist creates a new MemoryTokenDenylist
+func NewMemoryTokenDenylist() *MemoryTokenDenylist {
+	return &MemoryTokenDenylist{
+		entries: make(map[string]time.Time),
+	}
+}
+
+// Revoke adds an identifier to the denylist
+func (d *MemoryTokenDenylist) Revoke(ctx context.Context, id string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if current, ok := d.entries[id]; !ok || expiresAt.After(current) {
+		d.entries[id] = expiresAt
+	}
+	return nil
+}
+
+// IsRevoked reports whether any of the identifiers has been revoked
+func (d *MemoryTokenDenylist) IsRevoked(ctx context.Context, ids ...string) (bool, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	now := time.Now()
+	for _, id := range nonEmpty(ids) {
+		if expiresAt, ok := d.entries[id]; ok && expiresAt.After(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func nonEmpty(ids []string) []string {
+	result := ids[:0:0]
+	for _, id := range ids {
+		if id != "" {
+			result = append(result, id)
+		}
+	}
+	return result
+}
@@ -0,0 +1,137 @@
+package middleware
+
+import (
+	"net/http"
+	"sort"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Permission is a fine-grained action a role can be allowed to perform
+type Permission string
+
+// Permissions checked by the services
+const (
+	// PermCatalogWrite allows creating, updating and deleting products and categories
+	PermCatalogWrite Permission = "catalog:write"
+	// PermInventoryWrite allows managing stock reservations directly
+	PermInventoryWrite Permission = "inventory:write"
+	// PermPromotionsWrite allows creating and updating promotions and coupon codes
+	PermPromotionsWrite Permission = "promotions:write"
+	// PermFulfillmentWrite allows creating shipments and recording carrier tracking
+	PermFulfillmentWrite Permission = "fulfillment:write"
+	// PermPaymentsWrite allows capturing, voiding and refunding payments
+	PermPaymentsWrite Permission = "payments:write"
+	// PermOrdersReadAny allows reading orders, payments and shipments of any user
+	PermOrdersReadAny Permission = "orders:read:any"
+	// PermUsersAdmin allows managing users and their roles
+	PermUsersAdmin Permission = "users:admin"
+)
+
+// Roles known to the system
+const (
+	// RoleUser is the implicit role of every account
+	RoleUser = "user"
+	// RoleSupport can look up any order for customer support
+	RoleSupport = "support"
+	// RoleCatalogManager maintains the product catalog
+	RoleCatalogManager = "catalog_manager"
+	// RoleWarehouse ships paid orders and records their tracking
+	RoleWarehouse = "warehouse"
+	// RoleAdmin can do everything
+	RoleAdmin = "admin"
+)
+
+// ContextRoles is the context key of the caller's roles as a []string
+const ContextRoles = "roles"
+
+// rolePermissions is the permission set granted by each role
+var rolePermissions = map[string][]Permission{
+	RoleUser:           {},
+	RoleSupport:        {PermOrdersReadAny},
+	RoleCatalogManager: {PermCatalogWrite, PermInventoryWrite, PermPromotionsWrite},
+	RoleWarehouse:      {PermFulfillmentWrite, PermOrdersReadAny},
+	RoleAdmin:          {PermCatalogWrite, PermInventoryWrite, PermPromotionsWrite, PermFulfillmentWrite, PermPaymentsWrite, PermOrdersReadAny, PermUsersAdmin},
+}
+
+// IsKnownRole checks if a role is defined
+func IsKnownRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// RolePermissions returns every role with the permissions it grants
+func RolePermissions() map[string][]Permission {
+	roles := make(map[string][]Permission, len(rolePermissions))
+	for role, perms := range rolePermissions {
+		roles[role] = append([]Permission(nil), perms...)
+	}
+	return roles
+}
+
+// RolesGrant checks if any of the roles grants the permission
+func RolesGrant(roles []string, perm Permission) bool {
+	for _, role := range roles {
+		for _, p := range rolePermissions[role] {
+			if p == perm {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// PermissionsOf returns the sorted permissions granted by the roles
+func PermissionsOf(roles []string) []Permission {
+	set := make(map[Permission]struct{})
+	for _, role := range roles {
+		for _, p := range rolePermissions[role] {
+			set[p] = struct{}{}
+		}
+	}
+
+	perms := make([]Permission, 0, len(set))
+	for p := range set {
+		perms = append(perms, p)
+	}
+	sort.Slice(perms, func(i, j int) bool { return perms[i] < perms[j] })
+	return perms
+}
+
+// Roles returns the roles JWTAuthMiddleware stored in the context
+func Roles(c *gin.Context) []string {
+	roles, _ := c.Get(ContextRoles)
+	names, _ := roles.([]string)
+	return names
+}
+
+// HasPermission checks if the authenticated caller has the permission
+func HasPermission(c *gin.Context, perm Permission) bool {
+	return RolesGrant(Roles(c), perm)
+}
+
+// RequirePermissions is a middleware that only lets callers holding every
+// permission through. It must run after JWTAuthMiddleware, which is usually
+// done by attaching both to a route group:
+//
+//	admin := router.Group("/api/products", authMiddleware, middleware.RequirePermissions(middleware.PermCatalogWrite))
+func RequirePermissions(perms ...Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("user_id"); !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "未授權"})
+			c.Abort()
+			return
+		}
+
+		roles := Roles(c)
+		for _, perm := range perms {
+			if !RolesGrant(roles, perm) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "權限不足", "required": perm})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
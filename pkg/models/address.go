@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+)
+
+// Address is a saved address in a user's address book
+type Address struct {
+	ID     string `json:"id" bson:"_id"`
+	UserID string `json:"-" bson:"user_id"`
+	// Label is a name chosen by the user, such as "Home" or "Office"
+	Label        string `json:"label" bson:"label"`
+	ShippingInfo `bson:",inline"`
+	// A user has at most one default shipping and one default billing address
+	DefaultShipping bool      `json:"default_shipping" bson:"default_shipping"`
+	DefaultBilling  bool      `json:"default_billing" bson:"default_billing"`
+	CreatedAt       time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// Kinds of default address
+const (
+	AddressDefaultShipping = "shipping"
+	AddressDefaultBilling  = "billing"
+)
+
+// AddressRequest represents the data needed to save an address
+type AddressRequest struct {
+	Label string `json:"label" binding:"max=50"`
+	ShippingInfo
+	DefaultShipping bool `json:"default_shipping"`
+	DefaultBilling  bool `json:"default_billing"`
+}
+
+// ProfileUpdateRequest represents a change of the user's profile, omitted fields are kept
+type ProfileUpdateRequest struct {
+	FirstName *string `json:"first_name" binding:"omitempty,min=1"`
+	LastName  *string `json:"last_name" binding:"omitempty,min=1"`
+}
+
+// ChangePasswordRequest represents the data needed to change the password of a signed in user
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required,min=8"`
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
 )
 
 // CartItem represents an item in a shopping cart
@@ -14,12 +16,22 @@ type CartItem struct {
 }
 
 // Cart represents a shopping cart in the system
+//
+// A cart belongs either to a user or to a guest identified by a signed cart
+// token. Guest carts expire at ExpiresAt unless they are used again. Coupons
+// holds the applied coupon codes in the order they were applied. Currency is
+// the currency locked at the last checkout; requests that do not select a
+// currency are priced in it.
 type Cart struct {
 	ID        string     `json:"id" bson:"_id"`
-	UserID    string     `json:"user_id" bson:"user_id"`
+	UserID    string     `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	GuestID   string     `json:"-" bson:"guest_id,omitempty"`
 	Items     []CartItem `json:"items" bson:"items"`
+	Coupons   []string   `json:"coupons,omitempty" bson:"coupons,omitempty"`
+	Currency  string     `json:"currency,omitempty" bson:"currency,omitempty"`
 	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
 	UpdatedAt time.Time  `json:"updated_at" bson:"updated_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty" bson:"expires_at,omitempty"`
 }
 
 // CartItemRequest represents the data needed to add an item to a cart
@@ -95,6 +107,30 @@ func (c *Cart) RemoveItem(productID string) bool {
 	return false
 }
 
+// AddCoupon applies a coupon code to the cart, returning false if it is already applied
+func (c *Cart) AddCoupon(code string) bool {
+	for _, existing := range c.Coupons {
+		if existing == code {
+			return false
+		}
+	}
+	c.Coupons = append(c.Coupons, code)
+	c.UpdatedAt = time.Now()
+	return true
+}
+
+// RemoveCoupon removes a coupon code from the cart
+func (c *Cart) RemoveCoupon(code string) bool {
+	for i, existing := range c.Coupons {
+		if existing == code {
+			c.Coupons = append(c.Coupons[:i], c.Coupons[i+1:]...)
+			c.UpdatedAt = time.Now()
+			return true
+		}
+	}
+	return false
+}
+
 // Clear clears all items from the cart
 func (c *Cart) Clear() {
 	c.Items = []CartItem{}
@@ -110,10 +146,78 @@ func (c *Cart) TotalItems() int {
 	return total
 }
 
-// TotalAmount returns the total amount of the cart
-func (c *Cart) TotalAmount() float64 {
-	// This function is not implemented as the price of the item is not available
-	// in the CartItem struct. You may need to add the price to the CartItem struct
-	// or retrieve it from another source.
-	return 0.0
+// TotalAmount returns the total amount of the cart at the given unit prices
+//
+// Items without a price are not counted. Prices are not stored on the cart
+// because they are looked up from the product service each time the cart is
+// rendered. It fails if a price is not in the given currency.
+func (c *Cart) TotalAmount(prices map[string]money.Money, currency string) (money.Money, error) {
+	total := money.Zero(currency)
+	for _, item := range c.Items {
+		price, ok := prices[item.ProductID]
+		if !ok {
+			continue
+		}
+		var err error
+		if total, err = total.Add(price.Multiply(int64(item.Quantity))); err != nil {
+			return money.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// Cart item availability
+const (
+	ItemAvailable         = "available"
+	ItemInsufficientStock = "insufficient_stock"
+	ItemUnavailable       = "unavailable"
+)
+
+// PricedCartItem is a cart item with the product's current name, price and stock
+//
+// PriceConverted is set when the product has no price in the cart's currency
+// and UnitPrice was converted from its base price at the current rate.
+// TaxCategory is carried to the order, which computes the tax.
+type PricedCartItem struct {
+	CartItem
+	Name           string      `json:"name"`
+	CategoryID     string      `json:"category_id,omitempty"`
+	TaxCategory    string      `json:"tax_category,omitempty"`
+	UnitPrice      money.Money `json:"unit_price"`
+	PriceConverted bool        `json:"price_converted,omitempty"`
+	Subtotal       money.Money `json:"subtotal"`
+	Available      int         `json:"available"`
+	Status         string      `json:"status"`
+}
+
+// PricedCart represents a cart rendered with current product prices
+//
+// Items whose product no longer exists are listed as unavailable and are not
+// counted in the totals. Total is the subtotal less the discounts of the
+// coupons that apply. Every amount is in Currency.
+type PricedCart struct {
+	ID              string           `json:"id"`
+	UserID          string           `json:"user_id,omitempty"`
+	Currency        string           `json:"currency"`
+	Items           []PricedCartItem `json:"items"`
+	ItemCount       int              `json:"item_count"`
+	Subtotal        money.Money      `json:"subtotal"`
+	Discounts       []DiscountLine   `json:"discounts"`
+	DiscountTotal   money.Money      `json:"discount_total"`
+	FreeShipping    bool             `json:"free_shipping"`
+	RejectedCoupons []RejectedCoupon `json:"rejected_coupons,omitempty"`
+	Total           money.Money      `json:"total"`
+	UpdatedAt       time.Time        `json:"updated_at"`
+	ExpiresAt       *time.Time       `json:"expires_at,omitempty"`
+}
+
+// Unavailable returns the items that cannot be ordered in their current quantity
+func (c *PricedCart) Unavailable() []PricedCartItem {
+	var items []PricedCartItem
+	for _, item := range c.Items {
+		if item.Status != ItemAvailable {
+			items = append(items, item)
+		}
+	}
+	return items
 }
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+)
+
+// ReservationStatus represents the status of a stock reservation
+type ReservationStatus string
+
+const (
+	// ReservationPending represents stock held for an unpaid order
+	ReservationPending ReservationStatus = "PENDING"
+	// ReservationCommitted represents stock deducted after payment
+	ReservationCommitted ReservationStatus = "COMMITTED"
+	// ReservationReleased represents stock returned after cancellation or expiry
+	ReservationReleased ReservationStatus = "RELEASED"
+)
+
+// StockHold is a quantity of a product held for an order, embedded in the product document
+type StockHold struct {
+	OrderID   string    `json:"order_id" bson:"order_id"`
+	Quantity  int       `json:"quantity" bson:"quantity"`
+	ExpiresAt time.Time `json:"expires_at" bson:"expires_at"`
+}
+
+// ReservationItem represents a product and quantity in a reservation
+type ReservationItem struct {
+	ProductID string `json:"product_id" bson:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" bson:"quantity" binding:"required,gt=0"`
+}
+
+// Reservation represents the stock reserved for an order
+type Reservation struct {
+	OrderID   string            `json:"order_id" bson:"_id"`
+	Items     []ReservationItem `json:"items" bson:"items"`
+	Status    ReservationStatus `json:"status" bson:"status"`
+	Reason    string            `json:"reason,omitempty" bson:"reason,omitempty"`
+	ExpiresAt time.Time         `json:"expires_at" bson:"expires_at"`
+	CreatedAt time.Time         `json:"created_at" bson:"created_at"`
+	UpdatedAt time.Time         `json:"updated_at" bson:"updated_at"`
+}
+
+// ReserveStockRequest represents the data needed to reserve stock for an order
+type ReserveStockRequest struct {
+	OrderID    string            `json:"order_id" binding:"required"`
+	Items      []ReservationItem `json:"items" binding:"required,min=1,dive"`
+	TTLSeconds int               `json:"ttl_seconds" binding:"gte=0"`
+}
+
+// NewReservation creates a new pending reservation
+func NewReservation(orderID string, items []ReservationItem, ttl time.Duration) *Reservation {
+	now := time.Now()
+	return &Reservation{
+		OrderID:   orderID,
+		Items:     items,
+		Status:    ReservationPending,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+// LoginAttempts counts recent failed logins for an account or a client IP
+type LoginAttempts struct {
+	Key            string     `json:"key" bson:"_id"`
+	Failures       int        `json:"failures" bson:"failures"`
+	FirstFailureAt time.Time  `json:"first_failure_at" bson:"first_failure_at"`
+	LastFailureAt  time.Time  `json:"last_failure_at" bson:"last_failure_at"`
+	LockedUntil    *time.Time `json:"locked_until,omitempty" bson:"locked_until,omitempty"`
+	ExpiresAt      time.Time  `json:"expires_at" bson:"expires_at"`
+}
+
+// Events recorded in the auth audit log
+const (
+	AuditLoginSucceeded = "login_succeeded"
+	AuditLoginFailed    = "login_failed"
+	AuditLoginLocked    = "login_locked"
+	AuditLoginUnlocked  = "login_unlocked"
+	AuditRoleGranted    = "role_granted"
+	AuditRoleRevoked    = "role_revoked"
+	AuditPasswordReset  = "password_reset"
+	AuditPasswordChange = "password_changed"
+
+	AuditPrivacyRequested = "privacy_requested"
+	AuditAccountErased    = "account_erased"
+
+	AuditTwoFactorEnabled      = "two_factor_enabled"
+	AuditTwoFactorDisabled     = "two_factor_disabled"
+	AuditTwoFactorFailed       = "two_factor_failed"
+	AuditRecoveryCodeUsed      = "recovery_code_used"
+	AuditRecoveryCodesRenewed  = "recovery_codes_renewed"
+	AuditTwoFactorPolicyChange = "two_factor_policy_changed"
+)
+
+// AuditEntry is a security relevant event of the auth service
+type AuditEntry struct {
+	ID        string    `json:"id" bson:"_id"`
+	Event     string    `json:"event" bson:"event"`
+	UserID    string    `json:"user_id,omitempty" bson:"user_id,omitempty"`
+	Email     string    `json:"email,omitempty" bson:"email,omitempty"`
+	IP        string    `json:"ip,omitempty" bson:"ip,omitempty"`
+	ActorID   string    `json:"actor_id,omitempty" bson:"actor_id,omitempty"` // Administrator who performed the action
+	Detail    string    `json:"detail,omitempty" bson:"detail,omitempty"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// AuditFilter filters audit entries; empty fields match everything
+type AuditFilter struct {
+	UserID string
+	Email  string
+	IP     string
+	Limit  int
+}
@@ -4,6 +4,8 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
 )
 
 // OrderStatus represents the status of an order
@@ -40,7 +42,7 @@ const (
 type Order struct {
 	ID            string       `json:"id" gorm:"primaryKey"`
 	UserID        string       `json:"user_id" gorm:"index"`
-	TotalAmount   float64      `json:"total_amount"`
+	TotalAmount   money.Money  `json:"total_amount"`
 	Status        OrderStatus  `json:"status"`
 	Items         []OrderItem  `json:"items" gorm:"foreignKey:OrderID"`
 	ShippingInfo  ShippingInfo `json:"shipping_info" gorm:"embedded"`
@@ -52,25 +54,25 @@ type Order struct {
 
 // OrderItem represents an item in an order
 type OrderItem struct {
-	ID        string  `json:"id" gorm:"primaryKey"`
-	OrderID   string  `json:"order_id" gorm:"index"`
-	ProductID string  `json:"product_id"`
-	Name      string  `json:"name"`
-	Price     float64 `json:"price"`
-	Quantity  int     `json:"quantity"`
-	Subtotal  float64 `json:"subtotal"`
+	ID        string      `json:"id" gorm:"primaryKey"`
+	OrderID   string      `json:"order_id" gorm:"index"`
+	ProductID string      `json:"product_id"`
+	Name      string      `json:"name"`
+	Price     money.Money `json:"price"`
+	Quantity  int         `json:"quantity"`
+	Subtotal  money.Money `json:"subtotal"`
 }
 
 // ShippingInfo represents shipping information for an order
 type ShippingInfo struct {
-	FullName     string `json:"full_name"`
-	AddressLine1 string `json:"address_line_1"`
-	AddressLine2 string `json:"address_line_2"`
-	City         string `json:"city"`
-	State        string `json:"state"`
-	PostalCode   string `json:"postal_code"`
-	Country      string `json:"country"`
-	PhoneNumber  string `json:"phone_number"`
+	FullName     string `json:"full_name" bson:"full_name" binding:"required"`
+	AddressLine1 string `json:"address_line_1" bson:"address_line_1" binding:"required"`
+	AddressLine2 string `json:"address_line_2" bson:"address_line_2"`
+	City         string `json:"city" bson:"city" binding:"required"`
+	State        string `json:"state" bson:"state"`
+	PostalCode   string `json:"postal_code" bson:"postal_code"`
+	Country      string `json:"country" bson:"country" binding:"required"`
+	PhoneNumber  string `json:"phone_number" bson:"phone_number"`
 }
 
 // PaymentInfo represents payment information for an order
@@ -93,7 +95,7 @@ type OrderItemRequest struct {
 }
 
 // NewOrder creates a new order
-func NewOrder(userID string, items []OrderItem, shippingInfo ShippingInfo, totalAmount float64) *Order {
+func NewOrder(userID string, items []OrderItem, shippingInfo ShippingInfo, totalAmount money.Money) *Order {
 	return &Order{
 		ID:            uuid.New().String(),
 		UserID:        userID,
@@ -108,7 +110,7 @@ func NewOrder(userID string, items []OrderItem, shippingInfo ShippingInfo, total
 }
 
 // NewOrderItem creates a new order item
-func NewOrderItem(orderID, productID, name string, price float64, quantity int) OrderItem {
+func NewOrderItem(orderID, productID, name string, price money.Money, quantity int) OrderItem {
 	return OrderItem{
 		ID:        uuid.New().String(),
 		OrderID:   orderID,
@@ -116,7 +118,7 @@ func NewOrderItem(orderID, productID, name string, price float64, quantity int)
 		Name:      name,
 		Price:     price,
 		Quantity:  quantity,
-		Subtotal:  price * float64(quantity),
+		Subtotal:  price.Multiply(int64(quantity)),
 	}
 }
 
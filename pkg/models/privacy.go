@@ -0,0 +1,77 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// States of a data subject request and of each service taking part in it
+const (
+	PrivacyPending   = "pending"
+	PrivacyCompleted = "completed"
+	PrivacyFailed    = "failed"
+)
+
+// PrivacyRequest is a request to export or erase the personal data of a user
+//
+// Every service holding user data reports its part of the work in Services,
+// keyed by service name.
+type PrivacyRequest struct {
+	ID          string                     `json:"id" bson:"_id"`
+	UserID      string                     `json:"user_id" bson:"user_id"`
+	Kind        string                     `json:"kind" bson:"kind"`
+	Status      string                     `json:"status" bson:"status"`
+	RequestedBy string                     `json:"requested_by" bson:"requested_by"`
+	Services    map[string]*PrivacyService `json:"services" bson:"services"`
+	CreatedAt   time.Time                  `json:"created_at" bson:"created_at"`
+	CompletedAt *time.Time                 `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	// ExportExpiresAt is when the exported data is removed from the request
+	ExportExpiresAt *time.Time `json:"export_expires_at,omitempty" bson:"export_expires_at,omitempty"`
+}
+
+// PrivacyService is the part of a data subject request handled by one service
+type PrivacyService struct {
+	Status      string     `json:"status" bson:"status"`
+	Error       string     `json:"error,omitempty" bson:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	// Data is the exported data of the service, only served by the export download
+	Data []byte `json:"-" bson:"data,omitempty"`
+}
+
+// Pending reports whether any service other than except has not finished its part yet
+func (r *PrivacyRequest) Pending(except string) bool {
+	for name, svc := range r.Services {
+		if name != except && svc.Status == PrivacyPending {
+			return true
+		}
+	}
+	return false
+}
+
+// Failed reports whether any service could not finish its part
+func (r *PrivacyRequest) Failed() bool {
+	for _, svc := range r.Services {
+		if svc.Status == PrivacyFailed {
+			return true
+		}
+	}
+	return false
+}
+
+// PrivacyRequestBody is the body of an administrator's data subject request
+type PrivacyRequestBody struct {
+	Kind string `json:"kind" binding:"required,oneof=export erasure"`
+}
+
+// ErasureRequest is the body of a user's request to delete their account
+type ErasureRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// DataExport is the bundle of a user's data collected from every service
+type DataExport struct {
+	RequestID   string                     `json:"request_id"`
+	UserID      string                     `json:"user_id"`
+	GeneratedAt time.Time                  `json:"generated_at"`
+	Services    map[string]json.RawMessage `json:"services"`
+}
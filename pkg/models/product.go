@@ -1,23 +1,48 @@
 package models
 
 import (
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
 )
 
 // Product represents a product in the system
+//
+// Price is the base price. Prices lists prices set for other currencies;
+// currencies without one are priced by converting the base price.
+// PriceConverted is set on responses whose Price was converted to the
+// currency selected by the request. TaxCategory selects the tax rate of the
+// product, such as reduced or exempt; empty is the standard rate. Weight and
+// Dimensions describe the packed product and are used to quote shipping.
 type Product struct {
-	ID          string    `json:"id" bson:"_id,omitempty"`
-	Name        string    `json:"name" bson:"name"`
-	Description string    `json:"description" bson:"description"`
-	Price       float64   `json:"price" bson:"price"`
-	SKU         string    `json:"sku" bson:"sku"`
-	CategoryID  string    `json:"category_id" bson:"category_id"`
-	Inventory   int       `json:"inventory" bson:"inventory"`
-	Images      []string  `json:"images" bson:"images"`
-	CreatedAt   time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at" bson:"updated_at"`
+	ID             string        `json:"id" bson:"_id,omitempty"`
+	Name           string        `json:"name" bson:"name"`
+	Description    string        `json:"description" bson:"description"`
+	Price          money.Money   `json:"price" bson:"price"`
+	Prices         []money.Money `json:"prices,omitempty" bson:"prices,omitempty"`
+	PriceConverted bool          `json:"price_converted,omitempty" bson:"-"`
+	SKU            string        `json:"sku" bson:"sku"`
+	CategoryID     string        `json:"category_id" bson:"category_id"`
+	TaxCategory    string        `json:"tax_category,omitempty" bson:"tax_category,omitempty"`
+	Weight         int           `json:"weight" bson:"weight"`
+	Dimensions     Dimensions    `json:"dimensions" bson:"dimensions"`
+	Inventory      int           `json:"inventory" bson:"inventory"`
+	Reserved       int           `json:"reserved" bson:"reserved"`
+	Holds          []StockHold   `json:"-" bson:"holds,omitempty"`
+	Images         []string      `json:"images" bson:"images"`
+	CreatedAt      time.Time     `json:"created_at" bson:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at" bson:"updated_at"`
+}
+
+// Dimensions is the packed size of a product in millimetres
+type Dimensions struct {
+	Length int `json:"length" bson:"length" binding:"gte=0"`
+	Width  int `json:"width" bson:"width" binding:"gte=0"`
+	Height int `json:"height" bson:"height" binding:"gte=0"`
 }
 
 // Category represents a product category
@@ -30,14 +55,40 @@ type Category struct {
 }
 
 // ProductRequest represents the data needed to create or update a product
+//
+// Price is given in minor units, e.g. {"amount": 19900, "currency": "TWD"}
+// for NT$199.00. Prices optionally sets prices in other currencies, at most
+// one per currency. Weight is in grams and Dimensions in millimetres.
 type ProductRequest struct {
-	Name        string   `json:"name" binding:"required"`
-	Description string   `json:"description" binding:"required"`
-	Price       float64  `json:"price" binding:"required,gt=0"`
-	SKU         string   `json:"sku" binding:"required"`
-	CategoryID  string   `json:"category_id" binding:"required"`
-	Inventory   int      `json:"inventory" binding:"required,gte=0"`
-	Images      []string `json:"images"`
+	Name        string        `json:"name" binding:"required"`
+	Description string        `json:"description" binding:"required"`
+	Price       money.Money   `json:"price" binding:"required"`
+	Prices      []money.Money `json:"prices"`
+	SKU         string        `json:"sku" binding:"required"`
+	CategoryID  string        `json:"category_id" binding:"required"`
+	TaxCategory string        `json:"tax_category" binding:"omitempty,max=50"`
+	Weight      int           `json:"weight" binding:"gte=0"`
+	Dimensions  Dimensions    `json:"dimensions"`
+	Inventory   int           `json:"inventory" binding:"required,gte=0"`
+	Images      []string      `json:"images"`
+}
+
+// Validate checks the fields that binding tags cannot express
+func (r ProductRequest) Validate() error {
+	seen := map[string]bool{}
+	for _, price := range append([]money.Money{r.Price}, r.Prices...) {
+		if err := price.Validate(); err != nil {
+			return err
+		}
+		if !price.IsPositive() {
+			return errors.New("價格必須大於 0")
+		}
+		if seen[price.Currency] {
+			return fmt.Errorf("%s 的價格重複", price.Currency)
+		}
+		seen[price.Currency] = true
+	}
+	return nil
 }
 
 // CategoryRequest represents the data needed to create or update a category
@@ -47,7 +98,7 @@ type CategoryRequest struct {
 }
 
 // NewProduct creates a new product
-func NewProduct(name, description string, price float64, sku, categoryID string, inventory int, images []string) *Product {
+func NewProduct(name, description string, price money.Money, sku, categoryID string, inventory int, images []string) *Product {
 	return &Product{
 		ID:          uuid.New().String(),
 		Name:        name,
@@ -78,13 +129,36 @@ func (p *Product) UpdateProduct(req ProductRequest) {
 	p.Name = req.Name
 	p.Description = req.Description
 	p.Price = req.Price
+	p.Prices = req.Prices
 	p.SKU = req.SKU
 	p.CategoryID = req.CategoryID
+	p.TaxCategory = req.TaxCategory
+	p.Weight = req.Weight
+	p.Dimensions = req.Dimensions
 	p.Inventory = req.Inventory
 	p.Images = req.Images
 	p.UpdatedAt = time.Now()
 }
 
+// ListPrice returns the price set for the currency, which is either the
+// base price or one of the other currency prices
+func (p *Product) ListPrice(currency string) (money.Money, bool) {
+	if p.Price.Currency == currency {
+		return p.Price, true
+	}
+	for _, price := range p.Prices {
+		if price.Currency == currency {
+			return price, true
+		}
+	}
+	return money.Money{}, false
+}
+
+// Available returns the inventory that is not held by any reservation
+func (p *Product) Available() int {
+	return p.Inventory - p.Reserved
+}
+
 // UpdateCategory updates a category with the provided data
 func (c *Category) UpdateCategory(req CategoryRequest) {
 	c.Name = req.Name
@@ -0,0 +1,138 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// Promotion types
+const (
+	// PromotionPercentage takes a percentage off the eligible items
+	PromotionPercentage = "percentage"
+	// PromotionFixed takes a fixed amount off the eligible items
+	PromotionFixed = "fixed"
+	// PromotionBuyXGetY gives the cheapest GetQuantity of every BuyQuantity+GetQuantity eligible units for free
+	PromotionBuyXGetY = "buy_x_get_y"
+	// PromotionFreeShipping waives the shipping fee
+	PromotionFreeShipping = "free_shipping"
+)
+
+// Promotion represents a discount redeemed with a coupon code
+type Promotion struct {
+	ID          string `json:"id" bson:"_id"`
+	Code        string `json:"code" bson:"code"`
+	Description string `json:"description" bson:"description"`
+	Type        string `json:"type" bson:"type"`
+	// Percent is the percentage off for percentage promotions
+	Percent float64 `json:"percent,omitempty" bson:"percent,omitempty"`
+	// Amount is the amount off for fixed promotions
+	Amount      money.Money `json:"amount,omitempty" bson:"amount,omitempty"`
+	BuyQuantity int         `json:"buy_quantity,omitempty" bson:"buy_quantity,omitempty"`
+	GetQuantity int         `json:"get_quantity,omitempty" bson:"get_quantity,omitempty"`
+	// CategoryIDs limits the promotion to products in these categories; empty means every product
+	CategoryIDs []string `json:"category_ids,omitempty" bson:"category_ids,omitempty"`
+	// MinSubtotal is the subtotal of the eligible items needed to use the promotion
+	MinSubtotal money.Money `json:"min_subtotal,omitempty" bson:"min_subtotal,omitempty"`
+	StartsAt    *time.Time  `json:"starts_at,omitempty" bson:"starts_at,omitempty"`
+	EndsAt      *time.Time  `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	// UsageLimit and PerUserLimit cap the number of redemptions; 0 means unlimited
+	UsageLimit   int       `json:"usage_limit" bson:"usage_limit"`
+	PerUserLimit int       `json:"per_user_limit" bson:"per_user_limit"`
+	UsageCount   int       `json:"usage_count" bson:"usage_count"`
+	Active       bool      `json:"active" bson:"active"`
+	CreatedAt    time.Time `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" bson:"updated_at"`
+}
+
+// PromotionRequest represents the data needed to create or update a promotion
+type PromotionRequest struct {
+	Code         string      `json:"code" binding:"required,max=32"`
+	Description  string      `json:"description"`
+	Type         string      `json:"type" binding:"required,oneof=percentage fixed buy_x_get_y free_shipping"`
+	Percent      float64     `json:"percent" binding:"gte=0,lte=100"`
+	Amount       money.Money `json:"amount"`
+	BuyQuantity  int         `json:"buy_quantity" binding:"gte=0"`
+	GetQuantity  int         `json:"get_quantity" binding:"gte=0"`
+	CategoryIDs  []string    `json:"category_ids"`
+	MinSubtotal  money.Money `json:"min_subtotal"`
+	StartsAt     *time.Time  `json:"starts_at"`
+	EndsAt       *time.Time  `json:"ends_at"`
+	UsageLimit   int         `json:"usage_limit" binding:"gte=0"`
+	PerUserLimit int         `json:"per_user_limit" binding:"gte=0"`
+	Active       bool        `json:"active"`
+}
+
+// ApplyCouponRequest represents the data needed to apply a coupon code to a cart
+type ApplyCouponRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// DiscountLine is the discount a promotion gives to a cart or order
+type DiscountLine struct {
+	PromotionID string      `json:"promotion_id" bson:"promotion_id"`
+	Code        string      `json:"code" bson:"code"`
+	Type        string      `json:"type" bson:"type"`
+	Description string      `json:"description" bson:"description"`
+	Amount      money.Money `json:"amount" bson:"amount"`
+}
+
+// RejectedCoupon is a coupon code on a cart that does not currently apply
+type RejectedCoupon struct {
+	Code   string `json:"code"`
+	Reason string `json:"reason"`
+}
+
+// NormalizeCouponCode returns the canonical form of a coupon code
+func NormalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// NewPromotion creates a new promotion
+func NewPromotion(req PromotionRequest) *Promotion {
+	now := time.Now()
+	promotion := &Promotion{
+		ID:        uuid.New().String(),
+		CreatedAt: now,
+	}
+	promotion.UpdatePromotion(req)
+	promotion.UpdatedAt = now
+	return promotion
+}
+
+// UpdatePromotion updates a promotion with the provided data
+//
+// The usage count is kept so that lowering a limit does not reset redemptions.
+func (p *Promotion) UpdatePromotion(req PromotionRequest) {
+	p.Code = NormalizeCouponCode(req.Code)
+	p.Description = req.Description
+	p.Type = req.Type
+	p.Percent = req.Percent
+	p.Amount = req.Amount
+	p.BuyQuantity = req.BuyQuantity
+	p.GetQuantity = req.GetQuantity
+	p.CategoryIDs = req.CategoryIDs
+	p.MinSubtotal = req.MinSubtotal
+	p.StartsAt = req.StartsAt
+	p.EndsAt = req.EndsAt
+	p.UsageLimit = req.UsageLimit
+	p.PerUserLimit = req.PerUserLimit
+	p.Active = req.Active
+	p.UpdatedAt = time.Now()
+}
+
+// AppliesToCategory checks if products of the category are eligible
+func (p *Promotion) AppliesToCategory(categoryID string) bool {
+	if len(p.CategoryIDs) == 0 {
+		return true
+	}
+	for _, id := range p.CategoryIDs {
+		if id == categoryID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+)
+
+// RefreshToken represents a stored refresh token
+//
+// Only the SHA-256 hash of the opaque token is stored. Every refresh rotates
+// the token: the old one is marked used and a new one is issued in the same
+// family. Presenting a used token again means it was leaked, so the whole
+// family is revoked.
+type RefreshToken struct {
+	ID         string     `json:"id" bson:"_id"`
+	TokenHash  string     `json:"-" bson:"token_hash"`
+	UserID     string     `json:"user_id" bson:"user_id"`
+	FamilyID   string     `json:"family_id" bson:"family_id"`
+	ExpiresAt  time.Time  `json:"expires_at" bson:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at" bson:"created_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty" bson:"used_at,omitempty"`
+	ReplacedBy string     `json:"replaced_by,omitempty" bson:"replaced_by,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" bson:"revoked_at,omitempty"`
+}
+
+// IsActive checks if the refresh token can still be exchanged
+func (t *RefreshToken) IsActive(now time.Time) bool {
+	return t.UsedAt == nil && t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// TokenPair represents the tokens returned on login and refresh
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// RefreshRequest represents the data needed to refresh an access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// Purposes of action tokens
+const (
+	TokenPurposeEmailVerification = "email_verification"
+	TokenPurposePasswordReset     = "password_reset"
+	TokenPurposeTwoFactorLogin    = "two_factor_login"
+)
+
+// ActionToken represents a single-use token sent by email to verify an
+// address or reset a password
+//
+// Like refresh tokens, only the SHA-256 hash of the token is stored.
+type ActionToken struct {
+	ID        string     `json:"id" bson:"_id"`
+	TokenHash string     `json:"-" bson:"token_hash"`
+	UserID    string     `json:"user_id" bson:"user_id"`
+	Purpose   string     `json:"purpose" bson:"purpose"`
+	ExpiresAt time.Time  `json:"expires_at" bson:"expires_at"`
+	CreatedAt time.Time  `json:"created_at" bson:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" bson:"used_at,omitempty"`
+}
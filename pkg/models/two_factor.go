@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+)
+
+// TwoFactor holds the TOTP two-factor authentication settings of a user
+type TwoFactor struct {
+	Enabled bool `bson:"enabled"`
+	// Secret is the confirmed TOTP secret, PendingSecret one that has been
+	// issued but not confirmed with a code yet
+	Secret        string `bson:"secret,omitempty"`
+	PendingSecret string `bson:"pending_secret,omitempty"`
+	// RecoveryCodes are SHA-256 hashes of the unused recovery codes
+	RecoveryCodes []string `bson:"recovery_codes,omitempty"`
+	// LastUsedStep is the time step of the last accepted code, older or
+	// equal steps are rejected so a code cannot be replayed
+	LastUsedStep int64      `bson:"last_used_step,omitempty"`
+	EnabledAt    *time.Time `bson:"enabled_at,omitempty"`
+}
+
+// TwoFactorPolicy lists the roles that must use two-factor authentication
+type TwoFactorPolicy struct {
+	RequiredRoles []string  `json:"required_roles" bson:"required_roles"`
+	UpdatedAt     time.Time `json:"updated_at" bson:"updated_at"`
+	UpdatedBy     string    `json:"updated_by,omitempty" bson:"updated_by,omitempty"`
+}
+
+// TOTPEnrollment is returned when a TOTP secret is issued
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// TwoFactorChallenge is returned by login when a second factor is needed
+//
+// If EnrollmentRequired is set, a role of the user requires two-factor
+// authentication that the user has not set up yet. Enrollment holds a new
+// secret that the first verified code confirms.
+type TwoFactorChallenge struct {
+	ChallengeToken     string          `json:"challenge_token"`
+	ExpiresIn          int             `json:"expires_in"`
+	EnrollmentRequired bool            `json:"enrollment_required"`
+	Enrollment         *TOTPEnrollment `json:"enrollment,omitempty"`
+}
+
+// TwoFactorVerifyRequest represents the data needed to complete a two-factor login
+type TwoFactorVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	// Code is a TOTP code or a recovery code
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorCodeRequest represents a request confirmed with a TOTP or recovery code
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorDisableRequest represents the data needed to turn off two-factor authentication
+type TwoFactorDisableRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// TwoFactorPolicyRequest represents the data needed to change the two-factor policy
+type TwoFactorPolicyRequest struct {
+	RequiredRoles []string `json:"required_roles"`
+}
@@ -9,15 +9,19 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID        string    `json:"id" gorm:"primaryKey" bson:"_id,omitempty"`
-	Email     string    `json:"email" gorm:"uniqueIndex" bson:"email"`
-	Password  string    `json:"-" bson:"password"` // Password is not exposed in JSON
-	FirstName string    `json:"first_name" bson:"first_name"`
-	LastName  string    `json:"last_name" bson:"last_name"`
-	Role      string    `json:"role" bson:"role"`
-	Active    bool      `json:"active" bson:"active"`
-	CreatedAt time.Time `json:"created_at" bson:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	ID              string     `json:"id" gorm:"primaryKey" bson:"_id,omitempty"`
+	Email           string     `json:"email" gorm:"uniqueIndex" bson:"email"`
+	Password        string     `json:"-" bson:"password"` // Password is not exposed in JSON
+	FirstName       string     `json:"first_name" bson:"first_name"`
+	LastName        string     `json:"last_name" bson:"last_name"`
+	Role            string     `json:"role" bson:"role"`
+	Roles           []string   `json:"roles,omitempty" bson:"roles,omitempty"` // Roles granted by an administrator
+	Active          bool       `json:"active" bson:"active"`
+	EmailVerified   bool       `json:"email_verified" bson:"email_verified"` // Set once the user follows the verification link
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty" bson:"email_verified_at,omitempty"`
+	TwoFactor       *TwoFactor `json:"-" bson:"two_factor,omitempty"`
+	CreatedAt       time.Time  `json:"created_at" bson:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" bson:"updated_at"`
 }
 
 // UserRegistration represents the data needed to register a user
@@ -34,14 +38,38 @@ type UserLogin struct {
 	Password string `json:"password" binding:"required"`
 }
 
+// EmailRequest represents a request that only carries an email address
+type EmailRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// VerifyEmailRequest represents the data needed to verify an email address
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// ResetPasswordRequest represents the data needed to reset a forgotten password
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=8"`
+}
+
 // UserResponse represents the data returned when a user is requested
 type UserResponse struct {
-	ID        string    `json:"id"`
-	Email     string    `json:"email"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID               string    `json:"id"`
+	Email            string    `json:"email"`
+	FirstName        string    `json:"first_name"`
+	LastName         string    `json:"last_name"`
+	Role             string    `json:"role"`
+	Roles            []string  `json:"roles"`
+	EmailVerified    bool      `json:"email_verified"`
+	TwoFactorEnabled bool      `json:"two_factor_enabled"`
+	CreatedAt        time.Time `json:"created_at"`
+}
+
+// RoleAssignment represents the data needed to grant a role to a user
+type RoleAssignment struct {
+	Role string `json:"role" binding:"required"`
 }
 
 // NewUser creates a new user
@@ -68,6 +96,17 @@ func NewUser(email, password, firstName, lastName string) (*User, error) {
 	return user, nil
 }
 
+// SetPassword hashes and sets a new password
+func (u *User) SetPassword(password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	u.Password = string(hashedPassword)
+	return nil
+}
+
 // CheckPassword checks if the provided password matches the user's password
 func (u *User) CheckPassword(password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password))
@@ -77,11 +116,33 @@ func (u *User) CheckPassword(password string) bool {
 // ToResponse converts a User to a UserResponse
 func (u *User) ToResponse() UserResponse {
 	return UserResponse{
-		ID:        u.ID,
-		Email:     u.Email,
-		FirstName: u.FirstName,
-		LastName:  u.LastName,
-		Role:      u.Role,
-		CreatedAt: u.CreatedAt,
+		ID:               u.ID,
+		Email:            u.Email,
+		FirstName:        u.FirstName,
+		LastName:         u.LastName,
+		Role:             u.Role,
+		Roles:            u.RoleNames(),
+		EmailVerified:    u.EmailVerified,
+		TwoFactorEnabled: u.TwoFactorEnabled(),
+		CreatedAt:        u.CreatedAt,
+	}
+}
+
+// TwoFactorEnabled checks if the user has confirmed TOTP two-factor authentication
+func (u *User) TwoFactorEnabled() bool {
+	return u.TwoFactor != nil && u.TwoFactor.Enabled
+}
+
+// RoleNames returns all roles of the user, starting with the primary role
+func (u *User) RoleNames() []string {
+	roles := make([]string, 0, len(u.Roles)+1)
+	if u.Role != "" {
+		roles = append(roles, u.Role)
+	}
+	for _, role := range u.Roles {
+		if role != u.Role {
+			roles = append(roles, role)
+		}
 	}
+	return roles
 }
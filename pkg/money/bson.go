@@ -0,0 +1,48 @@
+package money
+
+import (
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/bsontype"
+)
+
+// UnmarshalBSONValue decodes an amount stored as an {amount, currency} document
+//
+// Documents written before this package existed stored prices as numbers in
+// major units; those are read as amounts in DefaultCurrency.
+func (m *Money) UnmarshalBSONValue(t bsontype.Type, data []byte) error {
+	raw := bson.RawValue{Type: t, Value: data}
+
+	var legacy float64
+	switch t {
+	case bsontype.EmbeddedDocument:
+		var doc struct {
+			Amount   int64  `bson:"amount"`
+			Currency string `bson:"currency"`
+		}
+		if err := raw.Unmarshal(&doc); err != nil {
+			return err
+		}
+		*m = New(doc.Amount, doc.Currency)
+		return nil
+	case bsontype.Null, bsontype.Undefined:
+		*m = Money{}
+		return nil
+	case bsontype.Double:
+		legacy = raw.Double()
+	case bsontype.Int32:
+		legacy = float64(raw.Int32())
+	case bsontype.Int64:
+		legacy = float64(raw.Int64())
+	default:
+		return fmt.Errorf("%w: 無法解析 BSON %s 為金額", ErrInvalidAmount, t)
+	}
+
+	converted, err := FromFloat(legacy, DefaultCurrency)
+	if err != nil {
+		return err
+	}
+	*m = converted
+	return nil
+}
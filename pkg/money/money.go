@@ -0,0 +1,381 @@
+// Package money represents monetary amounts exactly as an integer number of
+// minor units (cents for USD, for example) of an ISO 4217 currency.
+//
+// Amounts of different currencies are never combined implicitly: arithmetic
+// between them returns ErrCurrencyMismatch.
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency of amounts that were stored before
+// currencies were recorded, and of prices that do not specify one
+var DefaultCurrency = "TWD"
+
+// Errors returned by the package
+var (
+	ErrCurrencyMismatch = errors.New("幣別不同的金額無法計算")
+	ErrUnknownCurrency  = errors.New("不支援的幣別")
+	ErrInvalidAmount    = errors.New("無效的金額")
+)
+
+// minorUnits is the number of decimal places of the minor unit of each
+// supported currency, as defined by ISO 4217
+var minorUnits = map[string]int{
+	"AUD": 2,
+	"CAD": 2,
+	"CHF": 2,
+	"CNY": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"HKD": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"KWD": 3,
+	"MYR": 2,
+	"SGD": 2,
+	"THB": 2,
+	"TWD": 2,
+	"USD": 2,
+	"VND": 0,
+}
+
+// MinorUnits returns the number of decimal places of the currency's minor unit
+func MinorUnits(currency string) (int, error) {
+	units, ok := minorUnits[currency]
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownCurrency, currency)
+	}
+	return units, nil
+}
+
+// IsKnownCurrency checks if the currency is supported
+func IsKnownCurrency(currency string) bool {
+	_, ok := minorUnits[currency]
+	return ok
+}
+
+// RoundingMode decides how an amount between two minor units is rounded
+type RoundingMode int
+
+const (
+	// HalfUp rounds to the nearest minor unit, and halves away from zero
+	HalfUp RoundingMode = iota
+	// HalfEven rounds to the nearest minor unit, and halves to the even one (banker's rounding)
+	HalfEven
+	// Down rounds towards zero
+	Down
+	// Up rounds away from zero
+	Up
+)
+
+// Money is an amount in minor units of a currency
+type Money struct {
+	Amount   int64  `json:"amount" bson:"amount"`
+	Currency string `json:"currency" bson:"currency"`
+}
+
+// New creates an amount from minor units
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: currency}
+}
+
+// Zero returns a zero amount of the currency
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// Parse parses a decimal amount in major units, such as "12.34"
+//
+// It fails if the amount has more decimal places than the currency's minor unit.
+func Parse(amount, currency string) (Money, error) {
+	units, err := MinorUnits(currency)
+	if err != nil {
+		return Money{}, err
+	}
+
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(amount))
+	if !ok {
+		return Money{}, fmt.Errorf("%w: %q", ErrInvalidAmount, amount)
+	}
+	r.Mul(r, new(big.Rat).SetInt(pow10(units)))
+	if !r.IsInt() || !r.Num().IsInt64() {
+		return Money{}, fmt.Errorf("%w: %q 超過 %s 的精確度", ErrInvalidAmount, amount, currency)
+	}
+	return New(r.Num().Int64(), currency), nil
+}
+
+// FromFloat converts an amount in major units, rounding half up to the minor unit
+//
+// It is meant for values that were stored as floating point before this
+// package existed; new amounts should be created with New or Parse.
+func FromFloat(amount float64, currency string) (Money, error) {
+	units, err := MinorUnits(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return Money{}, fmt.Errorf("%w: %v", ErrInvalidAmount, amount)
+	}
+	// Format first so that 0.1+0.2 style binary errors do not leak into the result
+	r, _ := new(big.Rat).SetString(strconv.FormatFloat(amount, 'f', -1, 64))
+	return fromRat(r, units, currency, HalfUp), nil
+}
+
+// Validate checks that the amount has a supported currency
+func (m Money) Validate() error {
+	_, err := MinorUnits(m.Currency)
+	return err
+}
+
+// IsZero checks if the amount is zero
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive checks if the amount is greater than zero
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// IsNegative checks if the amount is less than zero
+func (m Money) IsNegative() bool {
+	return m.Amount < 0
+}
+
+// SameCurrency checks if two amounts have the same currency
+func (m Money) SameCurrency(other Money) bool {
+	return m.Currency == other.Currency
+}
+
+// Add returns m + other
+func (m Money) Add(other Money) (Money, error) {
+	if err := m.check(other); err != nil {
+		return Money{}, err
+	}
+	return New(m.Amount+other.Amount, m.Currency), nil
+}
+
+// Sub returns m - other
+func (m Money) Sub(other Money) (Money, error) {
+	if err := m.check(other); err != nil {
+		return Money{}, err
+	}
+	return New(m.Amount-other.Amount, m.Currency), nil
+}
+
+// Cmp compares two amounts, returning -1, 0 or +1
+func (m Money) Cmp(other Money) (int, error) {
+	if err := m.check(other); err != nil {
+		return 0, err
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// Min returns the smaller of two amounts
+func (m Money) Min(other Money) (Money, error) {
+	cmp, err := m.Cmp(other)
+	if err != nil {
+		return Money{}, err
+	}
+	if cmp <= 0 {
+		return m, nil
+	}
+	return other, nil
+}
+
+// Neg returns -m
+func (m Money) Neg() Money {
+	return New(-m.Amount, m.Currency)
+}
+
+// Multiply returns the amount times an integer quantity
+func (m Money) Multiply(quantity int64) Money {
+	return New(m.Amount*quantity, m.Currency)
+}
+
+// MulRat returns the amount times a rational factor, rounded to the minor unit
+func (m Money) MulRat(factor *big.Rat, mode RoundingMode) Money {
+	r := new(big.Rat).SetInt64(m.Amount)
+	r.Mul(r, factor)
+	return fromRat(r, 0, m.Currency, mode)
+}
+
+// Percent returns percent% of the amount, rounded to the minor unit
+func (m Money) Percent(percent float64, mode RoundingMode) Money {
+	factor, _ := new(big.Rat).SetString(strconv.FormatFloat(percent, 'f', -1, 64))
+	return m.MulRat(factor.Quo(factor, big.NewRat(100, 1)), mode)
+}
+
+// Convert converts the amount to another currency at an exchange rate
+//
+// The rate is the number of major units of currency that one major unit of
+// the amount buys, so the result is rounded to the minor unit of currency
+// even if the two currencies have different minor units.
+func (m Money) Convert(currency string, rate *big.Rat, mode RoundingMode) (Money, error) {
+	from, err := MinorUnits(m.Currency)
+	if err != nil {
+		return Money{}, err
+	}
+	to, err := MinorUnits(currency)
+	if err != nil {
+		return Money{}, err
+	}
+	if rate.Sign() <= 0 {
+		return Money{}, fmt.Errorf("%w: 匯率需大於 0", ErrInvalidAmount)
+	}
+
+	major := new(big.Rat).SetFrac(big.NewInt(m.Amount), pow10(from))
+	return fromRat(major.Mul(major, rate), to, currency, mode), nil
+}
+
+// Allocate splits the amount in proportion to the ratios without losing minor units
+//
+// The minor units left over after rounding every share down are handed out
+// one at a time, starting with the shares that lost the largest fraction, so
+// the shares always add up to the amount. Ratios must not be negative and at
+// least one must be positive.
+func (m Money) Allocate(ratios ...int64) ([]Money, error) {
+	var total int64
+	for _, ratio := range ratios {
+		if ratio < 0 {
+			return nil, fmt.Errorf("%w: 分配比例不能為負數", ErrInvalidAmount)
+		}
+		total += ratio
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("%w: 分配比例總和為 0", ErrInvalidAmount)
+	}
+
+	shares := make([]Money, len(ratios))
+	remainders := make([]*big.Rat, len(ratios))
+	left := m.Amount
+	for i, ratio := range ratios {
+		exact := new(big.Rat).SetFrac(new(big.Int).Mul(big.NewInt(m.Amount), big.NewInt(ratio)), big.NewInt(total))
+		share := fromRat(exact, 0, m.Currency, Down)
+		shares[i] = share
+		remainders[i] = exact.Sub(exact, new(big.Rat).SetInt64(share.Amount))
+		left -= share.Amount
+	}
+
+	// left has the sign of the amount and is smaller than the number of shares
+	step := int64(1)
+	if left < 0 {
+		step = -1
+	}
+	for left != 0 {
+		best := -1
+		for i := range shares {
+			if ratios[i] == 0 {
+				continue
+			}
+			if best < 0 || remainders[i].Cmp(remainders[best])*int(step) > 0 {
+				best = i
+			}
+		}
+		shares[best].Amount += step
+		remainders[best] = new(big.Rat)
+		left -= step
+	}
+	return shares, nil
+}
+
+// Split divides the amount into n shares that differ by at most one minor unit
+func (m Money) Split(n int) ([]Money, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("%w: 分割數量需大於 0", ErrInvalidAmount)
+	}
+	ratios := make([]int64, n)
+	for i := range ratios {
+		ratios[i] = 1
+	}
+	return m.Allocate(ratios...)
+}
+
+// Decimal formats the amount in major units, such as "12.34"
+func (m Money) Decimal() string {
+	units, ok := minorUnits[m.Currency]
+	if !ok || units == 0 {
+		return strconv.FormatInt(m.Amount, 10)
+	}
+
+	sign := ""
+	amount := m.Amount
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := fmt.Sprintf("%0*d", units+1, amount)
+	return sign + digits[:len(digits)-units] + "." + digits[len(digits)-units:]
+}
+
+// String formats the amount with its currency, such as "USD 12.34"
+func (m Money) String() string {
+	return m.Currency + " " + m.Decimal()
+}
+
+// check returns ErrCurrencyMismatch unless both amounts have the same currency
+func (m Money) check(other Money) error {
+	if m.Currency != other.Currency {
+		return fmt.Errorf("%w: %s 與 %s", ErrCurrencyMismatch, m.Currency, other.Currency)
+	}
+	return nil
+}
+
+// Sum adds up amounts of the currency; an empty list sums to zero
+func Sum(currency string, amounts ...Money) (Money, error) {
+	total := Zero(currency)
+	for _, amount := range amounts {
+		var err error
+		if total, err = total.Add(amount); err != nil {
+			return Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// fromRat rounds r, scaled by 10^units, to an integer number of minor units
+func fromRat(r *big.Rat, units int, currency string, mode RoundingMode) Money {
+	scaled := new(big.Rat).Mul(r, new(big.Rat).SetInt(pow10(units)))
+	num, den := scaled.Num(), scaled.Denom()
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if rem.Sign() != 0 {
+		// Compare twice the remainder with the denominator to find the nearest unit
+		half := new(big.Int).Abs(rem)
+		half.Mul(half, big.NewInt(2))
+		cmp := half.Cmp(den)
+
+		away := false
+		switch mode {
+		case HalfUp:
+			away = cmp >= 0
+		case HalfEven:
+			away = cmp > 0 || (cmp == 0 && quo.Bit(0) == 1)
+		case Up:
+			away = true
+		case Down:
+		}
+		if away {
+			quo.Add(quo, big.NewInt(int64(num.Sign())))
+		}
+	}
+	return New(quo.Int64(), currency)
+}
+
+// pow10 returns 10^n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
@@ -0,0 +1,265 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     Money
+		wantErr  error
+	}{
+		{amount: "12.34", currency: "USD", want: New(1234, "USD")},
+		{amount: " 7.1 ", currency: "TWD", want: New(710, "TWD")},
+		{amount: "-3.10", currency: "TWD", want: New(-310, "TWD")},
+		{amount: "12", currency: "JPY", want: New(12, "JPY")},
+		{amount: "1.234", currency: "KWD", want: New(1234, "KWD")},
+		{amount: "12.5", currency: "JPY", wantErr: ErrInvalidAmount},
+		{amount: "0.001", currency: "USD", wantErr: ErrInvalidAmount},
+		{amount: "abc", currency: "USD", wantErr: ErrInvalidAmount},
+		{amount: "1.00", currency: "XXX", wantErr: ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.currency+" "+tt.amount, func(t *testing.T) {
+			got, err := Parse(tt.amount, tt.currency)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Parse() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFromFloat(t *testing.T) {
+	tests := []struct {
+		amount   float64
+		currency string
+		want     Money
+		wantErr  error
+	}{
+		{amount: 0.1 + 0.2, currency: "USD", want: New(30, "USD")},
+		{amount: 1.005, currency: "USD", want: New(101, "USD")},
+		{amount: 999.99, currency: "TWD", want: New(99999, "TWD")},
+		{amount: 1234.5, currency: "JPY", want: New(1235, "JPY")},
+		{amount: -1234.5, currency: "JPY", want: New(-1235, "JPY")},
+		{amount: math.NaN(), currency: "USD", wantErr: ErrInvalidAmount},
+		{amount: math.Inf(1), currency: "USD", wantErr: ErrInvalidAmount},
+		{amount: 1, currency: "XXX", wantErr: ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.currency, " ", tt.amount), func(t *testing.T) {
+			got, err := FromFloat(tt.amount, tt.currency)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("FromFloat() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("FromFloat() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRounding(t *testing.T) {
+	// Every case multiplies the amount by one tenth, so 25 is exactly half way
+	tests := []struct {
+		amount int64
+		mode   RoundingMode
+		want   int64
+	}{
+		{amount: 30, mode: HalfUp, want: 3},
+		{amount: 30, mode: Up, want: 3},
+		{amount: 24, mode: HalfUp, want: 2},
+		{amount: 25, mode: HalfUp, want: 3},
+		{amount: -25, mode: HalfUp, want: -3},
+		{amount: 25, mode: HalfEven, want: 2},
+		{amount: 35, mode: HalfEven, want: 4},
+		{amount: -25, mode: HalfEven, want: -2},
+		{amount: -35, mode: HalfEven, want: -4},
+		{amount: 26, mode: HalfEven, want: 3},
+		{amount: 29, mode: Down, want: 2},
+		{amount: -29, mode: Down, want: -2},
+		{amount: 21, mode: Up, want: 3},
+		{amount: -21, mode: Up, want: -3},
+	}
+
+	names := map[RoundingMode]string{HalfUp: "HalfUp", HalfEven: "HalfEven", Down: "Down", Up: "Up"}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %d", names[tt.mode], tt.amount), func(t *testing.T) {
+			got := New(tt.amount, "TWD").MulRat(big.NewRat(1, 10), tt.mode)
+			if got != New(tt.want, "TWD") {
+				t.Errorf("MulRat() = %d, want %d", got.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		amount  Money
+		percent float64
+		want    Money
+	}{
+		{amount: New(160000, "TWD"), percent: 10, want: New(16000, "TWD")},
+		{amount: New(160000, "TWD"), percent: 33.333, want: New(53333, "TWD")},
+		{amount: New(999, "JPY"), percent: 5, want: New(50, "JPY")},
+		{amount: New(1, "USD"), percent: 100, want: New(1, "USD")},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.percent, "% of ", tt.amount), func(t *testing.T) {
+			if got := tt.amount.Percent(tt.percent, HalfUp); got != tt.want {
+				t.Errorf("Percent() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  int64
+		ratios  []int64
+		want    []int64
+		wantErr bool
+	}{
+		{name: "even split hands the leftover to the first share", amount: 100, ratios: []int64{1, 1, 1}, want: []int64{34, 33, 33}},
+		{name: "leftover goes to the largest remainder", amount: 100, ratios: []int64{1, 2}, want: []int64{33, 67}},
+		{name: "negative amount", amount: -100, ratios: []int64{1, 1, 1}, want: []int64{-34, -33, -33}},
+		{name: "zero ratio gets nothing", amount: 5, ratios: []int64{0, 1, 1}, want: []int64{0, 3, 2}},
+		{name: "fewer units than shares", amount: 1, ratios: []int64{1, 1, 1, 1}, want: []int64{1, 0, 0, 0}},
+		{name: "proportional to prices", amount: 1000, ratios: []int64{99999, 60000}, want: []int64{625, 375}},
+		{name: "negative ratio", amount: 100, ratios: []int64{1, -1}, wantErr: true},
+		{name: "all ratios zero", amount: 100, ratios: []int64{0, 0}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shares, err := New(tt.amount, "TWD").Allocate(tt.ratios...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Allocate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidAmount) {
+					t.Errorf("Allocate() error = %v, want ErrInvalidAmount", err)
+				}
+				return
+			}
+
+			got := make([]int64, len(shares))
+			var sum int64
+			for i, share := range shares {
+				if share.Currency != "TWD" {
+					t.Errorf("share %d in %s, want TWD", i, share.Currency)
+				}
+				got[i] = share.Amount
+				sum += share.Amount
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("Allocate() = %v, want %v", got, tt.want)
+			}
+			if sum != tt.amount {
+				t.Errorf("shares add up to %d, want %d", sum, tt.amount)
+			}
+		})
+	}
+}
+
+func TestSplit(t *testing.T) {
+	shares, err := New(1000, "USD").Split(3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(shares) != "[USD 3.34 USD 3.33 USD 3.33]" {
+		t.Errorf("Split(3) = %v", shares)
+	}
+
+	if _, err := New(1000, "USD").Split(0); !errors.Is(err, ErrInvalidAmount) {
+		t.Errorf("Split(0) error = %v, want ErrInvalidAmount", err)
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		name     string
+		amount   Money
+		currency string
+		rate     *big.Rat
+		mode     RoundingMode
+		want     Money
+		wantErr  error
+	}{
+		{name: "to a currency without minor units", amount: New(1000, "USD"), currency: "JPY", rate: big.NewRat(1505, 10), want: New(1505, "JPY")},
+		{name: "from a currency without minor units", amount: New(1000, "JPY"), currency: "USD", rate: big.NewRat(67, 10000), want: New(670, "USD")},
+		{name: "to a currency with three decimals", amount: New(10000, "TWD"), currency: "KWD", rate: big.NewRat(95, 10000), want: New(950, "KWD")},
+		{name: "rounds half up", amount: New(1, "USD"), currency: "JPY", rate: big.NewRat(1505, 10), mode: HalfUp, want: New(2, "JPY")},
+		{name: "rounds down", amount: New(1, "USD"), currency: "JPY", rate: big.NewRat(1505, 10), mode: Down, want: New(1, "JPY")},
+		{name: "zero rate", amount: New(100, "USD"), currency: "JPY", rate: new(big.Rat), wantErr: ErrInvalidAmount},
+		{name: "unknown target", amount: New(100, "USD"), currency: "XXX", rate: big.NewRat(1, 1), wantErr: ErrUnknownCurrency},
+		{name: "unknown source", amount: New(100, "XXX"), currency: "USD", rate: big.NewRat(1, 1), wantErr: ErrUnknownCurrency},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.amount.Convert(tt.currency, tt.rate, tt.mode)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Convert() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Convert() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestArithmeticRequiresSameCurrency(t *testing.T) {
+	usd, twd := New(100, "USD"), New(100, "TWD")
+
+	if _, err := usd.Add(twd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Add() error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := usd.Sub(twd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sub() error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := usd.Cmp(twd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Cmp() error = %v, want ErrCurrencyMismatch", err)
+	}
+	if _, err := Sum("USD", usd, twd); !errors.Is(err, ErrCurrencyMismatch) {
+		t.Errorf("Sum() error = %v, want ErrCurrencyMismatch", err)
+	}
+
+	total, err := Sum("USD", usd, New(250, "USD"))
+	if err != nil || total != New(350, "USD") {
+		t.Errorf("Sum() = %v, %v, want USD 3.50", total, err)
+	}
+}
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		amount Money
+		want   string
+	}{
+		{amount: New(1234, "USD"), want: "USD 12.34"},
+		{amount: New(-5, "USD"), want: "USD -0.05"},
+		{amount: New(1234, "JPY"), want: "JPY 1234"},
+		{amount: New(1, "KWD"), want: "KWD 0.001"},
+		{amount: New(0, "TWD"), want: "TWD 0.00"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.amount.String(); got != tt.want {
+				t.Errorf("String() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,17 @@
+package moneypb
+
+import "github.com/arrontsai/ecommerce/pkg/money"
+
+// FromMoney converts an amount to its protobuf message
+func FromMoney(m money.Money) *Money {
+	return &Money{Amount: m.Amount, Currency: m.Currency}
+}
+
+// ToMoney converts the message to an amount; a nil message is an amount of zero
+// without a currency
+func (x *Money) ToMoney() money.Money {
+	if x == nil {
+		return money.Money{}
+	}
+	return money.New(x.Amount, x.Currency)
+}
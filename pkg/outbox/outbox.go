@@ -0,0 +1,112 @@
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Message 一筆待發布的外寄事件
+type Message struct {
+	ID        string    `json:"id" db:"id" bson:"_id"`
+	Topic     string    `json:"topic" db:"topic" bson:"topic"`
+	Key       string    `json:"key" db:"message_key" bson:"key"`
+	Payload   []byte    `json:"payload" db:"payload" bson:"payload"`
+	Attempts  int       `json:"attempts" db:"attempts" bson:"attempts"`
+	CreatedAt time.Time `json:"created_at" db:"created_at" bson:"created_at"`
+}
+
+// Store 外寄事件儲存介面，由各服務的資料庫實作
+type Store interface {
+	// Pending 依寫入順序取出尚未發布的事件
+	Pending(ctx context.Context, limit int) ([]Message, error)
+	// MarkSent 標記事件已成功發布
+	MarkSent(ctx context.Context, id string) error
+	// MarkFailed 記錄一次發布失敗
+	MarkFailed(ctx context.Context, id string, cause error) error
+}
+
+// Publisher 將事件發布到消息代理，messaging.KafkaClient 實作此介面
+type Publisher interface {
+	PublishMessage(ctx context.Context, topic string, key string, message []byte) error
+}
+
+// Relay 定期將外寄事件發布到消息代理
+//
+// 事件在發布成功後才標記為已發送，若在兩者之間當機，重啟後會再次發布，
+// 因此保證至少一次送達，消費者需自行處理重複事件。
+type Relay struct {
+	store     Store
+	publisher Publisher
+	logger    *zap.Logger
+	interval  time.Duration
+	batchSize int
+}
+
+// NewRelay 創建外寄事件轉發器
+func NewRelay(store Store, publisher Publisher, logger *zap.Logger) *Relay {
+	return &Relay{
+		store:     store,
+		publisher: publisher,
+		logger:    logger,
+		interval:  time.Second,
+		batchSize: 100,
+	}
+}
+
+// Run 持續轉發外寄事件，直到 ctx 被取消
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		// 一批取滿時代表可能還有積壓，立即處理下一批
+		for ctx.Err() == nil && r.Flush(ctx) == r.batchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			r.logger.Info("停止轉發外寄事件")
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush 發布一批待發送的事件，回傳成功發布的數量
+//
+// 遇到發布失敗時會停止本批次，以保留同一主題內的事件順序。
+func (r *Relay) Flush(ctx context.Context) int {
+	messages, err := r.store.Pending(ctx, r.batchSize)
+	if err != nil {
+		r.logger.Error("讀取外寄事件失敗", zap.Error(err))
+		return 0
+	}
+
+	sent := 0
+	for _, msg := range messages {
+		if err := r.publisher.PublishMessage(ctx, msg.Topic, msg.Key, msg.Payload); err != nil {
+			r.logger.Error("發布外寄事件失敗",
+				zap.String("id", msg.ID),
+				zap.String("topic", msg.Topic),
+				zap.Int("attempts", msg.Attempts+1),
+				zap.Error(err),
+			)
+			if markErr := r.store.MarkFailed(ctx, msg.ID, err); markErr != nil {
+				r.logger.Error("記錄外寄事件失敗次數失敗", zap.String("id", msg.ID), zap.Error(markErr))
+			}
+			return sent
+		}
+
+		if err := r.store.MarkSent(ctx, msg.ID); err != nil {
+			// 事件已發布但未標記，下一輪會重送
+			r.logger.Error("標記外寄事件已發送失敗", zap.String("id", msg.ID), zap.Error(err))
+			return sent
+		}
+		sent++
+	}
+
+	return sent
+}
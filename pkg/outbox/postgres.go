@@ -0,0 +1,82 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// DefaultTable 預設的外寄事件資料表
+const DefaultTable = "outbox"
+
+// PostgresStore 以 PostgreSQL 的 outbox 資料表實作 Store
+type PostgresStore struct {
+	db    *sqlx.DB
+	table string
+}
+
+// NewPostgresStore 創建使用預設資料表的 PostgreSQL 外寄事件儲存
+func NewPostgresStore(db *sqlx.DB) *PostgresStore {
+	return NewPostgresStoreWithTable(db, DefaultTable)
+}
+
+// NewPostgresStoreWithTable 創建使用指定資料表的外寄事件儲存；
+// 共用同一個資料庫的服務應各自使用不同的資料表，避免轉發器發布其他服務的事件
+func NewPostgresStoreWithTable(db *sqlx.DB, table string) *PostgresStore {
+	return &PostgresStore{db: db, table: table}
+}
+
+// Add 在呼叫端的交易中寫入一筆外寄事件，與業務資料一起提交或回滾
+func (s *PostgresStore) Add(ctx context.Context, tx sqlx.ExecerContext, topic, key string, payload []byte) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO `+s.table+` (topic, message_key, payload, created_at)
+		VALUES ($1, $2, $3, CURRENT_TIMESTAMP)`,
+		topic, key, payload)
+	if err != nil {
+		return fmt.Errorf("寫入外寄事件失敗: %w", err)
+	}
+	return nil
+}
+
+// Pending 依寫入順序取出尚未發布的事件
+func (s *PostgresStore) Pending(ctx context.Context, limit int) ([]Message, error) {
+	var messages []Message
+	err := s.db.SelectContext(ctx, &messages,
+		`SELECT id, topic, message_key, payload, attempts, created_at
+		FROM `+s.table+` WHERE sent_at IS NULL ORDER BY id LIMIT $1`, limit)
+	if err != nil {
+		return nil, fmt.Errorf("查詢外寄事件失敗: %w", err)
+	}
+	return messages, nil
+}
+
+// MarkSent 標記事件已成功發布
+func (s *PostgresStore) MarkSent(ctx context.Context, id string) error {
+	rowID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("無效的外寄事件 ID: %s", id)
+	}
+
+	_, err = s.db.ExecContext(ctx, `UPDATE `+s.table+` SET sent_at = CURRENT_TIMESTAMP WHERE id = $1`, rowID)
+	if err != nil {
+		return fmt.Errorf("標記外寄事件失敗: %w", err)
+	}
+	return nil
+}
+
+// MarkFailed 記錄一次發布失敗
+func (s *PostgresStore) MarkFailed(ctx context.Context, id string, cause error) error {
+	rowID, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		return fmt.Errorf("無效的外寄事件 ID: %s", id)
+	}
+
+	_, err = s.db.ExecContext(ctx,
+		`UPDATE `+s.table+` SET attempts = attempts + 1, last_error = $1 WHERE id = $2`, cause.Error(), rowID)
+	if err != nil {
+		return fmt.Errorf("記錄外寄事件失敗次數失敗: %w", err)
+	}
+	return nil
+}
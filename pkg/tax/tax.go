@@ -0,0 +1,301 @@
+// Package tax computes line-level sales tax and VAT from a versioned table of
+// region rules.
+package tax
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// StandardCategory is the tax category of products without one
+const StandardCategory = "standard"
+
+// ErrNoRules is returned when no version of the rules is in effect
+var ErrNoRules = errors.New("tax: no rules in effect")
+
+//go:embed rules.yaml
+var defaultRules []byte
+
+// Table is the tax rules file, a list of rule versions
+type Table struct {
+	Versions []Rules `yaml:"versions"`
+}
+
+// Rules is one version of the tax rules
+//
+// DefaultCountry is used for addresses without a country.
+type Rules struct {
+	Version        string    `yaml:"version"`
+	EffectiveFrom  time.Time `yaml:"effective_from"`
+	DefaultCountry string    `yaml:"default_country"`
+	Regions        []Region  `yaml:"regions"`
+}
+
+// Region is the tax of a country, or of a state when State is set
+//
+// Rates maps tax categories to percentages, e.g. "5" or "7.25", and must
+// have the standard category. Inclusive regions have tax included in
+// prices; the others add it on top of them.
+type Region struct {
+	Country   string            `yaml:"country"`
+	State     string            `yaml:"state"`
+	Name      string            `yaml:"name"`
+	Inclusive bool              `yaml:"inclusive"`
+	Rates     map[string]string `yaml:"rates"`
+}
+
+// Address is where the goods are shipped to
+type Address struct {
+	Country string
+	State   string
+}
+
+// Item is an amount to tax, usually an order line net of its discounts
+type Item struct {
+	ProductID string
+	Category  string
+	Amount    money.Money
+}
+
+// Line is the tax of one item
+//
+// Rate is the percentage applied. For inclusive regions Amount is the part of
+// Taxable that is tax; otherwise it is charged on top of Taxable.
+type Line struct {
+	ProductID    string      `json:"product_id"`
+	Category     string      `json:"category"`
+	Jurisdiction string      `json:"jurisdiction"`
+	Name         string      `json:"name,omitempty"`
+	Rate         string      `json:"rate"`
+	Inclusive    bool        `json:"inclusive"`
+	Taxable      money.Money `json:"taxable"`
+	Amount       money.Money `json:"amount"`
+}
+
+// Result is the tax of a set of items
+//
+// Lines is empty when the address is outside every region.
+type Result struct {
+	Version   string
+	Inclusive bool
+	Lines     []Line
+	Total     money.Money
+}
+
+// Calculator computes tax with a parsed Table
+type Calculator struct {
+	versions []parsedRules
+}
+
+// parsedRules is a Rules version with parsed rates
+type parsedRules struct {
+	version        string
+	effectiveFrom  time.Time
+	defaultCountry string
+	regions        map[string]parsedRegion
+}
+
+// parsedRegion is a Region with parsed rates
+type parsedRegion struct {
+	jurisdiction string
+	name         string
+	inclusive    bool
+	rates        map[string]*big.Rat
+}
+
+// New creates a Calculator for the table
+func New(table Table) (*Calculator, error) {
+	if len(table.Versions) == 0 {
+		return nil, errors.New("tax: table has no versions")
+	}
+
+	c := &Calculator{}
+	seen := map[string]bool{}
+	for _, rules := range table.Versions {
+		if rules.Version == "" {
+			return nil, errors.New("tax: version without a name")
+		}
+		if seen[rules.Version] {
+			return nil, fmt.Errorf("tax: duplicate version %s", rules.Version)
+		}
+		seen[rules.Version] = true
+
+		parsed, err := rules.parse()
+		if err != nil {
+			return nil, fmt.Errorf("tax: version %s: %w", rules.Version, err)
+		}
+		c.versions = append(c.versions, parsed)
+	}
+
+	sort.Slice(c.versions, func(i, j int) bool {
+		return c.versions[i].effectiveFrom.Before(c.versions[j].effectiveFrom)
+	})
+	return c, nil
+}
+
+// Load creates a Calculator from a YAML rules file, or from the built-in
+// rules if path is empty
+//
+// The file is read once; restart the service to pick up new rules.
+func Load(path string) (*Calculator, error) {
+	data := defaultRules
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("tax: read %s: %w", path, err)
+		}
+	}
+
+	var table Table
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("tax: decode rules: %w", err)
+	}
+	return New(table)
+}
+
+// parse validates the version and parses its rates
+func (r Rules) parse() (parsedRules, error) {
+	if r.EffectiveFrom.IsZero() {
+		return parsedRules{}, errors.New("missing effective_from")
+	}
+
+	parsed := parsedRules{
+		version:        r.Version,
+		effectiveFrom:  r.EffectiveFrom,
+		defaultCountry: strings.ToUpper(r.DefaultCountry),
+		regions:        make(map[string]parsedRegion, len(r.Regions)),
+	}
+	for _, region := range r.Regions {
+		key := jurisdiction(region.Country, region.State)
+		if region.Country == "" {
+			return parsedRules{}, errors.New("region without a country")
+		}
+		if _, ok := parsed.regions[key]; ok {
+			return parsedRules{}, fmt.Errorf("duplicate region %s", key)
+		}
+		if _, ok := region.Rates[StandardCategory]; !ok {
+			return parsedRules{}, fmt.Errorf("region %s: missing %s rate", key, StandardCategory)
+		}
+
+		rates := make(map[string]*big.Rat, len(region.Rates))
+		for category, value := range region.Rates {
+			rate, ok := new(big.Rat).SetString(value)
+			if !ok || rate.Sign() < 0 || rate.Cmp(big.NewRat(100, 1)) >= 0 {
+				return parsedRules{}, fmt.Errorf("region %s: invalid %s rate %q", key, category, value)
+			}
+			rates[category] = rate
+		}
+
+		parsed.regions[key] = parsedRegion{
+			jurisdiction: key,
+			name:         region.Name,
+			inclusive:    region.Inclusive,
+			rates:        rates,
+		}
+	}
+	return parsed, nil
+}
+
+// jurisdiction returns the region key of a country and state, e.g. "TW" or "US-CA"
+func jurisdiction(country, state string) string {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	state = strings.ToUpper(strings.TrimSpace(state))
+	if state == "" {
+		return country
+	}
+	return country + "-" + state
+}
+
+// rules returns the version in effect at the time
+func (c *Calculator) rules(at time.Time) (parsedRules, error) {
+	for i := len(c.versions) - 1; i >= 0; i-- {
+		if !c.versions[i].effectiveFrom.After(at) {
+			return c.versions[i], nil
+		}
+	}
+	return parsedRules{}, fmt.Errorf("%w at %s", ErrNoRules, at.Format(time.RFC3339))
+}
+
+// region returns the region of the address: the state's region if there is
+// one, otherwise the country's
+func (r parsedRules) region(address Address) (parsedRegion, bool) {
+	country := address.Country
+	if strings.TrimSpace(country) == "" {
+		country = r.defaultCountry
+	}
+	if region, ok := r.regions[jurisdiction(country, address.State)]; ok {
+		return region, true
+	}
+	region, ok := r.regions[jurisdiction(country, "")]
+	return region, ok
+}
+
+// Calculate computes the tax of each item shipped to the address with the
+// rules in effect at the time
+//
+// Every item must be in currency. Tax is rounded half up on each line, so
+// Total is the sum of the line amounts.
+func (c *Calculator) Calculate(at time.Time, address Address, currency string, items []Item) (*Result, error) {
+	rules, err := c.rules(at)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{Version: rules.version, Lines: []Line{}, Total: money.Zero(currency)}
+	region, ok := rules.region(address)
+	if !ok {
+		return result, nil
+	}
+	result.Inclusive = region.inclusive
+
+	for _, item := range items {
+		if item.Amount.Currency != currency {
+			return nil, fmt.Errorf("tax: product %s is priced in %s, not %s", item.ProductID, item.Amount.Currency, currency)
+		}
+
+		category := item.Category
+		if category == "" {
+			category = StandardCategory
+		}
+		rate, ok := region.rates[category]
+		if !ok {
+			rate = region.rates[StandardCategory]
+		}
+
+		line := Line{
+			ProductID:    item.ProductID,
+			Category:     category,
+			Jurisdiction: region.jurisdiction,
+			Name:         region.name,
+			Rate:         rate.FloatString(2),
+			Inclusive:    region.inclusive,
+			Taxable:      item.Amount,
+			Amount:       item.Amount.MulRat(factor(rate, region.inclusive), money.HalfUp),
+		}
+		if result.Total, err = result.Total.Add(line.Amount); err != nil {
+			return nil, err
+		}
+		result.Lines = append(result.Lines, line)
+	}
+	return result, nil
+}
+
+// factor returns the share of an amount that is tax: rate/100 on top of the
+// amount, or rate/(100+rate) of an amount that includes it
+func factor(rate *big.Rat, inclusive bool) *big.Rat {
+	base := big.NewRat(100, 1)
+	if inclusive {
+		base.Add(base, rate)
+	}
+	return new(big.Rat).Quo(rate, base)
+}
@@ -0,0 +1,126 @@
+package tax
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+func TestCalculate(t *testing.T) {
+	calculator, err := Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name          string
+		at            time.Time
+		address       Address
+		currency      string
+		items         []Item
+		wantInclusive bool
+		wantRates     []string
+		wantTotal     money.Money
+		wantErr       error
+	}{
+		{
+			// 999.99 * 5/105 = 47.6185...
+			name:          "inclusive VAT",
+			at:            at,
+			address:       Address{Country: "TW"},
+			currency:      "TWD",
+			items:         []Item{{ProductID: "p1", Amount: money.New(99999, "TWD")}},
+			wantInclusive: true,
+			wantRates:     []string{"5.00"},
+			wantTotal:     money.New(4762, "TWD"),
+		},
+		{
+			// 3199.00 * 7.25% = 231.9275
+			name:      "exclusive state sales tax",
+			at:        at,
+			address:   Address{Country: "US", State: "ca"},
+			currency:  "USD",
+			items:     []Item{{ProductID: "p1", Amount: money.New(319900, "USD")}},
+			wantRates: []string{"7.25"},
+			wantTotal: money.New(23193, "USD"),
+		},
+		{
+			name:     "rounded per line",
+			at:       at,
+			address:  Address{Country: "US", State: "NY"},
+			currency: "USD",
+			items: []Item{
+				{ProductID: "p1", Amount: money.New(1013, "USD")},
+				{ProductID: "p2", Amount: money.New(1013, "USD")},
+			},
+			// 10.13 * 4% = 0.4052 per line
+			wantRates: []string{"4.00", "4.00"},
+			wantTotal: money.New(82, "USD"),
+		},
+		{
+			name:          "category rate and unknown category",
+			at:            at,
+			address:       Address{Country: "JP"},
+			currency:      "JPY",
+			items:         []Item{{ProductID: "food", Category: "reduced", Amount: money.New(1080, "JPY")}, {ProductID: "toy", Category: "luxury", Amount: money.New(1100, "JPY")}},
+			wantInclusive: true,
+			wantRates:     []string{"8.00", "10.00"},
+			wantTotal:     money.New(180, "JPY"),
+		},
+		{
+			name:          "default country",
+			at:            at,
+			currency:      "TWD",
+			items:         []Item{{ProductID: "p1", Amount: money.New(10500, "TWD")}},
+			wantInclusive: true,
+			wantRates:     []string{"5.00"},
+			wantTotal:     money.New(500, "TWD"),
+		},
+		{
+			name:      "untaxed state",
+			at:        at,
+			address:   Address{Country: "US", State: "TX"},
+			currency:  "USD",
+			items:     []Item{{ProductID: "p1", Amount: money.New(319900, "USD")}},
+			wantRates: []string{},
+			wantTotal: money.Zero("USD"),
+		},
+		{
+			name:     "before the first version",
+			at:       time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC),
+			address:  Address{Country: "TW"},
+			currency: "TWD",
+			items:    []Item{{ProductID: "p1", Amount: money.New(99999, "TWD")}},
+			wantErr:  ErrNoRules,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := calculator.Calculate(tt.at, tt.address, tt.currency, tt.items)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Calculate() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if result.Inclusive != tt.wantInclusive {
+				t.Errorf("Inclusive = %v, want %v", result.Inclusive, tt.wantInclusive)
+			}
+			if result.Total != tt.wantTotal {
+				t.Errorf("Total = %v, want %v", result.Total, tt.wantTotal)
+			}
+			if len(result.Lines) != len(tt.wantRates) {
+				t.Fatalf("got %d lines, want %d", len(result.Lines), len(tt.wantRates))
+			}
+			for i, line := range result.Lines {
+				if line.Rate != tt.wantRates[i] {
+					t.Errorf("line %d rate = %s, want %s", i, line.Rate, tt.wantRates[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package totp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 test key of RFC 6238 appendix B, "12345678901234567890" in base32
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestCodeRFC6238Vectors(t *testing.T) {
+	// Appendix B lists 8 digit codes; the 6 digit codes are their last 6 digits
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1111111111, want: "050471"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+		{unix: 20000000000, want: "353130"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			got, err := Code(rfcSecret, Step(time.Unix(tt.unix, 0)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("Code at %d = %s, want %s", tt.unix, got, tt.want)
+			}
+
+			// Secrets typed by hand are often lower case
+			lower, err := Code(strings.ToLower(rfcSecret), Step(time.Unix(tt.unix, 0)))
+			if err != nil || lower != tt.want {
+				t.Errorf("Code with lower case secret = %s, %v, want %s", lower, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCodeInvalidSecret(t *testing.T) {
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Error("Code() with an invalid secret succeeded")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1234567890, 0)
+	current := Step(now)
+	codeAt := func(step int64) string {
+		code, err := Code(rfcSecret, step)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return code
+	}
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{name: "current step", secret: rfcSecret, code: "005924", wantStep: current, wantOK: true},
+		{name: "surrounding whitespace", secret: rfcSecret, code: " 005924\n", wantStep: current, wantOK: true},
+		{name: "previous step within skew", secret: rfcSecret, code: codeAt(current - 1), wantStep: current - 1, wantOK: true},
+		{name: "next step within skew", secret: rfcSecret, code: codeAt(current + 1), wantStep: current + 1, wantOK: true},
+		{name: "two steps old", secret: rfcSecret, code: codeAt(current - 2)},
+		{name: "two steps ahead", secret: rfcSecret, code: codeAt(current + 2)},
+		{name: "8 digit code", secret: rfcSecret, code: "89005924"},
+		{name: "wrong code", secret: rfcSecret, code: "000000"},
+		{name: "invalid secret", secret: "not base32!", code: "005924"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(tt.secret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Errorf("Validate() = %d, %v, want %d, %v", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	secret, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := encoding.DecodeString(secret)
+	if err != nil {
+		t.Fatalf("secret %q is not base32: %v", secret, err)
+	}
+	if len(key) != secretSize {
+		t.Errorf("secret is %d bytes, want %d", len(key), secretSize)
+	}
+
+	other, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other == secret {
+		t.Error("GenerateSecret() returned the same secret twice")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("E-commerce", "alice@example.com", rfcSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/E-commerce:alice@example.com" {
+		t.Errorf("URI = %s, want otpauth://totp/E-commerce:alice@example.com", uri)
+	}
+
+	want := map[string]string{
+		"secret":    rfcSecret,
+		"issuer":    "E-commerce",
+		"algorithm": "SHA1",
+		"digits":    "6",
+		"period":    "30",
+	}
+	for key, value := range want {
+		if got := uri.Query().Get(key); got != value {
+			t.Errorf("URI %s = %q, want %q", key, got, value)
+		}
+	}
+}
@@ -5,9 +5,60 @@ db = db.getSiblingDB('ecommerce');
 db.createCollection('users');
 db.users.createIndex({ "email": 1 }, { unique: true });
 
+// Refresh tokens are looked up by hash and revoked per family or per user
+db.createCollection('refresh_tokens');
+db.refresh_tokens.createIndex({ "token_hash": 1 }, { unique: true });
+db.refresh_tokens.createIndex({ "family_id": 1 });
+db.refresh_tokens.createIndex({ "user_id": 1 });
+db.refresh_tokens.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
+// Single-use email verification and password reset tokens
+db.createCollection('action_tokens');
+db.action_tokens.createIndex({ "token_hash": 1 }, { unique: true });
+db.action_tokens.createIndex({ "user_id": 1, "purpose": 1 });
+db.action_tokens.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
+// Failed login counters per account and client IP, removed after the window and lock pass
+db.createCollection('login_attempts');
+db.login_attempts.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
+// Security audit log of the auth service
+db.createCollection('auth_audit');
+db.auth_audit.createIndex({ "created_at": -1 });
+db.auth_audit.createIndex({ "user_id": 1, "created_at": -1 });
+db.auth_audit.createIndex({ "email": 1, "created_at": -1 });
+db.auth_audit.createIndex({ "ip": 1, "created_at": -1 });
+
+// Address books of users
+db.createCollection('addresses');
+db.addresses.createIndex({ "user_id": 1, "created_at": 1 });
+
+// Data export and erasure requests, with the request event kept in an outbox array until published
+db.createCollection('privacy_requests');
+db.privacy_requests.createIndex({ "user_id": 1, "created_at": -1 });
+db.privacy_requests.createIndex({ "outbox.0": 1 }, { sparse: true });
+db.privacy_requests.createIndex({ "export_expires_at": 1 }, { sparse: true });
+
+// Auth service settings such as the two-factor policy, one document per setting
+db.createCollection('auth_settings');
+
+// Revoked access token IDs and families, shared by every service checking JWTs
+db.createCollection('revoked_tokens');
+db.revoked_tokens.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+
+// Token signing keys of the auth service, removed once no token they signed can be valid
+db.createCollection('signing_keys');
+db.signing_keys.createIndex({ "verify_until": 1 }, { expireAfterSeconds: 0 });
+
 // Create products collection for product service
 db.createCollection('products');
 db.products.createIndex({ "sku": 1 }, { unique: true });
+// Stock holds are embedded in the product document (see inventory reservations)
+db.products.createIndex({ "holds.order_id": 1 }, { sparse: true });
+
+// Create reservations collection for product service, keyed by order ID
+db.createCollection('reservations');
+db.reservations.createIndex({ "status": 1, "expires_at": 1 });
 
 // Create categories collection for product service
 db.createCollection('categories');
@@ -15,7 +66,18 @@ db.categories.createIndex({ "name": 1 }, { unique: true });
 
 // Create carts collection for cart service
 db.createCollection('carts');
-db.carts.createIndex({ "userId": 1 }, { unique: true });
+db.carts.createIndex({ "user_id": 1 }, { unique: true, partialFilterExpression: { "user_id": { $exists: true } } });
+// Guest carts are found by the guest ID of their cart token and expire when abandoned
+db.carts.createIndex({ "guest_id": 1 }, { unique: true, partialFilterExpression: { "guest_id": { $exists: true } } });
+db.carts.createIndex({ "expires_at": 1 }, { expireAfterSeconds: 0 });
+// Pending checkout events are embedded in the cart document (see cart outbox)
+db.carts.createIndex({ "outbox._id": 1 }, { sparse: true });
+
+// Promotions are looked up by coupon code; per-user redemption counts live in promotion_usages
+db.createCollection('promotions');
+db.promotions.createIndex({ "code": 1 }, { unique: true });
+db.createCollection('promotion_usages');
+db.promotion_usages.createIndex({ "promotion_id": 1 });
 
 // Insert some sample data
 db.categories.insertMany([
@@ -29,8 +91,11 @@ db.products.insertMany([
   {
     name: "Smartphone X",
     description: "Latest smartphone with advanced features",
-    price: 999.99,
+    price: { amount: NumberLong(99999), currency: "TWD" },
+    prices: [{ amount: NumberLong(3199), currency: "USD" }],
     sku: "PHONE-X-001",
+    weight: 450,
+    dimensions: { length: 180, width: 95, height: 60 },
     categoryId: db.categories.findOne({ name: "Electronics" })._id,
     inventory: 100,
     images: ["phone-x-1.jpg", "phone-x-2.jpg"],
@@ -40,8 +105,10 @@ db.products.insertMany([
   {
     name: "Laptop Pro",
     description: "High-performance laptop for professionals",
-    price: 1499.99,
+    price: { amount: NumberLong(149999), currency: "TWD" },
     sku: "LAPTOP-PRO-001",
+    weight: 2600,
+    dimensions: { length: 400, width: 290, height: 80 },
     categoryId: db.categories.findOne({ name: "Electronics" })._id,
     inventory: 50,
     images: ["laptop-pro-1.jpg", "laptop-pro-2.jpg"],
@@ -51,8 +118,10 @@ db.products.insertMany([
   {
     name: "Cotton T-Shirt",
     description: "Comfortable cotton t-shirt",
-    price: 19.99,
+    price: { amount: NumberLong(1999), currency: "TWD" },
     sku: "TSHIRT-001",
+    weight: 250,
+    dimensions: { length: 300, width: 250, height: 30 },
     categoryId: db.categories.findOne({ name: "Clothing" })._id,
     inventory: 200,
     images: ["tshirt-1.jpg", "tshirt-2.jpg"],
@@ -62,8 +131,10 @@ db.products.insertMany([
   {
     name: "Programming in Go",
     description: "Learn Go programming language",
-    price: 39.99,
+    price: { amount: NumberLong(3999), currency: "TWD" },
     sku: "BOOK-GO-001",
+    weight: 700,
+    dimensions: { length: 240, width: 180, height: 40 },
     categoryId: db.categories.findOne({ name: "Books" })._id,
     inventory: 75,
     images: ["go-book-1.jpg"],
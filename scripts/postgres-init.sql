@@ -0,0 +1,200 @@
+-- Create tables for order service
+
+-- Amounts are BIGINT minor units (cents) of the currency column, e.g. 12345
+-- with currency TWD is NT$123.45
+CREATE TABLE IF NOT EXISTS orders (
+    order_id    VARCHAR(36) PRIMARY KEY,
+    user_id     VARCHAR(36) NOT NULL,
+    total_price BIGINT NOT NULL DEFAULT 0,
+    currency    CHAR(3) NOT NULL DEFAULT 'TWD',
+    status      VARCHAR(20) NOT NULL,
+    payment_method VARCHAR(255) NOT NULL DEFAULT '',
+    payment_id  VARCHAR(36) NOT NULL DEFAULT '',
+    paid_at     TIMESTAMPTZ,
+    -- Copy of the shipping address when the order was placed
+    shipping_info JSONB NOT NULL DEFAULT '{}',
+    -- Promotion discounts applied at checkout; total_price is net of discount_total
+    discounts   JSONB NOT NULL DEFAULT '[]',
+    discount_total BIGINT NOT NULL DEFAULT 0,
+    free_shipping BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Line-level tax; with tax_inclusive it is included in the prices,
+    -- otherwise total_price includes tax_total
+    tax_lines   JSONB NOT NULL DEFAULT '[]',
+    tax_total   BIGINT NOT NULL DEFAULT 0,
+    tax_inclusive BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Version of the tax rules the order was taxed with
+    tax_version VARCHAR(50) NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- ListOrders sorts by (created_at, order_id) and pages with a keyset cursor
+CREATE INDEX IF NOT EXISTS idx_orders_created ON orders (created_at DESC, order_id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_user_created ON orders (user_id, created_at DESC, order_id DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_status_created ON orders (status, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS order_items (
+    id           SERIAL PRIMARY KEY,
+    order_id     VARCHAR(36) NOT NULL REFERENCES orders (order_id) ON DELETE CASCADE,
+    product_id   VARCHAR(36) NOT NULL,
+    product_name VARCHAR(255) NOT NULL DEFAULT '',
+    quantity     INTEGER NOT NULL,
+    -- Minor units in the currency of the order
+    unit_price   BIGINT NOT NULL DEFAULT 0,
+    subtotal     BIGINT NOT NULL DEFAULT 0,
+    -- Tax category of the product; empty is the standard rate
+    tax_category VARCHAR(50) NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_items_order ON order_items (order_id);
+
+-- Every order status transition, including the initial PENDING state
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id          BIGSERIAL PRIMARY KEY,
+    order_id    VARCHAR(36) NOT NULL REFERENCES orders (order_id) ON DELETE CASCADE,
+    from_status VARCHAR(20) NOT NULL DEFAULT '',
+    to_status   VARCHAR(20) NOT NULL,
+    actor       VARCHAR(100) NOT NULL,
+    reason      TEXT NOT NULL DEFAULT '',
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order ON order_status_history (order_id, changed_at);
+
+-- Order/payment saga state, updated in the same transaction as the order
+CREATE TABLE IF NOT EXISTS order_sagas (
+    order_id    VARCHAR(36) PRIMARY KEY REFERENCES orders (order_id) ON DELETE CASCADE,
+    state       VARCHAR(20) NOT NULL,
+    payment_id  VARCHAR(36) NOT NULL DEFAULT '',
+    deadline    TIMESTAMPTZ NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+-- The timeout sweeper looks for sagas still awaiting payment past their deadline
+CREATE INDEX IF NOT EXISTS idx_order_sagas_awaiting ON order_sagas (deadline) WHERE state = 'AWAITING_PAYMENT';
+
+-- Transactional outbox: rows are written in the same transaction as the
+-- business change and published to Kafka by the outbox relay
+CREATE TABLE IF NOT EXISTS outbox (
+    id          BIGSERIAL PRIMARY KEY,
+    topic       VARCHAR(255) NOT NULL,
+    message_key VARCHAR(255) NOT NULL DEFAULT '',
+    payload     BYTEA NOT NULL,
+    attempts    INTEGER NOT NULL DEFAULT 0,
+    last_error  TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_outbox_unsent ON outbox (id) WHERE sent_at IS NULL;
+
+-- Create tables for payment service
+
+-- Card numbers are never stored, only the last four digits
+CREATE TABLE IF NOT EXISTS payments (
+    payment_id      VARCHAR(36) PRIMARY KEY,
+    order_id        VARCHAR(36) NOT NULL,
+    user_id         VARCHAR(36) NOT NULL DEFAULT '',
+    idempotency_key VARCHAR(255) UNIQUE,
+    -- Amounts are BIGINT minor units of currency
+    amount          BIGINT NOT NULL,
+    captured_amount BIGINT NOT NULL DEFAULT 0,
+    refunded_amount BIGINT NOT NULL DEFAULT 0,
+    currency        CHAR(3) NOT NULL,
+    status          VARCHAR(20) NOT NULL,
+    gateway         VARCHAR(50) NOT NULL,
+    gateway_ref     VARCHAR(255) NOT NULL DEFAULT '',
+    card_last4      VARCHAR(4) NOT NULL DEFAULT '',
+    failure_code    VARCHAR(50) NOT NULL DEFAULT '',
+    failure_message TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_payments_order ON payments (order_id, created_at);
+
+-- Payment events are relayed from their own outbox table so the order
+-- service relay, which shares this database, never publishes them
+CREATE TABLE IF NOT EXISTS payment_outbox (
+    id          BIGSERIAL PRIMARY KEY,
+    topic       VARCHAR(255) NOT NULL,
+    message_key VARCHAR(255) NOT NULL DEFAULT '',
+    payload     BYTEA NOT NULL,
+    attempts    INTEGER NOT NULL DEFAULT 0,
+    last_error  TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_payment_outbox_unsent ON payment_outbox (id) WHERE sent_at IS NULL;
+
+-- Shipments of the fulfillment service; an order can ship in several shipments
+CREATE TABLE IF NOT EXISTS shipments (
+    shipment_id     VARCHAR(36) PRIMARY KEY,
+    order_id        VARCHAR(36) NOT NULL,
+    user_id         VARCHAR(36) NOT NULL DEFAULT '',
+    carrier         VARCHAR(50) NOT NULL,
+    service         VARCHAR(50) NOT NULL,
+    tracking_number VARCHAR(100) NOT NULL,
+    status          VARCHAR(20) NOT NULL,
+    -- Copy of the order's shipping address when the shipment was created
+    shipping_info   JSONB NOT NULL DEFAULT '{}',
+    -- Carrier charge in minor units of currency
+    cost            BIGINT NOT NULL DEFAULT 0,
+    currency        CHAR(3) NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipments_order ON shipments (order_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_shipments_user ON shipments (user_id);
+CREATE UNIQUE INDEX IF NOT EXISTS idx_shipments_tracking ON shipments (carrier, tracking_number);
+
+CREATE TABLE IF NOT EXISTS shipment_items (
+    id          SERIAL PRIMARY KEY,
+    shipment_id VARCHAR(36) NOT NULL REFERENCES shipments (shipment_id) ON DELETE CASCADE,
+    order_id    VARCHAR(36) NOT NULL,
+    product_id  VARCHAR(36) NOT NULL,
+    quantity    INTEGER NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipment_items_shipment ON shipment_items (shipment_id);
+CREATE INDEX IF NOT EXISTS idx_shipment_items_order ON shipment_items (order_id);
+
+-- Ordered quantities copied from the order on its first shipment, so partial
+-- shipments can be checked against them without asking the order service
+CREATE TABLE IF NOT EXISTS fulfillment_order_lines (
+    order_id   VARCHAR(36) NOT NULL,
+    product_id VARCHAR(36) NOT NULL,
+    quantity   INTEGER NOT NULL,
+    PRIMARY KEY (order_id, product_id)
+);
+
+-- Carrier tracking history, including the initial CREATED state
+CREATE TABLE IF NOT EXISTS shipment_tracking (
+    id          BIGSERIAL PRIMARY KEY,
+    shipment_id VARCHAR(36) NOT NULL REFERENCES shipments (shipment_id) ON DELETE CASCADE,
+    status      VARCHAR(20) NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    location    VARCHAR(255) NOT NULL DEFAULT '',
+    occurred_at TIMESTAMPTZ NOT NULL,
+    recorded_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_shipment_tracking_shipment ON shipment_tracking (shipment_id, occurred_at);
+
+-- Fulfillment events are relayed from their own outbox table, like payment events
+CREATE TABLE IF NOT EXISTS fulfillment_outbox (
+    id          BIGSERIAL PRIMARY KEY,
+    topic       VARCHAR(255) NOT NULL,
+    message_key VARCHAR(255) NOT NULL DEFAULT '',
+    payload     BYTEA NOT NULL,
+    attempts    INTEGER NOT NULL DEFAULT 0,
+    last_error  TEXT,
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    sent_at     TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_fulfillment_outbox_unsent ON fulfillment_outbox (id) WHERE sent_at IS NULL;
@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,12 +15,18 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/arrontsai/ecommerce/pkg/config"
 	"github.com/arrontsai/ecommerce/pkg/database"
+	"github.com/arrontsai/ecommerce/pkg/jwks"
 	"github.com/arrontsai/ecommerce/pkg/logger"
+	"github.com/arrontsai/ecommerce/pkg/mailer"
+	"github.com/arrontsai/ecommerce/pkg/messaging"
 	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
 	"github.com/arrontsai/ecommerce/services/auth/handler"
+	"github.com/arrontsai/ecommerce/services/auth/proto/pb"
 	"github.com/arrontsai/ecommerce/services/auth/repository"
 	"github.com/arrontsai/ecommerce/services/auth/service"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -43,9 +51,115 @@ func main() {
 
 	// Initialize repositories
 	userRepo := repository.NewMongoUserRepository(mongoClient.DB)
+	tokenRepo := repository.NewMongoRefreshTokenRepository(mongoClient.DB)
+	actionTokenRepo := repository.NewMongoActionTokenRepository(mongoClient.DB)
+	privacyRepo := repository.NewMongoPrivacyRepository(mongoClient.DB)
+
+	// Initialize the message broker for data subject requests (KAFKA_BROKERS=memory:// uses an in-memory broker)
+	broker, err := messaging.NewBroker(cfg.KafkaBrokers, appLogger.Logger)
+	if err != nil {
+		appLogger.Fatal("Failed to initialize message broker", zap.Error(err))
+	}
+	defer broker.Close()
+
+	// Initialize the mailer for verification and password reset emails
+	mail, err := mailer.New(mailer.Config{
+		Driver:       cfg.Mailer,
+		From:         cfg.MailFrom,
+		SMTPHost:     cfg.SMTPHost,
+		SMTPPort:     cfg.SMTPPort,
+		SMTPUsername: cfg.SMTPUsername,
+		SMTPPassword: cfg.SMTPPassword,
+		Dir:          cfg.MailDir,
+	})
+	if err != nil {
+		appLogger.Fatal("Failed to initialize mailer", zap.Error(err))
+	}
+
+	// Revoked access tokens are shared with the other services through MongoDB
+	denylist := middleware.NewMongoTokenDenylist(mongoClient.DB)
+	authOptions := []middleware.AuthOption{middleware.WithDenylist(denylist)}
+
+	// Sign access tokens with the shared secret, or with rotating key pairs
+	// published at /.well-known/jwks.json when JWT_SIGNING_ALG is RS256 or EdDSA
+	accessTTL := time.Duration(cfg.JWTExpiry) * time.Second
+	var signer middleware.TokenSigner = middleware.NewHMACSigner(cfg.JWTSecret)
+	var keyRing *jwks.KeyRing
+	if cfg.JWTSigningAlg != "HS256" {
+		// Private keys are encrypted with JWT_KEY_ENCRYPTION_KEY, which only the auth service gets
+		kek, err := base64.StdEncoding.DecodeString(cfg.JWTKeyEncryptionKey)
+		if err != nil {
+			appLogger.Fatal("Invalid JWT_KEY_ENCRYPTION_KEY", zap.Error(err))
+		}
+		keyStore, err := jwks.NewMongoKeyStore(mongoClient.DB, kek)
+		if err != nil {
+			appLogger.Fatal("Failed to create signing key store", zap.Error(err))
+		}
+		keyRing, err = jwks.NewKeyRing(keyStore, jwks.KeyRingConfig{
+			Algorithm:        cfg.JWTSigningAlg,
+			RotationInterval: time.Duration(cfg.JWTKeyRotation) * time.Second,
+			PrePublish:       jwks.DefaultPrePublish,
+			TokenTTL:         accessTTL,
+		}, appLogger.Logger)
+		if err != nil {
+			appLogger.Fatal("Failed to create signing key ring", zap.Error(err))
+		}
+		if err := keyRing.Rotate(context.Background()); err != nil {
+			appLogger.Fatal("Failed to load signing keys", zap.Error(err))
+		}
+		go keyRing.Run(context.Background(), time.Minute)
+
+		signer = keyRing
+		authOptions = append(authOptions, middleware.WithKeyProvider(keyRing))
+	}
+
+	// Failed login counters must be shared by all instances unless there is only one
+	var attemptRepo repository.LoginAttemptRepository
+	switch cfg.LoginAttemptStore {
+	case "mongo":
+		attemptRepo = repository.NewMongoLoginAttemptRepository(mongoClient.DB)
+	case "memory":
+		attemptRepo = repository.NewMemoryLoginAttemptRepository()
+	default:
+		appLogger.Fatal("Unknown login attempt store", zap.String("store", cfg.LoginAttemptStore))
+	}
+
+	lockout := service.DefaultLockoutPolicy()
+	lockout.AccountThreshold = cfg.LoginMaxAccountFailures
+	lockout.IPThreshold = cfg.LoginMaxIPFailures
+	lockout.LockDuration = time.Duration(cfg.LoginLockoutDuration) * time.Second
 
 	// Initialize services
-	authService := service.NewAuthService(userRepo, cfg.JWTSecret, cfg.JWTExpiry)
+	authService := service.NewAuthService(service.Dependencies{
+		Users:         userRepo,
+		RefreshTokens: tokenRepo,
+		ActionTokens:  actionTokenRepo,
+		LoginAttempts: attemptRepo,
+		Audit:         repository.NewMongoAuditRepository(mongoClient.DB),
+		Settings:      repository.NewMongoSettingsRepository(mongoClient.DB),
+		Addresses:     repository.NewMongoAddressRepository(mongoClient.DB),
+		Privacy:       privacyRepo,
+		Denylist:      denylist,
+		Mailer:        mail,
+	}, service.TokenConfig{
+		Signer:          signer,
+		AccessTokenTTL:  accessTTL,
+		RefreshTokenTTL: time.Duration(cfg.RefreshTokenExpiry) * time.Second,
+	}, service.AccountConfig{
+		RequireEmailVerification: cfg.RequireEmailVerification,
+		LinkBaseURL:              cfg.AppBaseURL,
+		Lockout:                  lockout,
+		TOTPIssuer:               cfg.TOTPIssuer,
+		PrivacyServices:          cfg.PrivacyServices,
+		ExportRetention:          time.Duration(cfg.ExportRetention) * time.Second,
+	})
+
+	// Publish data subject requests to the other services and collect their results
+	go outbox.NewRelay(privacyRepo, broker, appLogger.Logger).Run(context.Background())
+	if err := handler.SubscribePrivacyResults(context.Background(), broker, authService); err != nil {
+		appLogger.Fatal("Failed to subscribe to privacy results", zap.Error(err))
+	}
+	go purgeDataExports(privacyRepo, appLogger.Logger)
 
 	// Initialize handlers
 	authHandler := handler.NewAuthHandler(authService)
@@ -53,15 +167,24 @@ func main() {
 	// Initialize Gin router
 	router := gin.Default()
 
+	// Only trust X-Forwarded-For from known proxies, the client IP drives login throttling
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		appLogger.Fatal("Invalid trusted proxies", zap.Error(err))
+	}
+
 	// Add middleware
 	router.Use(gin.Recovery())
 	router.Use(gin.Logger())
 
 	// Create JWT auth middleware
-	jwtMiddleware := middleware.JWTAuthMiddleware(cfg.JWTSecret)
+	jwtMiddleware := middleware.JWTAuthMiddleware(cfg.JWTSecret, authOptions...)
 
 	// Register routes
 	authHandler.RegisterRoutes(router, jwtMiddleware)
+	handler.NewAdminHandler(authService).RegisterRoutes(router, jwtMiddleware)
+	if keyRing != nil {
+		handler.NewJWKSHandler(keyRing).RegisterRoutes(router)
+	}
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -82,6 +205,21 @@ func main() {
 		}
 	}()
 
+	// Start the gRPC server for other services, such as saved addresses for the order service
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GrpcPort))
+	if err != nil {
+		appLogger.Fatal("Failed to listen for gRPC", zap.Error(err))
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterAccountServiceServer(grpcServer, handler.NewAccountGRPCServer(authService))
+
+	go func() {
+		appLogger.Info("Starting gRPC server", zap.Int("port", cfg.GrpcPort))
+		if err := grpcServer.Serve(lis); err != nil {
+			appLogger.Fatal("Failed to start gRPC server", zap.Error(err))
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shut down the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -92,10 +230,28 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Shutdown the server
+	// Shutdown the servers
+	grpcServer.GracefulStop()
 	if err := server.Shutdown(ctx); err != nil {
 		appLogger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 
 	appLogger.Info("Server exiting")
 }
+
+// purgeDataExports removes exported data once its download period is over
+func purgeDataExports(repo repository.PrivacyRepository, logger *zap.Logger) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		purged, err := repo.PurgeExports(context.Background(), time.Now())
+		if err != nil {
+			logger.Error("Failed to purge data exports", zap.Error(err))
+			continue
+		}
+		if purged > 0 {
+			logger.Info("Purged data exports", zap.Int64("count", purged))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/auth/proto/pb"
+	"github.com/arrontsai/ecommerce/services/auth/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AccountGRPCServer implements the account gRPC service used by other services
+type AccountGRPCServer struct {
+	pb.UnimplementedAccountServiceServer
+	authService service.AuthService
+}
+
+// NewAccountGRPCServer creates a new AccountGRPCServer
+func NewAccountGRPCServer(authService service.AuthService) *AccountGRPCServer {
+	return &AccountGRPCServer{
+		authService: authService,
+	}
+}
+
+// GetAddress returns a saved address of a user, or the default shipping address
+func (s *AccountGRPCServer) GetAddress(ctx context.Context, req *pb.GetAddressRequest) (*pb.Address, error) {
+	if req.UserId == "" {
+		return nil, status.Error(codes.InvalidArgument, "用戶 ID 不能為空")
+	}
+
+	address, err := s.authService.GetAddress(ctx, req.UserId, req.AddressId)
+	if err != nil {
+		if errors.Is(err, service.ErrAddressNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "獲取地址失敗: %v", err)
+	}
+
+	return toAddressResponse(address), nil
+}
+
+// toAddressResponse converts an address to its gRPC message
+func toAddressResponse(address *models.Address) *pb.Address {
+	return &pb.Address{
+		Id:              address.ID,
+		Label:           address.Label,
+		FullName:        address.FullName,
+		AddressLine1:    address.AddressLine1,
+		AddressLine2:    address.AddressLine2,
+		City:            address.City,
+		State:           address.State,
+		PostalCode:      address.PostalCode,
+		Country:         address.Country,
+		PhoneNumber:     address.PhoneNumber,
+		DefaultShipping: address.DefaultShipping,
+		DefaultBilling:  address.DefaultBilling,
+	}
+}
@@ -0,0 +1,180 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/auth/service"
+)
+
+// AdminHandler handles user administration HTTP requests
+type AdminHandler struct {
+	authService service.AuthService
+}
+
+// NewAdminHandler creates a new AdminHandler
+func NewAdminHandler(authService service.AuthService) *AdminHandler {
+	return &AdminHandler{
+		authService: authService,
+	}
+}
+
+// ListRoles handles listing the roles and the permissions they grant
+func (h *AdminHandler) ListRoles(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"roles": middleware.RolePermissions()})
+}
+
+// GetUser handles getting any user
+func (h *AdminHandler) GetUser(c *gin.Context) {
+	user, err := h.authService.GetUserByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "獲取用戶信息失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"user": user})
+}
+
+// GrantRole handles granting a role to a user
+func (h *AdminHandler) GrantRole(c *gin.Context) {
+	var req models.RoleAssignment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	user, err := h.authService.GrantRole(c.Request.Context(), c.GetString("user_id"), c.Param("id"), req.Role)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": "授予角色失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "角色已授予", "user": user})
+}
+
+// RevokeRole handles revoking a role from a user
+func (h *AdminHandler) RevokeRole(c *gin.Context) {
+	user, err := h.authService.RevokeRole(c.Request.Context(), c.GetString("user_id"), c.Param("id"), c.Param("role"))
+	if err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": "撤銷角色失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "角色已撤銷", "user": user})
+}
+
+// UnlockUser handles clearing the login lockout of a user
+func (h *AdminHandler) UnlockUser(c *gin.Context) {
+	if err := h.authService.UnlockUser(c.Request.Context(), c.GetString("user_id"), c.Param("id")); err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": "解除鎖定失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "帳號已解除鎖定"})
+}
+
+// UnlockIP handles clearing the login lockout of a client IP
+func (h *AdminHandler) UnlockIP(c *gin.Context) {
+	ip := net.ParseIP(c.Param("ip"))
+	if ip == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的 IP 位址"})
+		return
+	}
+
+	if err := h.authService.UnlockIP(c.Request.Context(), c.GetString("user_id"), ip.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "解除鎖定失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "IP 已解除鎖定"})
+}
+
+// ListAudit handles listing auth audit entries
+func (h *AdminHandler) ListAudit(c *gin.Context) {
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "0"))
+	if err != nil || limit < 0 || limit > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的 limit"})
+		return
+	}
+
+	entries, err := h.authService.ListAudit(c.Request.Context(), models.AuditFilter{
+		UserID: c.Query("user_id"),
+		Email:  c.Query("email"),
+		IP:     c.Query("ip"),
+		Limit:  limit,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取稽核紀錄失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"entries": entries})
+}
+
+// GetTwoFactorPolicy handles getting the roles that must use two-factor authentication
+func (h *AdminHandler) GetTwoFactorPolicy(c *gin.Context) {
+	policy, err := h.authService.GetTwoFactorPolicy(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取兩步驟驗證政策失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"policy": policy})
+}
+
+// SetTwoFactorPolicy handles changing the roles that must use two-factor authentication
+func (h *AdminHandler) SetTwoFactorPolicy(c *gin.Context) {
+	var req models.TwoFactorPolicyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	policy, err := h.authService.SetTwoFactorPolicy(c.Request.Context(), c.GetString("user_id"), req.RequiredRoles)
+	if err != nil {
+		c.JSON(roleErrorStatus(err), gin.H{"error": "更新兩步驟驗證政策失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "兩步驟驗證政策已更新", "policy": policy})
+}
+
+// RegisterRoutes registers the user administration routes
+func (h *AdminHandler) RegisterRoutes(router *gin.Engine, authMiddleware gin.HandlerFunc) {
+	admin := router.Group("/api/admin", authMiddleware, middleware.RequirePermissions(middleware.PermUsersAdmin))
+	{
+		admin.GET("/roles", h.ListRoles)
+		admin.GET("/users/:id", h.GetUser)
+		admin.POST("/users/:id/roles", h.GrantRole)
+		admin.DELETE("/users/:id/roles/:role", h.RevokeRole)
+		admin.POST("/users/:id/unlock", h.UnlockUser)
+		admin.POST("/ips/:ip/unlock", h.UnlockIP)
+		admin.GET("/audit", h.ListAudit)
+		admin.GET("/users/:id/privacy-requests", h.ListPrivacyRequests)
+		admin.POST("/users/:id/privacy-requests", h.RequestPrivacy)
+		admin.GET("/privacy-requests/:id", h.GetPrivacyRequest)
+		admin.GET("/privacy-requests/:id/export", h.DownloadDataExport)
+		admin.GET("/2fa-policy", h.GetTwoFactorPolicy)
+		admin.PUT("/2fa-policy", h.SetTwoFactorPolicy)
+	}
+}
+
+// roleErrorStatus maps role management errors to HTTP status codes
+func roleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrUnknownRole),
+		errors.Is(err, service.ErrImplicitRole):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrSelfRevoke):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
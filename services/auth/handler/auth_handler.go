@@ -1,9 +1,13 @@
 package handler
 
 import (
+	"errors"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/arrontsai/ecommerce/pkg/middleware"
 	"github.com/arrontsai/ecommerce/pkg/models"
 	"github.com/arrontsai/ecommerce/services/auth/service"
 )
@@ -29,6 +33,10 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	user, err := h.authService.Register(c.Request.Context(), req)
+	if errors.Is(err, service.ErrVerificationMailFailed) {
+		c.JSON(http.StatusCreated, gin.H{"message": "用戶註冊成功，但驗證郵件寄送失敗，請稍後重新寄送", "user": user})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "註冊失敗: " + err.Error()})
 		return
@@ -45,17 +53,121 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	token, user, err := h.authService.Login(c.Request.Context(), req)
+	result, err := h.authService.Login(c.Request.Context(), req, c.ClientIP())
+	if err != nil {
+		writeLoginError(c, "登入失敗", err)
+		return
+	}
+
+	writeLoginResult(c, result)
+}
+
+// Refresh handles exchanging a refresh token for a new token pair
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	tokens, err := h.authService.Refresh(c.Request.Context(), req.RefreshToken)
 	if err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "登入失敗: " + err.Error()})
+		if errors.Is(err, service.ErrInvalidRefreshToken) || errors.Is(err, service.ErrRefreshTokenReused) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "刷新令牌失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout handles revoking the current session
+func (h *AuthHandler) Logout(c *gin.Context) {
+	err := h.authService.Logout(c.Request.Context(), c.GetString(middleware.ContextTokenFamily), c.GetString(middleware.ContextTokenID))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登出失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "登出成功"})
+}
+
+// LogoutAll handles revoking every session of the current user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	if err := h.authService.LogoutAll(c.Request.Context(), c.GetString("user_id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "登出失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已登出所有裝置"})
+}
+
+// VerifyEmail handles verifying an email address with a token from the verification email
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	if err := h.authService.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+		c.JSON(actionTokenErrorStatus(err), gin.H{"error": "驗證電子郵件失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "電子郵件驗證成功"})
+}
+
+// ResendVerification handles sending a new verification email
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	if err := h.authService.ResendVerification(c.Request.Context(), req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "寄送驗證郵件失敗: " + err.Error()})
+		return
+	}
+
+	// Same response whether or not the address has an account
+	c.JSON(http.StatusAccepted, gin.H{"message": "如果該電子郵件已註冊且尚未驗證，驗證郵件已寄出"})
+}
+
+// ForgotPassword handles requesting a password reset email
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req models.EmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	if err := h.authService.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "寄送重設密碼郵件失敗: " + err.Error()})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "登入成功",
-		"token":   token,
-		"user":    user,
-	})
+	// Same response whether or not the address has an account
+	c.JSON(http.StatusAccepted, gin.H{"message": "如果該電子郵件已註冊，重設密碼郵件已寄出"})
+}
+
+// ResetPassword handles setting a new password with a token from the reset email
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	if err := h.authService.ResetPassword(c.Request.Context(), req.Token, req.NewPassword); err != nil {
+		c.JSON(actionTokenErrorStatus(err), gin.H{"error": "重設密碼失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "密碼已重設，請重新登入"})
 }
 
 // GetMe handles getting the current user
@@ -72,7 +184,7 @@ func (h *AuthHandler) GetMe(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"user": user})
+	c.JSON(http.StatusOK, gin.H{"user": user, "permissions": middleware.PermissionsOf(middleware.Roles(c))})
 }
 
 // RegisterRoutes registers the authentication routes
@@ -81,6 +193,99 @@ func (h *AuthHandler) RegisterRoutes(router *gin.Engine, authMiddleware gin.Hand
 	{
 		auth.POST("/register", h.Register)
 		auth.POST("/login", h.Login)
+		auth.POST("/refresh", h.Refresh)
+		auth.POST("/verify-email", h.VerifyEmail)
+		auth.POST("/verify-email/resend", h.ResendVerification)
+		auth.POST("/password/forgot", h.ForgotPassword)
+		auth.POST("/password/reset", h.ResetPassword)
+		auth.POST("/password/change", authMiddleware, h.ChangePassword)
+		auth.POST("/logout", authMiddleware, h.Logout)
+		auth.POST("/logout-all", authMiddleware, h.LogoutAll)
 		auth.GET("/me", authMiddleware, h.GetMe)
+		auth.PATCH("/me", authMiddleware, h.UpdateProfile)
+	}
+
+	addresses := auth.Group("/me/addresses", authMiddleware)
+	{
+		addresses.GET("", h.ListAddresses)
+		addresses.POST("", h.CreateAddress)
+		addresses.GET("/:id", h.GetAddress)
+		addresses.PUT("/:id", h.UpdateAddress)
+		addresses.DELETE("/:id", h.DeleteAddress)
+	}
+
+	privacy := auth.Group("/me/privacy-requests", authMiddleware)
+	{
+		privacy.GET("", h.ListPrivacyRequests)
+		privacy.POST("/export", h.RequestDataExport)
+		privacy.POST("/erasure", h.RequestErasure)
+		privacy.GET("/:id", h.GetPrivacyRequest)
+		privacy.GET("/:id/export", h.DownloadDataExport)
+	}
+
+	twoFactor := auth.Group("/2fa")
+	{
+		twoFactor.POST("/verify", h.VerifyTwoFactor)
+		twoFactor.POST("/enroll", authMiddleware, h.EnrollTwoFactor)
+		twoFactor.POST("/confirm", authMiddleware, h.ConfirmTwoFactor)
+		twoFactor.POST("/disable", authMiddleware, h.DisableTwoFactor)
+		twoFactor.POST("/recovery-codes", authMiddleware, h.RegenerateRecoveryCodes)
+	}
+}
+
+// writeLoginResult writes the tokens of a completed login or the two-factor challenge
+func writeLoginResult(c *gin.Context, result *service.LoginResult) {
+	if result.Challenge != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message":             "需要兩步驟驗證",
+			"two_factor_required": true,
+			"challenge_token":     result.Challenge.ChallengeToken,
+			"expires_in":          result.Challenge.ExpiresIn,
+			"enrollment_required": result.Challenge.EnrollmentRequired,
+			"enrollment":          result.Challenge.Enrollment,
+		})
+		return
+	}
+
+	tokens := result.Tokens
+	response := gin.H{
+		"message":       "登入成功",
+		"token":         tokens.AccessToken,
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+		"user":          result.User,
+	}
+	if result.RecoveryCodes != nil {
+		response["recovery_codes"] = result.RecoveryCodes
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// writeLoginError maps login and two-factor errors to HTTP responses
+func writeLoginError(c *gin.Context, message string, err error) {
+	var throttled *service.ThrottledError
+	switch {
+	case errors.As(err, &throttled):
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": message + ": " + err.Error()})
+	case errors.Is(err, service.ErrEmailNotVerified),
+		errors.Is(err, service.ErrAccountInactive):
+		c.JSON(http.StatusForbidden, gin.H{"error": message + ": " + err.Error()})
+	case errors.Is(err, service.ErrInvalidCredentials),
+		errors.Is(err, service.ErrInvalidTwoFactorCode),
+		errors.Is(err, service.ErrInvalidChallenge):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": message + ": " + err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message})
+	}
+}
+
+// actionTokenErrorStatus maps verification and password reset errors to HTTP status codes
+func actionTokenErrorStatus(err error) int {
+	if errors.Is(err, service.ErrInvalidActionToken) {
+		return http.StatusBadRequest
 	}
+	return http.StatusInternalServerError
 }
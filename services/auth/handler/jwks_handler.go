@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/arrontsai/ecommerce/pkg/jwks"
+)
+
+// JWKSHandler publishes the public token signing keys
+type JWKSHandler struct {
+	keyRing *jwks.KeyRing
+}
+
+// NewJWKSHandler creates a new JWKSHandler
+func NewJWKSHandler(keyRing *jwks.KeyRing) *JWKSHandler {
+	return &JWKSHandler{
+		keyRing: keyRing,
+	}
+}
+
+// GetKeySet handles getting the JSON Web Key Set
+func (h *JWKSHandler) GetKeySet(c *gin.Context) {
+	// New keys are published well ahead of use, so short caching is safe
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.keyRing.KeySet())
+}
+
+// RegisterRoutes registers the JWKS route
+func (h *JWKSHandler) RegisterRoutes(router *gin.Engine) {
+	router.GET("/.well-known/jwks.json", h.GetKeySet)
+}
@@ -0,0 +1,22 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/messaging"
+	"github.com/arrontsai/ecommerce/services/auth/service"
+)
+
+// SubscribePrivacyResults records the results other services report for data subject requests
+//
+// Results are keyed by request ID, so the results of one request are handled
+// one at a time and only the last one finishes the request.
+func SubscribePrivacyResults(ctx context.Context, broker messaging.Broker, authService service.AuthService) error {
+	mux := events.NewMux(nil)
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.DataSubjectCompleted) error {
+		return authService.RecordPrivacyResult(ctx, event)
+	})
+
+	return messaging.SubscribeEvents(ctx, broker, events.TopicPrivacyResults, service.ServiceName, mux)
+}
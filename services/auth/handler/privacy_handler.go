@@ -0,0 +1,151 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/auth/service"
+)
+
+// RequestDataExport handles starting an export of the current user's data
+func (h *AuthHandler) RequestDataExport(c *gin.Context) {
+	userID := c.GetString("user_id")
+	request, err := h.authService.RequestPrivacy(c.Request.Context(), userID, userID, events.DataSubjectExport)
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"error": "申請匯出資料失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "資料匯出處理中", "request": request})
+}
+
+// RequestErasure handles starting the deletion of the current user's account
+func (h *AuthHandler) RequestErasure(c *gin.Context) {
+	var req models.ErasureRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	request, err := h.authService.RequestErasure(c.Request.Context(), c.GetString("user_id"), req.Password, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, service.ErrTooManyAttempts) {
+			writeLoginError(c, "申請刪除帳號失敗", err)
+			return
+		}
+		c.JSON(privacyErrorStatus(err), gin.H{"error": "申請刪除帳號失敗: " + err.Error()})
+		return
+	}
+
+	// Every session is signed out once the request is accepted
+	c.JSON(http.StatusAccepted, gin.H{"message": "帳號刪除處理中，所有裝置已登出", "request": request})
+}
+
+// ListPrivacyRequests handles listing the current user's data requests
+func (h *AuthHandler) ListPrivacyRequests(c *gin.Context) {
+	requests, err := h.authService.ListPrivacyRequests(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取資料請求失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// GetPrivacyRequest handles getting the per-service status of one of the current user's data requests
+func (h *AuthHandler) GetPrivacyRequest(c *gin.Context) {
+	request, err := h.authService.GetPrivacyRequest(c.Request.Context(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"error": "獲取資料請求失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"request": request})
+}
+
+// DownloadDataExport handles downloading the data of one of the current user's exports
+func (h *AuthHandler) DownloadDataExport(c *gin.Context) {
+	export, err := h.authService.GetDataExport(c.Request.Context(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"error": "下載匯出資料失敗: " + err.Error()})
+		return
+	}
+
+	writeDataExport(c, export)
+}
+
+// RequestPrivacy handles starting an export or erasure of any user's data
+func (h *AdminHandler) RequestPrivacy(c *gin.Context) {
+	var req models.PrivacyRequestBody
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	request, err := h.authService.RequestPrivacy(c.Request.Context(), c.GetString("user_id"), c.Param("id"), req.Kind)
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"error": "建立資料請求失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "資料請求處理中", "request": request})
+}
+
+// ListPrivacyRequests handles listing the data requests of a user
+func (h *AdminHandler) ListPrivacyRequests(c *gin.Context) {
+	requests, err := h.authService.ListPrivacyRequests(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取資料請求失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requests": requests})
+}
+
+// GetPrivacyRequest handles getting the per-service status of any data request
+func (h *AdminHandler) GetPrivacyRequest(c *gin.Context) {
+	request, err := h.authService.GetPrivacyRequest(c.Request.Context(), "", c.Param("id"))
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"error": "獲取資料請求失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"request": request})
+}
+
+// DownloadDataExport handles downloading the data of any export
+func (h *AdminHandler) DownloadDataExport(c *gin.Context) {
+	export, err := h.authService.GetDataExport(c.Request.Context(), "", c.Param("id"))
+	if err != nil {
+		c.JSON(privacyErrorStatus(err), gin.H{"error": "下載匯出資料失敗: " + err.Error()})
+		return
+	}
+
+	writeDataExport(c, export)
+}
+
+// writeDataExport writes an export as a JSON file download
+func writeDataExport(c *gin.Context, export *models.DataExport) {
+	c.Header("Content-Disposition", `attachment; filename="data-export-`+export.RequestID+`.json"`)
+	c.JSON(http.StatusOK, export)
+}
+
+// privacyErrorStatus maps data subject request errors to HTTP status codes
+func privacyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrPrivacyRequestNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrWrongPassword):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrPrivacyRequestPending), errors.Is(err, service.ErrExportNotReady):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrExportExpired):
+		return http.StatusGone
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/auth/service"
+)
+
+// UpdateProfile handles changing the current user's profile
+func (h *AuthHandler) UpdateProfile(c *gin.Context) {
+	var req models.ProfileUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	user, err := h.authService.UpdateProfile(c.Request.Context(), c.GetString("user_id"), req)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": "更新個人資料失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "個人資料已更新", "user": user})
+}
+
+// ChangePassword handles changing the current user's password
+func (h *AuthHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	tokens, err := h.authService.ChangePassword(c.Request.Context(), c.GetString("user_id"), req, c.ClientIP())
+	if err != nil {
+		if errors.Is(err, service.ErrTooManyAttempts) {
+			writeLoginError(c, "變更密碼失敗", err)
+			return
+		}
+		c.JSON(profileErrorStatus(err), gin.H{"error": "變更密碼失敗: " + err.Error()})
+		return
+	}
+
+	// Every other session is signed out, the caller continues with the new tokens
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "密碼已變更，其他裝置需重新登入",
+		"access_token":  tokens.AccessToken,
+		"refresh_token": tokens.RefreshToken,
+		"token_type":    tokens.TokenType,
+		"expires_in":    tokens.ExpiresIn,
+	})
+}
+
+// ListAddresses handles listing the current user's address book
+func (h *AuthHandler) ListAddresses(c *gin.Context) {
+	addresses, err := h.authService.ListAddresses(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取地址失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"addresses": addresses})
+}
+
+// GetAddress handles getting a saved address
+func (h *AuthHandler) GetAddress(c *gin.Context) {
+	address, err := h.authService.GetAddress(c.Request.Context(), c.GetString("user_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": "獲取地址失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"address": address})
+}
+
+// CreateAddress handles saving a new address
+func (h *AuthHandler) CreateAddress(c *gin.Context) {
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	address, err := h.authService.CreateAddress(c.Request.Context(), c.GetString("user_id"), req)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": "新增地址失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "地址已新增", "address": address})
+}
+
+// UpdateAddress handles replacing a saved address
+func (h *AuthHandler) UpdateAddress(c *gin.Context) {
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	address, err := h.authService.UpdateAddress(c.Request.Context(), c.GetString("user_id"), c.Param("id"), req)
+	if err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": "更新地址失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "地址已更新", "address": address})
+}
+
+// DeleteAddress handles deleting a saved address
+func (h *AuthHandler) DeleteAddress(c *gin.Context) {
+	if err := h.authService.DeleteAddress(c.Request.Context(), c.GetString("user_id"), c.Param("id")); err != nil {
+		c.JSON(profileErrorStatus(err), gin.H{"error": "刪除地址失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "地址已刪除"})
+}
+
+// profileErrorStatus maps profile and address book errors to HTTP status codes
+func profileErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound), errors.Is(err, service.ErrAddressNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrWrongPassword):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrSamePassword):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrTooManyAddresses):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
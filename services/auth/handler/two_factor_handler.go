@@ -0,0 +1,117 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/auth/service"
+)
+
+// VerifyTwoFactor handles completing a login with a TOTP or recovery code
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req models.TwoFactorVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	result, err := h.authService.VerifyTwoFactor(c.Request.Context(), req.ChallengeToken, req.Code, c.ClientIP())
+	if err != nil {
+		writeLoginError(c, "兩步驟驗證失敗", err)
+		return
+	}
+
+	writeLoginResult(c, result)
+}
+
+// EnrollTwoFactor handles issuing a TOTP secret for the current user
+func (h *AuthHandler) EnrollTwoFactor(c *gin.Context) {
+	enrollment, err := h.authService.EnrollTwoFactor(c.Request.Context(), c.GetString("user_id"))
+	if err != nil {
+		c.JSON(twoFactorErrorStatus(err), gin.H{"error": "設定兩步驟驗證失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "請以驗證器掃描並輸入驗證碼確認", "enrollment": enrollment})
+}
+
+// ConfirmTwoFactor handles enabling two-factor authentication with the first code
+func (h *AuthHandler) ConfirmTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	codes, err := h.authService.ConfirmTwoFactor(c.Request.Context(), c.GetString("user_id"), req.Code)
+	if err != nil {
+		writeTwoFactorError(c, "啟用兩步驟驗證失敗", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "兩步驟驗證已啟用，請妥善保存復原碼", "recovery_codes": codes})
+}
+
+// DisableTwoFactor handles turning off two-factor authentication
+func (h *AuthHandler) DisableTwoFactor(c *gin.Context) {
+	var req models.TwoFactorDisableRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	err := h.authService.DisableTwoFactor(c.Request.Context(), c.GetString("user_id"), req.Password, req.Code)
+	if err != nil {
+		writeTwoFactorError(c, "停用兩步驟驗證失敗", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "兩步驟驗證已停用"})
+}
+
+// RegenerateRecoveryCodes handles replacing the recovery codes of the current user
+func (h *AuthHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	codes, err := h.authService.RegenerateRecoveryCodes(c.Request.Context(), c.GetString("user_id"), req.Code)
+	if err != nil {
+		writeTwoFactorError(c, "產生復原碼失敗", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "已產生新的復原碼，舊的復原碼已失效", "recovery_codes": codes})
+}
+
+// writeTwoFactorError writes a two-factor error, including throttling
+func writeTwoFactorError(c *gin.Context, message string, err error) {
+	if errors.Is(err, service.ErrTooManyAttempts) {
+		writeLoginError(c, message, err)
+		return
+	}
+	c.JSON(twoFactorErrorStatus(err), gin.H{"error": message + ": " + err.Error()})
+}
+
+// twoFactorErrorStatus maps two-factor errors to HTTP status codes
+func twoFactorErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrTwoFactorAlreadyEnabled):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrTwoFactorNotEnabled), errors.Is(err, service.ErrNoPendingEnrollment):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrInvalidTwoFactorCode), errors.Is(err, service.ErrInvalidCredentials):
+		return http.StatusUnauthorized
+	case errors.Is(err, service.ErrTwoFactorRequired):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ActionTokenRepository defines the interface for email verification and password reset token operations
+type ActionTokenRepository interface {
+	Create(ctx context.Context, token *models.ActionToken) error
+	Find(ctx context.Context, tokenHash, purpose string) (*models.ActionToken, error)
+	Consume(ctx context.Context, tokenHash, purpose string) (*models.ActionToken, error)
+	InvalidateUser(ctx context.Context, userID, purpose string) error
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+// MongoActionTokenRepository implements ActionTokenRepository using MongoDB
+type MongoActionTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoActionTokenRepository creates a new MongoActionTokenRepository
+func NewMongoActionTokenRepository(db *mongo.Database) ActionTokenRepository {
+	return &MongoActionTokenRepository{
+		collection: db.Collection("action_tokens"),
+	}
+}
+
+// Create stores a new action token
+func (r *MongoActionTokenRepository) Create(ctx context.Context, token *models.ActionToken) error {
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+// Find finds an unused, unexpired token without using it up
+func (r *MongoActionTokenRepository) Find(ctx context.Context, tokenHash, purpose string) (*models.ActionToken, error) {
+	var token models.ActionToken
+	err := r.collection.FindOne(ctx, bson.M{
+		"token_hash": tokenHash,
+		"purpose":    purpose,
+		"used_at":    nil,
+		"expires_at": bson.M{"$gt": time.Now()},
+	}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// Consume atomically marks an unused, unexpired token as used and returns it
+//
+// It returns nil if no such token exists, so a token can only be consumed once.
+func (r *MongoActionTokenRepository) Consume(ctx context.Context, tokenHash, purpose string) (*models.ActionToken, error) {
+	now := time.Now()
+
+	var token models.ActionToken
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{
+			"token_hash": tokenHash,
+			"purpose":    purpose,
+			"used_at":    nil,
+			"expires_at": bson.M{"$gt": now},
+		},
+		bson.M{"$set": bson.M{"used_at": now}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// InvalidateUser marks every outstanding token of the user with the purpose as used
+func (r *MongoActionTokenRepository) InvalidateUser(ctx context.Context, userID, purpose string) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "purpose": purpose, "used_at": nil},
+		bson.M{"$set": bson.M{"used_at": time.Now()}},
+	)
+	return err
+}
+
+// DeleteUser deletes every token of a user
+func (r *MongoActionTokenRepository) DeleteUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
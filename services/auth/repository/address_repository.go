@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AddressRepository defines the interface for address book operations
+//
+// Every method is scoped to a user, so one user can never read or change
+// another user's addresses by ID.
+type AddressRepository interface {
+	List(ctx context.Context, userID string) ([]*models.Address, error)
+	FindByID(ctx context.Context, userID, id string) (*models.Address, error)
+	FindDefault(ctx context.Context, userID, kind string) (*models.Address, error)
+	Count(ctx context.Context, userID string) (int64, error)
+	Create(ctx context.Context, address *models.Address) error
+	Update(ctx context.Context, address *models.Address) (bool, error)
+	Delete(ctx context.Context, userID, id string) (bool, error)
+	DeleteUser(ctx context.Context, userID string) error
+	ClearDefault(ctx context.Context, userID, kind, exceptID string) error
+}
+
+// MongoAddressRepository implements AddressRepository using MongoDB
+type MongoAddressRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAddressRepository creates a new MongoAddressRepository
+func NewMongoAddressRepository(db *mongo.Database) AddressRepository {
+	return &MongoAddressRepository{
+		collection: db.Collection("addresses"),
+	}
+}
+
+// List returns the addresses of a user, oldest first
+func (r *MongoAddressRepository) List(ctx context.Context, userID string) ([]*models.Address, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	addresses := []*models.Address{}
+	if err := cursor.All(ctx, &addresses); err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+// FindByID finds an address of a user
+func (r *MongoAddressRepository) FindByID(ctx context.Context, userID, id string) (*models.Address, error) {
+	return r.findOne(ctx, bson.M{"_id": id, "user_id": userID})
+}
+
+// FindDefault finds the default shipping or billing address of a user
+func (r *MongoAddressRepository) FindDefault(ctx context.Context, userID, kind string) (*models.Address, error) {
+	return r.findOne(ctx, bson.M{"user_id": userID, defaultField(kind): true})
+}
+
+// Count counts the addresses of a user
+func (r *MongoAddressRepository) Count(ctx context.Context, userID string) (int64, error) {
+	return r.collection.CountDocuments(ctx, bson.M{"user_id": userID})
+}
+
+// Create stores a new address
+func (r *MongoAddressRepository) Create(ctx context.Context, address *models.Address) error {
+	_, err := r.collection.InsertOne(ctx, address)
+	return err
+}
+
+// Update replaces an address, returning false if the user does not have it
+func (r *MongoAddressRepository) Update(ctx context.Context, address *models.Address) (bool, error) {
+	address.UpdatedAt = time.Now()
+	result, err := r.collection.ReplaceOne(ctx, bson.M{"_id": address.ID, "user_id": address.UserID}, address)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// Delete deletes an address, returning false if the user does not have it
+func (r *MongoAddressRepository) Delete(ctx context.Context, userID, id string) (bool, error) {
+	result, err := r.collection.DeleteOne(ctx, bson.M{"_id": id, "user_id": userID})
+	if err != nil {
+		return false, err
+	}
+	return result.DeletedCount > 0, nil
+}
+
+// DeleteUser deletes every address of a user
+func (r *MongoAddressRepository) DeleteUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
+
+// ClearDefault removes a default flag from every address of a user except one
+func (r *MongoAddressRepository) ClearDefault(ctx context.Context, userID, kind, exceptID string) error {
+	field := defaultField(kind)
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, field: true, "_id": bson.M{"$ne": exceptID}},
+		bson.M{"$set": bson.M{field: false, "updated_at": time.Now()}},
+	)
+	return err
+}
+
+// findOne finds a single address, returning nil if there is none
+func (r *MongoAddressRepository) findOne(ctx context.Context, filter bson.M) (*models.Address, error) {
+	var address models.Address
+	err := r.collection.FindOne(ctx, filter).Decode(&address)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &address, nil
+}
+
+// defaultField returns the document field of a default address kind
+func defaultField(kind string) string {
+	if kind == models.AddressDefaultBilling {
+		return "default_billing"
+	}
+	return "default_shipping"
+}
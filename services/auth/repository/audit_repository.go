@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// DefaultAuditLimit is the number of audit entries returned when no limit is given
+const DefaultAuditLimit = 100
+
+// AuditRepository defines the interface for auth audit log operations
+type AuditRepository interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+	List(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
+	Anonymize(ctx context.Context, userID, email string) error
+}
+
+// MongoAuditRepository implements AuditRepository using MongoDB
+type MongoAuditRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoAuditRepository creates a new MongoAuditRepository
+func NewMongoAuditRepository(db *mongo.Database) AuditRepository {
+	return &MongoAuditRepository{
+		collection: db.Collection("auth_audit"),
+	}
+}
+
+// Record appends an entry to the audit log
+func (r *MongoAuditRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	_, err := r.collection.InsertOne(ctx, entry)
+	return err
+}
+
+// List returns the newest entries matching the filter
+func (r *MongoAuditRepository) List(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	query := bson.M{}
+	if filter.UserID != "" {
+		query["user_id"] = filter.UserID
+	}
+	if filter.Email != "" {
+		query["email"] = filter.Email
+	}
+	if filter.IP != "" {
+		query["ip"] = filter.IP
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = DefaultAuditLimit
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+	cursor, err := r.collection.Find(ctx, query, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	entries := []*models.AuditEntry{}
+	if err := cursor.All(ctx, &entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Anonymize removes the email address and client IP from the entries of an erased user
+//
+// Failed logins of unknown accounts are recorded by email only, so entries
+// with the user's email address are anonymized too.
+func (r *MongoAuditRepository) Anonymize(ctx context.Context, userID, email string) error {
+	filter := bson.M{"user_id": userID}
+	if email != "" {
+		filter = bson.M{"$or": bson.A{filter, bson.M{"email": email}}}
+	}
+	_, err := r.collection.UpdateMany(ctx, filter, bson.M{"$unset": bson.M{"email": "", "ip": ""}})
+	return err
+}
@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// LoginAttemptRepository defines the interface for failed login counter operations
+//
+// Keys identify what is counted, such as an account or a client IP.
+// Failures older than the window are forgotten when the next one is recorded.
+type LoginAttemptRepository interface {
+	Get(ctx context.Context, key string) (*models.LoginAttempts, error)
+	RecordFailure(ctx context.Context, key string, window time.Duration) (*models.LoginAttempts, error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// MongoLoginAttemptRepository implements LoginAttemptRepository using MongoDB
+//
+// Counters are removed by a TTL index on expires_at once both the window and
+// any lock have passed.
+type MongoLoginAttemptRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoLoginAttemptRepository creates a new MongoLoginAttemptRepository
+func NewMongoLoginAttemptRepository(db *mongo.Database) LoginAttemptRepository {
+	return &MongoLoginAttemptRepository{
+		collection: db.Collection("login_attempts"),
+	}
+}
+
+// Get finds the counter of a key
+func (r *MongoLoginAttemptRepository) Get(ctx context.Context, key string) (*models.LoginAttempts, error) {
+	var attempts models.LoginAttempts
+	err := r.collection.FindOne(ctx, bson.M{"_id": key}).Decode(&attempts)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+// RecordFailure atomically counts a failure and returns the updated counter
+func (r *MongoLoginAttemptRepository) RecordFailure(ctx context.Context, key string, window time.Duration) (*models.LoginAttempts, error) {
+	now := time.Now()
+	inWindow := bson.M{"$gt": bson.A{"$last_failure_at", now.Add(-window)}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"failures":         bson.M{"$cond": bson.A{inWindow, bson.M{"$add": bson.A{"$failures", 1}}, 1}},
+			"first_failure_at": bson.M{"$cond": bson.A{inWindow, "$first_failure_at", now}},
+			"last_failure_at":  now,
+			"expires_at":       bson.M{"$max": bson.A{"$expires_at", now.Add(window)}},
+		}}},
+	}
+
+	var attempts models.LoginAttempts
+	err := r.collection.FindOneAndUpdate(ctx, bson.M{"_id": key}, pipeline,
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&attempts)
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+// Lock locks a key until the given time
+func (r *MongoLoginAttemptRepository) Lock(ctx context.Context, key string, until time.Time) error {
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": key}, bson.M{
+		"$set": bson.M{"locked_until": until},
+		"$max": bson.M{"expires_at": until},
+	}, options.Update().SetUpsert(true))
+	return err
+}
+
+// Reset clears the counter and lock of a key
+func (r *MongoLoginAttemptRepository) Reset(ctx context.Context, key string) error {
+	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": key})
+	return err
+}
+
+// MemoryLoginAttemptRepository implements LoginAttemptRepository in memory
+//
+// Counters are not shared between instances, so it only suits a single auth
+// service instance and tests.
+type MemoryLoginAttemptRepository struct {
+	mu       sync.Mutex
+	attempts map[string]models.LoginAttempts
+}
+
+// NewMemoryLoginAttemptRepository creates a new MemoryLoginAttemptRepository
+func NewMemoryLoginAttemptRepository() LoginAttemptRepository {
+	return &MemoryLoginAttemptRepository{
+		attempts: make(map[string]models.LoginAttempts),
+	}
+}
+
+// Get finds the counter of a key
+func (r *MemoryLoginAttemptRepository) Get(_ context.Context, key string) (*models.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts, ok := r.attempts[key]
+	if !ok || !time.Now().Before(attempts.ExpiresAt) {
+		delete(r.attempts, key)
+		return nil, nil
+	}
+	return &attempts, nil
+}
+
+// RecordFailure counts a failure and returns the updated counter
+func (r *MemoryLoginAttemptRepository) RecordFailure(_ context.Context, key string, window time.Duration) (*models.LoginAttempts, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	attempts, ok := r.attempts[key]
+	if ok && attempts.LastFailureAt.After(now.Add(-window)) {
+		attempts.Failures++
+	} else {
+		attempts.Key = key
+		attempts.Failures = 1
+		attempts.FirstFailureAt = now
+	}
+	attempts.LastFailureAt = now
+	if expiresAt := now.Add(window); expiresAt.After(attempts.ExpiresAt) {
+		attempts.ExpiresAt = expiresAt
+	}
+
+	r.attempts[key] = attempts
+	return &attempts, nil
+}
+
+// Lock locks a key until the given time
+func (r *MemoryLoginAttemptRepository) Lock(_ context.Context, key string, until time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	attempts := r.attempts[key]
+	attempts.Key = key
+	attempts.LockedUntil = &until
+	if until.After(attempts.ExpiresAt) {
+		attempts.ExpiresAt = until
+	}
+
+	r.attempts[key] = attempts
+	return nil
+}
+
+// Reset clears the counter and lock of a key
+func (r *MemoryLoginAttemptRepository) Reset(_ context.Context, key string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.attempts, key)
+	return nil
+}
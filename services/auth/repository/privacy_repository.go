@@ -0,0 +1,209 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// PrivacyRepository defines the interface for data subject request operations
+//
+// It is also the outbox.Store of the auth service: the request event is kept
+// in an outbox array of the request document and inserted together with it.
+type PrivacyRepository interface {
+	outbox.Store
+	Create(ctx context.Context, request *models.PrivacyRequest, event outbox.Message) error
+	FindByID(ctx context.Context, id string) (*models.PrivacyRequest, error)
+	FindPending(ctx context.Context, userID, kind string) (*models.PrivacyRequest, error)
+	ListByUser(ctx context.Context, userID string) ([]*models.PrivacyRequest, error)
+	SetService(ctx context.Context, id, service string, result *models.PrivacyService) (*models.PrivacyRequest, error)
+	Finish(ctx context.Context, id, status string, exportExpiresAt *time.Time) (bool, error)
+	PurgeExports(ctx context.Context, now time.Time) (int64, error)
+}
+
+// MongoPrivacyRepository implements PrivacyRepository using MongoDB
+type MongoPrivacyRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoPrivacyRepository creates a new MongoPrivacyRepository
+func NewMongoPrivacyRepository(db *mongo.Database) PrivacyRepository {
+	return &MongoPrivacyRepository{
+		collection: db.Collection("privacy_requests"),
+	}
+}
+
+// privacyDocument is a request document together with its unsent events
+type privacyDocument struct {
+	models.PrivacyRequest `bson:",inline"`
+	Outbox                []outbox.Message `bson:"outbox"`
+}
+
+// Create stores a new request together with the event announcing it
+func (r *MongoPrivacyRepository) Create(ctx context.Context, request *models.PrivacyRequest, event outbox.Message) error {
+	_, err := r.collection.InsertOne(ctx, privacyDocument{PrivacyRequest: *request, Outbox: []outbox.Message{event}})
+	return err
+}
+
+// FindByID finds a request by ID
+func (r *MongoPrivacyRepository) FindByID(ctx context.Context, id string) (*models.PrivacyRequest, error) {
+	return r.findOne(ctx, bson.M{"_id": id})
+}
+
+// FindPending finds an unfinished request of a user
+func (r *MongoPrivacyRepository) FindPending(ctx context.Context, userID, kind string) (*models.PrivacyRequest, error) {
+	return r.findOne(ctx, bson.M{"user_id": userID, "kind": kind, "status": models.PrivacyPending})
+}
+
+// ListByUser returns the requests of a user, newest first
+func (r *MongoPrivacyRepository) ListByUser(ctx context.Context, userID string) ([]*models.PrivacyRequest, error) {
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}}).
+		SetProjection(bson.M{"outbox": 0})
+	cursor, err := r.collection.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	requests := []*models.PrivacyRequest{}
+	if err := cursor.All(ctx, &requests); err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// SetService records the result of one service and returns the updated request
+//
+// Results of finished requests are ignored and nil is returned.
+func (r *MongoPrivacyRepository) SetService(ctx context.Context, id, service string, result *models.PrivacyService) (*models.PrivacyRequest, error) {
+	var request models.PrivacyRequest
+	err := r.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": id, "status": models.PrivacyPending},
+		bson.M{"$set": bson.M{"services." + service: result}},
+		options.FindOneAndUpdate().
+			SetReturnDocument(options.After).
+			SetProjection(bson.M{"outbox": 0}),
+	).Decode(&request)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
+
+// Finish marks a pending request as completed or failed
+//
+// It returns false if the request was already finished, so only one of
+// several concurrent callers acts on the final result.
+func (r *MongoPrivacyRepository) Finish(ctx context.Context, id, status string, exportExpiresAt *time.Time) (bool, error) {
+	set := bson.M{"status": status, "completed_at": time.Now()}
+	if exportExpiresAt != nil {
+		set["export_expires_at"] = *exportExpiresAt
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "status": models.PrivacyPending},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// PurgeExports removes the exported data of requests whose download period is over
+//
+// The request itself is kept as a record that the request was answered.
+func (r *MongoPrivacyRepository) PurgeExports(ctx context.Context, now time.Time) (int64, error) {
+	cursor, err := r.collection.Find(ctx,
+		bson.M{"export_expires_at": bson.M{"$lte": now}},
+		options.Find().SetProjection(bson.M{"services": 1}),
+	)
+	if err != nil {
+		return 0, err
+	}
+	defer cursor.Close(ctx)
+
+	var purged int64
+	for cursor.Next(ctx) {
+		var request models.PrivacyRequest
+		if err := cursor.Decode(&request); err != nil {
+			return purged, err
+		}
+
+		unset := bson.M{"export_expires_at": ""}
+		for service := range request.Services {
+			unset["services."+service+".data"] = ""
+		}
+		if _, err := r.collection.UpdateOne(ctx, bson.M{"_id": request.ID}, bson.M{"$unset": unset}); err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, cursor.Err()
+}
+
+// Pending returns unsent request events, oldest first
+func (r *MongoPrivacyRepository) Pending(ctx context.Context, limit int) ([]outbox.Message, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"outbox.0": bson.M{"$exists": true}}}},
+		{{Key: "$unwind", Value: "$outbox"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$outbox"}}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []outbox.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+// MarkSent removes a published event from its request
+func (r *MongoPrivacyRepository) MarkSent(ctx context.Context, id string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"outbox._id": id},
+		bson.M{"$pull": bson.M{"outbox": bson.M{"_id": id}}},
+	)
+	return err
+}
+
+// MarkFailed records a failed attempt to publish an event
+func (r *MongoPrivacyRepository) MarkFailed(ctx context.Context, id string, cause error) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"outbox._id": id},
+		bson.M{
+			"$inc": bson.M{"outbox.$.attempts": 1},
+			"$set": bson.M{"outbox.$.last_error": cause.Error()},
+		},
+	)
+	return err
+}
+
+// findOne finds a single request, returning nil if there is none
+func (r *MongoPrivacyRepository) findOne(ctx context.Context, filter bson.M) (*models.PrivacyRequest, error) {
+	var request models.PrivacyRequest
+	err := r.collection.FindOne(ctx, filter, options.FindOne().SetProjection(bson.M{"outbox": 0})).Decode(&request)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &request, nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// twoFactorPolicyID is the document ID of the two-factor policy
+const twoFactorPolicyID = "two_factor_policy"
+
+// SettingsRepository defines the interface for auth service settings operations
+type SettingsRepository interface {
+	GetTwoFactorPolicy(ctx context.Context) (*models.TwoFactorPolicy, error)
+	SetTwoFactorPolicy(ctx context.Context, policy *models.TwoFactorPolicy) error
+}
+
+// MongoSettingsRepository implements SettingsRepository using MongoDB
+type MongoSettingsRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoSettingsRepository creates a new MongoSettingsRepository
+func NewMongoSettingsRepository(db *mongo.Database) SettingsRepository {
+	return &MongoSettingsRepository{
+		collection: db.Collection("auth_settings"),
+	}
+}
+
+// GetTwoFactorPolicy returns the two-factor policy, which is empty if it was never set
+func (r *MongoSettingsRepository) GetTwoFactorPolicy(ctx context.Context) (*models.TwoFactorPolicy, error) {
+	var policy models.TwoFactorPolicy
+	err := r.collection.FindOne(ctx, bson.M{"_id": twoFactorPolicyID}).Decode(&policy)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return &models.TwoFactorPolicy{RequiredRoles: []string{}}, nil
+		}
+		return nil, err
+	}
+	return &policy, nil
+}
+
+// SetTwoFactorPolicy stores the two-factor policy
+func (r *MongoSettingsRepository) SetTwoFactorPolicy(ctx context.Context, policy *models.TwoFactorPolicy) error {
+	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": twoFactorPolicyID}, policy, options.Replace().SetUpsert(true))
+	return err
+}
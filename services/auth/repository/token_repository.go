@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RefreshTokenRepository defines the interface for refresh token repository operations
+type RefreshTokenRepository interface {
+	Create(ctx context.Context, token *models.RefreshToken) error
+	FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error)
+	MarkUsed(ctx context.Context, id, replacedBy string) (bool, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+	RevokeUser(ctx context.Context, userID string) ([]string, error)
+	DeleteUser(ctx context.Context, userID string) error
+}
+
+// MongoRefreshTokenRepository implements RefreshTokenRepository using MongoDB
+type MongoRefreshTokenRepository struct {
+	collection *mongo.Collection
+}
+
+// NewMongoRefreshTokenRepository creates a new MongoRefreshTokenRepository
+func NewMongoRefreshTokenRepository(db *mongo.Database) RefreshTokenRepository {
+	return &MongoRefreshTokenRepository{
+		collection: db.Collection("refresh_tokens"),
+	}
+}
+
+// Create stores a new refresh token
+func (r *MongoRefreshTokenRepository) Create(ctx context.Context, token *models.RefreshToken) error {
+	_, err := r.collection.InsertOne(ctx, token)
+	return err
+}
+
+// FindByHash finds a refresh token by the hash of its value
+func (r *MongoRefreshTokenRepository) FindByHash(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	err := r.collection.FindOne(ctx, bson.M{"token_hash": tokenHash}).Decode(&token)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &token, nil
+}
+
+// MarkUsed marks an active refresh token as rotated
+//
+// It returns false if the token was already used or revoked, which lets two
+// concurrent refreshes with the same token be detected as reuse.
+func (r *MongoRefreshTokenRepository) MarkUsed(ctx context.Context, id, replacedBy string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "used_at": nil, "revoked_at": nil},
+		bson.M{"$set": bson.M{"used_at": time.Now(), "replaced_by": replacedBy}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// RevokeFamily revokes every token of a refresh token family
+func (r *MongoRefreshTokenRepository) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := r.collection.UpdateMany(ctx,
+		bson.M{"family_id": familyID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	)
+	return err
+}
+
+// RevokeUser revokes every active token of a user and returns the affected family IDs
+func (r *MongoRefreshTokenRepository) RevokeUser(ctx context.Context, userID string) ([]string, error) {
+	filter := bson.M{"user_id": userID, "revoked_at": nil, "expires_at": bson.M{"$gt": time.Now()}}
+
+	families, err := r.collection.Distinct(ctx, "family_id", filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := r.collection.UpdateMany(ctx,
+		bson.M{"user_id": userID, "revoked_at": nil},
+		bson.M{"$set": bson.M{"revoked_at": time.Now()}},
+	); err != nil {
+		return nil, err
+	}
+
+	familyIDs := make([]string, 0, len(families))
+	for _, family := range families {
+		if id, ok := family.(string); ok {
+			familyIDs = append(familyIDs, id)
+		}
+	}
+	return familyIDs, nil
+}
+
+// DeleteUser deletes every token of a user
+func (r *MongoRefreshTokenRepository) DeleteUser(ctx context.Context, userID string) error {
+	_, err := r.collection.DeleteMany(ctx, bson.M{"user_id": userID})
+	return err
+}
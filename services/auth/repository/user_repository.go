@@ -17,6 +17,11 @@ type UserRepository interface {
 	FindByEmail(ctx context.Context, email string) (*models.User, error)
 	Update(ctx context.Context, user *models.User) error
 	Delete(ctx context.Context, id string) error
+	GrantRole(ctx context.Context, id, role string) (bool, error)
+	RevokeRole(ctx context.Context, id, role, fallback string) (bool, error)
+	SetTwoFactor(ctx context.Context, id string, settings *models.TwoFactor) error
+	UseTwoFactorStep(ctx context.Context, id string, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, id, codeHash string) (bool, error)
 }
 
 // MongoUserRepository implements UserRepository using MongoDB
@@ -82,3 +87,79 @@ func (r *MongoUserRepository) Delete(ctx context.Context, id string) error {
 	_, err := r.collection.DeleteOne(ctx, bson.M{"_id": id})
 	return err
 }
+
+// GrantRole adds a role to a user, returning false if the user does not exist
+func (r *MongoUserRepository) GrantRole(ctx context.Context, id, role string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, bson.M{
+		"$addToSet": bson.M{"roles": role},
+		"$set":      bson.M{"updated_at": time.Now()},
+	})
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// RevokeRole removes a role from a user, returning false if the user does not exist
+//
+// If the role is also the user's primary role, the primary role is reset to
+// fallback in the same update.
+func (r *MongoUserRepository) RevokeRole(ctx context.Context, id, role, fallback string) (bool, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"roles":      bson.M{"$setDifference": bson.A{bson.M{"$ifNull": bson.A{"$roles", bson.A{}}}, bson.A{role}}},
+			"role":       bson.M{"$cond": bson.A{bson.M{"$eq": bson.A{"$role", role}}, fallback, "$role"}},
+			"updated_at": time.Now(),
+		}}},
+	}
+	result, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, pipeline)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// SetTwoFactor replaces the two-factor settings of a user, removing them if settings is nil
+func (r *MongoUserRepository) SetTwoFactor(ctx context.Context, id string, settings *models.TwoFactor) error {
+	update := bson.M{"$set": bson.M{"two_factor": settings, "updated_at": time.Now()}}
+	if settings == nil {
+		update = bson.M{"$unset": bson.M{"two_factor": ""}, "$set": bson.M{"updated_at": time.Now()}}
+	}
+
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": id}, update)
+	return err
+}
+
+// UseTwoFactorStep records the time step of an accepted TOTP code
+//
+// It returns false if a code of the same or a later step was already
+// accepted, which rejects replayed codes even under concurrent logins.
+func (r *MongoUserRepository) UseTwoFactorStep(ctx context.Context, id string, step int64) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{
+			"_id":                id,
+			"two_factor.enabled": true,
+			"$or": bson.A{
+				bson.M{"two_factor.last_used_step": bson.M{"$lt": step}},
+				bson.M{"two_factor.last_used_step": bson.M{"$exists": false}},
+			},
+		},
+		bson.M{"$set": bson.M{"two_factor.last_used_step": step}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
+
+// UseRecoveryCode removes a recovery code, returning false if the user does not have it
+func (r *MongoUserRepository) UseRecoveryCode(ctx context.Context, id, codeHash string) (bool, error) {
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"_id": id, "two_factor.enabled": true, "two_factor.recovery_codes": codeHash},
+		bson.M{"$pull": bson.M{"two_factor.recovery_codes": codeHash}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.ModifiedCount > 0, nil
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/mailer"
+	"github.com/arrontsai/ecommerce/pkg/models"
+)
+
+// Lifetimes of action tokens
+const (
+	EmailVerificationTTL = 24 * time.Hour
+	PasswordResetTTL     = time.Hour
+)
+
+var (
+	// ErrEmailNotVerified is returned on login when verification is required
+	ErrEmailNotVerified = errors.New("電子郵件尚未驗證")
+	// ErrAccountInactive is returned on login to a deactivated account, e.g. one being erased
+	ErrAccountInactive = errors.New("帳號已停用")
+	// ErrInvalidActionToken is returned when a verification or reset token is unknown, used or expired
+	ErrInvalidActionToken = errors.New("無效或已過期的令牌")
+	// ErrVerificationMailFailed is returned when the verification email could not be sent
+	ErrVerificationMailFailed = errors.New("驗證郵件寄送失敗")
+)
+
+// VerifyEmail marks the email address of the token's user as verified
+func (s *DefaultAuthService) VerifyEmail(ctx context.Context, token string) error {
+	user, err := s.consumeActionToken(ctx, token, models.TokenPurposeEmailVerification)
+	if err != nil {
+		return err
+	}
+	if user.EmailVerified {
+		return nil
+	}
+
+	markVerified(user)
+	return s.userRepo.Update(ctx, user)
+}
+
+// ResendVerification sends a new verification email
+//
+// Unknown and already verified addresses are ignored without an error, so
+// the endpoint cannot be used to find out which addresses have accounts.
+func (s *DefaultAuthService) ResendVerification(ctx context.Context, email string) error {
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil || user.EmailVerified {
+		return nil
+	}
+
+	return s.sendVerification(ctx, user)
+}
+
+// RequestPasswordReset sends a password reset email
+//
+// Unknown addresses are ignored without an error for the same reason as in
+// ResendVerification.
+func (s *DefaultAuthService) RequestPasswordReset(ctx context.Context, email string) error {
+	user, err := s.userRepo.FindByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if user == nil || !user.Active {
+		return nil
+	}
+
+	token, err := s.createActionToken(ctx, user.ID, models.TokenPurposePasswordReset, PasswordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "重設您的密碼",
+		Body: fmt.Sprintf("%s 您好：\n\n請在 %d 分鐘內開啟以下連結重設密碼：\n\n%s\n\n如果您沒有要求重設密碼，請忽略這封郵件。\n",
+			user.FirstName, int(PasswordResetTTL.Minutes()), s.link("/reset-password", token)),
+	})
+}
+
+// ResetPassword sets a new password with a password reset token
+//
+// Every session of the user is revoked, and the email counts as verified
+// because the user proved access to it.
+func (s *DefaultAuthService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	user, err := s.consumeActionToken(ctx, token, models.TokenPurposePasswordReset)
+	if err != nil {
+		return err
+	}
+
+	if err := user.SetPassword(newPassword); err != nil {
+		return err
+	}
+	if !user.EmailVerified {
+		markVerified(user)
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return err
+	}
+	if err := s.LogoutAll(ctx, user.ID); err != nil {
+		return err
+	}
+
+	// A successful reset also lifts a lockout of the account
+	if err := s.attemptRepo.Reset(ctx, loginKey{kind: lockAccount, value: normalizeEmail(user.Email)}.String()); err != nil {
+		return err
+	}
+	return s.audit(ctx, &models.AuditEntry{Event: models.AuditPasswordReset, UserID: user.ID, Email: normalizeEmail(user.Email)})
+}
+
+// sendVerification sends an email verification link to the user
+func (s *DefaultAuthService) sendVerification(ctx context.Context, user *models.User) error {
+	token, err := s.createActionToken(ctx, user.ID, models.TokenPurposeEmailVerification, EmailVerificationTTL)
+	if err != nil {
+		return err
+	}
+
+	return s.mailer.Send(ctx, mailer.Message{
+		To:      user.Email,
+		Subject: "請驗證您的電子郵件",
+		Body: fmt.Sprintf("%s 您好：\n\n感謝您的註冊，請在 %d 小時內開啟以下連結驗證電子郵件：\n\n%s\n",
+			user.FirstName, int(EmailVerificationTTL.Hours()), s.link("/verify-email", token)),
+	})
+}
+
+// createActionToken invalidates the user's earlier tokens with the purpose
+// and creates a new one, returning its value
+func (s *DefaultAuthService) createActionToken(ctx context.Context, userID, purpose string, ttl time.Duration) (string, error) {
+	if err := s.actionTokenRepo.InvalidateUser(ctx, userID, purpose); err != nil {
+		return "", err
+	}
+
+	secret, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.actionTokenRepo.Create(ctx, &models.ActionToken{
+		ID:        newTokenID(),
+		TokenHash: hashToken(secret),
+		UserID:    userID,
+		Purpose:   purpose,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// consumeActionToken uses up a token and returns its user
+func (s *DefaultAuthService) consumeActionToken(ctx context.Context, token, purpose string) (*models.User, error) {
+	stored, err := s.actionTokenRepo.Consume(ctx, hashToken(token), purpose)
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil {
+		return nil, ErrInvalidActionToken
+	}
+
+	user, err := s.userRepo.FindByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.Active {
+		return nil, ErrInvalidActionToken
+	}
+	return user, nil
+}
+
+// link builds a link to the frontend carrying the token
+func (s *DefaultAuthService) link(path, token string) string {
+	return strings.TrimRight(s.accounts.LinkBaseURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// markVerified marks the user's email address as verified
+func markVerified(user *models.User) {
+	now := time.Now()
+	user.EmailVerified = true
+	user.EmailVerifiedAt = &now
+}
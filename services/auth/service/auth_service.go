@@ -3,7 +3,11 @@ package service
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/mailer"
 	"github.com/arrontsai/ecommerce/pkg/middleware"
 	"github.com/arrontsai/ecommerce/pkg/models"
 	"github.com/arrontsai/ecommerce/services/auth/repository"
@@ -12,23 +16,110 @@ import (
 // AuthService defines the interface for authentication service operations
 type AuthService interface {
 	Register(ctx context.Context, req models.UserRegistration) (*models.UserResponse, error)
-	Login(ctx context.Context, req models.UserLogin) (string, *models.UserResponse, error)
+	Login(ctx context.Context, req models.UserLogin, clientIP string) (*LoginResult, error)
+	VerifyTwoFactor(ctx context.Context, challengeToken, code, clientIP string) (*LoginResult, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error)
+	Logout(ctx context.Context, familyID, accessTokenID string) error
+	LogoutAll(ctx context.Context, userID string) error
 	GetUserByID(ctx context.Context, id string) (*models.UserResponse, error)
+	UpdateProfile(ctx context.Context, userID string, req models.ProfileUpdateRequest) (*models.UserResponse, error)
+	ChangePassword(ctx context.Context, userID string, req models.ChangePasswordRequest, clientIP string) (*models.TokenPair, error)
+	ListAddresses(ctx context.Context, userID string) ([]*models.Address, error)
+	GetAddress(ctx context.Context, userID, id string) (*models.Address, error)
+	CreateAddress(ctx context.Context, userID string, req models.AddressRequest) (*models.Address, error)
+	UpdateAddress(ctx context.Context, userID, id string, req models.AddressRequest) (*models.Address, error)
+	DeleteAddress(ctx context.Context, userID, id string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ResendVerification(ctx context.Context, email string) error
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	GrantRole(ctx context.Context, actorID, userID, role string) (*models.UserResponse, error)
+	RevokeRole(ctx context.Context, actorID, userID, role string) (*models.UserResponse, error)
+	UnlockUser(ctx context.Context, actorID, userID string) error
+	UnlockIP(ctx context.Context, actorID, ip string) error
+	ListAudit(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error)
+	EnrollTwoFactor(ctx context.Context, userID string) (*models.TOTPEnrollment, error)
+	ConfirmTwoFactor(ctx context.Context, userID, code string) ([]string, error)
+	DisableTwoFactor(ctx context.Context, userID, password, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error)
+	GetTwoFactorPolicy(ctx context.Context) (*models.TwoFactorPolicy, error)
+	SetTwoFactorPolicy(ctx context.Context, actorID string, roles []string) (*models.TwoFactorPolicy, error)
+	RequestPrivacy(ctx context.Context, actorID, userID, kind string) (*models.PrivacyRequest, error)
+	RequestErasure(ctx context.Context, userID, password, clientIP string) (*models.PrivacyRequest, error)
+	GetPrivacyRequest(ctx context.Context, userID, id string) (*models.PrivacyRequest, error)
+	ListPrivacyRequests(ctx context.Context, userID string) ([]*models.PrivacyRequest, error)
+	GetDataExport(ctx context.Context, userID, id string) (*models.DataExport, error)
+	RecordPrivacyResult(ctx context.Context, result events.DataSubjectCompleted) error
+}
+
+// TokenConfig holds the token settings of the auth service
+type TokenConfig struct {
+	Signer          middleware.TokenSigner
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// AccountConfig holds the account settings of the auth service
+type AccountConfig struct {
+	// RequireEmailVerification refuses login until the email is verified
+	RequireEmailVerification bool
+	// LinkBaseURL is the base URL of verification and password reset links
+	LinkBaseURL string
+	// Lockout throttles failed logins
+	Lockout LockoutPolicy
+	// TOTPIssuer names the service in authenticator apps
+	TOTPIssuer string
+	// PrivacyServices are the other services that handle data subject requests
+	PrivacyServices []string
+	// ExportRetention is how long exported data can be downloaded
+	ExportRetention time.Duration
+}
+
+// Dependencies holds the stores and clients used by the auth service
+type Dependencies struct {
+	Users         repository.UserRepository
+	RefreshTokens repository.RefreshTokenRepository
+	ActionTokens  repository.ActionTokenRepository
+	LoginAttempts repository.LoginAttemptRepository
+	Audit         repository.AuditRepository
+	Settings      repository.SettingsRepository
+	Addresses     repository.AddressRepository
+	Privacy       repository.PrivacyRepository
+	Denylist      middleware.TokenDenylist
+	Mailer        mailer.Mailer
 }
 
 // DefaultAuthService implements AuthService
 type DefaultAuthService struct {
-	userRepo  repository.UserRepository
-	jwtSecret string
-	jwtExpiry int
+	userRepo        repository.UserRepository
+	tokenRepo       repository.RefreshTokenRepository
+	actionTokenRepo repository.ActionTokenRepository
+	attemptRepo     repository.LoginAttemptRepository
+	auditRepo       repository.AuditRepository
+	settingsRepo    repository.SettingsRepository
+	addressRepo     repository.AddressRepository
+	privacyRepo     repository.PrivacyRepository
+	denylist        middleware.TokenDenylist
+	mailer          mailer.Mailer
+	tokens          TokenConfig
+	accounts        AccountConfig
 }
 
 // NewAuthService creates a new AuthService
-func NewAuthService(userRepo repository.UserRepository, jwtSecret string, jwtExpiry int) AuthService {
+func NewAuthService(deps Dependencies, tokens TokenConfig, accounts AccountConfig) AuthService {
 	return &DefaultAuthService{
-		userRepo:  userRepo,
-		jwtSecret: jwtSecret,
-		jwtExpiry: jwtExpiry,
+		userRepo:        deps.Users,
+		tokenRepo:       deps.RefreshTokens,
+		actionTokenRepo: deps.ActionTokens,
+		attemptRepo:     deps.LoginAttempts,
+		auditRepo:       deps.Audit,
+		settingsRepo:    deps.Settings,
+		addressRepo:     deps.Addresses,
+		privacyRepo:     deps.Privacy,
+		denylist:        deps.Denylist,
+		mailer:          deps.Mailer,
+		tokens:          tokens,
+		accounts:        accounts,
 	}
 }
 
@@ -54,36 +145,85 @@ func (s *DefaultAuthService) Register(ctx context.Context, req models.UserRegist
 		return nil, err
 	}
 
-	// Return the user response
+	// The account exists even if the email cannot be sent, and the user can
+	// ask for it again, so the response is returned together with the error
 	response := user.ToResponse()
+	if err := s.sendVerification(ctx, user); err != nil {
+		return &response, fmt.Errorf("%w: %v", ErrVerificationMailFailed, err)
+	}
 	return &response, nil
 }
 
-// Login authenticates a user and returns a JWT token
-func (s *DefaultAuthService) Login(ctx context.Context, req models.UserLogin) (string, *models.UserResponse, error) {
+// Login authenticates a user and returns an access token and a refresh token
+//
+// Unknown emails and wrong passwords fail with the same ErrInvalidCredentials
+// and take the same time. Repeated failures per account and per client IP
+// are throttled, see LockoutPolicy. If the user has two-factor authentication
+// on, or a role requires it, a challenge for VerifyTwoFactor is returned
+// instead of the tokens.
+func (s *DefaultAuthService) Login(ctx context.Context, req models.UserLogin, clientIP string) (*LoginResult, error) {
+	keys := loginKeys(req.Email, clientIP)
+	if err := s.checkThrottle(ctx, keys); err != nil {
+		return nil, err
+	}
+
 	// Find the user
 	user, err := s.userRepo.FindByEmail(ctx, req.Email)
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
-	if user == nil {
-		return "", nil, errors.New("用戶不存在")
+
+	// Check the password, also for unknown users so timing reveals nothing
+	if !checkPassword(user, req.Password) {
+		entry := &models.AuditEntry{Event: models.AuditLoginFailed, Email: normalizeEmail(req.Email), IP: clientIP}
+		if user != nil {
+			entry.UserID = user.ID
+		}
+		if err := s.recordLoginFailure(ctx, keys, entry); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidCredentials
 	}
 
-	// Check the password
-	if !user.CheckPassword(req.Password) {
-		return "", nil, errors.New("密碼不正確")
+	// Only the account counter is reset, so an attacker cannot clear the IP
+	// counter by logging in to their own account between guesses
+	if err := s.attemptRepo.Reset(ctx, keys[0].String()); err != nil {
+		return nil, err
 	}
 
-	// Generate a JWT token
-	token, err := middleware.GenerateJWT(user.ID, user.Role, s.jwtSecret, s.jwtExpiry/3600) // Convert seconds to hours
+	if !user.Active {
+		return nil, ErrAccountInactive
+	}
+	if s.accounts.RequireEmailVerification && !user.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	required, err := s.twoFactorRequired(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	if required || user.TwoFactorEnabled() {
+		return s.startTwoFactor(ctx, user)
+	}
+
+	return s.completeLogin(ctx, user, clientIP, "")
+}
+
+// completeLogin starts a new session for an authenticated user
+func (s *DefaultAuthService) completeLogin(ctx context.Context, user *models.User, clientIP, method string) (*LoginResult, error) {
+	if err := s.recordLoginSuccess(ctx, user, clientIP, method); err != nil {
+		return nil, err
+	}
+
+	// Start a new refresh token family for this session
+	tokens, err := s.issueTokens(ctx, user, newTokenID())
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
 
-	// Return the token and user response
+	// Return the tokens and user response
 	response := user.ToResponse()
-	return token, &response, nil
+	return &LoginResult{Tokens: tokens, User: &response}, nil
 }
 
 // GetUserByID gets a user by ID
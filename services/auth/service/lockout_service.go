@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	// ErrInvalidCredentials is returned for both unknown emails and wrong passwords
+	ErrInvalidCredentials = errors.New("電子郵件或密碼不正確")
+	// ErrTooManyAttempts is returned while logins are throttled or locked
+	ErrTooManyAttempts = errors.New("登入嘗試次數過多，請稍後再試")
+)
+
+// ThrottledError is returned when a login is refused because of earlier
+// failures. It matches ErrTooManyAttempts with errors.Is.
+type ThrottledError struct {
+	RetryAfter time.Duration
+}
+
+// Error implements the error interface
+func (e *ThrottledError) Error() string {
+	return fmt.Sprintf("%s (%d 秒後)", ErrTooManyAttempts.Error(), int(e.RetryAfter.Seconds()+0.5))
+}
+
+// Unwrap returns ErrTooManyAttempts
+func (e *ThrottledError) Unwrap() error {
+	return ErrTooManyAttempts
+}
+
+// LockoutPolicy controls how failed logins are throttled
+//
+// Failures are counted per account (by email, whether or not it exists) and
+// per client IP. After FreeAttempts failures, each further attempt has to
+// wait BaseDelay, doubling with every failure up to MaxDelay. Reaching a
+// threshold locks the account or IP for LockDuration. Counters are forgotten
+// once no failure happened for Window.
+type LockoutPolicy struct {
+	Window           time.Duration
+	FreeAttempts     int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	AccountThreshold int
+	IPThreshold      int
+	LockDuration     time.Duration
+}
+
+// DefaultLockoutPolicy returns the default lockout policy
+func DefaultLockoutPolicy() LockoutPolicy {
+	return LockoutPolicy{
+		Window:           15 * time.Minute,
+		FreeAttempts:     3,
+		BaseDelay:        time.Second,
+		MaxDelay:         30 * time.Second,
+		AccountThreshold: 5,
+		IPThreshold:      20,
+		LockDuration:     15 * time.Minute,
+	}
+}
+
+// delay returns how long to wait after the last of the given failures
+func (p LockoutPolicy) delay(failures int) time.Duration {
+	if failures < p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Kinds of login attempt counters
+const (
+	lockAccount = "account"
+	lockIP      = "ip"
+)
+
+// loginKey identifies a login attempt counter
+type loginKey struct {
+	kind  string
+	value string
+}
+
+// String returns the key of the counter in the repository
+func (k loginKey) String() string {
+	return k.kind + ":" + k.value
+}
+
+// loginKeys returns the counters a login attempt is checked against
+func loginKeys(email, clientIP string) []loginKey {
+	keys := []loginKey{{kind: lockAccount, value: normalizeEmail(email)}}
+	if clientIP != "" {
+		keys = append(keys, loginKey{kind: lockIP, value: clientIP})
+	}
+	return keys
+}
+
+// threshold returns the failures that lock the counter
+func (p LockoutPolicy) threshold(key loginKey) int {
+	if key.kind == lockIP {
+		return p.IPThreshold
+	}
+	return p.AccountThreshold
+}
+
+// dummyPasswordHash is compared against when the user does not exist
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password for timing"), bcrypt.DefaultCost)
+
+// checkPassword checks the password of a possibly missing user in constant time
+func checkPassword(user *models.User, password string) bool {
+	if user == nil {
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(password))
+		return false
+	}
+	return user.CheckPassword(password)
+}
+
+// checkThrottle refuses the attempt while any counter is locked or delayed
+func (s *DefaultAuthService) checkThrottle(ctx context.Context, keys []loginKey) error {
+	now := time.Now()
+	policy := s.accounts.Lockout
+
+	var retryAfter time.Duration
+	for _, key := range keys {
+		attempts, err := s.attemptRepo.Get(ctx, key.String())
+		if err != nil {
+			return err
+		}
+		if attempts == nil {
+			continue
+		}
+
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(now) {
+			retryAfter = maxDuration(retryAfter, attempts.LockedUntil.Sub(now))
+		}
+		if attempts.LastFailureAt.After(now.Add(-policy.Window)) {
+			next := attempts.LastFailureAt.Add(policy.delay(attempts.Failures))
+			if next.After(now) {
+				retryAfter = maxDuration(retryAfter, next.Sub(now))
+			}
+		}
+	}
+
+	if retryAfter > 0 {
+		return &ThrottledError{RetryAfter: retryAfter}
+	}
+	return nil
+}
+
+// recordLoginFailure counts a failed login, locks counters over their
+// threshold and records the audit entries
+func (s *DefaultAuthService) recordLoginFailure(ctx context.Context, keys []loginKey, entry *models.AuditEntry) error {
+	policy := s.accounts.Lockout
+
+	for _, key := range keys {
+		attempts, err := s.attemptRepo.RecordFailure(ctx, key.String(), policy.Window)
+		if err != nil {
+			return err
+		}
+		if attempts.Failures < policy.threshold(key) {
+			continue
+		}
+		if attempts.LockedUntil != nil && attempts.LockedUntil.After(time.Now()) {
+			continue
+		}
+
+		if err := s.attemptRepo.Lock(ctx, key.String(), time.Now().Add(policy.LockDuration)); err != nil {
+			return err
+		}
+		locked := *entry
+		locked.Event = models.AuditLoginLocked
+		locked.Detail = fmt.Sprintf("%s locked after %d failures", key.kind, attempts.Failures)
+		if err := s.audit(ctx, &locked); err != nil {
+			return err
+		}
+	}
+
+	return s.audit(ctx, entry)
+}
+
+// recordLoginSuccess records the audit entry of a completed login, with the
+// second factor used if any
+func (s *DefaultAuthService) recordLoginSuccess(ctx context.Context, user *models.User, clientIP, method string) error {
+	return s.audit(ctx, &models.AuditEntry{
+		Event:  models.AuditLoginSucceeded,
+		UserID: user.ID,
+		Email:  normalizeEmail(user.Email),
+		IP:     clientIP,
+		Detail: method,
+	})
+}
+
+// UnlockUser clears the failed login counter and lock of a user's account
+func (s *DefaultAuthService) UnlockUser(ctx context.Context, actorID, userID string) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrUserNotFound
+	}
+
+	key := loginKey{kind: lockAccount, value: normalizeEmail(user.Email)}
+	if err := s.attemptRepo.Reset(ctx, key.String()); err != nil {
+		return err
+	}
+	return s.audit(ctx, &models.AuditEntry{
+		Event:   models.AuditLoginUnlocked,
+		UserID:  user.ID,
+		Email:   key.value,
+		ActorID: actorID,
+		Detail:  lockAccount,
+	})
+}
+
+// UnlockIP clears the failed login counter and lock of a client IP
+func (s *DefaultAuthService) UnlockIP(ctx context.Context, actorID, ip string) error {
+	key := loginKey{kind: lockIP, value: ip}
+	if err := s.attemptRepo.Reset(ctx, key.String()); err != nil {
+		return err
+	}
+	return s.audit(ctx, &models.AuditEntry{
+		Event:   models.AuditLoginUnlocked,
+		IP:      ip,
+		ActorID: actorID,
+		Detail:  lockIP,
+	})
+}
+
+// ListAudit returns the newest audit entries matching the filter
+func (s *DefaultAuthService) ListAudit(ctx context.Context, filter models.AuditFilter) ([]*models.AuditEntry, error) {
+	filter.Email = normalizeEmail(filter.Email)
+	return s.auditRepo.List(ctx, filter)
+}
+
+// audit records an audit entry
+func (s *DefaultAuthService) audit(ctx context.Context, entry *models.AuditEntry) error {
+	entry.ID = newTokenID()
+	entry.CreatedAt = time.Now()
+	return s.auditRepo.Record(ctx, entry)
+}
+
+// normalizeEmail normalizes an email address for counting and auditing
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// maxDuration returns the longer of two durations
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,345 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
+	"github.com/google/uuid"
+)
+
+// ServiceName names the auth service in events and data subject requests
+const ServiceName = "auth-service"
+
+// exportAuditLimit is the number of audit entries included in a data export
+const exportAuditLimit = 1000
+
+var (
+	// ErrPrivacyRequestNotFound is returned when a data subject request does not exist
+	ErrPrivacyRequestNotFound = errors.New("資料請求不存在")
+	// ErrPrivacyRequestPending is returned when the same kind of request is still being processed
+	ErrPrivacyRequestPending = errors.New("已有處理中的同類請求")
+	// ErrExportNotReady is returned when the data of an export is not complete
+	ErrExportNotReady = errors.New("匯出資料尚未完成")
+	// ErrExportExpired is returned when the data of an export has been removed
+	ErrExportExpired = errors.New("匯出資料已過期")
+)
+
+// RequestPrivacy starts exporting or erasing the personal data of a user
+//
+// The request is announced to the other services holding user data, which
+// report back through RecordPrivacyResult. The auth service exports its own
+// data right away, but deletes the account only after every other service
+// has erased its part, so a failed erasure can be requested again by the user.
+// Until then the account is deactivated, so the user cannot sign in and
+// create data that services which already reported would miss.
+func (s *DefaultAuthService) RequestPrivacy(ctx context.Context, actorID, userID, kind string) (*models.PrivacyRequest, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	pending, err := s.privacyRepo.FindPending(ctx, userID, kind)
+	if err != nil {
+		return nil, err
+	}
+	if pending != nil {
+		return nil, ErrPrivacyRequestPending
+	}
+
+	now := time.Now()
+	request := &models.PrivacyRequest{
+		ID:          uuid.NewString(),
+		UserID:      userID,
+		Kind:        kind,
+		Status:      models.PrivacyPending,
+		RequestedBy: actorID,
+		Services:    map[string]*models.PrivacyService{ServiceName: {Status: models.PrivacyPending}},
+		CreatedAt:   now,
+	}
+	for _, name := range s.accounts.PrivacyServices {
+		request.Services[name] = &models.PrivacyService{Status: models.PrivacyPending}
+	}
+
+	if kind == events.DataSubjectExport {
+		data, err := s.exportAccount(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		request.Services[ServiceName] = &models.PrivacyService{Status: models.PrivacyCompleted, CompletedAt: &now, Data: data}
+	}
+
+	encoded, err := events.Encode(ctx, ServiceName, events.DataSubjectRequested{
+		RequestID:   request.ID,
+		UserID:      userID,
+		Kind:        kind,
+		RequestedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	event := outbox.Message{
+		ID:        uuid.NewString(),
+		Topic:     encoded.Topic,
+		Key:       encoded.Key,
+		Payload:   encoded.Data,
+		CreatedAt: now,
+	}
+	erasure := kind == events.DataSubjectErasure
+	if erasure {
+		if err := s.setActive(ctx, userID, false); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.privacyRepo.Create(ctx, request, event); err != nil {
+		if erasure {
+			if restoreErr := s.setActive(ctx, userID, true); restoreErr != nil {
+				return nil, errors.Join(err, restoreErr)
+			}
+		}
+		return nil, err
+	}
+
+	entry := &models.AuditEntry{Event: models.AuditPrivacyRequested, UserID: userID, Detail: kind}
+	if actorID != userID {
+		entry.ActorID = actorID
+	}
+	if err := s.audit(ctx, entry); err != nil {
+		return nil, err
+	}
+
+	// The account is on its way out, sign it out everywhere now
+	if erasure {
+		if err := s.LogoutAll(ctx, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	// Without other services there is nothing to wait for
+	if !request.Pending(ServiceName) {
+		if err := s.finishPrivacyRequest(ctx, request); err != nil {
+			return nil, err
+		}
+		return s.privacyRepo.FindByID(ctx, request.ID)
+	}
+	return request, nil
+}
+
+// RequestErasure starts erasing the caller's account after checking the password
+//
+// Wrong passwords count as failed logins of the account, as in ChangePassword.
+func (s *DefaultAuthService) RequestErasure(ctx context.Context, userID, password, clientIP string) (*models.PrivacyRequest, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []loginKey{{kind: lockAccount, value: normalizeEmail(user.Email)}}
+	if err := s.checkThrottle(ctx, keys); err != nil {
+		return nil, err
+	}
+	if !user.CheckPassword(password) {
+		entry := &models.AuditEntry{Event: models.AuditLoginFailed, UserID: user.ID, Email: keys[0].value, IP: clientIP, Detail: "erasure"}
+		if err := s.recordLoginFailure(ctx, keys, entry); err != nil {
+			return nil, err
+		}
+		return nil, ErrWrongPassword
+	}
+	return s.RequestPrivacy(ctx, userID, userID, events.DataSubjectErasure)
+}
+
+// GetPrivacyRequest gets a data subject request, limited to one user unless userID is empty
+func (s *DefaultAuthService) GetPrivacyRequest(ctx context.Context, userID, id string) (*models.PrivacyRequest, error) {
+	request, err := s.privacyRepo.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if request == nil || (userID != "" && request.UserID != userID) {
+		return nil, ErrPrivacyRequestNotFound
+	}
+	return request, nil
+}
+
+// ListPrivacyRequests lists the data subject requests of a user
+func (s *DefaultAuthService) ListPrivacyRequests(ctx context.Context, userID string) ([]*models.PrivacyRequest, error) {
+	return s.privacyRepo.ListByUser(ctx, userID)
+}
+
+// GetDataExport returns the data collected by a completed export request
+func (s *DefaultAuthService) GetDataExport(ctx context.Context, userID, id string) (*models.DataExport, error) {
+	request, err := s.GetPrivacyRequest(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if request.Kind != events.DataSubjectExport {
+		return nil, ErrPrivacyRequestNotFound
+	}
+	if request.Status != models.PrivacyCompleted {
+		return nil, ErrExportNotReady
+	}
+	if request.ExportExpiresAt == nil || time.Now().After(*request.ExportExpiresAt) {
+		return nil, ErrExportExpired
+	}
+
+	export := &models.DataExport{
+		RequestID:   request.ID,
+		UserID:      request.UserID,
+		GeneratedAt: *request.CompletedAt,
+		Services:    make(map[string]json.RawMessage, len(request.Services)),
+	}
+	for name, svc := range request.Services {
+		data := json.RawMessage("null")
+		if len(svc.Data) > 0 {
+			data = svc.Data
+		}
+		export.Services[name] = data
+	}
+	return export, nil
+}
+
+// RecordPrivacyResult records the part of a data subject request done by another service
+//
+// Once every service has reported, the request is finished. Results of
+// unknown services and of finished requests are ignored.
+func (s *DefaultAuthService) RecordPrivacyResult(ctx context.Context, result events.DataSubjectCompleted) error {
+	if !s.isPrivacyService(result.Service) {
+		return nil
+	}
+
+	completedAt := result.CompletedAt
+	part := &models.PrivacyService{Status: models.PrivacyCompleted, CompletedAt: &completedAt, Data: result.Data}
+	if result.Error != "" {
+		part = &models.PrivacyService{Status: models.PrivacyFailed, CompletedAt: &completedAt, Error: result.Error}
+	}
+
+	request, err := s.privacyRepo.SetService(ctx, result.RequestID, result.Service, part)
+	if err != nil || request == nil {
+		return err
+	}
+	if request.Pending(ServiceName) {
+		return nil
+	}
+	return s.finishPrivacyRequest(ctx, request)
+}
+
+// finishPrivacyRequest completes the auth service's part and the request itself
+func (s *DefaultAuthService) finishPrivacyRequest(ctx context.Context, request *models.PrivacyRequest) error {
+	if request.Kind == events.DataSubjectErasure {
+		now := time.Now()
+		part := &models.PrivacyService{Status: models.PrivacyCompleted, CompletedAt: &now}
+		if request.Failed() {
+			part = &models.PrivacyService{Status: models.PrivacyFailed, CompletedAt: &now, Error: "其他服務未能完成刪除，帳號予以保留"}
+			if err := s.setActive(ctx, request.UserID, true); err != nil {
+				return err
+			}
+		} else if err := s.eraseAccount(ctx, request.UserID); err != nil {
+			return err
+		}
+
+		var err error
+		request, err = s.privacyRepo.SetService(ctx, request.ID, ServiceName, part)
+		if err != nil || request == nil {
+			return err
+		}
+	}
+
+	status := models.PrivacyCompleted
+	if request.Failed() {
+		status = models.PrivacyFailed
+	}
+
+	var expiresAt *time.Time
+	if request.Kind == events.DataSubjectExport && status == models.PrivacyCompleted {
+		expires := time.Now().Add(s.accounts.ExportRetention)
+		expiresAt = &expires
+	}
+
+	_, err := s.privacyRepo.Finish(ctx, request.ID, status, expiresAt)
+	return err
+}
+
+// exportAccount collects the data the auth service holds about a user
+func (s *DefaultAuthService) exportAccount(ctx context.Context, user *models.User) ([]byte, error) {
+	addresses, err := s.addressRepo.List(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := s.auditRepo.List(ctx, models.AuditFilter{UserID: user.ID, Limit: exportAuditLimit})
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		User      models.UserResponse  `json:"user"`
+		Addresses []*models.Address    `json:"addresses"`
+		AuditLog  []*models.AuditEntry `json:"audit_log"`
+	}{user.ToResponse(), addresses, entries})
+}
+
+// eraseAccount deletes a user together with their sessions, tokens and addresses
+//
+// Audit entries are kept for security but lose the email address and IP.
+// The user is deleted last, so a retry after a partial failure still knows
+// the email address, and erasing an already deleted user succeeds.
+func (s *DefaultAuthService) eraseAccount(ctx context.Context, userID string) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.LogoutAll(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.tokenRepo.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.actionTokenRepo.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+	if err := s.addressRepo.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+
+	email := ""
+	if user != nil {
+		email = normalizeEmail(user.Email)
+		if err := s.attemptRepo.Reset(ctx, loginKey{kind: lockAccount, value: email}.String()); err != nil {
+			return err
+		}
+	}
+	if err := s.auditRepo.Anonymize(ctx, userID, email); err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	if err := s.userRepo.Delete(ctx, userID); err != nil {
+		return err
+	}
+	return s.audit(ctx, &models.AuditEntry{Event: models.AuditAccountErased, UserID: userID})
+}
+
+// setActive activates or deactivates a user, ignoring users already deleted
+func (s *DefaultAuthService) setActive(ctx context.Context, userID string, active bool) error {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil || user == nil || user.Active == active {
+		return err
+	}
+	user.Active = active
+	return s.userRepo.Update(ctx, user)
+}
+
+// isPrivacyService reports whether a service takes part in data subject requests
+func (s *DefaultAuthService) isPrivacyService(name string) bool {
+	for _, service := range s.accounts.PrivacyServices {
+		if service == name {
+			return true
+		}
+	}
+	return false
+}
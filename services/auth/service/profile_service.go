@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/google/uuid"
+)
+
+// MaxAddresses is the number of addresses a user can save
+const MaxAddresses = 20
+
+var (
+	// ErrWrongPassword is returned when the current password does not match on a password change
+	ErrWrongPassword = errors.New("目前的密碼不正確")
+	// ErrSamePassword is returned when the new password equals the current one
+	ErrSamePassword = errors.New("新密碼不可與目前的密碼相同")
+	// ErrAddressNotFound is returned when the user has no such address
+	ErrAddressNotFound = errors.New("地址不存在")
+	// ErrTooManyAddresses is returned when the address book is full
+	ErrTooManyAddresses = errors.New("地址簿已滿")
+)
+
+// UpdateProfile changes the name of a user
+func (s *DefaultAuthService) UpdateProfile(ctx context.Context, userID string, req models.ProfileUpdateRequest) (*models.UserResponse, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.FirstName != nil {
+		user.FirstName = *req.FirstName
+	}
+	if req.LastName != nil {
+		user.LastName = *req.LastName
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	response := user.ToResponse()
+	return &response, nil
+}
+
+// ChangePassword sets a new password after checking the current one
+//
+// Wrong current passwords count as failed logins of the account, so a stolen
+// session cannot be used to guess the password. Every session is revoked and
+// a new token pair is returned for the caller.
+func (s *DefaultAuthService) ChangePassword(ctx context.Context, userID string, req models.ChangePasswordRequest, clientIP string) (*models.TokenPair, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := []loginKey{{kind: lockAccount, value: normalizeEmail(user.Email)}}
+	if err := s.checkThrottle(ctx, keys); err != nil {
+		return nil, err
+	}
+	if !user.CheckPassword(req.CurrentPassword) {
+		entry := &models.AuditEntry{Event: models.AuditLoginFailed, UserID: user.ID, Email: keys[0].value, IP: clientIP, Detail: "password_change"}
+		if err := s.recordLoginFailure(ctx, keys, entry); err != nil {
+			return nil, err
+		}
+		return nil, ErrWrongPassword
+	}
+	if req.CurrentPassword == req.NewPassword {
+		return nil, ErrSamePassword
+	}
+
+	if err := user.SetPassword(req.NewPassword); err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.Update(ctx, user); err != nil {
+		return nil, err
+	}
+
+	// Pending reset links were meant for the old password
+	if err := s.actionTokenRepo.InvalidateUser(ctx, user.ID, models.TokenPurposePasswordReset); err != nil {
+		return nil, err
+	}
+	if err := s.LogoutAll(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if err := s.attemptRepo.Reset(ctx, keys[0].String()); err != nil {
+		return nil, err
+	}
+	err = s.audit(ctx, &models.AuditEntry{Event: models.AuditPasswordChange, UserID: user.ID, Email: keys[0].value, IP: clientIP})
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, user, newTokenID())
+}
+
+// ListAddresses returns the address book of a user
+func (s *DefaultAuthService) ListAddresses(ctx context.Context, userID string) ([]*models.Address, error) {
+	return s.addressRepo.List(ctx, userID)
+}
+
+// GetAddress returns an address of a user, or the default shipping address
+// if id is empty
+func (s *DefaultAuthService) GetAddress(ctx context.Context, userID, id string) (*models.Address, error) {
+	var (
+		address *models.Address
+		err     error
+	)
+	if id == "" {
+		address, err = s.addressRepo.FindDefault(ctx, userID, models.AddressDefaultShipping)
+	} else {
+		address, err = s.addressRepo.FindByID(ctx, userID, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if address == nil {
+		return nil, ErrAddressNotFound
+	}
+	return address, nil
+}
+
+// CreateAddress saves a new address
+//
+// The first address becomes the default shipping and billing address.
+func (s *DefaultAuthService) CreateAddress(ctx context.Context, userID string, req models.AddressRequest) (*models.Address, error) {
+	count, err := s.addressRepo.Count(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if count >= MaxAddresses {
+		return nil, ErrTooManyAddresses
+	}
+
+	now := time.Now()
+	address := &models.Address{
+		ID:              uuid.New().String(),
+		UserID:          userID,
+		Label:           req.Label,
+		ShippingInfo:    req.ShippingInfo,
+		DefaultShipping: req.DefaultShipping || count == 0,
+		DefaultBilling:  req.DefaultBilling || count == 0,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	if err := s.addressRepo.Create(ctx, address); err != nil {
+		return nil, err
+	}
+	if err := s.clearOtherDefaults(ctx, address); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// UpdateAddress replaces a saved address
+//
+// Clearing a default flag leaves the user without that default.
+func (s *DefaultAuthService) UpdateAddress(ctx context.Context, userID, id string, req models.AddressRequest) (*models.Address, error) {
+	address, err := s.addressRepo.FindByID(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if address == nil {
+		return nil, ErrAddressNotFound
+	}
+
+	address.Label = req.Label
+	address.ShippingInfo = req.ShippingInfo
+	address.DefaultShipping = req.DefaultShipping
+	address.DefaultBilling = req.DefaultBilling
+	found, err := s.addressRepo.Update(ctx, address)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, ErrAddressNotFound
+	}
+	if err := s.clearOtherDefaults(ctx, address); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+// DeleteAddress deletes a saved address
+//
+// Orders keep a copy of their shipping address, so they are not affected.
+func (s *DefaultAuthService) DeleteAddress(ctx context.Context, userID, id string) error {
+	found, err := s.addressRepo.Delete(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrAddressNotFound
+	}
+	return nil
+}
+
+// clearOtherDefaults keeps the address the only default of each kind it is set for
+func (s *DefaultAuthService) clearOtherDefaults(ctx context.Context, address *models.Address) error {
+	if address.DefaultShipping {
+		if err := s.addressRepo.ClearDefault(ctx, address.UserID, models.AddressDefaultShipping, address.ID); err != nil {
+			return err
+		}
+	}
+	if address.DefaultBilling {
+		if err := s.addressRepo.ClearDefault(ctx, address.UserID, models.AddressDefaultBilling, address.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrInvalidRefreshToken is returned when a refresh token is unknown, expired or revoked
+	ErrInvalidRefreshToken = errors.New("無效的刷新令牌")
+	// ErrRefreshTokenReused is returned when a rotated refresh token is presented again
+	ErrRefreshTokenReused = errors.New("刷新令牌已被使用，該登入階段已撤銷")
+)
+
+// Refresh exchanges a refresh token for a new token pair and rotates the refresh token
+//
+// A refresh token can only be used once. Reusing one revokes its whole
+// family, including the access tokens issued from it, because either the
+// legitimate client or an attacker is holding a stolen copy.
+func (s *DefaultAuthService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	stored, err := s.tokenRepo.FindByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if stored == nil || stored.RevokedAt != nil || !time.Now().Before(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if stored.UsedAt != nil {
+		return nil, s.revokeReusedFamily(ctx, stored.FamilyID)
+	}
+
+	// Re-read the user so role changes and deactivation take effect on refresh
+	user, err := s.userRepo.FindByID(ctx, stored.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.Active {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Rotate: only one concurrent refresh with the same token can win
+	nextID := newTokenID()
+	marked, err := s.tokenRepo.MarkUsed(ctx, stored.ID, nextID)
+	if err != nil {
+		return nil, err
+	}
+	if !marked {
+		return nil, s.revokeReusedFamily(ctx, stored.FamilyID)
+	}
+
+	return s.issueTokensWithID(ctx, user, stored.FamilyID, nextID)
+}
+
+// Logout revokes the current session: its refresh token family and access token
+func (s *DefaultAuthService) Logout(ctx context.Context, familyID, accessTokenID string) error {
+	if familyID != "" {
+		if err := s.tokenRepo.RevokeFamily(ctx, familyID); err != nil {
+			return err
+		}
+	}
+	return s.revokeAccess(ctx, familyID, accessTokenID)
+}
+
+// LogoutAll revokes every session of the user
+func (s *DefaultAuthService) LogoutAll(ctx context.Context, userID string) error {
+	families, err := s.tokenRepo.RevokeUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	return s.revokeAccess(ctx, families...)
+}
+
+// issueTokens issues an access token and a new refresh token in the given family
+func (s *DefaultAuthService) issueTokens(ctx context.Context, user *models.User, familyID string) (*models.TokenPair, error) {
+	return s.issueTokensWithID(ctx, user, familyID, newTokenID())
+}
+
+// issueTokensWithID issues an access token and a refresh token with a preallocated ID
+func (s *DefaultAuthService) issueTokensWithID(ctx context.Context, user *models.User, familyID, refreshID string) (*models.TokenPair, error) {
+	accessToken, _, err := middleware.GenerateAccessToken(s.tokens.Signer, user.ID, user.RoleNames(), newTokenID(), familyID,
+		s.tokens.AccessTokenTTL)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	err = s.tokenRepo.Create(ctx, &models.RefreshToken{
+		ID:        refreshID,
+		TokenHash: hashToken(secret),
+		UserID:    user.ID,
+		FamilyID:  familyID,
+		ExpiresAt: now.Add(s.tokens.RefreshTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: secret,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.tokens.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// revokeReusedFamily revokes a family after refresh token reuse was detected
+func (s *DefaultAuthService) revokeReusedFamily(ctx context.Context, familyID string) error {
+	if err := s.tokenRepo.RevokeFamily(ctx, familyID); err != nil {
+		return err
+	}
+	if err := s.revokeAccess(ctx, familyID); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// revokeAccess denylists identifiers until every access token they cover has expired
+func (s *DefaultAuthService) revokeAccess(ctx context.Context, ids ...string) error {
+	expiresAt := time.Now().Add(s.tokens.AccessTokenTTL)
+	for _, id := range ids {
+		if id == "" {
+			continue
+		}
+		if err := s.denylist.Revoke(ctx, id, expiresAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newTokenID generates an identifier for tokens and token families
+func newTokenID() string {
+	return uuid.NewString()
+}
+
+// newOpaqueToken generates a random refresh token value
+func newOpaqueToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// hashToken hashes a refresh token value for storage and lookup
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,438 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/totp"
+)
+
+const (
+	// TwoFactorChallengeTTL is how long a login challenge waits for the second factor
+	TwoFactorChallengeTTL = 5 * time.Minute
+	// RecoveryCodeCount is the number of recovery codes issued at once
+	RecoveryCodeCount = 10
+)
+
+var (
+	// ErrTwoFactorAlreadyEnabled is returned when enrolling while two-factor authentication is on
+	ErrTwoFactorAlreadyEnabled = errors.New("兩步驟驗證已啟用")
+	// ErrTwoFactorNotEnabled is returned when two-factor authentication is off
+	ErrTwoFactorNotEnabled = errors.New("兩步驟驗證未啟用")
+	// ErrNoPendingEnrollment is returned when confirming without an issued secret
+	ErrNoPendingEnrollment = errors.New("沒有待確認的兩步驟驗證設定")
+	// ErrInvalidTwoFactorCode is returned for wrong, expired or replayed codes
+	ErrInvalidTwoFactorCode = errors.New("驗證碼不正確")
+	// ErrInvalidChallenge is returned when a login challenge is unknown, used or expired
+	ErrInvalidChallenge = errors.New("無效或已過期的登入驗證")
+	// ErrTwoFactorRequired is returned when disabling two-factor authentication that a role requires
+	ErrTwoFactorRequired = errors.New("您的角色必須使用兩步驟驗證")
+)
+
+// lockTwoFactor is the kind of the per-user counter of wrong second factors
+const lockTwoFactor = "2fa"
+
+// recoveryEncoding encodes recovery codes without ambiguous padding
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// LoginResult is the outcome of a login step
+//
+// Either Tokens and User are set, or Challenge asks for a second factor.
+// RecoveryCodes is only set when the login completed an enrollment that a
+// role required.
+type LoginResult struct {
+	Tokens        *models.TokenPair
+	User          *models.UserResponse
+	Challenge     *models.TwoFactorChallenge
+	RecoveryCodes []string
+}
+
+// VerifyTwoFactor completes a login challenge with a TOTP code or a recovery code
+//
+// Wrong codes are counted per user like failed logins, so the 10^6 codes
+// cannot be guessed within the lifetime of a challenge.
+func (s *DefaultAuthService) VerifyTwoFactor(ctx context.Context, challengeToken, code, clientIP string) (*LoginResult, error) {
+	challenge, err := s.actionTokenRepo.Find(ctx, hashToken(challengeToken), models.TokenPurposeTwoFactorLogin)
+	if err != nil {
+		return nil, err
+	}
+	if challenge == nil {
+		return nil, ErrInvalidChallenge
+	}
+
+	user, err := s.userRepo.FindByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil || !user.Active {
+		return nil, ErrInvalidChallenge
+	}
+
+	keys := twoFactorKeys(user)
+	if err := s.checkThrottle(ctx, keys); err != nil {
+		return nil, err
+	}
+
+	var (
+		method        string
+		recoveryCodes []string
+	)
+	switch {
+	case user.TwoFactorEnabled():
+		method, err = s.checkSecondFactor(ctx, user, code)
+	case user.TwoFactor != nil && user.TwoFactor.PendingSecret != "":
+		method = "totp"
+		recoveryCodes, err = s.confirmEnrollment(ctx, user, code)
+	default:
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, s.twoFactorFailed(ctx, keys, user, clientIP, err)
+	}
+
+	// Consuming the challenge last lets the user retry a mistyped code, and
+	// the atomic update stops two requests from both getting tokens
+	if consumed, err := s.actionTokenRepo.Consume(ctx, challenge.TokenHash, models.TokenPurposeTwoFactorLogin); err != nil {
+		return nil, err
+	} else if consumed == nil {
+		return nil, ErrInvalidChallenge
+	}
+	if err := s.attemptRepo.Reset(ctx, keys[0].String()); err != nil {
+		return nil, err
+	}
+
+	result, err := s.completeLogin(ctx, user, clientIP, method)
+	if err != nil {
+		return nil, err
+	}
+	result.RecoveryCodes = recoveryCodes
+	return result, nil
+}
+
+// EnrollTwoFactor issues a new TOTP secret that ConfirmTwoFactor activates
+func (s *DefaultAuthService) EnrollTwoFactor(ctx context.Context, userID string) (*models.TOTPEnrollment, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	return s.issueEnrollment(ctx, user)
+}
+
+// ConfirmTwoFactor enables two-factor authentication with the first code of
+// the issued secret and returns the recovery codes
+func (s *DefaultAuthService) ConfirmTwoFactor(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorAlreadyEnabled
+	}
+	if user.TwoFactor == nil || user.TwoFactor.PendingSecret == "" {
+		return nil, ErrNoPendingEnrollment
+	}
+
+	keys := twoFactorKeys(user)
+	if err := s.checkThrottle(ctx, keys); err != nil {
+		return nil, err
+	}
+	codes, err := s.confirmEnrollment(ctx, user, code)
+	if err != nil {
+		return nil, s.twoFactorFailed(ctx, keys, user, "", err)
+	}
+	return codes, s.attemptRepo.Reset(ctx, keys[0].String())
+}
+
+// DisableTwoFactor turns off two-factor authentication after checking the
+// password and a current code
+func (s *DefaultAuthService) DisableTwoFactor(ctx context.Context, userID, password, code string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !user.TwoFactorEnabled() {
+		return ErrTwoFactorNotEnabled
+	}
+	if !user.CheckPassword(password) {
+		return ErrInvalidCredentials
+	}
+
+	required, err := s.twoFactorRequired(ctx, user)
+	if err != nil {
+		return err
+	}
+	if required {
+		return ErrTwoFactorRequired
+	}
+
+	if err := s.checkSecondFactorThrottled(ctx, user, code); err != nil {
+		return err
+	}
+	if err := s.userRepo.SetTwoFactor(ctx, user.ID, nil); err != nil {
+		return err
+	}
+	return s.audit(ctx, &models.AuditEntry{Event: models.AuditTwoFactorDisabled, UserID: user.ID, Email: normalizeEmail(user.Email)})
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a current code
+func (s *DefaultAuthService) RegenerateRecoveryCodes(ctx context.Context, userID, code string) ([]string, error) {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.TwoFactorEnabled() {
+		return nil, ErrTwoFactorNotEnabled
+	}
+	if err := s.checkSecondFactorThrottled(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	// Reload so the step or recovery code just used is kept
+	user, err = s.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	settings := *user.TwoFactor
+	settings.RecoveryCodes = hashes
+	if err := s.userRepo.SetTwoFactor(ctx, user.ID, &settings); err != nil {
+		return nil, err
+	}
+
+	err = s.audit(ctx, &models.AuditEntry{Event: models.AuditRecoveryCodesRenewed, UserID: user.ID, Email: normalizeEmail(user.Email)})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// GetTwoFactorPolicy returns the roles that must use two-factor authentication
+func (s *DefaultAuthService) GetTwoFactorPolicy(ctx context.Context) (*models.TwoFactorPolicy, error) {
+	return s.settingsRepo.GetTwoFactorPolicy(ctx)
+}
+
+// SetTwoFactorPolicy changes the roles that must use two-factor authentication
+//
+// Users with such a role who have not enrolled are asked to at their next login.
+func (s *DefaultAuthService) SetTwoFactorPolicy(ctx context.Context, actorID string, roles []string) (*models.TwoFactorPolicy, error) {
+	required := make([]string, 0, len(roles))
+	seen := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		if !middleware.IsKnownRole(role) {
+			return nil, ErrUnknownRole
+		}
+		if !seen[role] {
+			seen[role] = true
+			required = append(required, role)
+		}
+	}
+
+	policy := &models.TwoFactorPolicy{RequiredRoles: required, UpdatedAt: time.Now(), UpdatedBy: actorID}
+	if err := s.settingsRepo.SetTwoFactorPolicy(ctx, policy); err != nil {
+		return nil, err
+	}
+
+	err := s.audit(ctx, &models.AuditEntry{
+		Event:   models.AuditTwoFactorPolicyChange,
+		ActorID: actorID,
+		Detail:  strings.Join(required, ","),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return policy, nil
+}
+
+// startTwoFactor creates a login challenge, issuing a secret first if a
+// role requires two-factor authentication the user has not set up
+func (s *DefaultAuthService) startTwoFactor(ctx context.Context, user *models.User) (*LoginResult, error) {
+	challenge := &models.TwoFactorChallenge{ExpiresIn: int(TwoFactorChallengeTTL.Seconds())}
+	if !user.TwoFactorEnabled() {
+		enrollment, err := s.issueEnrollment(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		challenge.EnrollmentRequired = true
+		challenge.Enrollment = enrollment
+	}
+
+	token, err := s.createActionToken(ctx, user.ID, models.TokenPurposeTwoFactorLogin, TwoFactorChallengeTTL)
+	if err != nil {
+		return nil, err
+	}
+	challenge.ChallengeToken = token
+	return &LoginResult{Challenge: challenge}, nil
+}
+
+// issueEnrollment stores a new pending secret and returns it for the authenticator app
+func (s *DefaultAuthService) issueEnrollment(ctx context.Context, user *models.User) (*models.TOTPEnrollment, error) {
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userRepo.SetTwoFactor(ctx, user.ID, &models.TwoFactor{PendingSecret: secret}); err != nil {
+		return nil, err
+	}
+	return &models.TOTPEnrollment{Secret: secret, URI: totp.URI(s.accounts.TOTPIssuer, user.Email, secret)}, nil
+}
+
+// confirmEnrollment checks a code of the pending secret, enables two-factor
+// authentication and returns new recovery codes
+func (s *DefaultAuthService) confirmEnrollment(ctx context.Context, user *models.User, code string) ([]string, error) {
+	secret := user.TwoFactor.PendingSecret
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidTwoFactorCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	err = s.userRepo.SetTwoFactor(ctx, user.ID, &models.TwoFactor{
+		Enabled:       true,
+		Secret:        secret,
+		RecoveryCodes: hashes,
+		LastUsedStep:  step,
+		EnabledAt:     &now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.audit(ctx, &models.AuditEntry{Event: models.AuditTwoFactorEnabled, UserID: user.ID, Email: normalizeEmail(user.Email)})
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// checkSecondFactor accepts a TOTP code or uses up a recovery code and
+// returns which one was given
+func (s *DefaultAuthService) checkSecondFactor(ctx context.Context, user *models.User, code string) (string, error) {
+	code = strings.TrimSpace(code)
+
+	if step, ok := totp.Validate(user.TwoFactor.Secret, code, time.Now()); ok {
+		fresh, err := s.userRepo.UseTwoFactorStep(ctx, user.ID, step)
+		if err != nil {
+			return "", err
+		}
+		if !fresh {
+			return "", ErrInvalidTwoFactorCode
+		}
+		return "totp", nil
+	}
+
+	used, err := s.userRepo.UseRecoveryCode(ctx, user.ID, hashRecoveryCode(code))
+	if err != nil {
+		return "", err
+	}
+	if !used {
+		return "", ErrInvalidTwoFactorCode
+	}
+
+	err = s.audit(ctx, &models.AuditEntry{
+		Event:  models.AuditRecoveryCodeUsed,
+		UserID: user.ID,
+		Email:  normalizeEmail(user.Email),
+		Detail: fmt.Sprintf("%d left", len(user.TwoFactor.RecoveryCodes)-1),
+	})
+	if err != nil {
+		return "", err
+	}
+	return "recovery_code", nil
+}
+
+// checkSecondFactorThrottled checks a code of a signed in user, counting
+// wrong codes like VerifyTwoFactor does
+func (s *DefaultAuthService) checkSecondFactorThrottled(ctx context.Context, user *models.User, code string) error {
+	keys := twoFactorKeys(user)
+	if err := s.checkThrottle(ctx, keys); err != nil {
+		return err
+	}
+	if _, err := s.checkSecondFactor(ctx, user, code); err != nil {
+		return s.twoFactorFailed(ctx, keys, user, "", err)
+	}
+	return s.attemptRepo.Reset(ctx, keys[0].String())
+}
+
+// twoFactorFailed counts a wrong code and returns the error to report
+func (s *DefaultAuthService) twoFactorFailed(ctx context.Context, keys []loginKey, user *models.User, clientIP string, err error) error {
+	if !errors.Is(err, ErrInvalidTwoFactorCode) {
+		return err
+	}
+	entry := &models.AuditEntry{Event: models.AuditTwoFactorFailed, UserID: user.ID, Email: normalizeEmail(user.Email), IP: clientIP}
+	if err := s.recordLoginFailure(ctx, keys, entry); err != nil {
+		return err
+	}
+	return ErrInvalidTwoFactorCode
+}
+
+// twoFactorKeys returns the counter of wrong second factors of a user
+func twoFactorKeys(user *models.User) []loginKey {
+	return []loginKey{{kind: lockTwoFactor, value: user.ID}}
+}
+
+// twoFactorRequired checks if a role of the user requires two-factor authentication
+func (s *DefaultAuthService) twoFactorRequired(ctx context.Context, user *models.User) (bool, error) {
+	policy, err := s.settingsRepo.GetTwoFactorPolicy(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, required := range policy.RequiredRoles {
+		for _, role := range user.RoleNames() {
+			if role == required {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// findUser finds a user that must exist
+func (s *DefaultAuthService) findUser(ctx context.Context, userID string) (*models.User, error) {
+	user, err := s.userRepo.FindByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrUserNotFound
+	}
+	return user, nil
+}
+
+// newRecoveryCodes generates recovery codes and the hashes that are stored
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	hashes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		buf := make([]byte, 5)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+		encoded := strings.ToLower(recoveryEncoding.EncodeToString(buf))
+		codes[i] = encoded[:4] + "-" + encoded[4:]
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring case and separators
+func hashRecoveryCode(code string) string {
+	code = strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashToken(code)
+}
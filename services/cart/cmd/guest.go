@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/cart/guest"
+	"github.com/arrontsai/ecommerce/services/cart/merge"
+	"github.com/arrontsai/ecommerce/services/cart/repository"
+)
+
+// cartTokenCookie 訪客購物車令牌的 cookie 名稱
+const cartTokenCookie = "cart_token"
+
+// cartOwnerKey 請求中購物車擁有者的 context key
+const cartOwnerKey = "cart_owner"
+
+// guestCarts 處理訪客購物車的令牌與登入後的合併
+type guestCarts struct {
+	repo   repository.CartRepository
+	tokens *guest.Tokens
+	merger *merge.Merger
+	ttl    time.Duration
+	// secure 只在 HTTPS 連線上傳送 cookie
+	secure bool
+}
+
+// optionalAuth 有授權標頭時以 JWT 驗證，沒有時以訪客身分繼續
+//
+// 授權標頭無效時仍回傳 401，不會悄悄改用訪客購物車。
+func optionalAuth(authMiddleware gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			c.Next()
+			return
+		}
+		authMiddleware(c)
+	}
+}
+
+// owner 決定請求的購物車擁有者
+//
+// 登入用戶帶著訪客令牌時，先將訪客購物車併入用戶購物車並清除令牌；合併失敗時
+// 保留令牌，下一次請求再試。訪客沒有有效令牌時簽發新的令牌。
+func (g *guestCarts) owner() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		guestID := g.guestID(c)
+
+		if userID := c.GetString("user_id"); userID != "" {
+			if guestID != "" {
+				if err := g.merge(c.Request.Context(), guestID, userID); err != nil {
+					log.Printf("合併訪客購物車失敗: %v", err)
+				} else {
+					g.setCookie(c, "", -1)
+				}
+			}
+			c.Set(cartOwnerKey, repository.UserOwner(userID))
+			c.Next()
+			return
+		}
+
+		if guestID == "" {
+			var token string
+			guestID, token = g.tokens.Issue()
+			g.setCookie(c, token, int(g.ttl.Seconds()))
+		}
+		c.Set(cartOwnerKey, repository.GuestOwner(guestID))
+		c.Next()
+	}
+}
+
+// mergeHandler 登入或註冊後立即合併訪客購物車
+func (g *guestCarts) mergeHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		guestID := g.guestID(c)
+		if guestID == "" {
+			c.JSON(http.StatusOK, gin.H{"message": "沒有需要合併的訪客購物車"})
+			return
+		}
+
+		if err := g.merge(c.Request.Context(), guestID, c.GetString("user_id")); err != nil {
+			c.JSON(cartErrorStatus(err), gin.H{"error": "合併購物車失敗: " + err.Error()})
+			return
+		}
+		g.setCookie(c, "", -1)
+
+		c.JSON(http.StatusOK, gin.H{"message": "訪客購物車已合併"})
+	}
+}
+
+// merge 依設定的規則將訪客購物車併入用戶購物車
+func (g *guestCarts) merge(ctx context.Context, guestID, userID string) error {
+	return g.repo.MergeGuestCart(guestID, userID, func(userItems, guestItems []models.CartItem) ([]models.CartItem, error) {
+		return g.merger.Merge(ctx, userItems, guestItems)
+	})
+}
+
+// guestID 讀取並驗證請求中的訪客令牌，沒有或無效時回傳空字串
+func (g *guestCarts) guestID(c *gin.Context) string {
+	token, err := c.Cookie(cartTokenCookie)
+	if err != nil {
+		return ""
+	}
+	guestID, err := g.tokens.Verify(token)
+	if err != nil {
+		if !errors.Is(err, guest.ErrInvalidToken) {
+			log.Printf("驗證購物車令牌失敗: %v", err)
+		}
+		return ""
+	}
+	return guestID
+}
+
+// setCookie 設定或清除 (maxAge < 0) 訪客令牌
+func (g *guestCarts) setCookie(c *gin.Context, token string, maxAge int) {
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(cartTokenCookie, token, maxAge, "/api/cart", "", g.secure, true)
+}
+
+// cartOwner 取得 owner 中介層決定的購物車擁有者
+func cartOwner(c *gin.Context) repository.Owner {
+	owner, _ := c.Get(cartOwnerKey)
+	return owner.(repository.Owner)
+}
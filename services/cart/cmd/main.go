@@ -1,17 +1,33 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 
 	"github.com/arrontsai/ecommerce/pkg/config"
 	"github.com/arrontsai/ecommerce/pkg/database"
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/jwks"
 	"github.com/arrontsai/ecommerce/pkg/logger"
 	"github.com/arrontsai/ecommerce/pkg/messaging"
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
+	"github.com/arrontsai/ecommerce/services/cart/guest"
+	"github.com/arrontsai/ecommerce/services/cart/merge"
+	"github.com/arrontsai/ecommerce/services/cart/pricing"
+	"github.com/arrontsai/ecommerce/services/cart/promotion"
 	"github.com/arrontsai/ecommerce/services/cart/repository"
+	"github.com/arrontsai/ecommerce/services/product/proto/pb"
 )
 
 func main() {
@@ -34,17 +50,59 @@ func main() {
 	}
 	defer mongoClient.Close()
 
-	// 初始化購物車儲存庫
-	cartRepo := repository.NewMongoCartRepository(mongoClient)
+	// 初始化購物車儲存庫，訪客購物車在最後一次修改後 GUEST_CART_TTL 秒過期
+	guestTTL := time.Duration(cfg.GuestCartTTL) * time.Second
+	cartRepo := repository.NewMongoCartRepository(mongoClient, guestTTL)
 
-	// 初始化Kafka生產者
-	kafkaProducer, err := messaging.NewKafkaClient(cfg.KafkaBrokers, appLogger.Logger)
+	// 初始化消息代理 (KAFKA_BROKERS=memory:// 時使用記憶體代理)
+	broker, err := messaging.NewBroker(cfg.KafkaBrokers, appLogger.Logger)
 	if err != nil {
-		log.Fatal("無法初始化Kafka生產者:", err)
+		log.Fatal("無法初始化消息代理:", err)
 	}
+	defer broker.Close()
+
+	// 啟動外寄事件轉發器，將購物車中的結帳事件發布到Kafka
+	relay := outbox.NewRelay(cartRepo, broker, appLogger.Logger)
+	go relay.Run(context.Background())
+
+	// 處理用戶資料匯出與刪除請求
+	if err := subscribeToPrivacyRequests(broker, cartRepo); err != nil {
+		log.Fatal("無法訂閱資料主體請求:", err)
+	}
+
+	// 連接產品服務，以商品的目前售價計算購物車金額；合併規則為 stock 時查詢可用庫存
+	productConn, err := grpc.Dial(cfg.ProductGrpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		log.Fatal("無法連接產品服務:", err)
+	}
+	defer productConn.Close()
+
+	// 優惠活動與優惠碼使用次數
+	promotionRepo := repository.NewMongoPromotionRepository(mongoClient)
+	promotions := promotion.NewPromotions(promotionRepo)
+	pricer := pricing.NewPricer(pricing.NewCatalogClient(pb.NewCatalogServiceClient(productConn)), promotions)
+
+	mergeRule, err := merge.ParseRule(cfg.CartMergeRule)
+	if err != nil {
+		log.Fatal("無效的購物車合併規則:", err)
+	}
+	guests := &guestCarts{
+		repo:   cartRepo,
+		tokens: guest.NewTokens(cfg.CartTokenSecret),
+		merger: merge.NewMerger(mergeRule, merge.NewInventoryStock(pb.NewInventoryServiceClient(productConn))),
+		ttl:    guestTTL,
+		secure: cfg.Environment == "production",
+	}
+
+	// 登入用戶的購物車擁有者取自令牌中的用戶ID；設定 JWT_JWKS_URL 時以認證服務的公鑰驗證
+	authOptions := []middleware.AuthOption{middleware.WithDenylist(middleware.NewMongoTokenDenylist(mongoClient.DB))}
+	if cfg.JWKSURL != "" {
+		authOptions = append(authOptions, middleware.WithKeyProvider(jwks.NewRemoteKeySet(cfg.JWKSURL)))
+	}
+	jwtMiddleware := middleware.JWTAuthMiddleware(cfg.JWTSecret, authOptions...)
 
 	// 設置HTTP路由
-	router := setupRouter(cartRepo, kafkaProducer)
+	router := setupRouter(cartRepo, promotionRepo, pricer, promotions, guests, jwtMiddleware)
 
 	// 啟動HTTP服務器
 	log.Println("購物車服務啟動於 :8082")
@@ -53,7 +111,7 @@ func main() {
 	}
 }
 
-func setupRouter(repo repository.CartRepository, producer messaging.KafkaProducer) *gin.Engine {
+func setupRouter(repo repository.CartRepository, promotionRepo repository.PromotionRepository, pricer *pricing.Pricer, promotions *promotion.Promotions, guests *guestCarts, authMiddleware gin.HandlerFunc) *gin.Engine {
 	r := gin.Default()
 
 	// 健康檢查
@@ -61,32 +119,54 @@ func setupRouter(repo repository.CartRepository, producer messaging.KafkaProduce
 		c.JSON(http.StatusOK, gin.H{"status": "OK"})
 	})
 
-	// 添加商品到購物車
-	r.POST("/cart", addToCartHandler(repo))
+	// 未登入時以 cart_token cookie 識別訪客購物車，登入後自動併入用戶購物車
+	// 金額以 ?currency= 或 X-Currency 選擇的幣別計算
+	cart := r.Group("/api/cart", optionalAuth(authMiddleware), guests.owner(), middleware.SelectCurrency())
+	{
+		// 獲取購物車內容
+		cart.GET("", getCartHandler(repo, pricer))
+		// 添加商品到購物車
+		cart.POST("/items", addToCartHandler(repo))
+		// 修改商品數量
+		cart.PUT("/items/:productID", updateItemHandler(repo))
+		// 移除商品
+		cart.DELETE("/items/:productID", removeItemHandler(repo))
+		// 套用優惠碼
+		cart.POST("/coupons", applyCouponHandler(repo, pricer))
+		// 移除優惠碼
+		cart.DELETE("/coupons/:code", removeCouponHandler(repo))
+	}
 
-	// 獲取購物車內容
-	r.GET("/cart/:userID", getCartHandler(repo))
+	// 合併與結帳需要登入
+	account := r.Group("/api/cart", authMiddleware, middleware.SelectCurrency())
+	{
+		// 登入或註冊後合併訪客購物車
+		account.POST("/merge", guests.mergeHandler())
+		// 結帳
+		account.POST("/checkout", checkoutHandler(repo, pricer, promotions))
+	}
 
-	// 結帳
-	r.POST("/cart/checkout", checkoutHandler(repo, producer))
+	// 優惠活動管理需要 promotions:write 權限
+	admin := r.Group("/api/admin/promotions", authMiddleware, middleware.RequirePermissions(middleware.PermPromotionsWrite))
+	{
+		admin.POST("", createPromotionHandler(promotionRepo))
+		admin.GET("", listPromotionsHandler(promotionRepo))
+		admin.GET("/:id", getPromotionHandler(promotionRepo))
+		admin.PUT("/:id", updatePromotionHandler(promotionRepo))
+	}
 
 	return r
 }
 
 func addToCartHandler(repo repository.CartRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req struct {
-			UserID    string `json:"user_id" binding:"required"`
-			ProductID string `json:"product_id" binding:"required"`
-			Quantity  int    `json:"quantity" binding:"required,min=1"`
-		}
-
+		var req models.CartItemRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
-		if err := repo.AddToCart(req.UserID, req.ProductID, req.Quantity); err != nil {
+		if err := repo.AddToCart(cartOwner(c), req.ProductID, req.Quantity); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "無法添加商品到購物車"})
 			return
 		}
@@ -95,60 +175,227 @@ func addToCartHandler(repo repository.CartRepository) gin.HandlerFunc {
 	}
 }
 
-func getCartHandler(repo repository.CartRepository) gin.HandlerFunc {
+func getCartHandler(repo repository.CartRepository, pricer *pricing.Pricer) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID := c.Param("userID")
-		cart, err := repo.GetCart(userID)
+		owner := cartOwner(c)
+		cart, err := repo.GetCart(owner)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "購物車不存在"})
+			// 尚未加入過商品的用戶或訪客視為空購物車
+			if !errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "無法獲取購物車"})
+				return
+			}
+			cart = &models.Cart{UserID: owner.UserID, Items: []models.CartItem{}}
+		}
+
+		// 以商品的目前售價計算金額
+		priced, err := pricer.Price(c.Request.Context(), cart, pricing.Currency(middleware.SelectedCurrency(c), cart))
+		if err != nil {
+			c.JSON(pricingErrorStatus(err), gin.H{"error": "無法計算購物車金額: " + err.Error()})
 			return
 		}
 
-		c.JSON(http.StatusOK, cart)
+		c.JSON(http.StatusOK, priced)
 	}
 }
 
-func checkoutHandler(repo repository.CartRepository, producer messaging.KafkaProducer) gin.HandlerFunc {
+func updateItemHandler(repo repository.CartRepository) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var req struct {
-			UserID string `json:"user_id" binding:"required"`
-		}
-
+		var req models.UpdateCartItemRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
 
+		if err := repo.UpdateItem(cartOwner(c), c.Param("productID"), req.Quantity); err != nil {
+			c.JSON(cartErrorStatus(err), gin.H{"error": "無法修改商品數量: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "商品數量已更新"})
+	}
+}
+
+func removeItemHandler(repo repository.CartRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := repo.RemoveItem(cartOwner(c), c.Param("productID")); err != nil {
+			c.JSON(cartErrorStatus(err), gin.H{"error": "無法移除商品: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "商品已從購物車移除"})
+	}
+}
+
+func checkoutHandler(repo repository.CartRepository, pricer *pricing.Pricer, promotions *promotion.Promotions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			// PaymentMethod 可選，提供時訂單建立後由支付服務自動付款
+			PaymentMethod string `json:"payment_method"`
+			// AddressID 與 ShippingInfo 可選，皆未提供時使用地址簿中的預設收件地址
+			AddressID    string               `json:"address_id"`
+			ShippingInfo *events.ShippingInfo `json:"shipping_info"`
+		}
+
+		// 所有欄位皆可選，允許沒有請求內容
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		userID := c.GetString("user_id")
+
 		// 獲取購物車
-		cart, err := repo.GetCart(req.UserID)
+		cart, err := repo.GetCart(repository.UserOwner(userID))
 		if err != nil {
 			c.JSON(http.StatusNotFound, gin.H{"error": "購物車不存在"})
 			return
 		}
 
-		// 發送事件到Kafka
-		event := map[string]interface{}{
-			"event_type": "CHECKOUT",
-			"user_id":    req.UserID,
-			"cart_id":    cart.ID,
-			"items":      cart.Items,
-			"timestamp":  time.Now(),
+		// 以商品的目前售價計價，下架或庫存不足的商品需先調整；
+		// 計價幣別鎖定在購物車與訂單上，之後匯率或售價變動不影響訂單
+		priced, err := pricer.Price(c.Request.Context(), cart, pricing.Currency(middleware.SelectedCurrency(c), cart))
+		if err != nil {
+			c.JSON(pricingErrorStatus(err), gin.H{"error": "無法計算購物車金額: " + err.Error()})
+			return
+		}
+		if unavailable := priced.Unavailable(); len(unavailable) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "部分商品已下架或庫存不足，請調整後再結帳", "items": unavailable})
+			return
+		}
+		if len(priced.RejectedCoupons) > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "部分優惠碼已無法使用，請移除後再結帳", "coupons": priced.RejectedCoupons})
+			return
 		}
 
-		if err := producer.Produce("cart-events", event); err != nil {
+		// 結帳事件與清空購物車在同一次更新中寫入，由事件轉發器發布到Kafka；
+		// 事件中的售價是結帳當下的快照，訂單以此計價
+		event := events.CartCheckedOut{
+			CartID:        cart.ID,
+			UserID:        userID,
+			Items:         make([]events.CartItem, 0, len(priced.Items)),
+			CheckedOutAt:  time.Now(),
+			PaymentMethod: req.PaymentMethod,
+			AddressID:     req.AddressID,
+			ShippingInfo:  req.ShippingInfo,
+			Subtotal:      priced.Subtotal,
+			Discounts:     make([]events.Discount, 0, len(priced.Discounts)),
+			FreeShipping:  priced.FreeShipping,
+		}
+		for _, line := range priced.Discounts {
+			event.Discounts = append(event.Discounts, events.Discount{
+				PromotionID: line.PromotionID,
+				Code:        line.Code,
+				Type:        line.Type,
+				Description: line.Description,
+				Amount:      line.Amount,
+			})
+		}
+		for _, item := range priced.Items {
+			event.Items = append(event.Items, events.CartItem{
+				ProductID:   item.ProductID,
+				Quantity:    item.Quantity,
+				ProductName: item.Name,
+				CategoryID:  item.CategoryID,
+				TaxCategory: item.TaxCategory,
+				UnitPrice:   item.UnitPrice,
+			})
+		}
+
+		encoded, err := events.Encode(c.Request.Context(), "cart-service", event)
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "結帳處理失敗"})
 			return
 		}
 
-		// 清空購物車
-		if err := repo.ClearCart(req.UserID); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "無法清空購物車"})
+		// 記錄優惠碼使用次數，結帳失敗時撤銷
+		if err := promotions.Redeem(c.Request.Context(), priced); err != nil {
+			c.JSON(promotionErrorStatus(err), gin.H{"error": "無法使用優惠碼: " + err.Error()})
+			return
+		}
+
+		cart.Currency = priced.Currency
+		err = repo.CheckoutCart(cart, outbox.Message{Topic: encoded.Topic, Key: encoded.Key, Payload: encoded.Data})
+		if err != nil {
+			if releaseErr := promotions.Release(c.Request.Context(), priced); releaseErr != nil {
+				log.Printf("撤銷優惠碼使用失敗: %v", releaseErr)
+			}
+			if errors.Is(err, repository.ErrCartChanged) {
+				c.JSON(http.StatusConflict, gin.H{"error": "購物車已變更或為空，請重新確認"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "結帳處理失敗"})
 			return
 		}
 
 		c.JSON(http.StatusOK, gin.H{
 			"message":   "結帳成功",
+			"currency":  priced.Currency,
+			"subtotal":  priced.Subtotal,
+			"discount":  priced.DiscountTotal,
+			"total":     priced.Total,
 			"timestamp": time.Now(),
 		})
 	}
 }
+
+// pricingErrorStatus 將購物車計價錯誤對應到HTTP狀態碼
+func pricingErrorStatus(err error) int {
+	if errors.Is(err, pricing.ErrCurrencyUnavailable) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusServiceUnavailable
+}
+
+// cartErrorStatus 將購物車錯誤對應到HTTP狀態碼
+func cartErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrItemNotFound),
+		errors.Is(err, repository.ErrCouponNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, repository.ErrCartChanged):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// subscribeToPrivacyRequests 處理用戶資料匯出與刪除請求，並將結果回報認證服務
+func subscribeToPrivacyRequests(broker messaging.Broker, repo repository.CartRepository) error {
+	mux := events.NewMux(nil)
+
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.DataSubjectRequested) error {
+		result := events.DataSubjectCompleted{
+			RequestID: event.RequestID,
+			UserID:    event.UserID,
+			Kind:      event.Kind,
+			Service:   "cart-service",
+		}
+
+		switch event.Kind {
+		case events.DataSubjectExport:
+			cart, err := repo.GetCart(repository.UserOwner(event.UserID))
+			if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+				return err
+			}
+			data, err := json.Marshal(cart)
+			if err != nil {
+				return err
+			}
+			result.Data = data
+		case events.DataSubjectErasure:
+			// 結帳事件尚未發布時稍後重試
+			if err := repo.DeleteCart(event.UserID); err != nil {
+				return err
+			}
+		default:
+			result.Error = "未知的請求種類: " + event.Kind
+		}
+
+		result.CompletedAt = time.Now()
+		return messaging.PublishEvent(ctx, broker, "cart-service", result)
+	})
+
+	return messaging.SubscribeEvents(context.Background(), broker, events.TopicPrivacyRequests, "cart-service", mux)
+}
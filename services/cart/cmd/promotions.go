@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/cart/pricing"
+	"github.com/arrontsai/ecommerce/services/cart/promotion"
+	"github.com/arrontsai/ecommerce/services/cart/repository"
+)
+
+// applyCouponHandler 將優惠碼套用到購物車，回傳套用後的購物車金額
+//
+// 優惠碼無法套用到目前的購物車時回傳 422 與原因，不會加入購物車。
+func applyCouponHandler(repo repository.CartRepository, pricer *pricing.Pricer) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.ApplyCouponRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		code := models.NormalizeCouponCode(req.Code)
+
+		owner := cartOwner(c)
+		cart, err := repo.GetCart(owner)
+		if err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				c.JSON(http.StatusNotFound, gin.H{"error": "購物車是空的"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "無法獲取購物車"})
+			return
+		}
+		if cart.AddCoupon(code) && len(cart.Coupons) > promotion.MaxCoupons {
+			c.JSON(http.StatusBadRequest, gin.H{"error": promotion.ErrTooManyCoupons.Error()})
+			return
+		}
+
+		priced, err := pricer.Price(c.Request.Context(), cart, pricing.Currency(middleware.SelectedCurrency(c), cart))
+		if err != nil {
+			c.JSON(pricingErrorStatus(err), gin.H{"error": "無法計算購物車金額: " + err.Error()})
+			return
+		}
+		if reason := promotion.Rejection(priced, code); reason != "" {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": promotion.ErrCouponNotApplicable.Error() + ": " + reason, "code": code})
+			return
+		}
+
+		if err := repo.AddCoupon(owner, code); err != nil {
+			c.JSON(cartErrorStatus(err), gin.H{"error": "無法套用優惠碼: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, priced)
+	}
+}
+
+// removeCouponHandler 從購物車移除優惠碼
+func removeCouponHandler(repo repository.CartRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		code := models.NormalizeCouponCode(c.Param("code"))
+		if err := repo.RemoveCoupon(cartOwner(c), code); err != nil {
+			c.JSON(cartErrorStatus(err), gin.H{"error": "無法移除優惠碼: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "優惠碼已移除"})
+	}
+}
+
+// createPromotionHandler 新增優惠活動
+func createPromotionHandler(repo repository.PromotionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.PromotionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		p := models.NewPromotion(req)
+		if err := promotion.Validate(p); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := repo.CreatePromotion(p); err != nil {
+			c.JSON(promotionErrorStatus(err), gin.H{"error": "無法新增優惠活動: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, p)
+	}
+}
+
+// listPromotionsHandler 列出全部優惠活動
+func listPromotionsHandler(repo repository.PromotionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		promotions, err := repo.ListPromotions()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "無法獲取優惠活動: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"promotions": promotions})
+	}
+}
+
+// getPromotionHandler 查詢優惠活動及其使用次數
+func getPromotionHandler(repo repository.PromotionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, err := repo.FindPromotion(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "無法獲取優惠活動: " + err.Error()})
+			return
+		}
+		if p == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "優惠活動不存在"})
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+// updatePromotionHandler 更新優惠活動的設定，停用時將 active 設為 false
+func updatePromotionHandler(repo repository.PromotionRepository) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.PromotionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		p, err := repo.FindPromotion(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "無法獲取優惠活動: " + err.Error()})
+			return
+		}
+		if p == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "優惠活動不存在"})
+			return
+		}
+
+		p.UpdatePromotion(req)
+		if err := promotion.Validate(p); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := repo.UpdatePromotion(p); err != nil {
+			c.JSON(promotionErrorStatus(err), gin.H{"error": "無法更新優惠活動: " + err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, p)
+	}
+}
+
+// promotionErrorStatus 將優惠活動錯誤對應到HTTP狀態碼
+func promotionErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, repository.ErrDuplicateCode),
+		errors.Is(err, repository.ErrUsageLimitReached),
+		errors.Is(err, repository.ErrUserLimitReached):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
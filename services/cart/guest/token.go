@@ -0,0 +1,52 @@
+package guest
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidToken 購物車令牌格式錯誤或簽名不符
+var ErrInvalidToken = errors.New("無效的購物車令牌")
+
+// Tokens 簽發與驗證訪客購物車令牌
+//
+// 令牌格式為 "<訪客ID>.<HMAC-SHA256 簽名>"，訪客無法偽造他人的訪客ID。
+// 令牌本身不會過期，過期由購物車文件的 TTL 索引負責。
+type Tokens struct {
+	secret []byte
+}
+
+// NewTokens 以密鑰創建購物車令牌簽發器
+func NewTokens(secret string) *Tokens {
+	return &Tokens{secret: []byte(secret)}
+}
+
+// Issue 產生新的訪客ID與其令牌
+func (t *Tokens) Issue() (string, string) {
+	guestID := uuid.NewString()
+	return guestID, guestID + "." + t.sign(guestID)
+}
+
+// Verify 驗證令牌並回傳其中的訪客ID
+func (t *Tokens) Verify(token string) (string, error) {
+	guestID, signature, ok := strings.Cut(token, ".")
+	if !ok || guestID == "" {
+		return "", ErrInvalidToken
+	}
+	if !hmac.Equal([]byte(signature), []byte(t.sign(guestID))) {
+		return "", ErrInvalidToken
+	}
+	return guestID, nil
+}
+
+// sign 計算訪客ID的簽名
+func (t *Tokens) sign(guestID string) string {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(guestID))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,124 @@
+package merge
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/product/proto/pb"
+)
+
+// Rule 訪客購物車併入用戶購物車時，同一商品數量的合併規則
+type Rule string
+
+const (
+	// Sum 數量相加
+	Sum Rule = "sum"
+	// Newest 保留最後加入的項目
+	Newest Rule = "newest"
+	// CapStock 數量相加後不超過目前可用庫存，無庫存或已下架的商品會被移除
+	CapStock Rule = "stock"
+)
+
+// ParseRule 解析設定中的合併規則
+func ParseRule(value string) (Rule, error) {
+	switch rule := Rule(value); rule {
+	case Sum, Newest, CapStock:
+		return rule, nil
+	}
+	return "", fmt.Errorf("未知的購物車合併規則: %s", value)
+}
+
+// StockSource 查詢商品目前的可用庫存，不存在的商品不會出現在結果中
+type StockSource interface {
+	AvailableStock(ctx context.Context, productIDs []string) (map[string]int, error)
+}
+
+// Merger 依規則合併購物車
+type Merger struct {
+	rule  Rule
+	stock StockSource
+}
+
+// NewMerger 創建購物車合併器，只有 CapStock 規則會使用 stock
+func NewMerger(rule Rule, stock StockSource) *Merger {
+	return &Merger{rule: rule, stock: stock}
+}
+
+// Merge 合併用戶與訪客購物車的商品，保留用戶購物車中的順序，訪客的新商品接在後面
+func (m *Merger) Merge(ctx context.Context, userItems, guestItems []models.CartItem) ([]models.CartItem, error) {
+	items := make([]models.CartItem, 0, len(userItems)+len(guestItems))
+	index := make(map[string]int, len(userItems)+len(guestItems))
+	for _, item := range append(append([]models.CartItem{}, userItems...), guestItems...) {
+		i, ok := index[item.ProductID]
+		if !ok {
+			index[item.ProductID] = len(items)
+			items = append(items, item)
+			continue
+		}
+
+		existing := &items[i]
+		switch m.rule {
+		case Newest:
+			// 加入時間相同時以訪客的為準，因為那是用戶最近一次的操作
+			if !item.AddedAt.Before(existing.AddedAt) {
+				*existing = item
+			}
+		default:
+			existing.Quantity += item.Quantity
+			if item.AddedAt.After(existing.AddedAt) {
+				existing.AddedAt = item.AddedAt
+			}
+		}
+	}
+
+	if m.rule == CapStock {
+		return m.capAtStock(ctx, items)
+	}
+	return items, nil
+}
+
+// capAtStock 將數量限制在可用庫存內
+func (m *Merger) capAtStock(ctx context.Context, items []models.CartItem) ([]models.CartItem, error) {
+	productIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		productIDs = append(productIDs, item.ProductID)
+	}
+	available, err := m.stock.AvailableStock(ctx, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("查詢庫存失敗: %w", err)
+	}
+
+	capped := items[:0]
+	for _, item := range items {
+		item.Quantity = min(item.Quantity, available[item.ProductID])
+		if item.Quantity > 0 {
+			capped = append(capped, item)
+		}
+	}
+	return capped, nil
+}
+
+// InventoryStock 透過產品服務的庫存gRPC接口查詢可用庫存
+type InventoryStock struct {
+	client pb.InventoryServiceClient
+}
+
+// NewInventoryStock 創建以產品服務查詢庫存的 StockSource
+func NewInventoryStock(client pb.InventoryServiceClient) *InventoryStock {
+	return &InventoryStock{client: client}
+}
+
+// AvailableStock 實作 StockSource
+func (s *InventoryStock) AvailableStock(ctx context.Context, productIDs []string) (map[string]int, error) {
+	resp, err := s.client.GetStock(ctx, &pb.GetStockRequest{ProductIds: productIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	available := make(map[string]int, len(resp.Stocks))
+	for _, stock := range resp.Stocks {
+		available[stock.ProductId] = int(stock.Available)
+	}
+	return available, nil
+}
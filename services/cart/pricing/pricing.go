@@ -0,0 +1,170 @@
+package pricing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/services/product/proto/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ErrCurrencyUnavailable 購物車中有商品無法以選擇的幣別計價
+var ErrCurrencyUnavailable = errors.New("無法以選擇的幣別計價")
+
+// Product 計價所需的商品資訊
+type Product struct {
+	ID         string
+	Name       string
+	CategoryID string
+	// TaxCategory 商品的稅別，空白表示一般稅率
+	TaxCategory string
+	Price       money.Money
+	// PriceConverted 售價由基準價格以匯率換算
+	PriceConverted bool
+	// Available 可用庫存 (庫存減去預留)
+	Available int
+}
+
+// Catalog 查詢商品目前的名稱、以指定幣別計算的售價與可用庫存，不存在的商品不會出現在結果中；
+// 無法以該幣別計價時回傳 ErrCurrencyUnavailable
+type Catalog interface {
+	Products(ctx context.Context, productIDs []string, currency string) (map[string]Product, error)
+}
+
+// Discounter 依購物車的優惠碼計算折扣，寫入購物車的折扣與總金額
+type Discounter interface {
+	Apply(ctx context.Context, cart *models.PricedCart, codes []string) error
+}
+
+// Pricer 以產品服務的目前售價計算購物車金額
+type Pricer struct {
+	catalog    Catalog
+	discounter Discounter
+}
+
+// NewPricer 創建購物車計價器
+func NewPricer(catalog Catalog, discounter Discounter) *Pricer {
+	return &Pricer{catalog: catalog, discounter: discounter}
+}
+
+// Currency 決定購物車的計價幣別：請求選擇的幣別優先，其次為購物車上次結帳鎖定的幣別，
+// 都沒有時使用 money.DefaultCurrency
+func Currency(selected string, cart *models.Cart) string {
+	switch {
+	case selected != "":
+		return selected
+	case cart.Currency != "":
+		return cart.Currency
+	}
+	return money.DefaultCurrency
+}
+
+// Price 以指定幣別查詢購物車中商品的目前售價與庫存，計算每項小計與總金額
+//
+// 已下架的商品標記為 unavailable 且不計入金額；庫存不足的商品標記為
+// insufficient_stock，仍計入金額，讓用戶看到調整數量前的金額。
+// 購物車有優惠碼時再計算折扣，總金額為商品金額減去折扣。
+// 商品沒有該幣別的售價時由產品服務以匯率換算。
+func (p *Pricer) Price(ctx context.Context, cart *models.Cart, currency string) (*models.PricedCart, error) {
+	priced := &models.PricedCart{
+		ID:            cart.ID,
+		UserID:        cart.UserID,
+		Currency:      currency,
+		Items:         make([]models.PricedCartItem, 0, len(cart.Items)),
+		Discounts:     []models.DiscountLine{},
+		DiscountTotal: money.Zero(currency),
+		UpdatedAt:     cart.UpdatedAt,
+		ExpiresAt:     cart.ExpiresAt,
+	}
+
+	products := map[string]Product{}
+	if len(cart.Items) > 0 {
+		productIDs := make([]string, 0, len(cart.Items))
+		for _, item := range cart.Items {
+			productIDs = append(productIDs, item.ProductID)
+		}
+		var err error
+		products, err = p.catalog.Products(ctx, productIDs, currency)
+		if err != nil {
+			return nil, fmt.Errorf("查詢商品價格失敗: %w", err)
+		}
+	}
+
+	prices := make(map[string]money.Money, len(products))
+	for _, item := range cart.Items {
+		line := models.PricedCartItem{CartItem: item, Status: models.ItemUnavailable}
+
+		if product, ok := products[item.ProductID]; ok {
+			line.Name = product.Name
+			line.CategoryID = product.CategoryID
+			line.TaxCategory = product.TaxCategory
+			line.UnitPrice = product.Price
+			line.PriceConverted = product.PriceConverted
+			line.Subtotal = product.Price.Multiply(int64(item.Quantity))
+			line.Available = product.Available
+			line.Status = models.ItemAvailable
+			if item.Quantity > product.Available {
+				line.Status = models.ItemInsufficientStock
+			}
+
+			prices[item.ProductID] = product.Price
+			priced.ItemCount += item.Quantity
+		}
+
+		priced.Items = append(priced.Items, line)
+	}
+
+	subtotal, err := cart.TotalAmount(prices, currency)
+	if err != nil {
+		return nil, fmt.Errorf("計算購物車金額失敗: %w", err)
+	}
+	priced.Subtotal = subtotal
+	priced.Total = subtotal
+
+	if len(cart.Coupons) > 0 {
+		if err := p.discounter.Apply(ctx, priced, cart.Coupons); err != nil {
+			return nil, err
+		}
+	}
+	return priced, nil
+}
+
+// CatalogClient 透過產品服務的商品gRPC接口查詢商品
+type CatalogClient struct {
+	client pb.CatalogServiceClient
+}
+
+// NewCatalogClient 創建以產品服務查詢商品的 Catalog
+func NewCatalogClient(client pb.CatalogServiceClient) *CatalogClient {
+	return &CatalogClient{client: client}
+}
+
+// Products 實作 Catalog
+func (c *CatalogClient) Products(ctx context.Context, productIDs []string, currency string) (map[string]Product, error) {
+	resp, err := c.client.GetProducts(ctx, &pb.GetProductsRequest{ProductIds: productIDs, Currency: currency})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.FailedPrecondition, codes.InvalidArgument:
+			return nil, fmt.Errorf("%w: %s", ErrCurrencyUnavailable, status.Convert(err).Message())
+		}
+		return nil, err
+	}
+
+	products := make(map[string]Product, len(resp.Products))
+	for _, product := range resp.Products {
+		products[product.ProductId] = Product{
+			ID:             product.ProductId,
+			Name:           product.Name,
+			CategoryID:     product.CategoryId,
+			TaxCategory:    product.TaxCategory,
+			Price:          product.Price.ToMoney(),
+			PriceConverted: product.PriceConverted,
+			Available:      int(product.Available),
+		}
+	}
+	return products, nil
+}
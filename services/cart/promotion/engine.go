@@ -0,0 +1,227 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// ErrInvalidPromotion 優惠活動缺少其類型所需的設定
+var ErrInvalidPromotion = errors.New("無效的優惠活動設定")
+
+// Validate 檢查優惠活動依類型所需的欄位
+func Validate(p *models.Promotion) error {
+	switch p.Type {
+	case models.PromotionPercentage:
+		if p.Percent <= 0 || p.Percent > 100 {
+			return fmt.Errorf("%w: 折扣百分比需大於 0 且不超過 100", ErrInvalidPromotion)
+		}
+	case models.PromotionFixed:
+		if err := p.Amount.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPromotion, err)
+		}
+		if !p.Amount.IsPositive() {
+			return fmt.Errorf("%w: 折扣金額需大於 0", ErrInvalidPromotion)
+		}
+	case models.PromotionBuyXGetY:
+		if p.BuyQuantity <= 0 || p.GetQuantity <= 0 {
+			return fmt.Errorf("%w: 買 X 送 Y 的數量需大於 0", ErrInvalidPromotion)
+		}
+	case models.PromotionFreeShipping:
+	default:
+		return fmt.Errorf("%w: 未知的類型 %s", ErrInvalidPromotion, p.Type)
+	}
+
+	if !p.MinSubtotal.IsZero() {
+		if err := p.MinSubtotal.Validate(); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidPromotion, err)
+		}
+		if p.MinSubtotal.IsNegative() {
+			return fmt.Errorf("%w: 最低消費金額不能為負數", ErrInvalidPromotion)
+		}
+	}
+	if p.Type == models.PromotionFixed && !p.MinSubtotal.IsZero() && !p.MinSubtotal.SameCurrency(p.Amount) {
+		return fmt.Errorf("%w: 最低消費金額與折扣金額的幣別不同", ErrInvalidPromotion)
+	}
+
+	if p.StartsAt != nil && p.EndsAt != nil && !p.EndsAt.After(*p.StartsAt) {
+		return fmt.Errorf("%w: 結束時間需晚於開始時間", ErrInvalidPromotion)
+	}
+	return nil
+}
+
+// Result 購物車套用優惠碼的結果
+type Result struct {
+	Discounts    []models.DiscountLine
+	Rejected     []models.RejectedCoupon
+	FreeShipping bool
+}
+
+// Evaluate 依序計算優惠活動對購物車的折扣
+//
+// 後面的優惠以前面優惠折抵後的剩餘金額計算，總折扣不會超過商品金額。
+// 最低消費以適用商品的原始小計判斷。已下架的商品不適用任何優惠。
+// 金額以購物車幣別的最小單位計算，固定金額與最低消費的幣別需與購物車相同。
+func Evaluate(cart *models.PricedCart, promotions []models.Promotion, now time.Time) Result {
+	remaining := make([]int64, len(cart.Items))
+	for i, item := range cart.Items {
+		if item.Status != models.ItemUnavailable {
+			remaining[i] = item.Subtotal.Amount
+		}
+	}
+
+	var result Result
+	for i := range promotions {
+		p := &promotions[i]
+		if reason := availability(p, now); reason != "" {
+			result.Rejected = append(result.Rejected, models.RejectedCoupon{Code: p.Code, Reason: reason})
+			continue
+		}
+
+		if reason := currencyMismatch(cart, p); reason != "" {
+			result.Rejected = append(result.Rejected, models.RejectedCoupon{Code: p.Code, Reason: reason})
+			continue
+		}
+
+		eligible, subtotal := eligibleItems(cart, p)
+		if len(eligible) == 0 {
+			result.Rejected = append(result.Rejected, models.RejectedCoupon{Code: p.Code, Reason: "購物車中沒有適用的商品"})
+			continue
+		}
+		if subtotal < p.MinSubtotal.Amount {
+			result.Rejected = append(result.Rejected, models.RejectedCoupon{
+				Code:   p.Code,
+				Reason: fmt.Sprintf("適用商品未達最低消費金額 %s", p.MinSubtotal),
+			})
+			continue
+		}
+
+		amount, reason := discountAmount(cart, p, eligible, remaining)
+		if reason != "" {
+			result.Rejected = append(result.Rejected, models.RejectedCoupon{Code: p.Code, Reason: reason})
+			continue
+		}
+		deduct(remaining, eligible, amount)
+
+		if p.Type == models.PromotionFreeShipping {
+			result.FreeShipping = true
+		}
+		result.Discounts = append(result.Discounts, models.DiscountLine{
+			PromotionID: p.ID,
+			Code:        p.Code,
+			Type:        p.Type,
+			Description: p.Description,
+			Amount:      money.New(amount, cart.Currency),
+		})
+	}
+	return result
+}
+
+// availability 檢查優惠活動是否啟用且在有效期間內，可使用時回傳空字串
+func availability(p *models.Promotion, now time.Time) string {
+	switch {
+	case !p.Active:
+		return "優惠碼已停用"
+	case p.StartsAt != nil && now.Before(*p.StartsAt):
+		return "優惠活動尚未開始"
+	case p.EndsAt != nil && !now.Before(*p.EndsAt):
+		return "優惠活動已結束"
+	}
+	return ""
+}
+
+// currencyMismatch 檢查優惠活動的金額設定與購物車幣別相同，相同時回傳空字串
+func currencyMismatch(cart *models.PricedCart, p *models.Promotion) string {
+	if p.Type == models.PromotionFixed && p.Amount.Currency != cart.Currency {
+		return fmt.Sprintf("優惠碼僅適用於 %s 計價的購物車", p.Amount.Currency)
+	}
+	if !p.MinSubtotal.IsZero() && p.MinSubtotal.Currency != cart.Currency {
+		return fmt.Sprintf("優惠碼僅適用於 %s 計價的購物車", p.MinSubtotal.Currency)
+	}
+	return ""
+}
+
+// eligibleItems 回傳適用商品的索引與其原始小計
+func eligibleItems(cart *models.PricedCart, p *models.Promotion) ([]int, int64) {
+	var eligible []int
+	var subtotal int64
+	for i, item := range cart.Items {
+		if item.Status == models.ItemUnavailable || !p.AppliesToCategory(item.CategoryID) {
+			continue
+		}
+		eligible = append(eligible, i)
+		subtotal += item.Subtotal.Amount
+	}
+	return eligible, subtotal
+}
+
+// discountAmount 計算優惠活動的折扣金額 (最小單位)，無法折抵時回傳原因
+func discountAmount(cart *models.PricedCart, p *models.Promotion, eligible []int, remaining []int64) (int64, string) {
+	if p.Type == models.PromotionFreeShipping {
+		return 0, ""
+	}
+
+	var available int64
+	for _, i := range eligible {
+		available += remaining[i]
+	}
+
+	var amount int64
+	switch p.Type {
+	case models.PromotionPercentage:
+		amount = money.New(available, cart.Currency).Percent(p.Percent, money.HalfUp).Amount
+	case models.PromotionFixed:
+		amount = p.Amount.Amount
+	case models.PromotionBuyXGetY:
+		amount = freeUnitsValue(cart, p, eligible)
+		if amount == 0 {
+			return 0, fmt.Sprintf("需購買 %d 件適用商品", p.BuyQuantity+p.GetQuantity)
+		}
+	}
+
+	amount = min(amount, available)
+	if amount <= 0 {
+		return 0, "已沒有可折抵的金額"
+	}
+	return amount, ""
+}
+
+// freeUnitsValue 買 X 送 Y：每 X+Y 件適用商品中，最便宜的 Y 件免費
+func freeUnitsValue(cart *models.PricedCart, p *models.Promotion, eligible []int) int64 {
+	items := make([]models.PricedCartItem, 0, len(eligible))
+	units := 0
+	for _, i := range eligible {
+		items = append(items, cart.Items[i])
+		units += cart.Items[i].Quantity
+	}
+	sort.Slice(items, func(a, b int) bool { return items[a].UnitPrice.Amount < items[b].UnitPrice.Amount })
+
+	free := units / (p.BuyQuantity + p.GetQuantity) * p.GetQuantity
+	var value int64
+	for _, item := range items {
+		n := min(free, item.Quantity)
+		value += item.UnitPrice.Amount * int64(n)
+		free -= n
+	}
+	return value
+}
+
+// deduct 將折扣依剩餘金額比例分攤到適用商品，分攤後的總和與折扣相同
+func deduct(remaining []int64, eligible []int, amount int64) {
+	ratios := make([]int64, len(eligible))
+	for n, i := range eligible {
+		ratios[n] = remaining[i]
+	}
+	shares, err := money.New(amount, "").Allocate(ratios...)
+	if err != nil {
+		// 適用商品已沒有剩餘金額，discountAmount 不會回傳折扣
+		return
+	}
+	for n, i := range eligible {
+		remaining[i] -= shares[n].Amount
+	}
+}
@@ -0,0 +1,236 @@
+package promotion
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// testCart 手機 1,000 元一支、手機殼 200 元三個，以及一件已下架的商品
+func testCart() *models.PricedCart {
+	item := func(id, category string, unit int64, quantity int, status string) models.PricedCartItem {
+		return models.PricedCartItem{
+			CartItem:   models.CartItem{ProductID: id, Quantity: quantity},
+			CategoryID: category,
+			UnitPrice:  money.New(unit, "TWD"),
+			Subtotal:   money.New(unit*int64(quantity), "TWD"),
+			Status:     status,
+		}
+	}
+	return &models.PricedCart{
+		Currency: "TWD",
+		Items: []models.PricedCartItem{
+			item("phone", "phones", 100000, 1, models.ItemAvailable),
+			item("case", "cases", 20000, 3, models.ItemAvailable),
+			item("retired", "phones", 50000, 1, models.ItemUnavailable),
+		},
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	yesterday, tomorrow := now.AddDate(0, 0, -1), now.AddDate(0, 0, 1)
+
+	percent := func(code string, pct float64, categories ...string) models.Promotion {
+		return models.Promotion{Code: code, Type: models.PromotionPercentage, Percent: pct, CategoryIDs: categories, Active: true}
+	}
+	fixed := func(code string, amount money.Money) models.Promotion {
+		return models.Promotion{Code: code, Type: models.PromotionFixed, Amount: amount, Active: true}
+	}
+
+	tests := []struct {
+		name       string
+		promotions []models.Promotion
+		// wantDiscounts 各優惠碼的折扣金額 (最小單位)
+		wantDiscounts    map[string]int64
+		wantRejected     []string
+		wantFreeShipping bool
+	}{
+		{
+			name:          "percentage of available items",
+			promotions:    []models.Promotion{percent("TEN", 10)},
+			wantDiscounts: map[string]int64{"TEN": 16000},
+		},
+		{
+			name:          "percentage limited to a category",
+			promotions:    []models.Promotion{percent("CASES50", 50, "cases")},
+			wantDiscounts: map[string]int64{"CASES50": 30000},
+		},
+		{
+			name:          "fixed amount",
+			promotions:    []models.Promotion{fixed("300OFF", money.New(30000, "TWD"))},
+			wantDiscounts: map[string]int64{"300OFF": 30000},
+		},
+		{
+			name:         "fixed amount in another currency",
+			promotions:   []models.Promotion{fixed("USD10", money.New(1000, "USD"))},
+			wantRejected: []string{"USD10"},
+		},
+		{
+			name: "below minimum subtotal",
+			promotions: []models.Promotion{{
+				Code: "BIG", Type: models.PromotionPercentage, Percent: 10, Active: true,
+				MinSubtotal: money.New(200000, "TWD"),
+			}},
+			wantRejected: []string{"BIG"},
+		},
+		{
+			// 已下架的商品不計入最低消費
+			name: "minimum subtotal ignores unavailable items",
+			promotions: []models.Promotion{{
+				Code: "PHONES", Type: models.PromotionPercentage, Percent: 10, Active: true,
+				CategoryIDs: []string{"phones"}, MinSubtotal: money.New(150000, "TWD"),
+			}},
+			wantRejected: []string{"PHONES"},
+		},
+		{
+			name:         "inactive",
+			promotions:   []models.Promotion{{Code: "OFF", Type: models.PromotionPercentage, Percent: 10}},
+			wantRejected: []string{"OFF"},
+		},
+		{
+			name: "not started and ended",
+			promotions: []models.Promotion{
+				{Code: "SOON", Type: models.PromotionPercentage, Percent: 10, Active: true, StartsAt: &tomorrow},
+				{Code: "OVER", Type: models.PromotionPercentage, Percent: 10, Active: true, EndsAt: &yesterday},
+			},
+			wantRejected: []string{"SOON", "OVER"},
+		},
+		{
+			name:         "no eligible items",
+			promotions:   []models.Promotion{percent("SHOES", 10, "shoes")},
+			wantRejected: []string{"SHOES"},
+		},
+		{
+			name: "buy two get the cheapest free",
+			promotions: []models.Promotion{{
+				Code: "B2G1", Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1, Active: true,
+			}},
+			// 4 件適用商品中最便宜的 1 件
+			wantDiscounts: map[string]int64{"B2G1": 20000},
+		},
+		{
+			name: "buy x get y without enough units",
+			promotions: []models.Promotion{{
+				Code: "PHONE2", Type: models.PromotionBuyXGetY, BuyQuantity: 1, GetQuantity: 1,
+				CategoryIDs: []string{"phones"}, Active: true,
+			}},
+			wantRejected: []string{"PHONE2"},
+		},
+		{
+			name:             "free shipping",
+			promotions:       []models.Promotion{{Code: "SHIP", Type: models.PromotionFreeShipping, Active: true}},
+			wantDiscounts:    map[string]int64{"SHIP": 0},
+			wantFreeShipping: true,
+		},
+		{
+			// 後面的優惠以剩餘金額計算，總折扣不超過商品金額
+			name:          "stacked discounts are capped at the remaining amount",
+			promotions:    []models.Promotion{percent("HALF", 50), fixed("1000OFF", money.New(100000, "TWD"))},
+			wantDiscounts: map[string]int64{"HALF": 80000, "1000OFF": 80000},
+		},
+		{
+			name:          "nothing left to discount",
+			promotions:    []models.Promotion{fixed("2000OFF", money.New(200000, "TWD")), percent("TEN", 10)},
+			wantDiscounts: map[string]int64{"2000OFF": 160000},
+			wantRejected:  []string{"TEN"},
+		},
+		{
+			// 手機殼已全額折抵，手機類的折扣只以手機的剩餘金額計算
+			name:          "later discounts use what is left of each item",
+			promotions:    []models.Promotion{percent("CASES", 100, "cases"), percent("PHONES", 10, "phones")},
+			wantDiscounts: map[string]int64{"CASES": 60000, "PHONES": 10000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Evaluate(testCart(), tt.promotions, now)
+
+			got := map[string]int64{}
+			for _, line := range result.Discounts {
+				if line.Amount.Currency != "TWD" {
+					t.Errorf("discount %s in %s, want TWD", line.Code, line.Amount.Currency)
+				}
+				got[line.Code] = line.Amount.Amount
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.wantDiscounts) {
+				t.Errorf("discounts = %v, want %v", got, tt.wantDiscounts)
+			}
+
+			var rejected []string
+			for _, coupon := range result.Rejected {
+				if coupon.Reason == "" {
+					t.Errorf("coupon %s rejected without a reason", coupon.Code)
+				}
+				rejected = append(rejected, coupon.Code)
+			}
+			if fmt.Sprint(rejected) != fmt.Sprint(tt.wantRejected) {
+				t.Errorf("rejected = %v, want %v", rejected, tt.wantRejected)
+			}
+
+			if result.FreeShipping != tt.wantFreeShipping {
+				t.Errorf("free shipping = %v, want %v", result.FreeShipping, tt.wantFreeShipping)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	end := start.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name      string
+		promotion models.Promotion
+		wantErr   bool
+	}{
+		{name: "percentage", promotion: models.Promotion{Type: models.PromotionPercentage, Percent: 15}},
+		{name: "percentage of zero", promotion: models.Promotion{Type: models.PromotionPercentage}, wantErr: true},
+		{name: "percentage over 100", promotion: models.Promotion{Type: models.PromotionPercentage, Percent: 101}, wantErr: true},
+		{name: "fixed", promotion: models.Promotion{Type: models.PromotionFixed, Amount: money.New(10000, "TWD")}},
+		{name: "fixed without currency", promotion: models.Promotion{Type: models.PromotionFixed, Amount: money.Money{Amount: 10000}}, wantErr: true},
+		{name: "fixed of zero", promotion: models.Promotion{Type: models.PromotionFixed, Amount: money.New(0, "TWD")}, wantErr: true},
+		{
+			name: "fixed with minimum in another currency",
+			promotion: models.Promotion{
+				Type: models.PromotionFixed, Amount: money.New(10000, "TWD"), MinSubtotal: money.New(5000, "USD"),
+			},
+			wantErr: true,
+		},
+		{name: "buy x get y", promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2, GetQuantity: 1}},
+		{name: "buy x get nothing", promotion: models.Promotion{Type: models.PromotionBuyXGetY, BuyQuantity: 2}, wantErr: true},
+		{name: "free shipping", promotion: models.Promotion{Type: models.PromotionFreeShipping}},
+		{name: "unknown type", promotion: models.Promotion{Type: "mystery"}, wantErr: true},
+		{
+			name:      "negative minimum",
+			promotion: models.Promotion{Type: models.PromotionFreeShipping, MinSubtotal: money.New(-100, "TWD")},
+			wantErr:   true,
+		},
+		{
+			name:      "ends after it starts",
+			promotion: models.Promotion{Type: models.PromotionFreeShipping, StartsAt: &start, EndsAt: &end},
+		},
+		{
+			name:      "ends before it starts",
+			promotion: models.Promotion{Type: models.PromotionFreeShipping, StartsAt: &end, EndsAt: &start},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate(&tt.promotion)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil && !errors.Is(err, ErrInvalidPromotion) {
+				t.Errorf("Validate() error = %v, want ErrInvalidPromotion", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+package promotion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/services/cart/repository"
+)
+
+// MaxCoupons 單一購物車可套用的優惠碼數量上限
+const MaxCoupons = 5
+
+// ErrTooManyCoupons 購物車的優惠碼數量已達上限
+var ErrTooManyCoupons = fmt.Errorf("每個購物車最多只能使用 %d 個優惠碼", MaxCoupons)
+
+// ErrCouponNotApplicable 優惠碼無法套用到目前的購物車
+var ErrCouponNotApplicable = errors.New("優惠碼無法使用")
+
+// Promotions 計算購物車的優惠折扣並記錄優惠碼使用次數
+type Promotions struct {
+	repo repository.PromotionRepository
+}
+
+// NewPromotions 創建優惠計算器
+func NewPromotions(repo repository.PromotionRepository) *Promotions {
+	return &Promotions{repo: repo}
+}
+
+// Apply 依購物車的優惠碼計算折扣，寫入購物車的折扣、免運與總金額
+//
+// 無法使用的優惠碼列在 RejectedCoupons 並附上原因，不會造成錯誤。
+// 購物車有用戶ID時檢查每人使用次數上限。
+func (p *Promotions) Apply(ctx context.Context, cart *models.PricedCart, codes []string) error {
+	promotions, err := p.repo.FindPromotionsByCode(codes)
+	if err != nil {
+		return fmt.Errorf("查詢優惠活動失敗: %w", err)
+	}
+	byCode := make(map[string]models.Promotion, len(promotions))
+	for _, promotion := range promotions {
+		byCode[promotion.Code] = promotion
+	}
+
+	var candidates []models.Promotion
+	var rejected []models.RejectedCoupon
+	for _, code := range codes {
+		promotion, ok := byCode[code]
+		if !ok {
+			rejected = append(rejected, models.RejectedCoupon{Code: code, Reason: "優惠碼不存在"})
+			continue
+		}
+		reason, err := p.usageLimit(&promotion, cart.UserID)
+		if err != nil {
+			return err
+		}
+		if reason != "" {
+			rejected = append(rejected, models.RejectedCoupon{Code: code, Reason: reason})
+			continue
+		}
+		candidates = append(candidates, promotion)
+	}
+
+	result := Evaluate(cart, candidates, time.Now())
+
+	cart.Discounts = result.Discounts
+	cart.RejectedCoupons = append(rejected, result.Rejected...)
+	cart.FreeShipping = result.FreeShipping
+	cart.DiscountTotal = money.Zero(cart.Currency)
+	for _, line := range cart.Discounts {
+		if cart.DiscountTotal, err = cart.DiscountTotal.Add(line.Amount); err != nil {
+			return err
+		}
+	}
+	if cart.Total, err = cart.Subtotal.Sub(cart.DiscountTotal); err != nil {
+		return err
+	}
+	if cart.Total.IsNegative() {
+		cart.Total = money.Zero(cart.Currency)
+	}
+	return nil
+}
+
+// usageLimit 檢查優惠碼的使用次數上限，可使用時回傳空字串
+func (p *Promotions) usageLimit(promotion *models.Promotion, userID string) (string, error) {
+	if promotion.UsageLimit > 0 && promotion.UsageCount >= promotion.UsageLimit {
+		return repository.ErrUsageLimitReached.Error(), nil
+	}
+	if promotion.PerUserLimit <= 0 || userID == "" {
+		return "", nil
+	}
+
+	used, err := p.repo.UserRedemptions(promotion.ID, userID)
+	if err != nil {
+		return "", fmt.Errorf("查詢優惠碼使用次數失敗: %w", err)
+	}
+	if used >= promotion.PerUserLimit {
+		return repository.ErrUserLimitReached.Error(), nil
+	}
+	return "", nil
+}
+
+// Rejection 回傳優惠碼無法使用的原因，可使用時回傳空字串
+func Rejection(cart *models.PricedCart, code string) string {
+	for _, rejected := range cart.RejectedCoupons {
+		if rejected.Code == code {
+			return rejected.Reason
+		}
+	}
+	return ""
+}
+
+// Redeem 結帳時記錄購物車中每個折扣的優惠碼使用次數
+//
+// 任一優惠碼已達使用上限時撤銷已記錄的使用並回傳
+// repository.ErrUsageLimitReached 或 repository.ErrUserLimitReached。
+func (p *Promotions) Redeem(ctx context.Context, cart *models.PricedCart) error {
+	if len(cart.Discounts) == 0 {
+		return nil
+	}
+
+	codes := make([]string, 0, len(cart.Discounts))
+	for _, line := range cart.Discounts {
+		codes = append(codes, line.Code)
+	}
+	promotions, err := p.repo.FindPromotionsByCode(codes)
+	if err != nil {
+		return fmt.Errorf("查詢優惠活動失敗: %w", err)
+	}
+
+	redeemed := make([]models.DiscountLine, 0, len(promotions))
+	for i := range promotions {
+		if err := p.repo.Redeem(&promotions[i], cart.UserID); err != nil {
+			p.release(redeemed, cart.UserID)
+			return fmt.Errorf("%s: %w", promotions[i].Code, err)
+		}
+		redeemed = append(redeemed, models.DiscountLine{PromotionID: promotions[i].ID})
+	}
+	return nil
+}
+
+// Release 撤銷結帳時記錄的優惠碼使用，用於結帳在 Redeem 之後失敗時
+func (p *Promotions) Release(ctx context.Context, cart *models.PricedCart) error {
+	return p.release(cart.Discounts, cart.UserID)
+}
+
+// release 撤銷折扣的優惠碼使用
+func (p *Promotions) release(lines []models.DiscountLine, userID string) error {
+	var errs []error
+	for _, line := range lines {
+		if err := p.repo.Release(line.PromotionID, userID); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
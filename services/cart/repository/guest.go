@@ -0,0 +1,166 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+)
+
+// maxMergedGuests 用戶購物車記錄的已合併訪客購物車數量上限
+const maxMergedGuests = 20
+
+// Owner 購物車擁有者，登入用戶以用戶ID識別，訪客以購物車令牌中的訪客ID識別
+type Owner struct {
+	UserID  string
+	GuestID string
+}
+
+// UserOwner 登入用戶的購物車
+func UserOwner(userID string) Owner {
+	return Owner{UserID: userID}
+}
+
+// GuestOwner 訪客的購物車
+func GuestOwner(guestID string) Owner {
+	return Owner{GuestID: guestID}
+}
+
+// IsGuest 檢查是否為訪客
+func (o Owner) IsGuest() bool {
+	return o.GuestID != ""
+}
+
+// filter 查詢擁有者購物車的條件
+func (o Owner) filter() bson.M {
+	if o.IsGuest() {
+		return bson.M{"guest_id": o.GuestID}
+	}
+	return bson.M{"user_id": o.UserID}
+}
+
+// expiresAt 訪客購物車在每次修改後延長保存期限，用戶購物車不會過期
+func (r *MongoCartRepository) expiresAt(owner Owner) *time.Time {
+	if !owner.IsGuest() {
+		return nil
+	}
+	expiresAt := time.Now().Add(r.guestTTL)
+	return &expiresAt
+}
+
+// MergeFunc 合併用戶與訪客購物車的商品
+type MergeFunc func(userItems, guestItems []models.CartItem) ([]models.CartItem, error)
+
+// mergedCart 用戶購物車文件，記錄已合併的訪客購物車
+type mergedCart struct {
+	models.Cart  `bson:",inline"`
+	MergedGuests []string `bson:"merged_guests,omitempty"`
+}
+
+// MergeGuestCart 將訪客購物車合併到用戶購物車後刪除訪客購物車
+//
+// 訪客購物車的優惠碼接在用戶購物車的優惠碼之後。
+// 兩份文件無法原子地同時更新，因此用戶購物車在合併時一併記錄訪客ID：
+// 若刪除訪客購物車前失敗，重試時只會刪除而不會再次合併，數量不會重複累加。
+// 訪客購物車不存在時不做任何事；用戶購物車在讀取後被修改時回傳 ErrCartChanged。
+func (r *MongoCartRepository) MergeGuestCart(guestID, userID string, merge MergeFunc) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var guest models.Cart
+	err := r.collection.FindOne(ctx, GuestOwner(guestID).filter()).Decode(&guest)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		return err
+	}
+
+	var user mergedCart
+	err = r.collection.FindOne(ctx, UserOwner(userID).filter()).Decode(&user)
+	exists := err == nil
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+
+	if !contains(user.MergedGuests, guestID) && len(guest.Items) > 0 {
+		items, err := merge(user.Items, guest.Items)
+		if err != nil {
+			return err
+		}
+		coupons := mergeCoupons(user.Coupons, guest.Coupons)
+		if err := r.saveMerged(ctx, &user, exists, userID, guestID, items, coupons); err != nil {
+			return err
+		}
+	}
+
+	_, err = r.collection.DeleteOne(ctx, bson.M{"_id": guest.ID})
+	return err
+}
+
+// saveMerged 寫入合併後的用戶購物車，並記錄已合併的訪客ID
+func (r *MongoCartRepository) saveMerged(ctx context.Context, user *mergedCart, exists bool, userID, guestID string, items []models.CartItem, coupons []string) error {
+	now := time.Now()
+
+	if !exists {
+		cart := mergedCart{
+			Cart: models.Cart{
+				ID:        uuid.New().String(),
+				UserID:    userID,
+				Items:     items,
+				Coupons:   coupons,
+				CreatedAt: now,
+				UpdatedAt: now,
+			},
+			MergedGuests: []string{guestID},
+		}
+		if _, err := r.collection.InsertOne(ctx, cart); err != nil {
+			// 同時有其他請求建立了用戶購物車
+			if mongo.IsDuplicateKeyError(err) {
+				return ErrCartChanged
+			}
+			return err
+		}
+		return nil
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID, "updated_at": user.UpdatedAt},
+		bson.M{
+			"$set":  bson.M{"items": items, "coupons": coupons, "updated_at": now},
+			"$push": bson.M{"merged_guests": bson.M{"$each": bson.A{guestID}, "$slice": -maxMergedGuests}},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCartChanged
+	}
+	return nil
+}
+
+// mergeCoupons 將訪客購物車的優惠碼接在用戶購物車的優惠碼之後，略過重複的優惠碼
+func mergeCoupons(userCoupons, guestCoupons []string) []string {
+	coupons := append([]string{}, userCoupons...)
+	for _, code := range guestCoupons {
+		if !contains(coupons, code) {
+			coupons = append(coupons, code)
+		}
+	}
+	return coupons
+}
+
+// contains 檢查清單中是否有指定的值
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -2,6 +2,7 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,40 +12,63 @@ import (
 
 	"github.com/arrontsai/ecommerce/pkg/models"
 	"github.com/arrontsai/ecommerce/pkg/database"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
 )
 
+// ErrCartChanged 結帳期間購物車內容已被修改或已結帳
+var ErrCartChanged = errors.New("購物車已被修改")
+
+// ErrItemNotFound 購物車中沒有指定的商品
+var ErrItemNotFound = errors.New("購物車中沒有此商品")
+
+// ErrCouponNotFound 購物車中沒有指定的優惠碼
+var ErrCouponNotFound = errors.New("購物車中沒有此優惠碼")
+
+// ErrCheckoutPending 購物車仍有尚未發布的結帳事件
+var ErrCheckoutPending = errors.New("購物車仍有尚未發布的結帳事件")
+
 // CartRepository 定義購物車儲存庫的介面
 type CartRepository interface {
-	AddToCart(userID, productID string, quantity int) error
-	GetCart(userID string) (*models.Cart, error)
+	AddToCart(owner Owner, productID string, quantity int) error
+	GetCart(owner Owner) (*models.Cart, error)
+	UpdateItem(owner Owner, productID string, quantity int) error
+	RemoveItem(owner Owner, productID string) error
+	AddCoupon(owner Owner, code string) error
+	RemoveCoupon(owner Owner, code string) error
+	MergeGuestCart(guestID, userID string, merge MergeFunc) error
 	ClearCart(userID string) error
+	DeleteCart(userID string) error
+	CheckoutCart(cart *models.Cart, event outbox.Message) error
 }
 
 // MongoCartRepository 實現基於MongoDB的購物車儲存庫
 type MongoCartRepository struct {
 	collection *mongo.Collection
+	// guestTTL 訪客購物車最後一次修改後的保存期限
+	guestTTL time.Duration
 }
 
 // NewMongoCartRepository 創建一個新的MongoDB購物車儲存庫
-func NewMongoCartRepository(client *database.MongoClient) *MongoCartRepository {
+func NewMongoCartRepository(client *database.MongoClient, guestTTL time.Duration) *MongoCartRepository {
 	collection := client.Collection("carts")
-	return &MongoCartRepository{collection: collection}
+	return &MongoCartRepository{collection: collection, guestTTL: guestTTL}
 }
 
 // AddToCart 添加商品到購物車
-func (r *MongoCartRepository) AddToCart(userID, productID string, quantity int) error {
+func (r *MongoCartRepository) AddToCart(owner Owner, productID string, quantity int) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	// 檢查購物車是否存在
 	var cart models.Cart
-	err := r.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&cart)
+	err := r.collection.FindOne(ctx, owner.filter()).Decode(&cart)
 	
 	if err == mongo.ErrNoDocuments {
 		// 創建新購物車
 		cart = models.Cart{
 			ID:        uuid.New().String(),
-			UserID:    userID,
+			UserID:    owner.UserID,
+			GuestID:   owner.GuestID,
 			Items:     []models.CartItem{},
 			CreatedAt: time.Now(),
 			UpdatedAt: time.Now(),
@@ -73,12 +97,13 @@ func (r *MongoCartRepository) AddToCart(userID, productID string, quantity int)
 	}
 
 	cart.UpdatedAt = time.Now()
+	cart.ExpiresAt = r.expiresAt(owner)
 
 	// 更新或插入購物車
 	opts := options.Update().SetUpsert(true)
 	_, err = r.collection.UpdateOne(
 		ctx,
-		bson.M{"user_id": userID},
+		owner.filter(),
 		bson.M{"$set": cart},
 		opts,
 	)
@@ -86,13 +111,13 @@ func (r *MongoCartRepository) AddToCart(userID, productID string, quantity int)
 	return err
 }
 
-// GetCart 獲取用戶的購物車
-func (r *MongoCartRepository) GetCart(userID string) (*models.Cart, error) {
+// GetCart 獲取用戶或訪客的購物車
+func (r *MongoCartRepository) GetCart(owner Owner) (*models.Cart, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
 	var cart models.Cart
-	err := r.collection.FindOne(ctx, bson.M{"user_id": userID}).Decode(&cart)
+	err := r.collection.FindOne(ctx, owner.filter()).Decode(&cart)
 	if err != nil {
 		return nil, err
 	}
@@ -100,11 +125,119 @@ func (r *MongoCartRepository) GetCart(userID string) (*models.Cart, error) {
 	return &cart, nil
 }
 
+// UpdateItem 修改購物車中商品的數量
+//
+// 購物車或商品不存在時回傳 ErrItemNotFound，購物車在讀取後被修改時回傳 ErrCartChanged。
+func (r *MongoCartRepository) UpdateItem(owner Owner, productID string, quantity int) error {
+	return r.changeCart(owner, ErrItemNotFound, func(cart *models.Cart) bool {
+		return cart.UpdateItem(productID, quantity)
+	})
+}
+
+// RemoveItem 從購物車移除商品
+//
+// 錯誤與 UpdateItem 相同。
+func (r *MongoCartRepository) RemoveItem(owner Owner, productID string) error {
+	return r.changeCart(owner, ErrItemNotFound, func(cart *models.Cart) bool {
+		return cart.RemoveItem(productID)
+	})
+}
+
+// AddCoupon 將優惠碼加入購物車，已加入時不做任何事
+//
+// 購物車不存在時回傳 ErrItemNotFound，購物車在讀取後被修改時回傳 ErrCartChanged。
+func (r *MongoCartRepository) AddCoupon(owner Owner, code string) error {
+	return r.changeCart(owner, ErrItemNotFound, func(cart *models.Cart) bool {
+		cart.AddCoupon(code)
+		return true
+	})
+}
+
+// RemoveCoupon 從購物車移除優惠碼，購物車或優惠碼不存在時回傳 ErrCouponNotFound
+func (r *MongoCartRepository) RemoveCoupon(owner Owner, code string) error {
+	return r.changeCart(owner, ErrCouponNotFound, func(cart *models.Cart) bool {
+		return cart.RemoveCoupon(code)
+	})
+}
+
+// changeCart 讀取購物車、以 change 修改商品或優惠碼後寫回
+//
+// 購物車不存在或 change 回傳 false 時回傳 notFound。以讀取時的 updated_at
+// 作為樂觀鎖，只更新商品與優惠碼欄位以保留尚未發布的外寄事件。
+func (r *MongoCartRepository) changeCart(owner Owner, notFound error, change func(cart *models.Cart) bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var cart models.Cart
+	err := r.collection.FindOne(ctx, owner.filter()).Decode(&cart)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return notFound
+		}
+		return err
+	}
+
+	readAt := cart.UpdatedAt
+	if !change(&cart) {
+		return notFound
+	}
+
+	filter := owner.filter()
+	filter["updated_at"] = readAt
+	set := bson.M{"items": cart.Items, "coupons": cart.Coupons, "updated_at": cart.UpdatedAt}
+	if expiresAt := r.expiresAt(owner); expiresAt != nil {
+		set["expires_at"] = expiresAt
+	}
+
+	result, err := r.collection.UpdateOne(ctx, filter, bson.M{"$set": set})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCartChanged
+	}
+	return nil
+}
+
 // ClearCart 清空用戶的購物車
+//
+// 只清空商品而不刪除文件，以保留尚未發布的外寄事件。
 func (r *MongoCartRepository) ClearCart(userID string) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	_, err := r.collection.DeleteOne(ctx, bson.M{"user_id": userID})
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"user_id": userID},
+		bson.M{"$set": bson.M{"items": []models.CartItem{}, "updated_at": time.Now()}},
+	)
 	return err
 }
+
+// DeleteCart 刪除用戶的購物車文件
+//
+// 仍有尚未發布的結帳事件時回傳 ErrCheckoutPending，避免訂單因事件遺失而無法建立，
+// 待事件轉發器發布後即可再次刪除。購物車不存在時視為已刪除。
+func (r *MongoCartRepository) DeleteCart(userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := r.collection.DeleteOne(ctx, bson.M{
+		"user_id":  userID,
+		"outbox.0": bson.M{"$exists": false},
+	})
+	if err != nil {
+		return err
+	}
+	if result.DeletedCount > 0 {
+		return nil
+	}
+
+	count, err := r.collection.CountDocuments(ctx, bson.M{"user_id": userID})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrCheckoutPending
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
+)
+
+// 外寄事件直接存放在購物車文件的 outbox 陣列中。
+// MongoDB 對單一文件的更新是原子的，因此清空購物車與寫入結帳事件
+// 不需要多文件交易 (也就不需要副本集) 即可同時成功或同時失敗。
+
+// CheckoutCart 清空購物車與優惠碼，並在同一次更新中寫入結帳事件與鎖定的計價幣別
+//
+// 以讀取時的 updated_at 作為樂觀鎖，若購物車在此之後被修改則回傳 ErrCartChanged。
+func (r *MongoCartRepository) CheckoutCart(cart *models.Cart, event outbox.Message) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if event.ID == "" {
+		event.ID = uuid.New().String()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	result, err := r.collection.UpdateOne(ctx,
+		bson.M{
+			"user_id":    cart.UserID,
+			"updated_at": cart.UpdatedAt,
+			"items.0":    bson.M{"$exists": true},
+		},
+		bson.M{
+			"$set":   bson.M{"items": []models.CartItem{}, "currency": cart.Currency, "updated_at": time.Now()},
+			"$unset": bson.M{"coupons": ""},
+			"$push":  bson.M{"outbox": event},
+		},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrCartChanged
+	}
+
+	return nil
+}
+
+// Pending 依寫入時間取出尚未發布的購物車事件
+func (r *MongoCartRepository) Pending(ctx context.Context, limit int) ([]outbox.Message, error) {
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"outbox.0": bson.M{"$exists": true}}}},
+		{{Key: "$unwind", Value: "$outbox"}},
+		{{Key: "$replaceRoot", Value: bson.M{"newRoot": "$outbox"}}},
+		{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}}}},
+		{{Key: "$limit", Value: limit}},
+	}
+
+	cursor, err := r.collection.Aggregate(ctx, pipeline, options.Aggregate())
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var messages []outbox.Message
+	if err := cursor.All(ctx, &messages); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
+
+// MarkSent 將已發布的事件從購物車文件中移除
+func (r *MongoCartRepository) MarkSent(ctx context.Context, id string) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"outbox._id": id},
+		bson.M{"$pull": bson.M{"outbox": bson.M{"_id": id}}},
+	)
+	return err
+}
+
+// MarkFailed 記錄一次發布失敗
+func (r *MongoCartRepository) MarkFailed(ctx context.Context, id string, cause error) error {
+	_, err := r.collection.UpdateOne(ctx,
+		bson.M{"outbox._id": id},
+		bson.M{
+			"$inc": bson.M{"outbox.$.attempts": 1},
+			"$set": bson.M{"outbox.$.last_error": cause.Error()},
+		},
+	)
+	return err
+}
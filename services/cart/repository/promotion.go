@@ -0,0 +1,246 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/arrontsai/ecommerce/pkg/database"
+	"github.com/arrontsai/ecommerce/pkg/models"
+)
+
+// ErrDuplicateCode 已有相同優惠碼的優惠活動
+var ErrDuplicateCode = errors.New("優惠碼已存在")
+
+// ErrUsageLimitReached 優惠碼已達總使用次數上限
+var ErrUsageLimitReached = errors.New("優惠碼已達使用次數上限")
+
+// ErrUserLimitReached 用戶已達優惠碼的每人使用次數上限
+var ErrUserLimitReached = errors.New("已達此優惠碼的每人使用次數上限")
+
+// PromotionRepository 定義優惠活動儲存庫的介面
+type PromotionRepository interface {
+	CreatePromotion(promotion *models.Promotion) error
+	UpdatePromotion(promotion *models.Promotion) error
+	FindPromotion(id string) (*models.Promotion, error)
+	FindPromotionsByCode(codes []string) ([]models.Promotion, error)
+	ListPromotions() ([]models.Promotion, error)
+	UserRedemptions(promotionID, userID string) (int, error)
+	Redeem(promotion *models.Promotion, userID string) error
+	Release(promotionID, userID string) error
+}
+
+// MongoPromotionRepository 實現基於MongoDB的優惠活動儲存庫
+//
+// 總使用次數記錄在優惠活動文件的 usage_count，每位用戶的使用次數記錄在
+// promotion_usages 集合，以 "<優惠活動ID>:<用戶ID>" 作為文件ID。
+type MongoPromotionRepository struct {
+	promotions *mongo.Collection
+	usages     *mongo.Collection
+}
+
+// promotionUsage 用戶使用優惠碼的次數
+type promotionUsage struct {
+	ID          string    `bson:"_id"`
+	PromotionID string    `bson:"promotion_id"`
+	UserID      string    `bson:"user_id"`
+	Count       int       `bson:"count"`
+	UpdatedAt   time.Time `bson:"updated_at"`
+}
+
+// NewMongoPromotionRepository 創建一個新的MongoDB優惠活動儲存庫
+func NewMongoPromotionRepository(client *database.MongoClient) *MongoPromotionRepository {
+	return &MongoPromotionRepository{
+		promotions: client.Collection("promotions"),
+		usages:     client.Collection("promotion_usages"),
+	}
+}
+
+// CreatePromotion 新增優惠活動，優惠碼重複時回傳 ErrDuplicateCode
+func (r *MongoPromotionRepository) CreatePromotion(promotion *models.Promotion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	_, err := r.promotions.InsertOne(ctx, promotion)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateCode
+	}
+	return err
+}
+
+// UpdatePromotion 更新優惠活動的設定，不改變已使用次數與建立時間
+func (r *MongoPromotionRepository) UpdatePromotion(promotion *models.Promotion) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	set := bson.M{
+		"code":           promotion.Code,
+		"description":    promotion.Description,
+		"type":           promotion.Type,
+		"percent":        promotion.Percent,
+		"amount":         promotion.Amount,
+		"buy_quantity":   promotion.BuyQuantity,
+		"get_quantity":   promotion.GetQuantity,
+		"category_ids":   promotion.CategoryIDs,
+		"min_subtotal":   promotion.MinSubtotal,
+		"starts_at":      promotion.StartsAt,
+		"ends_at":        promotion.EndsAt,
+		"usage_limit":    promotion.UsageLimit,
+		"per_user_limit": promotion.PerUserLimit,
+		"active":         promotion.Active,
+		"updated_at":     promotion.UpdatedAt,
+	}
+	_, err := r.promotions.UpdateOne(ctx, bson.M{"_id": promotion.ID}, bson.M{"$set": set})
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicateCode
+	}
+	return err
+}
+
+// FindPromotion 依ID查詢優惠活動，不存在時回傳 nil
+func (r *MongoPromotionRepository) FindPromotion(id string) (*models.Promotion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var promotion models.Promotion
+	err := r.promotions.FindOne(ctx, bson.M{"_id": id}).Decode(&promotion)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &promotion, nil
+}
+
+// FindPromotionsByCode 依優惠碼查詢優惠活動，不存在的優惠碼不會出現在結果中
+func (r *MongoPromotionRepository) FindPromotionsByCode(codes []string) ([]models.Promotion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.promotions.Find(ctx, bson.M{"code": bson.M{"$in": codes}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	promotions := []models.Promotion{}
+	if err := cursor.All(ctx, &promotions); err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+// ListPromotions 列出全部優惠活動，依建立時間由新到舊排序
+func (r *MongoPromotionRepository) ListPromotions() ([]models.Promotion, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	cursor, err := r.promotions.Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	promotions := []models.Promotion{}
+	if err := cursor.All(ctx, &promotions); err != nil {
+		return nil, err
+	}
+	return promotions, nil
+}
+
+// UserRedemptions 查詢用戶已使用優惠活動的次數
+func (r *MongoPromotionRepository) UserRedemptions(promotionID, userID string) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	var usage promotionUsage
+	err := r.usages.FindOne(ctx, bson.M{"_id": usageID(promotionID, userID)}).Decode(&usage)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return usage.Count, nil
+}
+
+// Redeem 記錄一次優惠碼使用
+//
+// 總使用次數與每人使用次數皆以條件更新檢查上限，並行結帳不會超用。
+// 超過上限時回傳 ErrUsageLimitReached 或 ErrUserLimitReached 且不記錄。
+func (r *MongoPromotionRepository) Redeem(promotion *models.Promotion, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	result, err := r.promotions.UpdateOne(ctx,
+		bson.M{
+			"_id": promotion.ID,
+			"$or": bson.A{
+				bson.M{"usage_limit": bson.M{"$lte": 0}},
+				bson.M{"$expr": bson.M{"$lt": bson.A{"$usage_count", "$usage_limit"}}},
+			},
+		},
+		bson.M{"$inc": bson.M{"usage_count": 1}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return ErrUsageLimitReached
+	}
+
+	// 已達上限時條件不符，upsert 會因文件ID重複而失敗
+	filter := bson.M{"_id": usageID(promotion.ID, userID)}
+	if promotion.PerUserLimit > 0 {
+		filter["count"] = bson.M{"$lt": promotion.PerUserLimit}
+	}
+	_, err = r.usages.UpdateOne(ctx, filter,
+		bson.M{
+			"$inc": bson.M{"count": 1},
+			"$set": bson.M{"promotion_id": promotion.ID, "user_id": userID, "updated_at": time.Now()},
+		},
+		options.Update().SetUpsert(true),
+	)
+	if err != nil {
+		r.releaseUsage(ctx, promotion.ID)
+		if mongo.IsDuplicateKeyError(err) {
+			return ErrUserLimitReached
+		}
+		return err
+	}
+	return nil
+}
+
+// Release 撤銷一次優惠碼使用，用於結帳在記錄使用後失敗時
+func (r *MongoPromotionRepository) Release(promotionID, userID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := r.releaseUsage(ctx, promotionID); err != nil {
+		return err
+	}
+	_, err := r.usages.UpdateOne(ctx,
+		bson.M{"_id": usageID(promotionID, userID), "count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"count": -1}, "$set": bson.M{"updated_at": time.Now()}},
+	)
+	return err
+}
+
+// releaseUsage 減少優惠活動的總使用次數
+func (r *MongoPromotionRepository) releaseUsage(ctx context.Context, promotionID string) error {
+	_, err := r.promotions.UpdateOne(ctx,
+		bson.M{"_id": promotionID, "usage_count": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"usage_count": -1}},
+	)
+	return err
+}
+
+// usageID 用戶使用次數文件的ID
+func usageID(promotionID, userID string) string {
+	return promotionID + ":" + userID
+}
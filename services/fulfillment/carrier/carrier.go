@@ -0,0 +1,93 @@
+package carrier
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+var (
+	// ErrNoRate 物流商不配送到該地址，或包裹超出可配送的重量
+	ErrNoRate = errors.New("沒有可用的運費")
+	// ErrUnknownService 物流商沒有指定的運送服務
+	ErrUnknownService = errors.New("不支援的運送服務")
+)
+
+// Address 收件地址中計算運費需要的部分
+type Address struct {
+	Country    string
+	State      string
+	PostalCode string
+}
+
+// Item 要寄送的商品，Weight 為單件重量 (公克)，Length、Width、Height 為單件包裝尺寸 (公釐)
+type Item struct {
+	ProductID string
+	Quantity  int
+	Weight    int
+	Length    int
+	Width     int
+	Height    int
+}
+
+// RateRequest 查詢運費的請求
+type RateRequest struct {
+	Destination Address
+	Items       []Item
+}
+
+// Rate 一種運送服務的運費報價，金額為物流商計價的幣別
+type Rate struct {
+	Carrier string      `json:"carrier"`
+	Service string      `json:"service"`
+	Name    string      `json:"name"`
+	Amount  money.Money `json:"amount"`
+	MinDays int         `json:"min_days"`
+	MaxDays int         `json:"max_days"`
+}
+
+// ShipRequest 建立出貨的請求，ShipmentID 讓物流商辨識重複的請求
+type ShipRequest struct {
+	ShipmentID  string
+	Service     string
+	Destination Address
+	Items       []Item
+}
+
+// Label 物流商建立的託運單
+type Label struct {
+	TrackingNumber string
+	// Amount 物流商向商家收取的運費
+	Amount money.Money
+}
+
+// Carrier 物流商介面
+//
+// 包裹超出物流商可配送的範圍時以 ErrNoRate 回報。
+type Carrier interface {
+	// Name 物流商名稱，會記錄在出貨資料上
+	Name() string
+	// Rates 依商品重量、尺寸與收件地址報價，依金額由低到高排序
+	Rates(ctx context.Context, req RateRequest) ([]Rate, error)
+	// Ship 以指定的運送服務建立託運單，取得追蹤編號
+	Ship(ctx context.Context, req ShipRequest) (*Label, error)
+}
+
+// Config 物流商設定
+type Config struct {
+	// Driver 物流商，目前只有 table
+	Driver string
+	// RatesFile table 物流商的運費表，空白時使用內建運費表
+	RatesFile string
+}
+
+// New 依設定建立物流商
+func New(cfg Config) (Carrier, error) {
+	switch cfg.Driver {
+	case "", TableCarrierName:
+		return LoadTableCarrier(cfg.RatesFile)
+	}
+	return nil, fmt.Errorf("不支援的物流商: %s", cfg.Driver)
+}
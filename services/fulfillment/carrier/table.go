@@ -0,0 +1,220 @@
+package carrier
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// TableCarrierName 以運費表計價的物流商名稱
+const TableCarrierName = "table"
+
+//go:embed rates.yaml
+var defaultRates []byte
+
+// RateTable 運費表檔案格式，見 rates.yaml
+type RateTable struct {
+	Currency          string         `yaml:"currency"`
+	VolumetricDivisor int64          `yaml:"volumetric_divisor"`
+	TrackingPrefix    string         `yaml:"tracking_prefix"`
+	Services          []TableService `yaml:"services"`
+}
+
+// TableService 一種運送服務的各區域運費
+type TableService struct {
+	Code  string      `yaml:"code"`
+	Name  string      `yaml:"name"`
+	Zones []TableZone `yaml:"zones"`
+}
+
+// TableZone 運送區域，Countries 為 "*" 時符合所有國家
+type TableZone struct {
+	Name      string         `yaml:"name"`
+	Countries []string       `yaml:"countries"`
+	MinDays   int            `yaml:"min_days"`
+	MaxDays   int            `yaml:"max_days"`
+	Rates     []TableBracket `yaml:"rates"`
+}
+
+// TableBracket 計費重量不超過 MaxWeight 公克時的運費，Amount 以元表示
+type TableBracket struct {
+	MaxWeight int64  `yaml:"max_weight"`
+	Amount    string `yaml:"amount"`
+}
+
+// TableCarrier 以運費表計價的物流商，適用於自行配送或簽約固定運費的物流
+//
+// 追蹤編號由出貨 ID 產生，同一出貨重複建立託運單會得到相同的追蹤編號。
+type TableCarrier struct {
+	table  RateTable
+	prices map[string][]money.Money
+}
+
+// NewTableCarrier 以運費表建立物流商
+func NewTableCarrier(table RateTable) (*TableCarrier, error) {
+	if !money.IsKnownCurrency(table.Currency) {
+		return nil, fmt.Errorf("運費表的幣別無效: %q", table.Currency)
+	}
+	if table.VolumetricDivisor <= 0 {
+		return nil, errors.New("運費表的 volumetric_divisor 需大於 0")
+	}
+	if len(table.Services) == 0 {
+		return nil, errors.New("運費表沒有運送服務")
+	}
+
+	c := &TableCarrier{table: table, prices: map[string][]money.Money{}}
+	for _, service := range table.Services {
+		if service.Code == "" {
+			return nil, errors.New("運送服務缺少 code")
+		}
+		for _, zone := range service.Zones {
+			if _, ok := c.prices[bracketKey(service.Code, zone.Name)]; ok {
+				return nil, fmt.Errorf("%s 的區域 %q 重複", service.Code, zone.Name)
+			}
+			var last int64
+			for _, bracket := range zone.Rates {
+				if bracket.MaxWeight <= last {
+					return nil, fmt.Errorf("%s/%s 的 max_weight 需由小到大排列", service.Code, zone.Name)
+				}
+				last = bracket.MaxWeight
+
+				amount, err := money.Parse(bracket.Amount, table.Currency)
+				if err != nil {
+					return nil, fmt.Errorf("%s/%s 的運費無效: %w", service.Code, zone.Name, err)
+				}
+				key := bracketKey(service.Code, zone.Name)
+				c.prices[key] = append(c.prices[key], amount)
+			}
+		}
+	}
+	return c, nil
+}
+
+// LoadTableCarrier 讀取 YAML 運費表建立物流商，path 空白時使用內建運費表
+//
+// 運費表只在啟動時讀取，修改後需重新啟動服務。
+func LoadTableCarrier(path string) (*TableCarrier, error) {
+	data := defaultRates
+	if path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf("讀取運費表失敗: %w", err)
+		}
+	}
+
+	var table RateTable
+	if err := yaml.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("解析運費表失敗: %w", err)
+	}
+	return NewTableCarrier(table)
+}
+
+// Name 實作 Carrier
+func (c *TableCarrier) Name() string {
+	return TableCarrierName
+}
+
+// Rates 實作 Carrier
+func (c *TableCarrier) Rates(ctx context.Context, req RateRequest) ([]Rate, error) {
+	weight := c.chargeableWeight(req.Items)
+
+	var rates []Rate
+	for _, service := range c.table.Services {
+		if rate, ok := c.rate(service, req.Destination, weight); ok {
+			rates = append(rates, rate)
+		}
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: %s %d 公克", ErrNoRate, req.Destination.Country, weight)
+	}
+
+	sort.SliceStable(rates, func(i, j int) bool {
+		return rates[i].Amount.Amount < rates[j].Amount.Amount
+	})
+	return rates, nil
+}
+
+// Ship 實作 Carrier
+func (c *TableCarrier) Ship(ctx context.Context, req ShipRequest) (*Label, error) {
+	for _, service := range c.table.Services {
+		if service.Code != req.Service {
+			continue
+		}
+		weight := c.chargeableWeight(req.Items)
+		rate, ok := c.rate(service, req.Destination, weight)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s %s %d 公克", ErrNoRate, req.Service, req.Destination.Country, weight)
+		}
+		return &Label{TrackingNumber: c.trackingNumber(req.ShipmentID), Amount: rate.Amount}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnknownService, req.Service)
+}
+
+// chargeableWeight 計費重量：實際重量與材積重量較大者 (公克)
+func (c *TableCarrier) chargeableWeight(items []Item) int64 {
+	var weight, volume int64
+	for _, item := range items {
+		quantity := int64(item.Quantity)
+		weight += int64(item.Weight) * quantity
+		volume += int64(item.Length) * int64(item.Width) * int64(item.Height) * quantity
+	}
+	// 材積重量無條件進位到公克
+	volumetric := (volume + c.table.VolumetricDivisor - 1) / c.table.VolumetricDivisor
+	return max(weight, volumetric)
+}
+
+// rate 依收件國家與計費重量查詢運送服務的運費
+func (c *TableCarrier) rate(service TableService, destination Address, weight int64) (Rate, bool) {
+	zone, ok := findZone(service.Zones, destination.Country)
+	if !ok {
+		return Rate{}, false
+	}
+
+	prices := c.prices[bracketKey(service.Code, zone.Name)]
+	for i, bracket := range zone.Rates {
+		if weight <= bracket.MaxWeight {
+			return Rate{
+				Carrier: TableCarrierName,
+				Service: service.Code,
+				Name:    service.Name,
+				Amount:  prices[i],
+				MinDays: zone.MinDays,
+				MaxDays: zone.MaxDays,
+			}, true
+		}
+	}
+	return Rate{}, false
+}
+
+// trackingNumber 以出貨 ID 產生追蹤編號：前綴加上去除連字號的大寫出貨 ID
+//
+// 出貨 ID 不會重複，追蹤編號因此也不會相撞，同一出貨重複請求時得到相同的編號。
+func (c *TableCarrier) trackingNumber(shipmentID string) string {
+	return c.table.TrackingPrefix + strings.ToUpper(strings.ReplaceAll(shipmentID, "-", ""))
+}
+
+// findZone 回傳第一個包含該國家的區域
+func findZone(zones []TableZone, country string) (TableZone, bool) {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	for _, zone := range zones {
+		for _, c := range zone.Countries {
+			if c == "*" || strings.EqualFold(c, country) {
+				return zone, true
+			}
+		}
+	}
+	return TableZone{}, false
+}
+
+// bracketKey 運費表中一個區域的索引鍵
+func bracketKey(service, zone string) string {
+	return service + "/" + zone
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/config"
+	"github.com/arrontsai/ecommerce/pkg/database"
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/exchange"
+	"github.com/arrontsai/ecommerce/pkg/jwks"
+	"github.com/arrontsai/ecommerce/pkg/logger"
+	"github.com/arrontsai/ecommerce/pkg/messaging"
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
+	"github.com/arrontsai/ecommerce/services/fulfillment/carrier"
+	"github.com/arrontsai/ecommerce/services/fulfillment/handler"
+	"github.com/arrontsai/ecommerce/services/fulfillment/model"
+	"github.com/arrontsai/ecommerce/services/fulfillment/proto/pb"
+	"github.com/arrontsai/ecommerce/services/fulfillment/repository"
+	"github.com/arrontsai/ecommerce/services/fulfillment/service"
+	orderpb "github.com/arrontsai/ecommerce/services/order/proto/pb"
+	productpb "github.com/arrontsai/ecommerce/services/product/proto/pb"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	// 初始化配置
+	cfg, err := config.LoadConfig("fulfillment-service")
+	if err != nil {
+		log.Fatal("無法載入配置:", err)
+	}
+
+	// 初始化日誌記錄器
+	appLogger, err := logger.NewLogger("fulfillment-service", cfg.LogLevel)
+	if err != nil {
+		log.Fatal("無法初始化日誌記錄器:", err)
+	}
+	defer appLogger.Sync()
+
+	// 構建 PostgreSQL 連接字符串
+	pgConnStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+
+	// 初始化PostgreSQL
+	pgClient, err := sqlx.Connect("postgres", pgConnStr)
+	if err != nil {
+		appLogger.Fatal("無法連接PostgreSQL:", zap.Error(err))
+	}
+	defer pgClient.Close()
+
+	// 初始化MongoDB連接，用於檢查已撤銷的令牌
+	mongoClient, err := database.NewMongoClient(cfg.MongoURI, cfg.MongoDB)
+	if err != nil {
+		appLogger.Fatal("無法連接MongoDB:", zap.Error(err))
+	}
+	defer mongoClient.Close()
+
+	// 初始化物流商 (SHIPPING_CARRIER=table 以運費表計價，SHIPPING_RATES_FILE 未設定時使用內建運費表)
+	shippingCarrier, err := carrier.New(carrier.Config{Driver: cfg.ShippingCarrier, RatesFile: cfg.ShippingRatesFile})
+	if err != nil {
+		appLogger.Fatal("無法初始化物流商:", zap.Error(err))
+	}
+
+	// 以匯率將運費換算成用戶選擇的幣別 (EXCHANGE_RATES=file、http 或 none)
+	rates, err := exchange.New(exchange.Config{
+		Driver:   cfg.ExchangeRates,
+		File:     cfg.ExchangeRatesFile,
+		URL:      cfg.ExchangeRatesURL,
+		TTL:      time.Duration(cfg.ExchangeRatesTTL) * time.Second,
+		MaxStale: time.Duration(cfg.ExchangeRatesMaxStale) * time.Second,
+	})
+	if err != nil {
+		appLogger.Fatal("無法初始化匯率:", zap.Error(err))
+	}
+
+	// 連接產品服務，查詢商品重量與尺寸
+	productConn, err := grpc.Dial(cfg.ProductGrpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		appLogger.Fatal("無法連接產品服務:", zap.Error(err))
+	}
+	defer productConn.Close()
+
+	// 連接訂單服務，查詢訂單項目與收件資訊
+	orderConn, err := grpc.Dial(cfg.OrderGrpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		appLogger.Fatal("無法連接訂單服務:", zap.Error(err))
+	}
+	defer orderConn.Close()
+
+	// 初始化消息代理 (KAFKA_BROKERS=memory:// 時使用記憶體代理)
+	broker, err := messaging.NewBroker(cfg.KafkaBrokers, appLogger.Logger)
+	if err != nil {
+		appLogger.Fatal("無法初始化消息代理:", zap.Error(err))
+	}
+	defer broker.Close()
+
+	// 創建出貨服務
+	shipmentRepo := repository.NewShipmentRepo(pgClient)
+	fulfillmentService := service.NewFulfillmentService(
+		shipmentRepo,
+		shippingCarrier,
+		service.NewCatalogClient(productpb.NewCatalogServiceClient(productConn)),
+		service.NewOrderClient(orderpb.NewOrderServiceClient(orderConn)),
+		rates,
+	)
+
+	// 啟動外寄事件轉發器，將出貨事件發布到Kafka
+	relay := outbox.NewRelay(shipmentRepo.Outbox(), broker, appLogger.Logger)
+	go relay.Run(context.Background())
+
+	// 訂閱用戶資料匯出與刪除請求
+	go subscribeToPrivacyRequests(broker, shipmentRepo)
+
+	// 初始化JWT驗證，設定 JWT_JWKS_URL 時以認證服務公布的公鑰驗證
+	authOptions := []middleware.AuthOption{middleware.WithDenylist(middleware.NewMongoTokenDenylist(mongoClient.DB))}
+	if cfg.JWKSURL != "" {
+		authOptions = append(authOptions, middleware.WithKeyProvider(jwks.NewRemoteKeySet(cfg.JWKSURL)))
+	}
+
+	// 初始化HTTP路由
+	router := gin.Default()
+	handler.NewFulfillmentHandler(fulfillmentService).RegisterRoutes(router, middleware.JWTAuthMiddleware(cfg.JWTSecret, authOptions...))
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.ServerPort),
+		Handler: router,
+	}
+	go func() {
+		appLogger.Info("出貨服務HTTP啟動", zap.Int("port", cfg.ServerPort))
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			appLogger.Fatal("無法啟動HTTP伺服器:", zap.Error(err))
+		}
+	}()
+
+	// 啟動gRPC伺服器
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GrpcPort))
+	if err != nil {
+		appLogger.Fatal("無法監聽端口:", zap.Error(err))
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterFulfillmentServiceServer(grpcServer, handler.NewFulfillmentGRPCServer(fulfillmentService))
+	go func() {
+		appLogger.Info("出貨服務gRPC啟動", zap.Int("port", cfg.GrpcPort))
+		if err := grpcServer.Serve(lis); err != nil {
+			appLogger.Fatal("無法啟動gRPC伺服器:", zap.Error(err))
+		}
+	}()
+
+	// 等待中斷信號以優雅地關閉伺服器
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	appLogger.Info("正在關閉出貨服務...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	grpcServer.GracefulStop()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		appLogger.Fatal("HTTP伺服器強制關閉:", zap.Error(err))
+	}
+}
+
+// subscribeToPrivacyRequests 處理用戶資料匯出與刪除請求，並將結果回報認證服務
+func subscribeToPrivacyRequests(broker messaging.Broker, repo *repository.ShipmentRepository) {
+	mux := events.NewMux(nil)
+
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.DataSubjectRequested) error {
+		result := events.DataSubjectCompleted{
+			RequestID: event.RequestID,
+			UserID:    event.UserID,
+			Kind:      event.Kind,
+			Service:   model.EventProducer,
+		}
+
+		switch event.Kind {
+		case events.DataSubjectExport:
+			shipments, err := repo.ExportUserShipments(ctx, event.UserID)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(shipments)
+			if err != nil {
+				return err
+			}
+			result.Data = data
+		case events.DataSubjectErasure:
+			// 未送達的出貨仍需收件資訊，回報失敗由用戶稍後重新申請
+			updated, err := repo.PseudonymizeUser(ctx, event.UserID)
+			if err != nil {
+				if !errors.Is(err, repository.ErrOpenShipments) {
+					return err
+				}
+				result.Error = err.Error()
+			} else {
+				log.Printf("已假名化用戶 %s 的 %d 筆出貨", event.UserID, updated)
+			}
+		default:
+			result.Error = "未知的請求種類: " + event.Kind
+		}
+
+		result.CompletedAt = time.Now()
+		return messaging.PublishEvent(ctx, broker, model.EventProducer, result)
+	})
+
+	// 啟動消費
+	ctx := context.Background()
+	err := messaging.SubscribeEvents(ctx, broker, events.TopicPrivacyRequests, "fulfillment-service", mux)
+	if err != nil {
+		log.Fatalf("無法消費消息: %v", err)
+	}
+}
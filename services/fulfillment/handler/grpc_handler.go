@@ -0,0 +1,208 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/pkg/money/moneypb"
+	"github.com/arrontsai/ecommerce/services/fulfillment/carrier"
+	"github.com/arrontsai/ecommerce/services/fulfillment/model"
+	"github.com/arrontsai/ecommerce/services/fulfillment/proto/pb"
+	"github.com/arrontsai/ecommerce/services/fulfillment/repository"
+	"github.com/arrontsai/ecommerce/services/fulfillment/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FulfillmentGRPCServer 實現出貨服務的gRPC接口
+type FulfillmentGRPCServer struct {
+	pb.UnimplementedFulfillmentServiceServer
+	fulfillmentService *service.FulfillmentService
+}
+
+// NewFulfillmentGRPCServer 創建出貨gRPC服務
+func NewFulfillmentGRPCServer(fulfillmentService *service.FulfillmentService) *FulfillmentGRPCServer {
+	return &FulfillmentGRPCServer{fulfillmentService: fulfillmentService}
+}
+
+// QuoteRates 實現運費報價的gRPC方法
+func (s *FulfillmentGRPCServer) QuoteRates(ctx context.Context, req *pb.QuoteRatesRequest) (*pb.QuoteRatesResponse, error) {
+	if req.Currency != "" && !money.IsKnownCurrency(req.Currency) {
+		return nil, status.Errorf(codes.InvalidArgument, "不支援的幣別: %s", req.Currency)
+	}
+
+	rates, err := s.fulfillmentService.Quote(ctx, service.QuoteInput{
+		Items:        fromPBItems(req.Items),
+		ShippingInfo: fromPBShippingInfo(req.ShippingInfo),
+		Currency:     req.Currency,
+	})
+	if err != nil {
+		return nil, shipmentStatusError(err)
+	}
+
+	resp := &pb.QuoteRatesResponse{Rates: make([]*pb.ShippingRate, 0, len(rates))}
+	for _, rate := range rates {
+		resp.Rates = append(resp.Rates, &pb.ShippingRate{
+			Carrier: rate.Carrier,
+			Service: rate.Service,
+			Name:    rate.Name,
+			Amount:  moneypb.FromMoney(rate.Amount),
+			MinDays: int32(rate.MinDays),
+			MaxDays: int32(rate.MaxDays),
+		})
+	}
+	return resp, nil
+}
+
+// CreateShipment 實現建立出貨的gRPC方法
+func (s *FulfillmentGRPCServer) CreateShipment(ctx context.Context, req *pb.CreateShipmentRequest) (*pb.ShipmentResponse, error) {
+	if req.OrderId == "" {
+		return nil, status.Error(codes.InvalidArgument, "訂單 ID 不能為空")
+	}
+
+	shipment, err := s.fulfillmentService.CreateShipment(ctx, service.CreateShipmentInput{
+		OrderID: req.OrderId,
+		Service: req.Service,
+		Items:   fromPBItems(req.Items),
+	})
+	if err != nil {
+		return nil, shipmentStatusError(err)
+	}
+	return toShipmentResponse(shipment), nil
+}
+
+// GetShipment 實現獲取出貨的gRPC方法
+func (s *FulfillmentGRPCServer) GetShipment(ctx context.Context, req *pb.GetShipmentRequest) (*pb.ShipmentResponse, error) {
+	shipment, err := s.fulfillmentService.GetShipment(ctx, req.ShipmentId)
+	if err != nil {
+		return nil, shipmentStatusError(err)
+	}
+	return toShipmentResponse(shipment), nil
+}
+
+// ListShipments 實現獲取訂單出貨的gRPC方法
+func (s *FulfillmentGRPCServer) ListShipments(ctx context.Context, req *pb.ListShipmentsRequest) (*pb.ListShipmentsResponse, error) {
+	shipments, err := s.fulfillmentService.ListOrderShipments(ctx, req.OrderId)
+	if err != nil {
+		return nil, shipmentStatusError(err)
+	}
+
+	resp := &pb.ListShipmentsResponse{Shipments: make([]*pb.ShipmentResponse, 0, len(shipments))}
+	for i := range shipments {
+		resp.Shipments = append(resp.Shipments, toShipmentResponse(&shipments[i]))
+	}
+	return resp, nil
+}
+
+// AddTrackingEvent 實現物流追蹤紀錄的gRPC方法
+func (s *FulfillmentGRPCServer) AddTrackingEvent(ctx context.Context, req *pb.AddTrackingEventRequest) (*pb.ShipmentResponse, error) {
+	var occurredAt time.Time
+	if req.OccurredAt != nil {
+		occurredAt = req.OccurredAt.AsTime()
+	}
+
+	shipment, err := s.fulfillmentService.UpdateTracking(ctx, req.ShipmentId, model.TrackingEvent{
+		Status:      model.ShipmentStatus(req.Status),
+		Description: req.Description,
+		Location:    req.Location,
+		OccurredAt:  occurredAt,
+	})
+	if err != nil {
+		return nil, shipmentStatusError(err)
+	}
+	return toShipmentResponse(shipment), nil
+}
+
+// shipmentStatusError 將出貨服務錯誤轉換為gRPC狀態錯誤
+func shipmentStatusError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrShipmentNotFound),
+		errors.Is(err, service.ErrOrderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInvalidItems),
+		errors.Is(err, service.ErrInvalidStatus),
+		errors.Is(err, service.ErrNoShippingAddress),
+		errors.Is(err, carrier.ErrUnknownService):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, service.ErrOrderNotShippable),
+		errors.Is(err, service.ErrNothingToShip),
+		errors.Is(err, service.ErrCurrencyUnavailable),
+		errors.Is(err, repository.ErrExceedsOrder),
+		errors.Is(err, model.ErrIllegalTransition),
+		errors.Is(err, carrier.ErrNoRate):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Errorf(codes.Internal, "出貨操作失敗: %v", err)
+}
+
+// fromPBItems 將gRPC出貨項目轉換為模型
+func fromPBItems(items []*pb.ShipmentItem) []model.ShipmentItem {
+	result := make([]model.ShipmentItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, model.ShipmentItem{ProductID: item.ProductId, Quantity: int(item.Quantity)})
+	}
+	return result
+}
+
+// fromPBShippingInfo 將gRPC收件資訊轉換為模型
+func fromPBShippingInfo(info *pb.ShippingInfo) model.ShippingInfo {
+	if info == nil {
+		return model.ShippingInfo{}
+	}
+	return model.ShippingInfo{
+		FullName:     info.FullName,
+		AddressLine1: info.AddressLine1,
+		AddressLine2: info.AddressLine2,
+		City:         info.City,
+		State:        info.State,
+		PostalCode:   info.PostalCode,
+		Country:      info.Country,
+		PhoneNumber:  info.PhoneNumber,
+	}
+}
+
+// toShipmentResponse 將出貨模型轉換為gRPC響應格式
+func toShipmentResponse(shipment *model.Shipment) *pb.ShipmentResponse {
+	resp := &pb.ShipmentResponse{
+		ShipmentId:     shipment.ID,
+		OrderId:        shipment.OrderID,
+		UserId:         shipment.UserID,
+		Carrier:        shipment.Carrier,
+		Service:        shipment.Service,
+		TrackingNumber: shipment.TrackingNumber,
+		Status:         string(shipment.Status),
+		Items:          make([]*pb.ShipmentItem, 0, len(shipment.Items)),
+		ShippingInfo: &pb.ShippingInfo{
+			FullName:     shipment.ShippingInfo.FullName,
+			AddressLine1: shipment.ShippingInfo.AddressLine1,
+			AddressLine2: shipment.ShippingInfo.AddressLine2,
+			City:         shipment.ShippingInfo.City,
+			State:        shipment.ShippingInfo.State,
+			PostalCode:   shipment.ShippingInfo.PostalCode,
+			Country:      shipment.ShippingInfo.Country,
+			PhoneNumber:  shipment.ShippingInfo.PhoneNumber,
+		},
+		Cost:      moneypb.FromMoney(shipment.Cost),
+		Tracking:  make([]*pb.TrackingEvent, 0, len(shipment.Tracking)),
+		CreatedAt: timestamppb.New(shipment.CreatedAt),
+		UpdatedAt: timestamppb.New(shipment.UpdatedAt),
+	}
+	for _, item := range shipment.Items {
+		resp.Items = append(resp.Items, &pb.ShipmentItem{ProductId: item.ProductID, Quantity: int32(item.Quantity)})
+	}
+	for _, event := range shipment.Tracking {
+		resp.Tracking = append(resp.Tracking, &pb.TrackingEvent{
+			Status:      string(event.Status),
+			Description: event.Description,
+			Location:    event.Location,
+			OccurredAt:  timestamppb.New(event.OccurredAt),
+		})
+	}
+	if shipment.DeliveredAt != nil {
+		resp.DeliveredAt = timestamppb.New(*shipment.DeliveredAt)
+	}
+	return resp
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/services/fulfillment/carrier"
+	"github.com/arrontsai/ecommerce/services/fulfillment/model"
+	"github.com/arrontsai/ecommerce/services/fulfillment/repository"
+	"github.com/arrontsai/ecommerce/services/fulfillment/service"
+	"github.com/gin-gonic/gin"
+)
+
+// FulfillmentHandler 處理運費報價與出貨的HTTP請求
+type FulfillmentHandler struct {
+	fulfillmentService *service.FulfillmentService
+}
+
+// NewFulfillmentHandler 創建出貨HTTP處理器
+func NewFulfillmentHandler(fulfillmentService *service.FulfillmentService) *FulfillmentHandler {
+	return &FulfillmentHandler{fulfillmentService: fulfillmentService}
+}
+
+// ShipmentItemRequest 出貨或報價的商品與數量
+type ShipmentItemRequest struct {
+	ProductID string `json:"product_id" binding:"required"`
+	Quantity  int    `json:"quantity" binding:"required,gt=0"`
+}
+
+// QuoteRequest 查詢運費請求，通常為購物車的商品與結帳時填寫的收件資訊
+type QuoteRequest struct {
+	Items        []ShipmentItemRequest `json:"items" binding:"required,min=1,dive"`
+	ShippingInfo model.ShippingInfo    `json:"shipping_info"`
+}
+
+// CreateShipmentRequest 建立出貨請求，items 省略時出貨訂單所有未出貨的項目
+type CreateShipmentRequest struct {
+	OrderID string                `json:"order_id" binding:"required"`
+	Service string                `json:"service"`
+	Items   []ShipmentItemRequest `json:"items" binding:"dive"`
+}
+
+// TrackingRequest 物流追蹤紀錄請求，occurred_at 省略時使用目前時間
+type TrackingRequest struct {
+	Status      string    `json:"status" binding:"required"`
+	Description string    `json:"description"`
+	Location    string    `json:"location"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// QuoteRates 處理運費報價，可透過 currency 參數或 X-Currency 標頭選擇報價幣別
+func (h *FulfillmentHandler) QuoteRates(c *gin.Context) {
+	var req QuoteRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	rates, err := h.fulfillmentService.Quote(c.Request.Context(), service.QuoteInput{
+		Items:        toShipmentItems(req.Items),
+		ShippingInfo: req.ShippingInfo,
+		Currency:     middleware.SelectedCurrency(c),
+	})
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": "查詢運費失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rates": rates})
+}
+
+// CreateShipment 處理建立出貨，一筆訂單可以分多次出貨
+func (h *FulfillmentHandler) CreateShipment(c *gin.Context) {
+	var req CreateShipmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	shipment, err := h.fulfillmentService.CreateShipment(c.Request.Context(), service.CreateShipmentInput{
+		OrderID: req.OrderID,
+		Service: req.Service,
+		Items:   toShipmentItems(req.Items),
+	})
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": "建立出貨失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "出貨建立成功", "shipment": shipment})
+}
+
+// GetShipment 處理獲取出貨
+func (h *FulfillmentHandler) GetShipment(c *gin.Context) {
+	shipment, err := h.fulfillmentService.GetShipment(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": "獲取出貨失敗: " + err.Error()})
+		return
+	}
+	if !canView(c, shipment) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "獲取出貨失敗: " + service.ErrShipmentNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shipment": shipment})
+}
+
+// ListOrderShipments 處理獲取訂單的所有出貨
+func (h *FulfillmentHandler) ListOrderShipments(c *gin.Context) {
+	shipments, err := h.fulfillmentService.ListOrderShipments(c.Request.Context(), c.Param("order_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取出貨失敗: " + err.Error()})
+		return
+	}
+
+	visible := make([]model.Shipment, 0, len(shipments))
+	for i := range shipments {
+		if canView(c, &shipments[i]) {
+			visible = append(visible, shipments[i])
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"shipments": visible})
+}
+
+// AddTracking 處理物流追蹤紀錄，狀態為 IN_TRANSIT 或 DELIVERED
+func (h *FulfillmentHandler) AddTracking(c *gin.Context) {
+	var req TrackingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	shipment, err := h.fulfillmentService.UpdateTracking(c.Request.Context(), c.Param("id"), model.TrackingEvent{
+		Status:      model.ShipmentStatus(req.Status),
+		Description: req.Description,
+		Location:    req.Location,
+		OccurredAt:  req.OccurredAt,
+	})
+	if err != nil {
+		c.JSON(shipmentErrorStatus(err), gin.H{"error": "更新物流追蹤失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "物流追蹤已更新", "shipment": shipment})
+}
+
+// RegisterRoutes 註冊運費報價與出貨路由
+func (h *FulfillmentHandler) RegisterRoutes(router *gin.Engine, authMiddleware gin.HandlerFunc) {
+	// 運費報價不需要登入，訪客結帳前也可以查詢
+	router.POST("/api/shipping/quotes", middleware.SelectCurrency(), h.QuoteRates)
+
+	shipments := router.Group("/api/shipments", authMiddleware)
+	{
+		shipments.GET("/:id", h.GetShipment)
+		shipments.GET("/order/:order_id", h.ListOrderShipments)
+	}
+
+	warehouse := router.Group("/api/shipments", authMiddleware, middleware.RequirePermissions(middleware.PermFulfillmentWrite))
+	{
+		warehouse.POST("", h.CreateShipment)
+		warehouse.POST("/:id/tracking", h.AddTracking)
+	}
+}
+
+// canView 只有訂單的用戶本人或具有 orders:read:any 權限的角色可以查看出貨
+func canView(c *gin.Context, shipment *model.Shipment) bool {
+	return shipment.UserID == c.GetString("user_id") || middleware.HasPermission(c, middleware.PermOrdersReadAny)
+}
+
+// toShipmentItems 將請求的商品轉換為出貨項目
+func toShipmentItems(items []ShipmentItemRequest) []model.ShipmentItem {
+	result := make([]model.ShipmentItem, 0, len(items))
+	for _, item := range items {
+		result = append(result, model.ShipmentItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	return result
+}
+
+// shipmentErrorStatus 將出貨服務錯誤對應到HTTP狀態碼
+func shipmentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrShipmentNotFound),
+		errors.Is(err, service.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidItems),
+		errors.Is(err, service.ErrInvalidStatus),
+		errors.Is(err, service.ErrNoShippingAddress),
+		errors.Is(err, carrier.ErrUnknownService):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrOrderNotShippable),
+		errors.Is(err, service.ErrNothingToShip),
+		errors.Is(err, repository.ErrExceedsOrder),
+		errors.Is(err, model.ErrIllegalTransition):
+		return http.StatusConflict
+	case errors.Is(err, carrier.ErrNoRate),
+		errors.Is(err, service.ErrCurrencyUnavailable):
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusInternalServerError
+}
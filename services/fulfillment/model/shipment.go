@@ -0,0 +1,145 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// ShipmentStatus 出貨狀態枚舉
+type ShipmentStatus string
+
+const (
+	StatusCreated   ShipmentStatus = "CREATED"    // 已建立託運單，等待物流商取件
+	StatusInTransit ShipmentStatus = "IN_TRANSIT" // 物流商已取件，配送中
+	StatusDelivered ShipmentStatus = "DELIVERED"  // 已送達
+)
+
+// ErrIllegalTransition 出貨狀態轉換不在允許的轉換圖中
+var ErrIllegalTransition = errors.New("不允許的出貨狀態轉換")
+
+// statusTransitions 出貨狀態轉換圖，配送中可以有多筆追蹤紀錄，DELIVERED 為終止狀態
+var statusTransitions = map[ShipmentStatus][]ShipmentStatus{
+	StatusCreated:   {StatusInTransit, StatusDelivered},
+	StatusInTransit: {StatusInTransit, StatusDelivered},
+	StatusDelivered: {},
+}
+
+// IsValid 檢查是否為已定義的出貨狀態
+func (s ShipmentStatus) IsValid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo 檢查是否允許從目前狀態轉換到指定狀態
+func (s ShipmentStatus) CanTransitionTo(next ShipmentStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition 驗證狀態轉換，不允許時回傳包裝 ErrIllegalTransition 的錯誤
+func ValidateTransition(from, to ShipmentStatus) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
+
+// EventProducer 出貨服務發布事件時使用的生產者名稱
+const EventProducer = "fulfillment-service"
+
+// Shipment 出貨模型，一筆訂單可以分成多次出貨
+type Shipment struct {
+	ID             string         `json:"id"`
+	OrderID        string         `json:"order_id"`
+	UserID         string         `json:"user_id"`
+	Carrier        string         `json:"carrier"`
+	Service        string         `json:"service"`
+	TrackingNumber string         `json:"tracking_number"`
+	Status         ShipmentStatus `json:"status"`
+	Items          []ShipmentItem `json:"items"`
+	// ShippingInfo 出貨時訂單收件資訊的副本
+	ShippingInfo ShippingInfo `json:"shipping_info"`
+	// Cost 物流商收取的運費
+	Cost        money.Money     `json:"cost"`
+	Tracking    []TrackingEvent `json:"tracking,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UpdatedAt   time.Time       `json:"updated_at"`
+	DeliveredAt *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// ShipmentItem 本次出貨的訂單項目與數量
+type ShipmentItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
+
+// TrackingEvent 物流追蹤紀錄
+type TrackingEvent struct {
+	Status      ShipmentStatus `json:"status"`
+	Description string         `json:"description,omitempty"`
+	Location    string         `json:"location,omitempty"`
+	OccurredAt  time.Time      `json:"occurred_at"`
+}
+
+// ShippingInfo 收件資訊，以 JSONB 存放於 shipments 資料表
+type ShippingInfo struct {
+	FullName     string `json:"full_name,omitempty"`
+	AddressLine1 string `json:"address_line_1,omitempty"`
+	AddressLine2 string `json:"address_line_2,omitempty"`
+	City         string `json:"city,omitempty"`
+	State        string `json:"state,omitempty"`
+	PostalCode   string `json:"postal_code,omitempty"`
+	Country      string `json:"country,omitempty"`
+	PhoneNumber  string `json:"phone_number,omitempty"`
+}
+
+// Value 實作 driver.Valuer，寫入 JSONB 欄位
+func (s ShippingInfo) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan 實作 sql.Scanner，讀取 JSONB 欄位
+func (s *ShippingInfo) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = ShippingInfo{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return fmt.Errorf("無法讀取收件資訊: %T", src)
+}
+
+// NewShipment 創建尚未建立託運單的出貨
+func NewShipment(orderID, userID string, items []ShipmentItem, shippingInfo ShippingInfo) *Shipment {
+	now := time.Now()
+	return &Shipment{
+		ID:           uuid.NewString(),
+		OrderID:      orderID,
+		UserID:       userID,
+		Status:       StatusCreated,
+		Items:        items,
+		ShippingInfo: shippingInfo,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+// OrderLine 訂單項目的訂購數量，用於檢查分批出貨不超過訂購數量
+type OrderLine struct {
+	ProductID string
+	Quantity  int
+}
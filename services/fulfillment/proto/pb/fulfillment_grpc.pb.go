@@ -0,0 +1,282 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v3.13.0
+// source: services/fulfillment/proto/fulfillment.proto
+
+package pb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.62.0 or later.
+const _ = grpc.SupportPackageIsVersion8
+
+const (
+	FulfillmentService_QuoteRates_FullMethodName       = "/fulfillment.FulfillmentService/QuoteRates"
+	FulfillmentService_CreateShipment_FullMethodName   = "/fulfillment.FulfillmentService/CreateShipment"
+	FulfillmentService_GetShipment_FullMethodName      = "/fulfillment.FulfillmentService/GetShipment"
+	FulfillmentService_ListShipments_FullMethodName    = "/fulfillment.FulfillmentService/ListShipments"
+	FulfillmentService_AddTrackingEvent_FullMethodName = "/fulfillment.FulfillmentService/AddTrackingEvent"
+)
+
+// FulfillmentServiceClient is the client API for FulfillmentService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// FulfillmentService 定義出貨服務的gRPC接口
+type FulfillmentServiceClient interface {
+	// QuoteRates 依商品重量、尺寸與收件地址查詢各運送服務的運費
+	QuoteRates(ctx context.Context, in *QuoteRatesRequest, opts ...grpc.CallOption) (*QuoteRatesResponse, error)
+	// CreateShipment 為已付款的訂單建立出貨，可以只出貨部分項目
+	CreateShipment(ctx context.Context, in *CreateShipmentRequest, opts ...grpc.CallOption) (*ShipmentResponse, error)
+	// GetShipment 獲取出貨詳情
+	GetShipment(ctx context.Context, in *GetShipmentRequest, opts ...grpc.CallOption) (*ShipmentResponse, error)
+	// ListShipments 獲取訂單的所有出貨
+	ListShipments(ctx context.Context, in *ListShipmentsRequest, opts ...grpc.CallOption) (*ListShipmentsResponse, error)
+	// AddTrackingEvent 記錄物流商回報的追蹤紀錄
+	AddTrackingEvent(ctx context.Context, in *AddTrackingEventRequest, opts ...grpc.CallOption) (*ShipmentResponse, error)
+}
+
+type fulfillmentServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewFulfillmentServiceClient(cc grpc.ClientConnInterface) FulfillmentServiceClient {
+	return &fulfillmentServiceClient{cc}
+}
+
+func (c *fulfillmentServiceClient) QuoteRates(ctx context.Context, in *QuoteRatesRequest, opts ...grpc.CallOption) (*QuoteRatesResponse, error) {
+	out := new(QuoteRatesResponse)
+	err := c.cc.Invoke(ctx, FulfillmentService_QuoteRates_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fulfillmentServiceClient) CreateShipment(ctx context.Context, in *CreateShipmentRequest, opts ...grpc.CallOption) (*ShipmentResponse, error) {
+	out := new(ShipmentResponse)
+	err := c.cc.Invoke(ctx, FulfillmentService_CreateShipment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fulfillmentServiceClient) GetShipment(ctx context.Context, in *GetShipmentRequest, opts ...grpc.CallOption) (*ShipmentResponse, error) {
+	out := new(ShipmentResponse)
+	err := c.cc.Invoke(ctx, FulfillmentService_GetShipment_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fulfillmentServiceClient) ListShipments(ctx context.Context, in *ListShipmentsRequest, opts ...grpc.CallOption) (*ListShipmentsResponse, error) {
+	out := new(ListShipmentsResponse)
+	err := c.cc.Invoke(ctx, FulfillmentService_ListShipments_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *fulfillmentServiceClient) AddTrackingEvent(ctx context.Context, in *AddTrackingEventRequest, opts ...grpc.CallOption) (*ShipmentResponse, error) {
+	out := new(ShipmentResponse)
+	err := c.cc.Invoke(ctx, FulfillmentService_AddTrackingEvent_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// FulfillmentServiceServer is the server API for FulfillmentService service.
+// All implementations must embed UnimplementedFulfillmentServiceServer
+// for forward compatibility.
+//
+// FulfillmentService 定義出貨服務的gRPC接口
+type FulfillmentServiceServer interface {
+	// QuoteRates 依商品重量、尺寸與收件地址查詢各運送服務的運費
+	QuoteRates(context.Context, *QuoteRatesRequest) (*QuoteRatesResponse, error)
+	// CreateShipment 為已付款的訂單建立出貨，可以只出貨部分項目
+	CreateShipment(context.Context, *CreateShipmentRequest) (*ShipmentResponse, error)
+	// GetShipment 獲取出貨詳情
+	GetShipment(context.Context, *GetShipmentRequest) (*ShipmentResponse, error)
+	// ListShipments 獲取訂單的所有出貨
+	ListShipments(context.Context, *ListShipmentsRequest) (*ListShipmentsResponse, error)
+	// AddTrackingEvent 記錄物流商回報的追蹤紀錄
+	AddTrackingEvent(context.Context, *AddTrackingEventRequest) (*ShipmentResponse, error)
+	mustEmbedUnimplementedFulfillmentServiceServer()
+}
+
+// UnimplementedFulfillmentServiceServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedFulfillmentServiceServer struct{}
+
+func (UnimplementedFulfillmentServiceServer) QuoteRates(context.Context, *QuoteRatesRequest) (*QuoteRatesResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method QuoteRates not implemented")
+}
+func (UnimplementedFulfillmentServiceServer) CreateShipment(context.Context, *CreateShipmentRequest) (*ShipmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateShipment not implemented")
+}
+func (UnimplementedFulfillmentServiceServer) GetShipment(context.Context, *GetShipmentRequest) (*ShipmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetShipment not implemented")
+}
+func (UnimplementedFulfillmentServiceServer) ListShipments(context.Context, *ListShipmentsRequest) (*ListShipmentsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListShipments not implemented")
+}
+func (UnimplementedFulfillmentServiceServer) AddTrackingEvent(context.Context, *AddTrackingEventRequest) (*ShipmentResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddTrackingEvent not implemented")
+}
+func (UnimplementedFulfillmentServiceServer) mustEmbedUnimplementedFulfillmentServiceServer() {}
+func (UnimplementedFulfillmentServiceServer) testEmbeddedByValue()                            {}
+
+// UnsafeFulfillmentServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to FulfillmentServiceServer will
+// result in compilation errors.
+type UnsafeFulfillmentServiceServer interface {
+	mustEmbedUnimplementedFulfillmentServiceServer()
+}
+
+func RegisterFulfillmentServiceServer(s grpc.ServiceRegistrar, srv FulfillmentServiceServer) {
+	// If the following call pancis, it indicates UnimplementedFulfillmentServiceServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&FulfillmentService_ServiceDesc, srv)
+}
+
+func _FulfillmentService_QuoteRates_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(QuoteRatesRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FulfillmentServiceServer).QuoteRates(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FulfillmentService_QuoteRates_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FulfillmentServiceServer).QuoteRates(ctx, req.(*QuoteRatesRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FulfillmentService_CreateShipment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateShipmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FulfillmentServiceServer).CreateShipment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FulfillmentService_CreateShipment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FulfillmentServiceServer).CreateShipment(ctx, req.(*CreateShipmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FulfillmentService_GetShipment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetShipmentRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FulfillmentServiceServer).GetShipment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FulfillmentService_GetShipment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FulfillmentServiceServer).GetShipment(ctx, req.(*GetShipmentRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FulfillmentService_ListShipments_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListShipmentsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FulfillmentServiceServer).ListShipments(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FulfillmentService_ListShipments_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FulfillmentServiceServer).ListShipments(ctx, req.(*ListShipmentsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _FulfillmentService_AddTrackingEvent_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddTrackingEventRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(FulfillmentServiceServer).AddTrackingEvent(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: FulfillmentService_AddTrackingEvent_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(FulfillmentServiceServer).AddTrackingEvent(ctx, req.(*AddTrackingEventRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// FulfillmentService_ServiceDesc is the grpc.ServiceDesc for FulfillmentService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var FulfillmentService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "fulfillment.FulfillmentService",
+	HandlerType: (*FulfillmentServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "QuoteRates",
+			Handler:    _FulfillmentService_QuoteRates_Handler,
+		},
+		{
+			MethodName: "CreateShipment",
+			Handler:    _FulfillmentService_CreateShipment_Handler,
+		},
+		{
+			MethodName: "GetShipment",
+			Handler:    _FulfillmentService_GetShipment_Handler,
+		},
+		{
+			MethodName: "ListShipments",
+			Handler:    _FulfillmentService_ListShipments_Handler,
+		},
+		{
+			MethodName: "AddTrackingEvent",
+			Handler:    _FulfillmentService_AddTrackingEvent_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "services/fulfillment/proto/fulfillment.proto",
+}
@@ -0,0 +1,437 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
+	"github.com/arrontsai/ecommerce/services/fulfillment/model"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+var (
+	// ErrShipmentNotFound 出貨不存在
+	ErrShipmentNotFound = errors.New("出貨不存在")
+	// ErrExceedsOrder 出貨數量超過訂單尚未出貨的數量，或商品不在訂單中
+	ErrExceedsOrder = errors.New("出貨數量超過訂單未出貨的數量")
+)
+
+// OutboxTable 出貨服務的外寄事件資料表，與其他服務共用資料庫時互不干擾
+const OutboxTable = "fulfillment_outbox"
+
+const shipmentColumns = `shipment_id, order_id, user_id, carrier, service, tracking_number, status,
+	shipping_info, cost, currency, created_at, updated_at, delivered_at`
+
+// shipmentRow 對應 shipments 資料表的一列，運費以幣別的最小單位儲存
+type shipmentRow struct {
+	ID             string               `db:"shipment_id"`
+	OrderID        string               `db:"order_id"`
+	UserID         string               `db:"user_id"`
+	Carrier        string               `db:"carrier"`
+	Service        string               `db:"service"`
+	TrackingNumber string               `db:"tracking_number"`
+	Status         model.ShipmentStatus `db:"status"`
+	ShippingInfo   model.ShippingInfo   `db:"shipping_info"`
+	Cost           int64                `db:"cost"`
+	Currency       string               `db:"currency"`
+	CreatedAt      time.Time            `db:"created_at"`
+	UpdatedAt      time.Time            `db:"updated_at"`
+	DeliveredAt    *time.Time           `db:"delivered_at"`
+}
+
+func (row shipmentRow) toModel() model.Shipment {
+	return model.Shipment{
+		ID:             row.ID,
+		OrderID:        row.OrderID,
+		UserID:         row.UserID,
+		Carrier:        row.Carrier,
+		Service:        row.Service,
+		TrackingNumber: row.TrackingNumber,
+		Status:         row.Status,
+		Items:          []model.ShipmentItem{},
+		ShippingInfo:   row.ShippingInfo,
+		Cost:           money.New(row.Cost, row.Currency),
+		Tracking:       []model.TrackingEvent{},
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+		DeliveredAt:    row.DeliveredAt,
+	}
+}
+
+// shipmentItemRow 對應 shipment_items 資料表的欄位
+type shipmentItemRow struct {
+	ShipmentID string `db:"shipment_id"`
+	ProductID  string `db:"product_id"`
+	Quantity   int    `db:"quantity"`
+}
+
+// trackingRow 對應 shipment_tracking 資料表的欄位
+type trackingRow struct {
+	ShipmentID  string               `db:"shipment_id"`
+	Status      model.ShipmentStatus `db:"status"`
+	Description string               `db:"description"`
+	Location    string               `db:"location"`
+	OccurredAt  time.Time            `db:"occurred_at"`
+}
+
+// ShipmentRepository 出貨的PostgreSQL儲存，出貨與外寄事件在同一交易中寫入
+type ShipmentRepository struct {
+	db     *sqlx.DB
+	outbox *outbox.PostgresStore
+}
+
+// NewShipmentRepo 創建出貨儲存，外寄事件寫入 OutboxTable
+func NewShipmentRepo(db *sqlx.DB) *ShipmentRepository {
+	return &ShipmentRepository{db: db, outbox: outbox.NewPostgresStoreWithTable(db, OutboxTable)}
+}
+
+// Outbox 回傳出貨服務的外寄事件儲存，供事件轉發器使用
+func (r *ShipmentRepository) Outbox() *outbox.PostgresStore {
+	return r.outbox
+}
+
+// Remaining 計算訂單各項目尚未出貨的數量，lines 為訂單的訂購數量
+//
+// 結果只供決定要出貨的項目，CreateShipment 會在交易中再次檢查。
+func (r *ShipmentRepository) Remaining(ctx context.Context, orderID string, lines []model.OrderLine) ([]model.ShipmentItem, error) {
+	shipped, err := r.shippedQuantities(ctx, r.db, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	var remaining []model.ShipmentItem
+	for _, line := range lines {
+		if left := line.Quantity - shipped[line.ProductID]; left > 0 {
+			remaining = append(remaining, model.ShipmentItem{ProductID: line.ProductID, Quantity: left})
+		}
+	}
+	return remaining, nil
+}
+
+// CreateShipment 在同一交易中寫入出貨、出貨項目、初始追蹤紀錄與出貨建立事件
+//
+// 同一訂單的出貨依序寫入；出貨數量加上已出貨的數量超過 lines 的訂購數量時
+// 回傳 ErrExceedsOrder。訂單第一次出貨時記錄訂購數量，之後以記錄的數量為準。
+func (r *ShipmentRepository) CreateShipment(ctx context.Context, shipment *model.Shipment, lines []model.OrderLine) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("開始交易失敗: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 以訂單ID鎖定，避免並行的出貨超出訂購數量
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, shipment.OrderID); err != nil {
+		return fmt.Errorf("鎖定訂單失敗: %w", err)
+	}
+
+	for _, line := range lines {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO fulfillment_order_lines (order_id, product_id, quantity) VALUES ($1, $2, $3)
+			ON CONFLICT (order_id, product_id) DO NOTHING`,
+			shipment.OrderID, line.ProductID, line.Quantity)
+		if err != nil {
+			return fmt.Errorf("記錄訂購數量失敗: %w", err)
+		}
+	}
+
+	ordered, err := r.orderedQuantities(ctx, tx, shipment.OrderID)
+	if err != nil {
+		return err
+	}
+	shipped, err := r.shippedQuantities(ctx, tx, shipment.OrderID)
+	if err != nil {
+		return err
+	}
+	for _, item := range shipment.Items {
+		if item.Quantity <= 0 || shipped[item.ProductID]+item.Quantity > ordered[item.ProductID] {
+			return fmt.Errorf("%w: 商品 %s 訂購 %d 件，已出貨 %d 件", ErrExceedsOrder,
+				item.ProductID, ordered[item.ProductID], shipped[item.ProductID])
+		}
+		shipped[item.ProductID] += item.Quantity
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO shipments (shipment_id, order_id, user_id, carrier, service, tracking_number, status,
+			shipping_info, cost, currency, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $11)`,
+		shipment.ID, shipment.OrderID, shipment.UserID, shipment.Carrier, shipment.Service, shipment.TrackingNumber,
+		shipment.Status, shipment.ShippingInfo, shipment.Cost.Amount, shipment.Cost.Currency, shipment.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("插入出貨失敗: %w", err)
+	}
+
+	created := events.ShipmentCreated{
+		ShipmentID:     shipment.ID,
+		OrderID:        shipment.OrderID,
+		UserID:         shipment.UserID,
+		Carrier:        shipment.Carrier,
+		Service:        shipment.Service,
+		TrackingNumber: shipment.TrackingNumber,
+		Items:          make([]events.ShipmentItem, 0, len(shipment.Items)),
+		AllShipped:     allShipped(ordered, shipped),
+	}
+	for _, item := range shipment.Items {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO shipment_items (shipment_id, order_id, product_id, quantity) VALUES ($1, $2, $3, $4)`,
+			shipment.ID, shipment.OrderID, item.ProductID, item.Quantity)
+		if err != nil {
+			return fmt.Errorf("插入出貨項目失敗: %w", err)
+		}
+		created.Items = append(created.Items, events.ShipmentItem(item))
+	}
+
+	initial := model.TrackingEvent{Status: shipment.Status, Description: "已建立託運單", OccurredAt: shipment.CreatedAt}
+	if err := r.addTracking(ctx, tx, shipment.ID, initial); err != nil {
+		return err
+	}
+	shipment.Tracking = append(shipment.Tracking, initial)
+
+	if err := r.addEvent(ctx, tx, created); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交交易失敗: %w", err)
+	}
+	return nil
+}
+
+// AddTracking 記錄物流追蹤並依狀態轉換圖更新出貨狀態
+//
+// 送達時在同一交易中寫入出貨送達事件，訂單所有項目都已出貨且送達時事件的
+// AllDelivered 為 true。
+func (r *ShipmentRepository) AddTracking(ctx context.Context, shipmentID string, event model.TrackingEvent) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("開始交易失敗: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 鎖定出貨列，避免並行的追蹤更新互相覆蓋
+	var current struct {
+		OrderID        string               `db:"order_id"`
+		UserID         string               `db:"user_id"`
+		TrackingNumber string               `db:"tracking_number"`
+		Status         model.ShipmentStatus `db:"status"`
+	}
+	err = tx.GetContext(ctx, &current,
+		`SELECT order_id, user_id, tracking_number, status FROM shipments WHERE shipment_id = $1 FOR UPDATE`, shipmentID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrShipmentNotFound
+		}
+		return fmt.Errorf("查詢出貨狀態失敗: %w", err)
+	}
+
+	if err := model.ValidateTransition(current.Status, event.Status); err != nil {
+		return err
+	}
+	if err := r.addTracking(ctx, tx, shipmentID, event); err != nil {
+		return err
+	}
+
+	var deliveredAt *time.Time
+	if event.Status == model.StatusDelivered {
+		deliveredAt = &event.OccurredAt
+	}
+	_, err = tx.ExecContext(ctx,
+		`UPDATE shipments SET status = $1, delivered_at = $2, updated_at = $3 WHERE shipment_id = $4`,
+		event.Status, deliveredAt, time.Now(), shipmentID)
+	if err != nil {
+		return fmt.Errorf("更新出貨狀態失敗: %w", err)
+	}
+
+	if event.Status == model.StatusDelivered {
+		// 與建立出貨使用相同的鎖，避免同時建立的出貨被漏算
+		if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, current.OrderID); err != nil {
+			return fmt.Errorf("鎖定訂單失敗: %w", err)
+		}
+		delivered, err := r.allDelivered(ctx, tx, current.OrderID)
+		if err != nil {
+			return err
+		}
+		if err := r.addEvent(ctx, tx, events.ShipmentDelivered{
+			ShipmentID:     shipmentID,
+			OrderID:        current.OrderID,
+			UserID:         current.UserID,
+			TrackingNumber: current.TrackingNumber,
+			AllDelivered:   delivered,
+		}); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交交易失敗: %w", err)
+	}
+	return nil
+}
+
+// GetShipment 依出貨ID查詢出貨、項目與追蹤紀錄，不存在時回傳 nil
+func (r *ShipmentRepository) GetShipment(ctx context.Context, shipmentID string) (*model.Shipment, error) {
+	shipments, err := r.list(ctx, `SELECT `+shipmentColumns+` FROM shipments WHERE shipment_id = $1`, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	if len(shipments) == 0 {
+		return nil, nil
+	}
+	return &shipments[0], nil
+}
+
+// ListShipmentsByOrder 查詢訂單的所有出貨，依建立時間排序
+func (r *ShipmentRepository) ListShipmentsByOrder(ctx context.Context, orderID string) ([]model.Shipment, error) {
+	return r.list(ctx,
+		`SELECT `+shipmentColumns+` FROM shipments WHERE order_id = $1 ORDER BY created_at, shipment_id`, orderID)
+}
+
+// list 查詢出貨並批次載入其項目與追蹤紀錄
+func (r *ShipmentRepository) list(ctx context.Context, query string, args ...interface{}) ([]model.Shipment, error) {
+	var rows []shipmentRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("查詢出貨失敗: %w", err)
+	}
+
+	shipments := make([]model.Shipment, 0, len(rows))
+	if len(rows) == 0 {
+		return shipments, nil
+	}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+
+	var items []shipmentItemRow
+	err := r.db.SelectContext(ctx, &items,
+		`SELECT shipment_id, product_id, quantity FROM shipment_items WHERE shipment_id = ANY($1) ORDER BY id`,
+		pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("查詢出貨項目失敗: %w", err)
+	}
+
+	var tracking []trackingRow
+	err = r.db.SelectContext(ctx, &tracking,
+		`SELECT shipment_id, status, description, location, occurred_at FROM shipment_tracking
+		WHERE shipment_id = ANY($1) ORDER BY occurred_at, id`, pq.Array(ids))
+	if err != nil {
+		return nil, fmt.Errorf("查詢追蹤紀錄失敗: %w", err)
+	}
+
+	index := make(map[string]int, len(rows))
+	for i, row := range rows {
+		index[row.ID] = i
+		shipments = append(shipments, row.toModel())
+	}
+	for _, item := range items {
+		shipment := &shipments[index[item.ShipmentID]]
+		shipment.Items = append(shipment.Items, model.ShipmentItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	for _, event := range tracking {
+		shipment := &shipments[index[event.ShipmentID]]
+		shipment.Tracking = append(shipment.Tracking, model.TrackingEvent{
+			Status:      event.Status,
+			Description: event.Description,
+			Location:    event.Location,
+			OccurredAt:  event.OccurredAt,
+		})
+	}
+	return shipments, nil
+}
+
+// addTracking 在交易中寫入一筆追蹤紀錄
+func (r *ShipmentRepository) addTracking(ctx context.Context, tx *sqlx.Tx, shipmentID string, event model.TrackingEvent) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO shipment_tracking (shipment_id, status, description, location, occurred_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		shipmentID, event.Status, event.Description, event.Location, event.OccurredAt)
+	if err != nil {
+		return fmt.Errorf("插入追蹤紀錄失敗: %w", err)
+	}
+	return nil
+}
+
+// addEvent 在交易中將出貨事件寫入外寄事件表
+func (r *ShipmentRepository) addEvent(ctx context.Context, tx *sqlx.Tx, event events.Event) error {
+	encoded, err := events.Encode(ctx, model.EventProducer, event)
+	if err != nil {
+		return err
+	}
+	return r.outbox.Add(ctx, tx, encoded.Topic, encoded.Key, encoded.Data)
+}
+
+// quantityRow 商品數量的查詢結果
+type quantityRow struct {
+	ProductID string `db:"product_id"`
+	Quantity  int    `db:"quantity"`
+}
+
+// orderedQuantities 查詢訂單記錄的訂購數量
+func (r *ShipmentRepository) orderedQuantities(ctx context.Context, q sqlx.QueryerContext, orderID string) (map[string]int, error) {
+	var rows []quantityRow
+	err := sqlx.SelectContext(ctx, q, &rows,
+		`SELECT product_id, quantity FROM fulfillment_order_lines WHERE order_id = $1`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢訂購數量失敗: %w", err)
+	}
+	return quantities(rows), nil
+}
+
+// shippedQuantities 查詢訂單各商品已出貨的數量
+func (r *ShipmentRepository) shippedQuantities(ctx context.Context, q sqlx.QueryerContext, orderID string) (map[string]int, error) {
+	var rows []quantityRow
+	err := sqlx.SelectContext(ctx, q, &rows,
+		`SELECT product_id, SUM(quantity) AS quantity FROM shipment_items WHERE order_id = $1 GROUP BY product_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢已出貨數量失敗: %w", err)
+	}
+	return quantities(rows), nil
+}
+
+// allDelivered 檢查訂單所有項目是否都已出貨且每筆出貨都已送達
+func (r *ShipmentRepository) allDelivered(ctx context.Context, tx *sqlx.Tx, orderID string) (bool, error) {
+	ordered, err := r.orderedQuantities(ctx, tx, orderID)
+	if err != nil {
+		return false, err
+	}
+	shipped, err := r.shippedQuantities(ctx, tx, orderID)
+	if err != nil {
+		return false, err
+	}
+	if !allShipped(ordered, shipped) {
+		return false, nil
+	}
+
+	var open int
+	err = tx.GetContext(ctx, &open,
+		`SELECT COUNT(*) FROM shipments WHERE order_id = $1 AND status <> $2`, orderID, model.StatusDelivered)
+	if err != nil {
+		return false, fmt.Errorf("查詢未送達出貨失敗: %w", err)
+	}
+	return open == 0, nil
+}
+
+// allShipped 檢查每項商品的出貨數量是否都已達訂購數量
+func allShipped(ordered, shipped map[string]int) bool {
+	for productID, quantity := range ordered {
+		if shipped[productID] < quantity {
+			return false
+		}
+	}
+	return true
+}
+
+func quantities(rows []quantityRow) map[string]int {
+	result := make(map[string]int, len(rows))
+	for _, row := range rows {
+		result[row.ProductID] = row.Quantity
+	}
+	return result
+}
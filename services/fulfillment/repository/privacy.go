@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arrontsai/ecommerce/services/fulfillment/model"
+)
+
+// ErrOpenShipments 用戶仍有尚未送達的出貨，收件資訊仍需交給物流商
+var ErrOpenShipments = errors.New("用戶仍有未送達的出貨")
+
+// ExportUserShipments 查詢用戶的全部出貨及其項目與追蹤紀錄，供資料匯出使用
+func (r *ShipmentRepository) ExportUserShipments(ctx context.Context, userID string) ([]model.Shipment, error) {
+	return r.list(ctx,
+		`SELECT `+shipmentColumns+` FROM shipments WHERE user_id = $1 ORDER BY created_at, shipment_id`, userID)
+}
+
+// PseudonymizeUser 將用戶全部出貨的收件資訊假名化，回傳更新的出貨數
+//
+// 與訂單服務相同，收件人姓名改為由用戶ID推導的假名，地址與電話移除，只保留
+// 州與國家；追蹤編號與運費保留供對帳。仍有未送達的出貨時不做任何變更並回傳
+// ErrOpenShipments。重複執行的結果相同。
+func (r *ShipmentRepository) PseudonymizeUser(ctx context.Context, userID string) (int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("開始交易失敗: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 鎖定用戶未送達的出貨，避免檢查後又有追蹤更新
+	var open int
+	err = tx.GetContext(ctx, &open,
+		`SELECT COUNT(*) FROM (
+			SELECT 1 FROM shipments WHERE user_id = $1 AND status <> $2 FOR UPDATE
+		) AS open_shipments`,
+		userID, model.StatusDelivered)
+	if err != nil {
+		return 0, fmt.Errorf("查詢未送達出貨失敗: %w", err)
+	}
+	if open > 0 {
+		return 0, fmt.Errorf("%w: %d 筆", ErrOpenShipments, open)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE shipments SET
+			shipping_info = jsonb_strip_nulls(jsonb_build_object(
+				'full_name', $2::text,
+				'state', shipping_info->'state',
+				'country', shipping_info->'country'
+			)),
+			updated_at = $3
+		WHERE user_id = $1`,
+		userID, pseudonym(userID), time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("假名化出貨收件資訊失敗: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交交易失敗: %w", err)
+	}
+	return updated, nil
+}
+
+// pseudonym 由用戶ID推導出無法還原的收件人假名，與訂單服務的假名相同
+func pseudonym(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return "erased-" + hex.EncodeToString(sum[:6])
+}
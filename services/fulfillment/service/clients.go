@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+
+	"github.com/arrontsai/ecommerce/services/fulfillment/carrier"
+	"github.com/arrontsai/ecommerce/services/fulfillment/model"
+	orderpb "github.com/arrontsai/ecommerce/services/order/proto/pb"
+	productpb "github.com/arrontsai/ecommerce/services/product/proto/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Catalog 查詢商品的單件重量與包裝尺寸，不存在的商品不會出現在結果中
+type Catalog interface {
+	Parcels(ctx context.Context, productIDs []string) (map[string]carrier.Item, error)
+}
+
+// Order 建立出貨所需的訂單資訊
+type Order struct {
+	ID           string
+	UserID       string
+	Status       string
+	Lines        []model.OrderLine
+	ShippingInfo model.ShippingInfo
+}
+
+// Orders 查詢訂單，不存在時回傳 nil
+type Orders interface {
+	GetOrder(ctx context.Context, orderID string) (*Order, error)
+}
+
+// CatalogClient 透過產品服務的商品gRPC接口查詢商品重量與尺寸
+type CatalogClient struct {
+	client productpb.CatalogServiceClient
+}
+
+// NewCatalogClient 創建以產品服務查詢商品的 Catalog
+func NewCatalogClient(client productpb.CatalogServiceClient) *CatalogClient {
+	return &CatalogClient{client: client}
+}
+
+// Parcels 實作 Catalog
+func (c *CatalogClient) Parcels(ctx context.Context, productIDs []string) (map[string]carrier.Item, error) {
+	resp, err := c.client.GetProducts(ctx, &productpb.GetProductsRequest{ProductIds: productIDs})
+	if err != nil {
+		return nil, err
+	}
+
+	parcels := make(map[string]carrier.Item, len(resp.Products))
+	for _, product := range resp.Products {
+		parcels[product.ProductId] = carrier.Item{
+			ProductID: product.ProductId,
+			Weight:    int(product.Weight),
+			Length:    int(product.Length),
+			Width:     int(product.Width),
+			Height:    int(product.Height),
+		}
+	}
+	return parcels, nil
+}
+
+// OrderClient 透過訂單服務的gRPC接口查詢訂單
+type OrderClient struct {
+	client orderpb.OrderServiceClient
+}
+
+// NewOrderClient 創建以訂單服務查詢訂單的 Orders
+func NewOrderClient(client orderpb.OrderServiceClient) *OrderClient {
+	return &OrderClient{client: client}
+}
+
+// GetOrder 實作 Orders
+func (c *OrderClient) GetOrder(ctx context.Context, orderID string) (*Order, error) {
+	resp, err := c.client.GetOrder(ctx, &orderpb.GetOrderRequest{OrderId: orderID})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	order := &Order{
+		ID:     resp.OrderId,
+		UserID: resp.UserId,
+		Status: resp.Status,
+		Lines:  make([]model.OrderLine, 0, len(resp.Items)),
+	}
+	// 同一商品可能出現在多個訂單項目，以商品合計數量
+	index := map[string]int{}
+	for _, item := range resp.Items {
+		if i, ok := index[item.ProductId]; ok {
+			order.Lines[i].Quantity += int(item.Quantity)
+			continue
+		}
+		index[item.ProductId] = len(order.Lines)
+		order.Lines = append(order.Lines, model.OrderLine{ProductID: item.ProductId, Quantity: int(item.Quantity)})
+	}
+	if info := resp.ShippingInfo; info != nil {
+		order.ShippingInfo = model.ShippingInfo{
+			FullName:     info.FullName,
+			AddressLine1: info.AddressLine1,
+			AddressLine2: info.AddressLine2,
+			City:         info.City,
+			State:        info.State,
+			PostalCode:   info.PostalCode,
+			Country:      info.Country,
+			PhoneNumber:  info.PhoneNumber,
+		}
+	}
+	return order, nil
+}
@@ -0,0 +1,275 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/exchange"
+	"github.com/arrontsai/ecommerce/services/fulfillment/carrier"
+	"github.com/arrontsai/ecommerce/services/fulfillment/model"
+	"github.com/arrontsai/ecommerce/services/fulfillment/repository"
+)
+
+var (
+	// ErrShipmentNotFound 出貨不存在
+	ErrShipmentNotFound = errors.New("出貨不存在")
+	// ErrOrderNotFound 訂單不存在
+	ErrOrderNotFound = errors.New("訂單不存在")
+	// ErrOrderNotShippable 訂單尚未付款或已完成、取消，不能出貨
+	ErrOrderNotShippable = errors.New("訂單目前不能出貨")
+	// ErrNothingToShip 訂單所有項目都已出貨
+	ErrNothingToShip = errors.New("訂單沒有待出貨的項目")
+	// ErrInvalidItems 出貨或報價的商品為空、數量無效或商品不存在
+	ErrInvalidItems = errors.New("無效的出貨項目")
+	// ErrNoShippingAddress 收件資訊缺少國家
+	ErrNoShippingAddress = errors.New("缺少收件國家")
+	// ErrInvalidStatus 追蹤紀錄的出貨狀態無效
+	ErrInvalidStatus = errors.New("無效的出貨狀態")
+	// ErrCurrencyUnavailable 運費無法換算成指定的幣別
+	ErrCurrencyUnavailable = errors.New("無法以選擇的幣別報價")
+)
+
+// DefaultService 建立出貨未指定運送服務時使用的服務
+const DefaultService = "standard"
+
+// shippableStatuses 可以出貨的訂單狀態，已出貨的訂單可以繼續出貨其餘項目
+var shippableStatuses = map[string]bool{"PAID": true, "SHIPPED": true}
+
+// QuoteInput 查詢運費的輸入
+type QuoteInput struct {
+	Items        []model.ShipmentItem
+	ShippingInfo model.ShippingInfo
+	// Currency 報價的幣別，空白時使用物流商計價的幣別
+	Currency string
+}
+
+// CreateShipmentInput 建立出貨的輸入
+type CreateShipmentInput struct {
+	OrderID string
+	// Service 運送服務，空白時使用 DefaultService
+	Service string
+	// Items 本次出貨的項目，空白時出貨訂單所有未出貨的項目
+	Items []model.ShipmentItem
+}
+
+// FulfillmentService 處理運費報價、出貨與物流追蹤
+type FulfillmentService struct {
+	repo    *repository.ShipmentRepository
+	carrier carrier.Carrier
+	catalog Catalog
+	orders  Orders
+	rates   exchange.ExchangeRateProvider
+}
+
+// NewFulfillmentService 創建出貨服務實例
+func NewFulfillmentService(repo *repository.ShipmentRepository, c carrier.Carrier, catalog Catalog, orders Orders, rates exchange.ExchangeRateProvider) *FulfillmentService {
+	return &FulfillmentService{
+		repo:    repo,
+		carrier: c,
+		catalog: catalog,
+		orders:  orders,
+		rates:   rates,
+	}
+}
+
+// Quote 依商品重量、尺寸與收件地址查詢各運送服務的運費，依金額由低到高排序
+//
+// 物流商無法配送時回傳 carrier.ErrNoRate。
+func (s *FulfillmentService) Quote(ctx context.Context, in QuoteInput) ([]carrier.Rate, error) {
+	if in.ShippingInfo.Country == "" {
+		return nil, ErrNoShippingAddress
+	}
+	items, err := s.parcels(ctx, in.Items)
+	if err != nil {
+		return nil, err
+	}
+
+	rates, err := s.carrier.Rates(ctx, carrier.RateRequest{Destination: destination(in.ShippingInfo), Items: items})
+	if err != nil {
+		return nil, err
+	}
+
+	if in.Currency != "" {
+		for i := range rates {
+			amount, err := exchange.Convert(ctx, s.rates, rates[i].Amount, in.Currency)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %v", ErrCurrencyUnavailable, err)
+			}
+			rates[i].Amount = amount
+		}
+	}
+	return rates, nil
+}
+
+// CreateShipment 為已付款的訂單建立出貨，向物流商取得追蹤編號
+//
+// 一筆訂單可以分多次出貨，每次出貨的數量加上已出貨的數量不能超過訂購數量，
+// 超過時回傳包裝 repository.ErrExceedsOrder 的錯誤。出貨項目在建立託運單前
+// 檢查，避免為無法寫入的出貨購買託運單；託運單在寫入資料庫前建立，物流商以
+// 出貨ID辨識重複的請求，寫入時會在交易中再次檢查數量。
+func (s *FulfillmentService) CreateShipment(ctx context.Context, in CreateShipmentInput) (*model.Shipment, error) {
+	order, err := s.orders.GetOrder(ctx, in.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢訂單失敗: %w", err)
+	}
+	if order == nil {
+		return nil, ErrOrderNotFound
+	}
+	if !shippableStatuses[order.Status] {
+		return nil, fmt.Errorf("%w: 訂單狀態為 %s", ErrOrderNotShippable, order.Status)
+	}
+	if order.ShippingInfo.Country == "" {
+		return nil, ErrNoShippingAddress
+	}
+
+	remaining, err := s.repo.Remaining(ctx, order.ID, order.Lines)
+	if err != nil {
+		return nil, err
+	}
+	items := remaining
+	if len(in.Items) > 0 {
+		items = mergeItems(in.Items)
+		if err := withinRemaining(items, remaining); err != nil {
+			return nil, err
+		}
+	}
+	if len(items) == 0 {
+		return nil, ErrNothingToShip
+	}
+
+	parcels, err := s.parcels(ctx, items)
+	if err != nil {
+		return nil, err
+	}
+
+	service := in.Service
+	if service == "" {
+		service = DefaultService
+	}
+
+	shipment := model.NewShipment(order.ID, order.UserID, items, order.ShippingInfo)
+	label, err := s.carrier.Ship(ctx, carrier.ShipRequest{
+		ShipmentID:  shipment.ID,
+		Service:     service,
+		Destination: destination(order.ShippingInfo),
+		Items:       parcels,
+	})
+	if err != nil {
+		return nil, err
+	}
+	shipment.Carrier = s.carrier.Name()
+	shipment.Service = service
+	shipment.TrackingNumber = label.TrackingNumber
+	shipment.Cost = label.Amount
+
+	if err := s.repo.CreateShipment(ctx, shipment, order.Lines); err != nil {
+		return nil, err
+	}
+	return shipment, nil
+}
+
+// UpdateTracking 記錄物流商回報的追蹤紀錄，OccurredAt 未指定時使用目前時間
+//
+// 出貨送達時發布 ShipmentDelivered，訂單所有出貨都送達後訂單服務將訂單改為已送達。
+func (s *FulfillmentService) UpdateTracking(ctx context.Context, shipmentID string, event model.TrackingEvent) (*model.Shipment, error) {
+	event.Status = model.ShipmentStatus(strings.ToUpper(string(event.Status)))
+	if !event.Status.IsValid() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidStatus, event.Status)
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	if err := s.repo.AddTracking(ctx, shipmentID, event); err != nil {
+		if errors.Is(err, repository.ErrShipmentNotFound) {
+			return nil, ErrShipmentNotFound
+		}
+		return nil, err
+	}
+	return s.GetShipment(ctx, shipmentID)
+}
+
+// GetShipment 依出貨ID獲取出貨
+func (s *FulfillmentService) GetShipment(ctx context.Context, shipmentID string) (*model.Shipment, error) {
+	shipment, err := s.repo.GetShipment(ctx, shipmentID)
+	if err != nil {
+		return nil, err
+	}
+	if shipment == nil {
+		return nil, ErrShipmentNotFound
+	}
+	return shipment, nil
+}
+
+// ListOrderShipments 獲取訂單的所有出貨
+func (s *FulfillmentService) ListOrderShipments(ctx context.Context, orderID string) ([]model.Shipment, error) {
+	return s.repo.ListShipmentsByOrder(ctx, orderID)
+}
+
+// parcels 查詢商品的重量與尺寸，組成要寄送的包裹內容
+func (s *FulfillmentService) parcels(ctx context.Context, items []model.ShipmentItem) ([]carrier.Item, error) {
+	if len(items) == 0 {
+		return nil, ErrInvalidItems
+	}
+
+	productIDs := make([]string, 0, len(items))
+	for _, item := range items {
+		if item.ProductID == "" || item.Quantity <= 0 {
+			return nil, fmt.Errorf("%w: 商品 %q 數量 %d", ErrInvalidItems, item.ProductID, item.Quantity)
+		}
+		productIDs = append(productIDs, item.ProductID)
+	}
+
+	products, err := s.catalog.Parcels(ctx, productIDs)
+	if err != nil {
+		return nil, fmt.Errorf("查詢商品失敗: %w", err)
+	}
+
+	parcels := make([]carrier.Item, 0, len(items))
+	for _, item := range items {
+		parcel, ok := products[item.ProductID]
+		if !ok {
+			return nil, fmt.Errorf("%w: 商品 %s 不存在", ErrInvalidItems, item.ProductID)
+		}
+		parcel.Quantity = item.Quantity
+		parcels = append(parcels, parcel)
+	}
+	return parcels, nil
+}
+
+// withinRemaining 檢查出貨項目都在訂單中且不超過尚未出貨的數量
+func withinRemaining(items, remaining []model.ShipmentItem) error {
+	left := make(map[string]int, len(remaining))
+	for _, item := range remaining {
+		left[item.ProductID] = item.Quantity
+	}
+	for _, item := range items {
+		if item.Quantity > left[item.ProductID] {
+			return fmt.Errorf("%w: 商品 %s 尚未出貨 %d 件", repository.ErrExceedsOrder, item.ProductID, left[item.ProductID])
+		}
+	}
+	return nil
+}
+
+// mergeItems 合併相同商品的出貨項目，保留第一次出現的順序
+func mergeItems(items []model.ShipmentItem) []model.ShipmentItem {
+	merged := make([]model.ShipmentItem, 0, len(items))
+	index := map[string]int{}
+	for _, item := range items {
+		if i, ok := index[item.ProductID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
+
+// destination 收件資訊中計算運費需要的部分
+func destination(info model.ShippingInfo) carrier.Address {
+	return carrier.Address{Country: info.Country, State: info.State, PostalCode: info.PostalCode}
+}
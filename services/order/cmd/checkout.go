@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/messaging"
+	"github.com/arrontsai/ecommerce/pkg/tax"
+	"github.com/arrontsai/ecommerce/services/order/model"
+)
+
+// checkoutConsumer 將購物車結帳事件轉為訂單
+//
+// 收件地址與建立訂單以函式注入，測試時可不連接認證服務、產品服務與資料庫。
+type checkoutConsumer struct {
+	taxes *tax.Calculator
+	// shipping 決定訂單的收件資訊，見 orderServer.resolveShipping
+	shipping func(ctx context.Context, userID, addressID string, given *model.ShippingInfo) (model.ShippingInfo, error)
+	// place 預留庫存並寫入訂單，見 orderServer.placeOrder
+	place func(ctx context.Context, order *model.Order) error
+}
+
+// handle 處理 CartCheckedOut：以結帳快照的售價、折扣與收件資訊建立訂單
+func (c *checkoutConsumer) handle(ctx context.Context, env *events.Envelope, event events.CartCheckedOut) error {
+	items := make([]model.OrderItem, 0, len(event.Items))
+	for _, item := range event.Items {
+		orderItem := model.NewOrderItem(item.ProductID, item.ProductName, item.Quantity, item.UnitPrice)
+		orderItem.TaxCategory = item.TaxCategory
+		items = append(items, orderItem)
+	}
+
+	var given *model.ShippingInfo
+	if event.ShippingInfo != nil {
+		info := model.ShippingInfo(*event.ShippingInfo)
+		given = &info
+	}
+	shipping, err := c.shipping(ctx, event.UserID, event.AddressID, given)
+	if err != nil {
+		log.Printf("取得收件地址失敗: %v", err)
+		// 地址不存在時重試無益，直接送往死信主題
+		if errors.Is(err, errAddressNotFound) {
+			return messaging.Fatal(err)
+		}
+		return err
+	}
+
+	// 訂單以結帳時購物車的幣別計價，幣別不一致的事件重試無益
+	order, err := model.NewOrder(event.UserID, event.Subtotal.Currency, items)
+	if err != nil {
+		return messaging.Fatal(err)
+	}
+	// 訂單ID由事件ID決定，重複投遞的事件使用相同的訂單與庫存預留；
+	// 沒有事件ID的事件會與其他事件得到相同的訂單ID而被略過
+	if env.ID == "" {
+		return messaging.Fatal(errors.New("結帳事件缺少事件ID"))
+	}
+	order.ID = model.CheckoutOrderID(env.ID)
+	if len(event.Discounts) > 0 || event.FreeShipping {
+		discounts := make([]model.Discount, 0, len(event.Discounts))
+		for _, discount := range event.Discounts {
+			discounts = append(discounts, model.Discount(discount))
+		}
+		if err := order.ApplyDiscounts(discounts, event.FreeShipping); err != nil {
+			return messaging.Fatal(err)
+		}
+	}
+	order.PaymentMethod = event.PaymentMethod
+	order.ShippingInfo = shipping
+	// 以結帳時間計稅，稅率表沒有涵蓋結帳時間時重試無益
+	checkedOutAt := event.CheckedOutAt
+	if checkedOutAt.IsZero() {
+		checkedOutAt = order.CreatedAt
+	}
+	if err := order.ApplyTax(c.taxes, checkedOutAt); err != nil {
+		return messaging.Fatal(err)
+	}
+
+	if err := c.place(ctx, order); err != nil {
+		log.Printf("創建訂單失敗: %v", err)
+		// 庫存不足時重試無益，直接送往死信主題
+		if errors.Is(err, errInsufficientStock) {
+			return messaging.Fatal(err)
+		}
+		return err
+	}
+	log.Printf("已為用戶 %s 創建訂單", event.UserID)
+	return nil
+}
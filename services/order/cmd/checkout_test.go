@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/messaging"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/pkg/tax"
+	"github.com/arrontsai/ecommerce/services/order/model"
+)
+
+// placedOrders 記錄 checkoutConsumer 寫入的訂單，代替產品服務與資料庫
+type placedOrders struct {
+	mu     sync.Mutex
+	err    error
+	calls  int
+	orders map[string]*model.Order
+}
+
+func (p *placedOrders) place(ctx context.Context, order *model.Order) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.calls++
+	if p.err != nil {
+		return p.err
+	}
+	if p.orders == nil {
+		p.orders = map[string]*model.Order{}
+	}
+	p.orders[order.ID] = order
+	return nil
+}
+
+func TestCheckoutCreatesOrderThroughMemoryBroker(t *testing.T) {
+	taxes, err := tax.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkedOut := events.CartCheckedOut{
+		CartID: "cart-1",
+		UserID: "user-1",
+		Items: []events.CartItem{
+			{ProductID: "p1", ProductName: "Phone", Quantity: 1, UnitPrice: money.New(99999, "TWD")},
+		},
+		CheckedOutAt: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		ShippingInfo: &events.ShippingInfo{FullName: "Alice", AddressLine1: "1 Main St", City: "Taipei", Country: "TW"},
+		Subtotal:     money.New(99999, "TWD"),
+	}
+
+	tests := []struct {
+		name         string
+		placeErr     error
+		shippingErr  error
+		checkedOutAt time.Time
+		deliveries   int
+		wantOrders   int
+		wantCalls    int
+		wantDLQ      int
+	}{
+		{name: "created once", deliveries: 1, wantOrders: 1, wantCalls: 1},
+		{name: "redelivered event reuses the order ID", deliveries: 2, wantOrders: 1, wantCalls: 2},
+		{name: "insufficient stock goes to dead letter", placeErr: errInsufficientStock, deliveries: 1, wantCalls: 1, wantDLQ: 1},
+		{name: "unknown address goes to dead letter", shippingErr: errAddressNotFound, deliveries: 1, wantDLQ: 1},
+		// 以結帳時間而非處理時間查詢稅率表
+		{name: "checkout before the tax rules goes to dead letter", checkedOutAt: time.Date(2025, 12, 31, 0, 0, 0, 0, time.UTC), deliveries: 1, wantDLQ: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			broker := messaging.NewMemoryBroker(nil)
+			defer broker.Close()
+
+			placed := &placedOrders{err: tt.placeErr}
+			checkout := &checkoutConsumer{
+				taxes: taxes,
+				shipping: func(ctx context.Context, userID, addressID string, given *model.ShippingInfo) (model.ShippingInfo, error) {
+					if tt.shippingErr != nil {
+						return model.ShippingInfo{}, tt.shippingErr
+					}
+					return *given, nil
+				},
+				place: placed.place,
+			}
+			mux := events.NewMux(nil)
+			events.Handle(mux, checkout.handle)
+			policy := messaging.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond, Multiplier: 1}
+			if err := messaging.SubscribeEvents(ctx, broker, events.TopicCartEvents, "order-service", mux, messaging.WithRetryPolicy(policy)); err != nil {
+				t.Fatal(err)
+			}
+
+			event := checkedOut
+			if !tt.checkedOutAt.IsZero() {
+				event.CheckedOutAt = tt.checkedOutAt
+			}
+			// 外寄事件轉發器重送時發布的是相同的序列化事件
+			encoded, err := events.Encode(ctx, "cart-service", event)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < tt.deliveries; i++ {
+				if err := broker.PublishMessage(ctx, encoded.Topic, encoded.Key, encoded.Data); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := broker.WaitIdle(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			if placed.calls != tt.wantCalls {
+				t.Errorf("place called %d times, want %d", placed.calls, tt.wantCalls)
+			}
+			if len(placed.orders) != tt.wantOrders {
+				t.Fatalf("got %d orders, want %d", len(placed.orders), tt.wantOrders)
+			}
+			if got := len(broker.Messages(messaging.DeadLetterTopic(events.TopicCartEvents))); got != tt.wantDLQ {
+				t.Errorf("got %d dead letters, want %d", got, tt.wantDLQ)
+			}
+
+			for id, order := range placed.orders {
+				if want := model.CheckoutOrderID(encoded.Envelope.ID); id != want {
+					t.Errorf("order ID = %s, want %s", id, want)
+				}
+				if order.UserID != "user-1" || order.Currency != "TWD" {
+					t.Errorf("order = %s %s, want user-1 TWD", order.UserID, order.Currency)
+				}
+				// 台灣為內含稅，總金額不變
+				if order.TotalPrice != money.New(99999, "TWD") {
+					t.Errorf("total = %v, want TWD 999.99", order.TotalPrice)
+				}
+				if order.TaxTotal != money.New(4762, "TWD") || !order.TaxInclusive {
+					t.Errorf("tax = %v inclusive %v, want TWD 47.62 inclusive", order.TaxTotal, order.TaxInclusive)
+				}
+			}
+		})
+	}
+}
+
+func TestCheckoutLegacyMessages(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	taxes, err := tax.Load("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	broker := messaging.NewMemoryBroker(nil)
+	defer broker.Close()
+
+	placed := &placedOrders{}
+	checkout := &checkoutConsumer{
+		taxes: taxes,
+		shipping: func(ctx context.Context, userID, addressID string, given *model.ShippingInfo) (model.ShippingInfo, error) {
+			return model.ShippingInfo{FullName: "Alice", AddressLine1: "1 Main St", City: "Taipei", Country: "TW"}, nil
+		},
+		place: placed.place,
+	}
+	mux := events.NewMux(nil)
+	events.Handle(mux, checkout.handle)
+	if err := messaging.SubscribeEvents(ctx, broker, events.TopicCartEvents, "order-service", mux); err != nil {
+		t.Fatal(err)
+	}
+
+	// 沒有信封與事件ID的舊版 CHECKOUT 訊息，第一則重複投遞一次
+	messages := []string{
+		`{"event_type":"CHECKOUT","user_id":"user-1","cart_id":"cart-1","items":[{"product_id":"p1","quantity":1}],"timestamp":"2026-03-01T00:00:00Z"}`,
+		`{"event_type":"CHECKOUT","user_id":"user-1","cart_id":"cart-1","items":[{"product_id":"p1","quantity":1}],"timestamp":"2026-03-01T00:00:00Z"}`,
+		`{"event_type":"CHECKOUT","user_id":"user-2","cart_id":"cart-2","items":[{"product_id":"p2","quantity":2}],"timestamp":"2026-03-01T00:05:00Z"}`,
+	}
+	for _, message := range messages {
+		if err := broker.PublishMessage(ctx, events.TopicCartEvents, "", []byte(message)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := broker.WaitIdle(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if placed.calls != 3 {
+		t.Errorf("place called %d times, want 3", placed.calls)
+	}
+	if len(placed.orders) != 2 {
+		t.Fatalf("got %d orders, want one per distinct legacy message", len(placed.orders))
+	}
+	users := map[string]bool{}
+	for _, order := range placed.orders {
+		users[order.UserID] = true
+	}
+	if !users["user-1"] || !users["user-2"] {
+		t.Errorf("orders for %v, want user-1 and user-2", users)
+	}
+	if got := len(broker.Messages(messaging.DeadLetterTopic(events.TopicCartEvents))); got != 0 {
+		t.Errorf("got %d dead letters, want 0", got)
+	}
+}
@@ -3,27 +3,50 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
-	"os"
+	"strings"
+	"time"
 
 	"github.com/arrontsai/ecommerce/pkg/config"
+	"github.com/arrontsai/ecommerce/pkg/events"
 	"github.com/arrontsai/ecommerce/pkg/logger"
 	"github.com/arrontsai/ecommerce/pkg/messaging"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/pkg/money/moneypb"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
+	"github.com/arrontsai/ecommerce/pkg/tax"
+	accountpb "github.com/arrontsai/ecommerce/services/auth/proto/pb"
 	"github.com/arrontsai/ecommerce/services/order/model"
 	"github.com/arrontsai/ecommerce/services/order/proto/pb"
 	"github.com/arrontsai/ecommerce/services/order/repository"
+	inventorypb "github.com/arrontsai/ecommerce/services/product/proto/pb"
 	"github.com/jmoiron/sqlx"
 	_ "github.com/lib/pq"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// grpcActor 透過gRPC接口更新訂單狀態時記錄的操作者
+//
+// gRPC接口只供內部服務呼叫且沒有呼叫端身分，請求中的 actor 不予採用，
+// 避免狀態歷史被偽造。
+const grpcActor = "order-grpc"
+
 // orderServer 實現訂單服務的gRPC接口
 type orderServer struct {
 	pb.UnimplementedOrderServiceServer
-	db *sqlx.DB
+	db        *sqlx.DB
+	repo      *repository.OrderRepository
+	inventory inventorypb.InventoryServiceClient
+	accounts  accountpb.AccountServiceClient
+	taxes     *tax.Calculator
 }
 
 func main() {
@@ -50,17 +73,54 @@ func main() {
 	}
 	defer pgClient.Close()
 
-	// 初始化Kafka消費者
-	kafkaConsumer, err := messaging.NewKafkaClient(cfg.KafkaBrokers, appLogger.Logger)
+	// 初始化消息代理 (KAFKA_BROKERS=memory:// 時使用記憶體代理)
+	broker, err := messaging.NewBroker(cfg.KafkaBrokers, appLogger.Logger)
+	if err != nil {
+		appLogger.Fatal("無法初始化消息代理:", zap.Error(err))
+	}
+	defer broker.Close()
+
+	// 連接產品服務的庫存預留gRPC接口
+	productConn, err := grpc.Dial(cfg.ProductGrpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		appLogger.Fatal("無法連接產品服務:", zap.Error(err))
+	}
+	defer productConn.Close()
+
+	// 連接認證服務，以地址簿中的地址作為收件資訊
+	authConn, err := grpc.Dial(cfg.AuthGrpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		appLogger.Fatal("無法連接認證服務:", zap.Error(err))
+	}
+	defer authConn.Close()
+
+	// 載入稅率表，TAX_RULES_FILE 未設定時使用內建稅率
+	taxes, err := tax.Load(cfg.TaxRulesFile)
 	if err != nil {
-		appLogger.Fatal("無法初始化Kafka消費者:", zap.Error(err))
+		appLogger.Fatal("無法載入稅率表:", zap.Error(err))
 	}
 
 	// 創建訂單服務
-	server := &orderServer{db: pgClient}
+	server := &orderServer{
+		db:        pgClient,
+		repo:      repository.NewOrderRepo(pgClient),
+		inventory: inventorypb.NewInventoryServiceClient(productConn),
+		accounts:  accountpb.NewAccountServiceClient(authConn),
+		taxes:     taxes,
+	}
+
+	// 啟動外寄事件轉發器，將 outbox 中的訂單事件發布到Kafka
+	relay := outbox.NewRelay(server.repo.Outbox(), broker, appLogger.Logger)
+	go relay.Run(context.Background())
 
 	// 訂閱Kafka主題
-	go subscribeToCartEvents(kafkaConsumer, server)
+	go subscribeToCartEvents(broker, server)
+	go subscribeToPaymentEvents(broker, server)
+	go subscribeToFulfillmentEvents(broker, server)
+	go subscribeToPrivacyRequests(broker, server)
+
+	// 取消超過付款時限的訂單
+	go sweepPaymentTimeouts(server)
 
 	// 啟動gRPC伺服器
 	lis, err := net.Listen("tcp", ":50051")
@@ -77,151 +137,546 @@ func main() {
 	}
 }
 
-// CreateOrder 實現創建訂單的gRPC方法
+// CreateOrder 實現創建訂單的gRPC方法，所有商品的單價需為相同幣別
 func (s *orderServer) CreateOrder(ctx context.Context, req *pb.CreateOrderRequest) (*pb.OrderResponse, error) {
-	// 生成訂單ID
-	orderID := fmt.Sprintf("%x", os.Getpid())
+	currency := money.DefaultCurrency
+	items := make([]model.OrderItem, 0, len(req.Items))
+	for i, item := range req.Items {
+		price := item.Price.ToMoney()
+		if err := price.Validate(); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "商品 %s 的單價無效: %v", item.ProductId, err)
+		}
+		if i == 0 {
+			currency = price.Currency
+		}
+		orderItem := model.NewOrderItem(item.ProductId, "", int(item.Quantity), price)
+		orderItem.TaxCategory = item.TaxCategory
+		items = append(items, orderItem)
+	}
 
-	// 開始資料庫事務
-	tx, err := s.db.BeginTxx(ctx, nil)
-	if err != nil {
-		return nil, fmt.Errorf("無法開始事務: %v", err)
+	var given *model.ShippingInfo
+	if req.ShippingInfo != nil {
+		info := fromShippingInfoMessage(req.ShippingInfo)
+		given = &info
 	}
-	
-	// 計算總金額
-	var totalAmount float64
-	for _, item := range req.Items {
-		totalAmount += float64(item.Price) * float64(item.Quantity)
+	shipping, err := s.resolveShipping(ctx, req.UserId, req.AddressId, given)
+	if err != nil {
+		if errors.Is(err, errAddressNotFound) {
+			return nil, status.Error(codes.NotFound, err.Error())
+		}
+		return nil, status.Errorf(codes.Unavailable, "無法取得收件地址: %v", err)
 	}
-	
-	// 插入訂單主記錄
-	_, err = tx.ExecContext(ctx,
-		`INSERT INTO orders (id, user_id, total_amount, status, created_at) 
-		 VALUES ($1, $2, $3, $4, NOW())`,
-		orderID, req.UserId, totalAmount, "PENDING")
+
+	order, err := model.NewOrder(req.UserId, currency, items)
 	if err != nil {
-		tx.Rollback()
-		return nil, fmt.Errorf("無法創建訂單: %v", err)
+		return nil, status.Error(codes.InvalidArgument, err.Error())
 	}
-	
-	// 插入訂單項目
-	for _, item := range req.Items {
-		_, err = tx.ExecContext(ctx,
-			`INSERT INTO order_items (order_id, product_id, quantity, price) 
-			 VALUES ($1, $2, $3, $4)`,
-			orderID, item.ProductId, item.Quantity, item.Price)
-		if err != nil {
-			tx.Rollback()
-			return nil, fmt.Errorf("無法創建訂單項目: %v", err)
-		}
+	order.PaymentMethod = req.PaymentMethod
+	order.ShippingInfo = shipping
+	if err := order.ApplyTax(s.taxes, order.CreatedAt); err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "無法計算稅額: %v", err)
 	}
-	
-	// 提交事務
-	if err = tx.Commit(); err != nil {
-		return nil, fmt.Errorf("無法提交事務: %v", err)
+
+	if err := s.placeOrder(ctx, order); err != nil {
+		if errors.Is(err, errInsufficientStock) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "無法創建訂單: %v", err)
 	}
-	
+
 	return &pb.OrderResponse{
-		OrderId: orderID,
-		Status:  "CREATED",
+		OrderId: order.ID,
+		Status:  string(order.Status),
 	}, nil
 }
 
-// GetOrder 實現獲取訂單的gRPC方法
-func (s *orderServer) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.OrderDetailResponse, error) {
-	// 從數據庫獲取訂單
-	var order struct {
-		ID          string  `db:"order_id"`
-		UserID      string  `db:"user_id"`
-		TotalAmount float64 `db:"total_price"`
-		Status      string  `db:"status"`
+// errInsufficientStock 表示預留庫存時庫存不足
+var errInsufficientStock = errors.New("庫存不足")
+
+// errAddressNotFound 表示指定的地址不在用戶的地址簿中
+var errAddressNotFound = errors.New("收件地址不存在")
+
+// resolveShipping 決定訂單的收件資訊：直接填寫的資訊優先，其次為指定的地址簿地址，
+// 兩者皆未提供時使用預設收件地址；沒有預設地址的訂單不帶收件資訊
+func (s *orderServer) resolveShipping(ctx context.Context, userID, addressID string, given *model.ShippingInfo) (model.ShippingInfo, error) {
+	if given != nil && !given.IsZero() {
+		return *given, nil
 	}
 
-	err := s.db.Get(&order, "SELECT order_id, user_id, total_price, status FROM orders WHERE order_id = $1", req.OrderId)
+	address, err := s.accounts.GetAddress(ctx, &accountpb.GetAddressRequest{UserId: userID, AddressId: addressID})
 	if err != nil {
-		return nil, fmt.Errorf("獲取訂單失敗: %w", err)
+		if status.Code(err) == codes.NotFound {
+			if addressID == "" {
+				return model.ShippingInfo{}, nil
+			}
+			return model.ShippingInfo{}, fmt.Errorf("%w: %s", errAddressNotFound, addressID)
+		}
+		return model.ShippingInfo{}, err
 	}
 
-	// 獲取訂單項目
-	var items []struct {
-		ProductID   string  `db:"product_id"`
-		ProductName string  `db:"product_name"`
-		Quantity    int32   `db:"quantity"`
-		UnitPrice   float64 `db:"unit_price"`
-	}
+	return model.ShippingInfo{
+		FullName:     address.FullName,
+		AddressLine1: address.AddressLine1,
+		AddressLine2: address.AddressLine2,
+		City:         address.City,
+		State:        address.State,
+		PostalCode:   address.PostalCode,
+		Country:      address.Country,
+		PhoneNumber:  address.PhoneNumber,
+	}, nil
+}
 
-	err = s.db.Select(&items, "SELECT product_id, product_name, quantity, unit_price FROM order_items WHERE order_id = $1", req.OrderId)
+// placeOrder 先為訂單預留庫存再寫入訂單，避免超賣
+func (s *orderServer) placeOrder(ctx context.Context, order *model.Order) error {
+	// 訂單已建立時不再預留庫存，重複投遞的結帳事件視為成功
+	existing, err := s.repo.GetOrder(ctx, order.ID)
 	if err != nil {
-		return nil, fmt.Errorf("獲取訂單項目失敗: %w", err)
+		return fmt.Errorf("查詢訂單失敗: %w", err)
+	}
+	if existing != nil {
+		log.Printf("訂單 %s 已建立，略過重複的請求", order.ID)
+		return nil
 	}
 
-	// 轉換為gRPC響應格式
-	pbItems := make([]*pb.OrderItem, 0, len(items))
-	for _, item := range items {
-		pbItems = append(pbItems, &pb.OrderItem{
+	reserveReq := &inventorypb.ReserveStockRequest{OrderId: order.ID}
+	for _, item := range order.Items {
+		reserveReq.Items = append(reserveReq.Items, &inventorypb.ReservationItem{
 			ProductId: item.ProductID,
-			Quantity:  item.Quantity,
-			Price:     float32(item.UnitPrice),
+			Quantity:  int32(item.Quantity),
 		})
 	}
-	
-	return &pb.OrderDetailResponse{
-		OrderId:     order.ID,
-		UserId:      order.UserID,
-		TotalAmount: float32(order.TotalAmount),
-		Status:      order.Status,
-		Items:       pbItems,
-	}, nil
+
+	if _, err := s.inventory.ReserveStock(ctx, reserveReq); err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			return fmt.Errorf("%w: %s", errInsufficientStock, status.Convert(err).Message())
+		}
+		return fmt.Errorf("預留庫存失敗: %w", err)
+	}
+
+	// 訂單、項目與訂單建立事件在同一交易中寫入；預留以訂單ID冪等，
+	// 並行的重複請求只會有一筆寫入成功，另一筆沿用相同的預留
+	created, err := s.repo.CreateOrder(ctx, order)
+	if err != nil {
+		// 訂單寫入失敗時釋放預留；若釋放失敗則交由預留逾時回收
+		if _, releaseErr := s.inventory.ReleaseReservation(ctx, &inventorypb.ReservationRequest{
+			OrderId: order.ID,
+			Reason:  "訂單建立失敗",
+		}); releaseErr != nil {
+			log.Printf("釋放預留庫存失敗: %v", releaseErr)
+		}
+		return err
+	}
+	if !created {
+		log.Printf("訂單 %s 已建立，略過重複的請求", order.ID)
+	}
+
+	return nil
+}
+
+// GetOrder 實現獲取訂單的gRPC方法
+func (s *orderServer) GetOrder(ctx context.Context, req *pb.GetOrderRequest) (*pb.OrderDetailResponse, error) {
+	// 從數據庫獲取訂單及其項目
+	order, err := s.repo.GetOrder(ctx, req.OrderId)
+	if err != nil {
+		return nil, fmt.Errorf("獲取訂單失敗: %w", err)
+	}
+	if order == nil {
+		return nil, status.Errorf(codes.NotFound, "訂單不存在: %s", req.OrderId)
+	}
+
+	return toOrderDetailResponse(*order), nil
 }
 
 // UpdateOrderStatus 實現更新訂單狀態的gRPC方法
 func (s *orderServer) UpdateOrderStatus(ctx context.Context, req *pb.UpdateOrderStatusRequest) (*pb.OrderResponse, error) {
-	// 更新訂單狀態
-	_, err := s.db.Exec("UPDATE orders SET status = $1 WHERE order_id = $2", req.Status, req.OrderId)
+	next := model.OrderStatus(strings.ToUpper(req.Status))
+	if !next.IsValid() {
+		return nil, status.Errorf(codes.InvalidArgument, "無效的訂單狀態: %s", req.Status)
+	}
+
+	// 依狀態轉換圖更新訂單狀態並記錄歷史
+	change, err := s.repo.UpdateStatus(ctx, req.OrderId, next, grpcActor, req.Reason)
 	if err != nil {
-		return nil, fmt.Errorf("更新訂單狀態失敗: %w", err)
+		switch {
+		case errors.Is(err, repository.ErrOrderNotFound):
+			return nil, status.Errorf(codes.NotFound, "訂單不存在: %s", req.OrderId)
+		case errors.Is(err, model.ErrIllegalTransition):
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "更新訂單狀態失敗: %v", err)
 	}
 
 	return &pb.OrderResponse{
 		OrderId: req.OrderId,
-		Status:  req.Status,
+		Status:  string(change.ToStatus),
+	}, nil
+}
+
+// GetOrderHistory 實現獲取訂單狀態歷史的gRPC方法
+func (s *orderServer) GetOrderHistory(ctx context.Context, req *pb.GetOrderHistoryRequest) (*pb.GetOrderHistoryResponse, error) {
+	history, err := s.repo.GetStatusHistory(ctx, req.OrderId)
+	if err != nil {
+		if errors.Is(err, repository.ErrOrderNotFound) {
+			return nil, status.Errorf(codes.NotFound, "訂單不存在: %s", req.OrderId)
+		}
+		return nil, status.Errorf(codes.Internal, "獲取訂單狀態歷史失敗: %v", err)
+	}
+
+	changes := make([]*pb.OrderStatusChange, 0, len(history))
+	for _, change := range history {
+		changes = append(changes, &pb.OrderStatusChange{
+			FromStatus: string(change.FromStatus),
+			ToStatus:   string(change.ToStatus),
+			Actor:      change.Actor,
+			Reason:     change.Reason,
+			ChangedAt:  timestamppb.New(change.ChangedAt),
+		})
+	}
+
+	return &pb.GetOrderHistoryResponse{
+		OrderId: req.OrderId,
+		Changes: changes,
+	}, nil
+}
+
+// ListOrders 實現分頁查詢訂單的gRPC方法
+func (s *orderServer) ListOrders(ctx context.Context, req *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	// 每頁筆數預設 10，超過上限時以 100 筆為準
+	pageSize := int(req.PageSize)
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	minTotal, err := totalBound("min_total", req.MinTotal)
+	if err != nil {
+		return nil, err
+	}
+	maxTotal, err := totalBound("max_total", req.MaxTotal)
+	if err != nil {
+		return nil, err
+	}
+
+	filter := repository.OrderFilter{
+		UserID:   req.UserId,
+		MinTotal: minTotal,
+		MaxTotal: maxTotal,
+		Limit:    pageSize,
+		Cursor:   req.PageToken,
+	}
+
+	for _, st := range req.Statuses {
+		orderStatus := model.OrderStatus(strings.ToUpper(st))
+		if !orderStatus.IsValid() {
+			return nil, status.Errorf(codes.InvalidArgument, "無效的訂單狀態: %s", st)
+		}
+		filter.Statuses = append(filter.Statuses, orderStatus)
+	}
+
+	if req.CreatedFrom != nil {
+		from := req.CreatedFrom.AsTime()
+		filter.CreatedFrom = &from
+	}
+	if req.CreatedTo != nil {
+		to := req.CreatedTo.AsTime()
+		filter.CreatedTo = &to
+	}
+	if filter.CreatedFrom != nil && filter.CreatedTo != nil && !filter.CreatedFrom.Before(*filter.CreatedTo) {
+		return nil, status.Error(codes.InvalidArgument, "created_from 必須早於 created_to")
+	}
+	if filter.MinTotal != nil && filter.MaxTotal != nil {
+		cmp, err := filter.MinTotal.Cmp(*filter.MaxTotal)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "min_total 與 max_total 需為相同幣別: %v", err)
+		}
+		if cmp > 0 {
+			return nil, status.Error(codes.InvalidArgument, "min_total 不可大於 max_total")
+		}
+	}
+
+	page, err := s.repo.ListOrders(ctx, filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		return nil, status.Errorf(codes.Internal, "查詢訂單列表失敗: %v", err)
+	}
+
+	orders := make([]*pb.OrderDetailResponse, 0, len(page.Orders))
+	for _, order := range page.Orders {
+		orders = append(orders, toOrderDetailResponse(order))
+	}
+
+	return &pb.ListOrdersResponse{
+		Orders:        orders,
+		NextPageToken: page.NextCursor,
 	}, nil
 }
 
+// totalBound 轉換訂單金額的查詢條件，未提供時回傳 nil
+func totalBound(name string, value *moneypb.Money) (*money.Money, error) {
+	if value == nil {
+		return nil, nil
+	}
+	total := value.ToMoney()
+	if err := total.Validate(); err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "無效的 %s: %v", name, err)
+	}
+	return &total, nil
+}
+
+// toOrderDetailResponse 將訂單模型轉換為gRPC響應格式
+func toOrderDetailResponse(order model.Order) *pb.OrderDetailResponse {
+	items := make([]*pb.OrderItem, 0, len(order.Items))
+	for _, item := range order.Items {
+		items = append(items, &pb.OrderItem{
+			ProductId:   item.ProductID,
+			Quantity:    int32(item.Quantity),
+			Price:       moneypb.FromMoney(item.UnitPrice),
+			TaxCategory: item.TaxCategory,
+		})
+	}
+
+	taxLines := make([]*pb.OrderTaxLine, 0, len(order.TaxLines))
+	for _, line := range order.TaxLines {
+		taxLines = append(taxLines, &pb.OrderTaxLine{
+			ProductId:    line.ProductID,
+			Category:     line.Category,
+			Jurisdiction: line.Jurisdiction,
+			Name:         line.Name,
+			Rate:         line.Rate,
+			Inclusive:    line.Inclusive,
+			Taxable:      moneypb.FromMoney(line.Taxable),
+			Amount:       moneypb.FromMoney(line.Amount),
+		})
+	}
+
+	discounts := make([]*pb.OrderDiscount, 0, len(order.Discounts))
+	for _, discount := range order.Discounts {
+		discounts = append(discounts, &pb.OrderDiscount{
+			PromotionId: discount.PromotionID,
+			Code:        discount.Code,
+			Type:        discount.Type,
+			Description: discount.Description,
+			Amount:      moneypb.FromMoney(discount.Amount),
+		})
+	}
+
+	return &pb.OrderDetailResponse{
+		OrderId:     order.ID,
+		UserId:      order.UserID,
+		TotalAmount: moneypb.FromMoney(order.TotalPrice),
+		Status:      string(order.Status),
+		Items:       items,
+		PaymentId:   order.PaymentID,
+		ShippingInfo: &pb.ShippingInfo{
+			FullName:     order.ShippingInfo.FullName,
+			AddressLine1: order.ShippingInfo.AddressLine1,
+			AddressLine2: order.ShippingInfo.AddressLine2,
+			City:         order.ShippingInfo.City,
+			State:        order.ShippingInfo.State,
+			PostalCode:   order.ShippingInfo.PostalCode,
+			Country:      order.ShippingInfo.Country,
+			PhoneNumber:  order.ShippingInfo.PhoneNumber,
+		},
+		Discounts:     discounts,
+		DiscountTotal: moneypb.FromMoney(order.DiscountTotal),
+		FreeShipping:  order.FreeShipping,
+		Currency:      order.Currency,
+		TaxLines:      taxLines,
+		TaxTotal:      moneypb.FromMoney(order.TaxTotal),
+		TaxInclusive:  order.TaxInclusive,
+		TaxVersion:    order.TaxVersion,
+	}
+}
+
+// fromShippingInfoMessage 將gRPC收件資訊轉換為訂單模型
+func fromShippingInfoMessage(info *pb.ShippingInfo) model.ShippingInfo {
+	return model.ShippingInfo{
+		FullName:     info.FullName,
+		AddressLine1: info.AddressLine1,
+		AddressLine2: info.AddressLine2,
+		City:         info.City,
+		State:        info.State,
+		PostalCode:   info.PostalCode,
+		Country:      info.Country,
+		PhoneNumber:  info.PhoneNumber,
+	}
+}
+
 // subscribeToCartEvents 訂閱購物車事件
-func subscribeToCartEvents(consumer *messaging.KafkaClient, server *orderServer) {
-	// 處理消息的回調函數
-	handler := func(msg []byte) error {
-		var event struct {
-			EventType string          `json:"event_type"`
-			UserID    string          `json:"user_id"`
-			Items     []model.OrderItem `json:"items"`
+func subscribeToCartEvents(consumer messaging.Broker, server *orderServer) {
+	mux := events.NewMux(nil)
+
+	// 購物車結帳後建立訂單
+	checkout := &checkoutConsumer{
+		taxes:    server.taxes,
+		shipping: server.resolveShipping,
+		place:    server.placeOrder,
+	}
+	events.Handle(mux, checkout.handle)
+
+	// 啟動消費
+	ctx := context.Background()
+	err := messaging.SubscribeEvents(ctx, consumer, events.TopicCartEvents, "order-service", mux)
+	if err != nil {
+		log.Fatalf("無法消費消息: %v", err)
+	}
+}
+
+// subscribeToPaymentEvents 訂閱支付事件，推進訂單與支付的 saga
+func subscribeToPaymentEvents(consumer messaging.Broker, server *orderServer) {
+	mux := events.NewMux(nil)
+
+	// 付款成功後訂單標記為已支付，訂單狀態變更事件會讓產品服務扣除預留庫存
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.PaymentSucceeded) error {
+		state, err := server.repo.HandlePaymentSucceeded(ctx, event)
+		if err != nil {
+			return sagaError(err)
 		}
+		log.Printf("訂單 %s 收到付款 %s，saga 狀態: %s", event.OrderID, event.PaymentID, state)
+		return nil
+	})
 
-		err := json.Unmarshal(msg, &event)
+	// 自動付款失敗後取消訂單，訂單狀態變更事件會讓產品服務釋放預留庫存
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.PaymentFailed) error {
+		state, err := server.repo.HandlePaymentFailed(ctx, event)
 		if err != nil {
-			log.Printf("解析消息失敗: %v", err)
-			return err
+			return sagaError(err)
+		}
+		log.Printf("訂單 %s 付款 %s 失敗 (%s)，saga 狀態: %s", event.OrderID, event.PaymentID, event.FailureCode, state)
+		return nil
+	})
+
+	// 啟動消費
+	ctx := context.Background()
+	err := messaging.SubscribeEvents(ctx, consumer, events.TopicPaymentEvents, "order-service", mux)
+	if err != nil {
+		log.Fatalf("無法消費消息: %v", err)
+	}
+}
+
+// subscribeToFulfillmentEvents 訂閱出貨事件：第一次出貨時訂單標記為已發貨，所有出貨送達後標記為已送達
+func subscribeToFulfillmentEvents(consumer messaging.Broker, server *orderServer) {
+	mux := events.NewMux(nil)
+
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.ShipmentCreated) error {
+		reason := fmt.Sprintf("出貨 %s (%s %s)", event.ShipmentID, event.Carrier, event.TrackingNumber)
+		return advanceOrder(ctx, server, event.OrderID, model.StatusShipped, reason)
+	})
+
+	// 分批出貨時只有最後一筆出貨送達才完成訂單
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.ShipmentDelivered) error {
+		if !event.AllDelivered {
+			return nil
 		}
+		reason := fmt.Sprintf("出貨 %s 已送達", event.ShipmentID)
+		return advanceOrder(ctx, server, event.OrderID, model.StatusDelivered, reason)
+	})
+
+	// 啟動消費
+	ctx := context.Background()
+	err := messaging.SubscribeEvents(ctx, consumer, events.TopicFulfillmentEvents, "order-service", mux)
+	if err != nil {
+		log.Fatalf("無法消費消息: %v", err)
+	}
+}
 
-		// 根據事件類型處理不同的邏輯
-		if event.EventType == "CHECKOUT" {
-			// 創建訂單
-			err := repository.NewOrderRepo(server.db).CreateOrder(event.UserID, event.Items)
+// advanceOrder 將訂單轉換為出貨服務回報的狀態，訂單已在該狀態或之後時不做變更
+func advanceOrder(ctx context.Context, server *orderServer, orderID string, to model.OrderStatus, reason string) error {
+	_, err := server.repo.UpdateStatus(ctx, orderID, to, "fulfillment-service", reason)
+	if errors.Is(err, model.ErrIllegalTransition) {
+		// 後續的分批出貨或重複投遞的事件
+		order, getErr := server.repo.GetOrder(ctx, orderID)
+		if getErr != nil {
+			return getErr
+		}
+		if order != nil && (order.Status == to || order.Status == model.StatusDelivered) {
+			return nil
+		}
+	}
+	if err != nil {
+		return sagaError(err)
+	}
+	log.Printf("訂單 %s 狀態更新為 %s: %s", orderID, to, reason)
+	return nil
+}
+
+// subscribeToPrivacyRequests 處理用戶資料匯出與刪除請求，並將結果回報認證服務
+func subscribeToPrivacyRequests(broker messaging.Broker, server *orderServer) {
+	mux := events.NewMux(nil)
+
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.DataSubjectRequested) error {
+		result := events.DataSubjectCompleted{
+			RequestID: event.RequestID,
+			UserID:    event.UserID,
+			Kind:      event.Kind,
+			Service:   model.EventProducer,
+		}
+
+		switch event.Kind {
+		case events.DataSubjectExport:
+			orders, err := server.repo.ExportUserOrders(ctx, event.UserID)
 			if err != nil {
-				log.Printf("創建訂單失敗: %v", err)
 				return err
 			}
-			log.Printf("已為用戶 %s 創建訂單", event.UserID)
+			data, err := json.Marshal(orders)
+			if err != nil {
+				return err
+			}
+			result.Data = data
+		case events.DataSubjectErasure:
+			// 訂單的財務資料需保留，只假名化收件資訊；未完成的訂單仍需出貨，回報失敗由用戶稍後重新申請
+			updated, err := server.repo.PseudonymizeUser(ctx, event.UserID)
+			if err != nil {
+				if !errors.Is(err, repository.ErrOpenOrders) {
+					return err
+				}
+				result.Error = err.Error()
+			} else {
+				log.Printf("已假名化用戶 %s 的 %d 筆訂單", event.UserID, updated)
+			}
+		default:
+			result.Error = "未知的請求種類: " + event.Kind
 		}
 
-		return nil
-	}
+		result.CompletedAt = time.Now()
+		return messaging.PublishEvent(ctx, broker, model.EventProducer, result)
+	})
 
 	// 啟動消費
 	ctx := context.Background()
-	err := consumer.ConsumeMessages(ctx, "cart_events", "order-service", handler)
+	err := messaging.SubscribeEvents(ctx, broker, events.TopicPrivacyRequests, "order-service", mux)
 	if err != nil {
 		log.Fatalf("無法消費消息: %v", err)
 	}
 }
+
+// sagaError 訂單或 saga 不存在與非法的狀態轉換重試無益，直接送往死信主題
+func sagaError(err error) error {
+	if errors.Is(err, repository.ErrOrderNotFound) ||
+		errors.Is(err, repository.ErrSagaNotFound) ||
+		errors.Is(err, model.ErrIllegalTransition) {
+		return messaging.Fatal(err)
+	}
+	return err
+}
+
+// sweepPaymentTimeouts 定期取消超過付款時限仍未付款的訂單
+func sweepPaymentTimeouts(server *orderServer) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		expired, err := server.repo.ExpirePaymentSagas(context.Background(), time.Now(), 100)
+		if err != nil {
+			log.Printf("取消付款逾時訂單失敗: %v", err)
+			continue
+		}
+		if expired > 0 {
+			log.Printf("已取消 %d 筆付款逾時訂單", expired)
+		}
+	}
+}
@@ -1,9 +1,16 @@
 package model
 
 import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/pkg/tax"
 )
 
 // OrderStatus 訂單狀態枚舉
@@ -17,40 +24,320 @@ const (
 	StatusCancelled OrderStatus = "CANCELLED" // 已取消
 )
 
+// ErrIllegalTransition 訂單狀態轉換不在允許的轉換圖中
+var ErrIllegalTransition = errors.New("不允許的訂單狀態轉換")
+
+// statusTransitions 訂單狀態轉換圖，DELIVERED 與 CANCELLED 為終止狀態
+var statusTransitions = map[OrderStatus][]OrderStatus{
+	StatusPending:   {StatusPaid, StatusCancelled},
+	StatusPaid:      {StatusShipped, StatusCancelled},
+	StatusShipped:   {StatusDelivered},
+	StatusDelivered: {},
+	StatusCancelled: {},
+}
+
+// IsValid 檢查是否為已定義的訂單狀態
+func (s OrderStatus) IsValid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo 檢查是否允許從目前狀態轉換到指定狀態
+func (s OrderStatus) CanTransitionTo(next OrderStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition 驗證狀態轉換，不允許時回傳包裝 ErrIllegalTransition 的錯誤
+func ValidateTransition(from, to OrderStatus) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
+
+// StatusChange 訂單狀態轉換紀錄
+type StatusChange struct {
+	OrderID    string      `json:"order_id" db:"order_id"`
+	FromStatus OrderStatus `json:"from_status" db:"from_status"`
+	ToStatus   OrderStatus `json:"to_status" db:"to_status"`
+	Actor      string      `json:"actor" db:"actor"`
+	Reason     string      `json:"reason" db:"reason"`
+	ChangedAt  time.Time   `json:"changed_at" db:"changed_at"`
+}
+
 // Order 訂單模型
 type Order struct {
 	ID         string      `json:"id" bson:"_id"`
 	UserID     string      `json:"user_id" bson:"user_id"`
 	Items      []OrderItem `json:"items" bson:"items"`
-	TotalPrice float64     `json:"total_price" bson:"total_price"`
+	TotalPrice money.Money `json:"total_price" bson:"total_price"`
 	Status     OrderStatus `json:"status" bson:"status"`
-	CreatedAt  time.Time   `json:"created_at" bson:"created_at"`
-	UpdatedAt  time.Time   `json:"updated_at" bson:"updated_at"`
+	// Currency 訂單的幣別，商品、折扣與總金額皆以此幣別計價
+	Currency string `json:"currency" bson:"currency"`
+	// PaymentMethod 建立訂單時選擇的付款方式，空白表示由用戶自行付款
+	PaymentMethod string     `json:"payment_method,omitempty" bson:"payment_method,omitempty"`
+	PaymentID     string     `json:"payment_id,omitempty" bson:"payment_id,omitempty"`
+	PaidAt        *time.Time `json:"paid_at,omitempty" bson:"paid_at,omitempty"`
+	// ShippingInfo 建立訂單時的收件資訊副本，之後修改或刪除地址簿不影響訂單
+	ShippingInfo ShippingInfo `json:"shipping_info" bson:"shipping_info"`
+	// Discounts 結帳時套用的優惠折扣，TotalPrice 已扣除 DiscountTotal
+	Discounts     Discounts   `json:"discounts" bson:"discounts"`
+	DiscountTotal money.Money `json:"discount_total" bson:"discount_total"`
+	FreeShipping  bool        `json:"free_shipping" bson:"free_shipping"`
+	// TaxLines 每項商品的稅額，TaxInclusive 時稅額已含於售價，否則 TotalPrice 已加上 TaxTotal；
+	// TaxVersion 為計稅時使用的稅率表版本
+	TaxLines     TaxLines    `json:"tax_lines" bson:"tax_lines"`
+	TaxTotal     money.Money `json:"tax_total" bson:"tax_total"`
+	TaxInclusive bool        `json:"tax_inclusive" bson:"tax_inclusive"`
+	TaxVersion   string      `json:"tax_version,omitempty" bson:"tax_version,omitempty"`
+	CreatedAt    time.Time   `json:"created_at" bson:"created_at"`
+	UpdatedAt    time.Time   `json:"updated_at" bson:"updated_at"`
+}
+
+// Discount 訂單的優惠折扣
+type Discount struct {
+	PromotionID string      `json:"promotion_id"`
+	Code        string      `json:"code"`
+	Type        string      `json:"type"`
+	Description string      `json:"description,omitempty"`
+	Amount      money.Money `json:"amount"`
+}
+
+// Discounts 訂單的優惠折扣，以 JSONB 存放於 orders 資料表
+type Discounts []Discount
+
+// Value 實作 driver.Valuer，寫入 JSONB 欄位
+func (d Discounts) Value() (driver.Value, error) {
+	if d == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]Discount(d))
+}
+
+// Scan 實作 sql.Scanner，讀取 JSONB 欄位
+func (d *Discounts) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*d = Discounts{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, (*[]Discount)(d))
+	case string:
+		return json.Unmarshal([]byte(v), (*[]Discount)(d))
+	}
+	return fmt.Errorf("無法讀取優惠折扣: %T", src)
+}
+
+// TaxLines 訂單的商品稅額，以 JSONB 存放於 orders 資料表
+type TaxLines []tax.Line
+
+// Value 實作 driver.Valuer，寫入 JSONB 欄位
+func (t TaxLines) Value() (driver.Value, error) {
+	if t == nil {
+		return []byte("[]"), nil
+	}
+	return json.Marshal([]tax.Line(t))
+}
+
+// Scan 實作 sql.Scanner，讀取 JSONB 欄位
+func (t *TaxLines) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*t = TaxLines{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, (*[]tax.Line)(t))
+	case string:
+		return json.Unmarshal([]byte(v), (*[]tax.Line)(t))
+	}
+	return fmt.Errorf("無法讀取稅額: %T", src)
+}
+
+// ShippingInfo 訂單收件資訊，以 JSONB 存放於 orders 資料表
+type ShippingInfo struct {
+	FullName     string `json:"full_name,omitempty"`
+	AddressLine1 string `json:"address_line_1,omitempty"`
+	AddressLine2 string `json:"address_line_2,omitempty"`
+	City         string `json:"city,omitempty"`
+	State        string `json:"state,omitempty"`
+	PostalCode   string `json:"postal_code,omitempty"`
+	Country      string `json:"country,omitempty"`
+	PhoneNumber  string `json:"phone_number,omitempty"`
+}
+
+// IsZero 檢查是否沒有任何收件資訊
+func (s ShippingInfo) IsZero() bool {
+	return s == ShippingInfo{}
+}
+
+// Value 實作 driver.Valuer，寫入 JSONB 欄位
+func (s ShippingInfo) Value() (driver.Value, error) {
+	return json.Marshal(s)
 }
 
-// OrderItem 訂單商品項目
+// Scan 實作 sql.Scanner，讀取 JSONB 欄位
+func (s *ShippingInfo) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*s = ShippingInfo{}
+		return nil
+	case []byte:
+		return json.Unmarshal(v, s)
+	case string:
+		return json.Unmarshal([]byte(v), s)
+	}
+	return fmt.Errorf("無法讀取收件資訊: %T", src)
+}
+
+// OrderItem 訂單商品項目，TaxCategory 為商品的稅別，空白表示一般稅率
 type OrderItem struct {
-	ProductID   string  `json:"product_id" bson:"product_id"`
-	ProductName string  `json:"product_name" bson:"product_name"`
-	Quantity    int     `json:"quantity" bson:"quantity"`
-	UnitPrice   float64 `json:"unit_price" bson:"unit_price"`
-	Subtotal    float64 `json:"subtotal" bson:"subtotal"`
+	ProductID   string      `json:"product_id" bson:"product_id"`
+	ProductName string      `json:"product_name" bson:"product_name"`
+	Quantity    int         `json:"quantity" bson:"quantity"`
+	UnitPrice   money.Money `json:"unit_price" bson:"unit_price"`
+	Subtotal    money.Money `json:"subtotal" bson:"subtotal"`
+	TaxCategory string      `json:"tax_category,omitempty" bson:"tax_category,omitempty"`
 }
 
-// NewOrder 創建新訂單
-func NewOrder(userID string, items []OrderItem) *Order {
-	totalPrice := 0.0
-	for _, item := range items {
-		totalPrice += item.Subtotal
+// NewOrderItem 以單價與數量建立訂單項目
+func NewOrderItem(productID, productName string, quantity int, unitPrice money.Money) OrderItem {
+	return OrderItem{
+		ProductID:   productID,
+		ProductName: productName,
+		Quantity:    quantity,
+		UnitPrice:   unitPrice,
+		Subtotal:    unitPrice.Multiply(int64(quantity)),
 	}
+}
 
-	return &Order{
-		ID:         uuid.NewString(),
-		UserID:     userID,
-		Items:      items,
-		TotalPrice: totalPrice,
-		Status:     StatusPending,
-		CreatedAt:  time.Now(),
-		UpdatedAt:  time.Now(),
+// checkoutNamespace 由結帳事件ID推導訂單ID時使用的 UUID 命名空間
+var checkoutNamespace = uuid.MustParse("6f1c9a52-3d4e-4b8a-9c71-2e5f0d8b7a13")
+
+// CheckoutOrderID 由結帳事件的信封ID推導訂單ID，同一事件重複投遞時得到相同的訂單ID
+func CheckoutOrderID(eventID string) string {
+	return uuid.NewSHA1(checkoutNamespace, []byte(eventID)).String()
+}
+
+// NewOrder 創建新訂單，商品的幣別需與訂單相同
+func NewOrder(userID, currency string, items []OrderItem) (*Order, error) {
+	order := &Order{
+		ID:            uuid.NewString(),
+		UserID:        userID,
+		Items:         items,
+		Currency:      currency,
+		Status:        StatusPending,
+		Discounts:     Discounts{},
+		DiscountTotal: money.Zero(currency),
+		TaxLines:      TaxLines{},
+		TaxTotal:      money.Zero(currency),
+		CreatedAt:     time.Now(),
+		UpdatedAt:     time.Now(),
+	}
+
+	subtotal, err := order.Subtotal()
+	if err != nil {
+		return nil, err
+	}
+	order.TotalPrice = subtotal
+	return order, nil
+}
+
+// Subtotal 計算訂單商品的折扣前總金額
+func (o *Order) Subtotal() (money.Money, error) {
+	subtotal := money.Zero(o.Currency)
+	for _, item := range o.Items {
+		var err error
+		if subtotal, err = subtotal.Add(item.Subtotal); err != nil {
+			return money.Money{}, fmt.Errorf("商品 %s: %w", item.ProductID, err)
+		}
 	}
+	return subtotal, nil
 }
+
+// ApplyDiscounts 記錄訂單的優惠折扣並從總金額扣除，總金額不會小於 0
+func (o *Order) ApplyDiscounts(discounts []Discount, freeShipping bool) error {
+	discountTotal := money.Zero(o.Currency)
+	for _, discount := range discounts {
+		var err error
+		if discountTotal, err = discountTotal.Add(discount.Amount); err != nil {
+			return fmt.Errorf("優惠碼 %s: %w", discount.Code, err)
+		}
+	}
+
+	subtotal, err := o.Subtotal()
+	if err != nil {
+		return err
+	}
+	total, err := subtotal.Sub(discountTotal)
+	if err != nil {
+		return err
+	}
+	if total.IsNegative() {
+		total = money.Zero(o.Currency)
+	}
+
+	o.Discounts = append(Discounts{}, discounts...)
+	o.FreeShipping = freeShipping
+	o.DiscountTotal = discountTotal
+	o.TotalPrice = total
+	return nil
+}
+
+// ApplyTax 依收件地址與下單時間 at 的稅率表計算每項商品的稅額，需在 ApplyDiscounts 之後呼叫
+//
+// 非同步結帳的訂單以結帳時間計稅，事件延遲處理時仍使用結帳當下生效的稅率。
+// 訂單折扣依各項商品小計的比例分攤，以折扣後的金額計稅。含稅地區的稅額已含於售價，
+// 總金額不變；未稅地區的稅額加到總金額上。
+func (o *Order) ApplyTax(calculator *tax.Calculator, at time.Time) error {
+	items := make([]tax.Item, 0, len(o.Items))
+	ratios := make([]int64, 0, len(o.Items))
+	var subtotal int64
+	for _, item := range o.Items {
+		items = append(items, tax.Item{ProductID: item.ProductID, Category: item.TaxCategory, Amount: item.Subtotal})
+		ratios = append(ratios, max(item.Subtotal.Amount, 0))
+		subtotal += max(item.Subtotal.Amount, 0)
+	}
+
+	if o.DiscountTotal.IsPositive() && subtotal > 0 {
+		discount, err := o.DiscountTotal.Min(money.New(subtotal, o.Currency))
+		if err != nil {
+			return err
+		}
+		shares, err := discount.Allocate(ratios...)
+		if err != nil {
+			return err
+		}
+		for i := range items {
+			if items[i].Amount, err = items[i].Amount.Sub(shares[i]); err != nil {
+				return fmt.Errorf("商品 %s: %w", items[i].ProductID, err)
+			}
+		}
+	}
+
+	result, err := calculator.Calculate(at, tax.Address{
+		Country: o.ShippingInfo.Country,
+		State:   o.ShippingInfo.State,
+	}, o.Currency, items)
+	if err != nil {
+		return err
+	}
+
+	if !result.Inclusive {
+		if o.TotalPrice, err = o.TotalPrice.Add(result.Total); err != nil {
+			return err
+		}
+	}
+	o.TaxLines = result.Lines
+	o.TaxTotal = result.Total
+	o.TaxInclusive = result.Inclusive
+	o.TaxVersion = result.Version
+	return nil
+}
+
+// EventProducer 訂單服務發布事件時使用的生產者名稱
+const EventProducer = "order-service"
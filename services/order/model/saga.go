@@ -0,0 +1,29 @@
+package model
+
+import "time"
+
+// SagaState 訂單支付 saga 的狀態
+type SagaState string
+
+const (
+	SagaAwaitingPayment SagaState = "AWAITING_PAYMENT" // 等待付款結果
+	SagaCompleted       SagaState = "COMPLETED"        // 付款成功，訂單已支付
+	SagaFailed          SagaState = "FAILED"           // 付款失敗，訂單已取消
+	SagaTimedOut        SagaState = "TIMED_OUT"        // 付款逾時，訂單已取消
+	SagaCancelled       SagaState = "CANCELLED"        // 付款前訂單已被取消
+	SagaCompensated     SagaState = "COMPENSATED"      // 訂單取消後才付款成功，已要求撤銷付款
+)
+
+// PaymentTimeout 訂單等待付款的時限；需短於庫存預留的 TTL (15 分鐘)，
+// 確保逾時取消訂單前庫存不會先被釋放
+const PaymentTimeout = 10 * time.Minute
+
+// OrderSaga 訂單與支付之間的 saga 狀態，與訂單在同一交易中更新
+type OrderSaga struct {
+	OrderID   string    `json:"order_id" db:"order_id"`
+	State     SagaState `json:"state" db:"state"`
+	PaymentID string    `json:"payment_id" db:"payment_id"`
+	Deadline  time.Time `json:"deadline" db:"deadline"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
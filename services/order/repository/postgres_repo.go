@@ -1,63 +1,486 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	
+	"strings"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
 	"github.com/arrontsai/ecommerce/services/order/model"
-	"github.com/google/uuid"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 )
 
 type OrderRepository struct {
-	db *sqlx.DB
+	db     *sqlx.DB
+	outbox *outbox.PostgresStore
 }
 
 func NewOrderRepo(db *sqlx.DB) *OrderRepository {
-	return &OrderRepository{db: db}
+	return &OrderRepository{db: db, outbox: outbox.NewPostgresStore(db)}
+}
+
+// Outbox 回傳訂單服務的外寄事件儲存，供事件轉發器使用
+func (r *OrderRepository) Outbox() *outbox.PostgresStore {
+	return r.outbox
 }
 
-func (r *OrderRepository) CreateOrder(userID string, items []model.OrderItem) error {
+// CreateOrder 在同一交易中寫入訂單、訂單項目、初始狀態紀錄與訂單建立事件
+//
+// 訂單ID已存在時不做任何變更並回傳 false，重複投遞的結帳事件不會建立重複的訂單。
+func (r *OrderRepository) CreateOrder(ctx context.Context, order *model.Order) (bool, error) {
 	// PostgreSQL交易實作
-	tx, err := r.db.Beginx()
-	if err != nil {
-		return fmt.Errorf("開始交易失敗: %w", err)
-	}
-	
-	// 創建訂單
-	orderID := uuid.New().String()
-	totalPrice := 0.0
-	for _, item := range items {
-		totalPrice += item.Subtotal
-	}
-	
-	_, err = tx.Exec(
-		`INSERT INTO orders (order_id, user_id, total_price, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)`,
-		orderID, userID, totalPrice, "PENDING",
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("開始交易失敗: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`INSERT INTO orders (order_id, user_id, total_price, currency, status, payment_method, shipping_info,
+			discounts, discount_total, free_shipping, tax_lines, tax_total, tax_inclusive, tax_version, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $15)
+		ON CONFLICT (order_id) DO NOTHING`,
+		order.ID, order.UserID, order.TotalPrice.Amount, order.Currency, order.Status, order.PaymentMethod, order.ShippingInfo,
+		order.Discounts, order.DiscountTotal.Amount, order.FreeShipping,
+		order.TaxLines, order.TaxTotal.Amount, order.TaxInclusive, order.TaxVersion, order.CreatedAt,
 	)
-	
 	if err != nil {
-		tx.Rollback()
-		return fmt.Errorf("插入訂單失敗: %w", err)
+		return false, fmt.Errorf("插入訂單失敗: %w", err)
+	}
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if inserted == 0 {
+		return false, nil
 	}
-	
+
 	// 插入訂單項目
-	for _, item := range items {
-		_, err = tx.Exec(
-			`INSERT INTO order_items (order_id, product_id, product_name, quantity, unit_price, subtotal)
-			VALUES ($1, $2, $3, $4, $5, $6)`,
-			orderID, item.ProductID, item.ProductName, item.Quantity, item.UnitPrice, item.Subtotal,
+	for _, item := range order.Items {
+		_, err = tx.ExecContext(ctx,
+			`INSERT INTO order_items (order_id, product_id, product_name, quantity, unit_price, subtotal, tax_category)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			order.ID, item.ProductID, item.ProductName, item.Quantity, item.UnitPrice.Amount, item.Subtotal.Amount, item.TaxCategory,
 		)
 		if err != nil {
-			tx.Rollback()
-			return fmt.Errorf("插入訂單項目失敗: %w", err)
+			return false, fmt.Errorf("插入訂單項目失敗: %w", err)
 		}
 	}
-	
+
+	// 記錄初始狀態
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason, changed_at)
+		VALUES ($1, '', $2, $3, $4, $5)`,
+		order.ID, order.Status, "system", "訂單建立", order.CreatedAt,
+	)
+	if err != nil {
+		return false, fmt.Errorf("插入訂單狀態紀錄失敗: %w", err)
+	}
+
+	// 開始等待付款的 saga，逾時未付款時取消訂單
+	if err := r.startSaga(ctx, tx, order); err != nil {
+		return false, err
+	}
+
+	// 在同一交易中寫入訂單建立事件
+	created := events.OrderCreated{
+		OrderID:       order.ID,
+		UserID:        order.UserID,
+		Status:        string(order.Status),
+		TotalPrice:    order.TotalPrice,
+		Items:         make([]events.OrderItem, 0, len(order.Items)),
+		PaymentMethod: order.PaymentMethod,
+	}
+	for _, item := range order.Items {
+		created.Items = append(created.Items, events.OrderItem{
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			Quantity:    item.Quantity,
+			UnitPrice:   item.UnitPrice,
+			Subtotal:    item.Subtotal,
+		})
+	}
+	if err := r.addEvent(ctx, tx, created); err != nil {
+		return false, err
+	}
+
 	// 提交交易
 	if err = tx.Commit(); err != nil {
-		return fmt.Errorf("提交交易失敗: %w", err)
+		return false, fmt.Errorf("提交交易失敗: %w", err)
+	}
+
+	return true, nil
+}
+
+// addEvent 在交易中將訂單事件寫入外寄事件表
+func (r *OrderRepository) addEvent(ctx context.Context, tx *sqlx.Tx, event events.Event) error {
+	encoded, err := events.Encode(ctx, model.EventProducer, event)
+	if err != nil {
+		return err
+	}
+	return r.outbox.Add(ctx, tx, encoded.Topic, encoded.Key, encoded.Data)
+}
+
+// OrderFilter 訂單查詢條件，零值欄位表示不過濾
+//
+// MinTotal 與 MaxTotal 只比對相同幣別的訂單。
+type OrderFilter struct {
+	UserID      string
+	Statuses    []model.OrderStatus
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	MinTotal    *money.Money
+	MaxTotal    *money.Money
+	Limit       int
+	Cursor      string
+}
+
+// OrderPage 一頁訂單查詢結果，NextCursor 為空表示沒有下一頁
+type OrderPage struct {
+	Orders     []model.Order
+	NextCursor string
+}
+
+// ErrInvalidCursor 分頁游標無法解析
+var ErrInvalidCursor = errors.New("無效的分頁游標")
+
+// ErrOrderNotFound 訂單不存在
+var ErrOrderNotFound = errors.New("訂單不存在")
+
+// orderRow 對應 orders 資料表的欄位
+type orderRow struct {
+	ID            string             `db:"order_id"`
+	UserID        string             `db:"user_id"`
+	TotalPrice    int64              `db:"total_price"`
+	Currency      string             `db:"currency"`
+	Status        string             `db:"status"`
+	PaymentMethod string             `db:"payment_method"`
+	PaymentID     string             `db:"payment_id"`
+	PaidAt        *time.Time         `db:"paid_at"`
+	ShippingInfo  model.ShippingInfo `db:"shipping_info"`
+	Discounts     model.Discounts    `db:"discounts"`
+	DiscountTotal int64              `db:"discount_total"`
+	FreeShipping  bool               `db:"free_shipping"`
+	TaxLines      model.TaxLines     `db:"tax_lines"`
+	TaxTotal      int64              `db:"tax_total"`
+	TaxInclusive  bool               `db:"tax_inclusive"`
+	TaxVersion    string             `db:"tax_version"`
+	CreatedAt     time.Time          `db:"created_at"`
+	UpdatedAt     time.Time          `db:"updated_at"`
+}
+
+// orderColumns orders 資料表中對應 orderRow 的欄位
+const orderColumns = `order_id, user_id, total_price, currency, status, payment_method, payment_id, paid_at, shipping_info,
+	discounts, discount_total, free_shipping, tax_lines, tax_total, tax_inclusive, tax_version, created_at, updated_at`
+
+// orderItemRow 對應 order_items 資料表的欄位，幣別取自所屬訂單
+type orderItemRow struct {
+	OrderID     string `db:"order_id"`
+	ProductID   string `db:"product_id"`
+	ProductName string `db:"product_name"`
+	Quantity    int    `db:"quantity"`
+	UnitPrice   int64  `db:"unit_price"`
+	Subtotal    int64  `db:"subtotal"`
+	TaxCategory string `db:"tax_category"`
+	Currency    string `db:"currency"`
+}
+
+func (row orderRow) toModel() model.Order {
+	return model.Order{
+		ID:            row.ID,
+		UserID:        row.UserID,
+		Items:         []model.OrderItem{},
+		TotalPrice:    money.New(row.TotalPrice, row.Currency),
+		Currency:      row.Currency,
+		Status:        model.OrderStatus(row.Status),
+		PaymentMethod: row.PaymentMethod,
+		PaymentID:     row.PaymentID,
+		PaidAt:        row.PaidAt,
+		ShippingInfo:  row.ShippingInfo,
+		Discounts:     row.Discounts,
+		DiscountTotal: money.New(row.DiscountTotal, row.Currency),
+		FreeShipping:  row.FreeShipping,
+		TaxLines:      row.TaxLines,
+		TaxTotal:      money.New(row.TaxTotal, row.Currency),
+		TaxInclusive:  row.TaxInclusive,
+		TaxVersion:    row.TaxVersion,
+		CreatedAt:     row.CreatedAt,
+		UpdatedAt:     row.UpdatedAt,
+	}
+}
+
+// GetOrder 依訂單ID查詢訂單及其項目，訂單不存在時回傳 nil
+func (r *OrderRepository) GetOrder(ctx context.Context, orderID string) (*model.Order, error) {
+	var row orderRow
+	err := r.db.GetContext(ctx, &row,
+		`SELECT `+orderColumns+` FROM orders WHERE order_id = $1`, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查詢訂單失敗: %w", err)
+	}
+
+	order := row.toModel()
+	items, err := r.findItems(ctx, []string{order.ID})
+	if err != nil {
+		return nil, err
+	}
+	order.Items = append(order.Items, items[order.ID]...)
+
+	return &order, nil
+}
+
+// ListOrders 依條件分頁查詢訂單，結果依建立時間由新到舊排序
+func (r *OrderRepository) ListOrders(ctx context.Context, filter OrderFilter) (*OrderPage, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 10
+	}
+
+	var conds []string
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.UserID != "" {
+		conds = append(conds, "user_id = "+arg(filter.UserID))
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, 0, len(filter.Statuses))
+		for _, s := range filter.Statuses {
+			statuses = append(statuses, string(s))
+		}
+		conds = append(conds, "status = ANY("+arg(pq.Array(statuses))+")")
+	}
+	if filter.CreatedFrom != nil {
+		conds = append(conds, "created_at >= "+arg(*filter.CreatedFrom))
+	}
+	if filter.CreatedTo != nil {
+		conds = append(conds, "created_at < "+arg(*filter.CreatedTo))
+	}
+	if filter.MinTotal != nil {
+		conds = append(conds, "currency = "+arg(filter.MinTotal.Currency), "total_price >= "+arg(filter.MinTotal.Amount))
+	}
+	if filter.MaxTotal != nil {
+		conds = append(conds, "currency = "+arg(filter.MaxTotal.Currency), "total_price <= "+arg(filter.MaxTotal.Amount))
+	}
+	if filter.Cursor != "" {
+		createdAt, orderID, err := decodeCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		conds = append(conds, fmt.Sprintf("(created_at, order_id) < (%s, %s)", arg(createdAt), arg(orderID)))
+	}
+
+	query := `SELECT ` + orderColumns + ` FROM orders`
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+	// 多取一筆以判斷是否還有下一頁
+	query += " ORDER BY created_at DESC, order_id DESC LIMIT " + arg(limit+1)
+
+	var rows []orderRow
+	if err := r.db.SelectContext(ctx, &rows, query, args...); err != nil {
+		return nil, fmt.Errorf("查詢訂單列表失敗: %w", err)
+	}
+
+	page := &OrderPage{Orders: make([]model.Order, 0, len(rows))}
+	if len(rows) > limit {
+		rows = rows[:limit]
+		last := rows[len(rows)-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	if len(rows) == 0 {
+		return page, nil
+	}
+
+	ids := make([]string, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	items, err := r.findItems(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		order := row.toModel()
+		order.Items = append(order.Items, items[order.ID]...)
+		page.Orders = append(page.Orders, order)
+	}
+
+	return page, nil
+}
+
+// findItems 批次查詢多筆訂單的項目，以訂單ID分組
+func (r *OrderRepository) findItems(ctx context.Context, orderIDs []string) (map[string][]model.OrderItem, error) {
+	var rows []orderItemRow
+	err := r.db.SelectContext(ctx, &rows,
+		`SELECT i.order_id, i.product_id, i.product_name, i.quantity, i.unit_price, i.subtotal, i.tax_category, o.currency
+		FROM order_items i JOIN orders o ON o.order_id = i.order_id
+		WHERE i.order_id = ANY($1)`, pq.Array(orderIDs))
+	if err != nil {
+		return nil, fmt.Errorf("查詢訂單項目失敗: %w", err)
+	}
+
+	items := make(map[string][]model.OrderItem, len(orderIDs))
+	for _, row := range rows {
+		items[row.OrderID] = append(items[row.OrderID], model.OrderItem{
+			ProductID:   row.ProductID,
+			ProductName: row.ProductName,
+			Quantity:    row.Quantity,
+			UnitPrice:   money.New(row.UnitPrice, row.Currency),
+			Subtotal:    money.New(row.Subtotal, row.Currency),
+			TaxCategory: row.TaxCategory,
+		})
+	}
+
+	return items, nil
+}
+
+// encodeCursor 將最後一筆訂單的排序鍵編碼為不透明的分頁游標
+func encodeCursor(createdAt time.Time, orderID string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + orderID
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+// decodeCursor 解析分頁游標
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	parts := strings.SplitN(string(raw), "|", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	createdAt, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return time.Time{}, "", ErrInvalidCursor
+	}
+
+	return createdAt, parts[1], nil
+}
+
+// UpdateStatus 依狀態轉換圖更新訂單狀態，並在同一交易中寫入狀態歷史
+func (r *OrderRepository) UpdateStatus(ctx context.Context, orderID string, to model.OrderStatus, actor, reason string) (*model.StatusChange, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("開始交易失敗: %w", err)
+	}
+	defer tx.Rollback()
+
+	change, err := r.updateStatusTx(ctx, tx, orderID, to, actor, reason)
+	if err != nil {
+		return nil, err
 	}
-	
-	return nil
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("提交交易失敗: %w", err)
+	}
+
+	return change, nil
+}
+
+// updateStatusTx 在呼叫端的交易中轉換訂單狀態、記錄歷史並寫入狀態變更事件
+func (r *OrderRepository) updateStatusTx(ctx context.Context, tx *sqlx.Tx, orderID string, to model.OrderStatus, actor, reason string) (*model.StatusChange, error) {
+	// 鎖定訂單列，避免並發的狀態更新互相覆蓋
+	var current struct {
+		UserID     string            `db:"user_id"`
+		Status     model.OrderStatus `db:"status"`
+		TotalPrice int64             `db:"total_price"`
+		Currency   string            `db:"currency"`
+	}
+	err := tx.GetContext(ctx, &current,
+		`SELECT user_id, status, total_price, currency FROM orders WHERE order_id = $1 FOR UPDATE`, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrOrderNotFound
+		}
+		return nil, fmt.Errorf("查詢訂單狀態失敗: %w", err)
+	}
+
+	if err := model.ValidateTransition(current.Status, to); err != nil {
+		return nil, err
+	}
+
+	change := &model.StatusChange{
+		OrderID:    orderID,
+		FromStatus: current.Status,
+		ToStatus:   to,
+		Actor:      actor,
+		Reason:     reason,
+		ChangedAt:  time.Now().UTC(),
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`UPDATE orders SET status = $1, updated_at = $2 WHERE order_id = $3`,
+		to, change.ChangedAt, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("更新訂單狀態失敗: %w", err)
+	}
+
+	_, err = tx.NamedExecContext(ctx,
+		`INSERT INTO order_status_history (order_id, from_status, to_status, actor, reason, changed_at)
+		VALUES (:order_id, :from_status, :to_status, :actor, :reason, :changed_at)`, change)
+	if err != nil {
+		return nil, fmt.Errorf("插入訂單狀態紀錄失敗: %w", err)
+	}
+
+	// 在同一交易中寫入狀態變更事件
+	err = r.addEvent(ctx, tx, events.OrderStatusChanged{
+		OrderID:    orderID,
+		UserID:     current.UserID,
+		FromStatus: string(current.Status),
+		ToStatus:   string(to),
+		TotalPrice: money.New(current.TotalPrice, current.Currency),
+		Actor:      actor,
+		Reason:     reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 手動將等待付款的訂單標記為已支付或取消時，同時結束 saga
+	if err := r.endAwaitingSaga(ctx, tx, orderID, to); err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// GetStatusHistory 查詢訂單的狀態轉換歷史，依時間由舊到新排序
+func (r *OrderRepository) GetStatusHistory(ctx context.Context, orderID string) ([]model.StatusChange, error) {
+	var history []model.StatusChange
+	err := r.db.SelectContext(ctx, &history,
+		`SELECT order_id, from_status, to_status, actor, reason, changed_at
+		FROM order_status_history WHERE order_id = $1 ORDER BY changed_at, id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢訂單狀態歷史失敗: %w", err)
+	}
+
+	if len(history) == 0 {
+		var exists bool
+		err := r.db.GetContext(ctx, &exists, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_id = $1)`, orderID)
+		if err != nil {
+			return nil, fmt.Errorf("查詢訂單失敗: %w", err)
+		}
+		if !exists {
+			return nil, ErrOrderNotFound
+		}
+	}
+
+	return history, nil
 }
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arrontsai/ecommerce/services/order/model"
+)
+
+// ErrOpenOrders 用戶仍有尚未送達或取消的訂單，收件資訊仍需用於出貨
+var ErrOpenOrders = errors.New("用戶仍有未完成的訂單")
+
+// exportPageSize 匯出用戶訂單時每次查詢的筆數
+const exportPageSize = 100
+
+// ExportUserOrders 查詢用戶的全部訂單及其項目，供資料匯出使用
+func (r *OrderRepository) ExportUserOrders(ctx context.Context, userID string) ([]model.Order, error) {
+	orders := []model.Order{}
+	filter := OrderFilter{UserID: userID, Limit: exportPageSize}
+	for {
+		page, err := r.ListOrders(ctx, filter)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, page.Orders...)
+		if page.NextCursor == "" {
+			return orders, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+// PseudonymizeUser 將用戶全部訂單的收件資訊假名化，回傳更新的訂單數
+//
+// 金額、商品與付款等財務欄位依法需保留，不做變更。收件人姓名改為由用戶ID
+// 推導的假名，同一用戶的訂單仍可互相對應；地址與電話移除，只保留稅務與統計
+// 所需的州與國家。仍有未完成的訂單時不做任何變更並回傳 ErrOpenOrders。
+// 重複執行的結果相同。
+func (r *OrderRepository) PseudonymizeUser(ctx context.Context, userID string) (int64, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("開始交易失敗: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 鎖定用戶的訂單，避免檢查後訂單狀態被改回未完成
+	var open int
+	err = tx.GetContext(ctx, &open,
+		`SELECT COUNT(*) FROM (
+			SELECT 1 FROM orders WHERE user_id = $1 AND status NOT IN ($2, $3) FOR UPDATE
+		) AS open_orders`,
+		userID, model.StatusDelivered, model.StatusCancelled)
+	if err != nil {
+		return 0, fmt.Errorf("查詢未完成訂單失敗: %w", err)
+	}
+	if open > 0 {
+		return 0, fmt.Errorf("%w: %d 筆", ErrOpenOrders, open)
+	}
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE orders SET
+			shipping_info = jsonb_strip_nulls(jsonb_build_object(
+				'full_name', $2::text,
+				'state', shipping_info->'state',
+				'country', shipping_info->'country'
+			)),
+			updated_at = $3
+		WHERE user_id = $1`,
+		userID, pseudonym(userID), time.Now())
+	if err != nil {
+		return 0, fmt.Errorf("假名化訂單收件資訊失敗: %w", err)
+	}
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("提交交易失敗: %w", err)
+	}
+	return updated, nil
+}
+
+// pseudonym 由用戶ID推導出無法還原的收件人假名
+func pseudonym(userID string) string {
+	sum := sha256.Sum256([]byte(userID))
+	return "erased-" + hex.EncodeToString(sum[:6])
+}
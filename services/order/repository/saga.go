@@ -0,0 +1,286 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/services/order/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrSagaNotFound 訂單沒有對應的支付 saga
+var ErrSagaNotFound = errors.New("訂單支付 saga 不存在")
+
+// sagaActor saga 轉換訂單狀態時記錄的操作者
+const sagaActor = "payment-saga"
+
+// startSaga 在建立訂單的交易中開始等待付款的 saga
+func (r *OrderRepository) startSaga(ctx context.Context, tx *sqlx.Tx, order *model.Order) error {
+	_, err := tx.ExecContext(ctx,
+		`INSERT INTO order_sagas (order_id, state, payment_id, deadline, created_at, updated_at)
+		VALUES ($1, $2, '', $3, $4, $4)`,
+		order.ID, model.SagaAwaitingPayment, order.CreatedAt.Add(model.PaymentTimeout), order.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("插入訂單 saga 失敗: %w", err)
+	}
+	return nil
+}
+
+// endAwaitingSaga 訂單在付款結果前被手動標記為已支付或取消時結束 saga
+func (r *OrderRepository) endAwaitingSaga(ctx context.Context, tx *sqlx.Tx, orderID string, to model.OrderStatus) error {
+	var state model.SagaState
+	switch to {
+	case model.StatusPaid:
+		state = model.SagaCompleted
+	case model.StatusCancelled:
+		state = model.SagaCancelled
+	default:
+		return nil
+	}
+
+	_, err := tx.ExecContext(ctx,
+		`UPDATE order_sagas SET state = $1, updated_at = $2 WHERE order_id = $3 AND state = $4`,
+		state, time.Now().UTC(), orderID, model.SagaAwaitingPayment)
+	if err != nil {
+		return fmt.Errorf("更新訂單 saga 失敗: %w", err)
+	}
+	return nil
+}
+
+// GetSaga 查詢訂單的支付 saga，不存在時回傳 nil
+func (r *OrderRepository) GetSaga(ctx context.Context, orderID string) (*model.OrderSaga, error) {
+	var saga model.OrderSaga
+	err := r.db.GetContext(ctx, &saga,
+		`SELECT order_id, state, payment_id, deadline, created_at, updated_at
+		FROM order_sagas WHERE order_id = $1`, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查詢訂單 saga 失敗: %w", err)
+	}
+	return &saga, nil
+}
+
+// HandlePaymentSucceeded 付款成功：等待中的訂單標記為已支付；
+// 付款的用戶或金額與訂單不符、訂單已取消或已由其他支付付清時，要求支付服務撤銷這筆付款
+func (r *OrderRepository) HandlePaymentSucceeded(ctx context.Context, event events.PaymentSucceeded) (model.SagaState, error) {
+	orderID, paymentID := event.OrderID, event.PaymentID
+	var result model.SagaState
+	err := r.withSaga(ctx, orderID, func(tx *sqlx.Tx, saga *model.OrderSaga) error {
+		payer, err := r.getPayer(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		if event.UserID != payer.UserID || event.Amount != payer.Total {
+			// 不是這筆訂單應付的款項，撤銷付款並繼續等待正確的付款
+			result = saga.State
+			return r.addEvent(ctx, tx, events.PaymentReversalRequested{
+				OrderID:   orderID,
+				PaymentID: paymentID,
+				Reason:    fmt.Sprintf("付款 %s %s 與訂單 %s %s 不符", event.UserID, event.Amount, payer.UserID, payer.Total),
+			})
+		}
+
+		switch {
+		case saga.State == model.SagaAwaitingPayment:
+			if _, err := r.updateStatusTx(ctx, tx, orderID, model.StatusPaid, sagaActor, "付款成功"); err != nil {
+				return err
+			}
+			if err := r.setPayment(ctx, tx, orderID, paymentID); err != nil {
+				return err
+			}
+			result = model.SagaCompleted
+			return r.setSagaState(ctx, tx, orderID, model.SagaCompleted, paymentID)
+
+		case saga.State == model.SagaCompleted && saga.PaymentID == "":
+			// 訂單在付款結果抵達前已被手動標記為已支付
+			result = model.SagaCompleted
+			if err := r.setPayment(ctx, tx, orderID, paymentID); err != nil {
+				return err
+			}
+			return r.setSagaState(ctx, tx, orderID, model.SagaCompleted, paymentID)
+
+		case saga.State == model.SagaCompleted && saga.PaymentID == paymentID:
+			// 重複投遞
+			result = model.SagaCompleted
+			return nil
+
+		case saga.State == model.SagaCompleted:
+			// 訂單已由另一筆支付付清，退還重複的付款
+			result = model.SagaCompleted
+			return r.addEvent(ctx, tx, events.PaymentReversalRequested{
+				OrderID:   orderID,
+				PaymentID: paymentID,
+				Reason:    "訂單已付款",
+			})
+		}
+
+		// 訂單已取消，補償：撤銷這筆遲到的付款
+		err = r.addEvent(ctx, tx, events.PaymentReversalRequested{
+			OrderID:   orderID,
+			PaymentID: paymentID,
+			Reason:    "訂單已取消",
+		})
+		if err != nil {
+			return err
+		}
+		result = model.SagaCompensated
+		return r.setSagaState(ctx, tx, orderID, model.SagaCompensated, paymentID)
+	})
+	return result, err
+}
+
+// HandlePaymentFailed 付款失敗：saga 自動發起的付款失敗時取消等待中的訂單，訂單取消事件會釋放預留庫存
+//
+// 用戶自行發起的付款失敗不影響訂單，用戶可以改用其他付款方式，逾時仍未付款時才取消。
+func (r *OrderRepository) HandlePaymentFailed(ctx context.Context, event events.PaymentFailed) (model.SagaState, error) {
+	orderID := event.OrderID
+	var result model.SagaState
+	err := r.withSaga(ctx, orderID, func(tx *sqlx.Tx, saga *model.OrderSaga) error {
+		result = saga.State
+		if saga.State != model.SagaAwaitingPayment {
+			// 訂單已支付或已取消，失敗的付款不影響訂單
+			return nil
+		}
+
+		payer, err := r.getPayer(ctx, tx, orderID)
+		if err != nil {
+			return err
+		}
+		if event.IdempotencyKey != events.OrderPaymentKey(orderID) || event.UserID != payer.UserID {
+			return nil
+		}
+
+		reason := event.FailureCode
+		if reason == "" {
+			reason = event.FailureMessage
+		}
+		if _, err := r.updateStatusTx(ctx, tx, orderID, model.StatusCancelled, sagaActor, "付款失敗: "+reason); err != nil {
+			return err
+		}
+		result = model.SagaFailed
+		return r.setSagaState(ctx, tx, orderID, model.SagaFailed, event.PaymentID)
+	})
+	return result, err
+}
+
+// ExpirePaymentSagas 取消超過付款時限仍未付款的訂單，回傳取消的訂單數
+func (r *OrderRepository) ExpirePaymentSagas(ctx context.Context, now time.Time, limit int) (int, error) {
+	var orderIDs []string
+	err := r.db.SelectContext(ctx, &orderIDs,
+		`SELECT order_id FROM order_sagas WHERE state = $1 AND deadline <= $2 ORDER BY deadline LIMIT $3`,
+		model.SagaAwaitingPayment, now, limit)
+	if err != nil {
+		return 0, fmt.Errorf("查詢逾時訂單 saga 失敗: %w", err)
+	}
+
+	expired := 0
+	for _, orderID := range orderIDs {
+		err := r.withSaga(ctx, orderID, func(tx *sqlx.Tx, saga *model.OrderSaga) error {
+			// 鎖定後重新檢查，付款結果可能剛好抵達
+			if saga.State != model.SagaAwaitingPayment || saga.Deadline.After(now) {
+				return nil
+			}
+			if _, err := r.updateStatusTx(ctx, tx, orderID, model.StatusCancelled, sagaActor, "付款逾時"); err != nil {
+				return err
+			}
+			expired++
+			return r.setSagaState(ctx, tx, orderID, model.SagaTimedOut, "")
+		})
+		if err != nil {
+			return expired, err
+		}
+	}
+
+	return expired, nil
+}
+
+// withSaga 在交易中鎖定訂單與其 saga 後執行 fn，fn 回傳 nil 時提交
+//
+// 先鎖訂單再鎖 saga，與 updateStatusTx 的鎖定順序一致，避免死結。
+func (r *OrderRepository) withSaga(ctx context.Context, orderID string, fn func(tx *sqlx.Tx, saga *model.OrderSaga) error) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("開始交易失敗: %w", err)
+	}
+	defer tx.Rollback()
+
+	var locked string
+	err = tx.GetContext(ctx, &locked, `SELECT order_id FROM orders WHERE order_id = $1 FOR UPDATE`, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrOrderNotFound
+		}
+		return fmt.Errorf("鎖定訂單失敗: %w", err)
+	}
+
+	var saga model.OrderSaga
+	err = tx.GetContext(ctx, &saga,
+		`SELECT order_id, state, payment_id, deadline, created_at, updated_at
+		FROM order_sagas WHERE order_id = $1 FOR UPDATE`, orderID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSagaNotFound
+		}
+		return fmt.Errorf("查詢訂單 saga 失敗: %w", err)
+	}
+
+	if err := fn(tx, &saga); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交交易失敗: %w", err)
+	}
+	return nil
+}
+
+// setSagaState 更新 saga 狀態，paymentID 非空白時一併記錄
+func (r *OrderRepository) setSagaState(ctx context.Context, tx *sqlx.Tx, orderID string, state model.SagaState, paymentID string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE order_sagas SET state = $1, payment_id = COALESCE(NULLIF($2::text, ''), payment_id), updated_at = $3
+		WHERE order_id = $4`,
+		state, paymentID, time.Now().UTC(), orderID)
+	if err != nil {
+		return fmt.Errorf("更新訂單 saga 失敗: %w", err)
+	}
+	return nil
+}
+
+// payer 訂單應付款的用戶與總金額
+type payer struct {
+	UserID string
+	Total  money.Money
+}
+
+// getPayer 在交易中查詢訂單應付款的用戶與總金額
+func (r *OrderRepository) getPayer(ctx context.Context, tx *sqlx.Tx, orderID string) (*payer, error) {
+	var row struct {
+		UserID     string `db:"user_id"`
+		TotalPrice int64  `db:"total_price"`
+		Currency   string `db:"currency"`
+	}
+	err := tx.GetContext(ctx, &row, `SELECT user_id, total_price, currency FROM orders WHERE order_id = $1`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢訂單失敗: %w", err)
+	}
+	return &payer{UserID: row.UserID, Total: money.New(row.TotalPrice, row.Currency)}, nil
+}
+
+// setPayment 記錄付清訂單的支付
+func (r *OrderRepository) setPayment(ctx context.Context, tx *sqlx.Tx, orderID, paymentID string) error {
+	_, err := tx.ExecContext(ctx,
+		`UPDATE orders SET payment_id = $1, paid_at = $2 WHERE order_id = $3`,
+		paymentID, time.Now().UTC(), orderID)
+	if err != nil {
+		return fmt.Errorf("記錄訂單支付失敗: %w", err)
+	}
+	return nil
+}
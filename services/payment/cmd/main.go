@@ -1,9 +1,205 @@
 package main
 
 import (
-	// 必要套件導入...
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/config"
+	"github.com/arrontsai/ecommerce/pkg/database"
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/jwks"
+	"github.com/arrontsai/ecommerce/pkg/logger"
+	"github.com/arrontsai/ecommerce/pkg/messaging"
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
+	orderpb "github.com/arrontsai/ecommerce/services/order/proto/pb"
+	"github.com/arrontsai/ecommerce/services/payment/gateway"
+	"github.com/arrontsai/ecommerce/services/payment/handler"
+	"github.com/arrontsai/ecommerce/services/payment/proto/pb"
+	"github.com/arrontsai/ecommerce/services/payment/repository"
+	"github.com/arrontsai/ecommerce/services/payment/service"
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	_ "github.com/lib/pq"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
-	// 支付服務初始化邏輯
+	// 初始化配置
+	cfg, err := config.LoadConfig("payment-service")
+	if err != nil {
+		log.Fatal("無法載入配置:", err)
+	}
+
+	// 初始化日誌記錄器
+	appLogger, err := logger.NewLogger("payment-service", cfg.LogLevel)
+	if err != nil {
+		log.Fatal("無法初始化日誌記錄器:", err)
+	}
+	defer appLogger.Sync()
+
+	// 構建 PostgreSQL 連接字符串
+	pgConnStr := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
+		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+
+	// 初始化PostgreSQL
+	pgClient, err := sqlx.Connect("postgres", pgConnStr)
+	if err != nil {
+		appLogger.Fatal("無法連接PostgreSQL:", zap.Error(err))
+	}
+	defer pgClient.Close()
+
+	// 初始化MongoDB連接，用於檢查已撤銷的令牌
+	mongoClient, err := database.NewMongoClient(cfg.MongoURI, cfg.MongoDB)
+	if err != nil {
+		appLogger.Fatal("無法連接MongoDB:", zap.Error(err))
+	}
+	defer mongoClient.Close()
+
+	// 初始化支付閘道 (PAYMENT_GATEWAY=fake 使用本機假閘道)
+	paymentGateway, err := gateway.New(cfg.PaymentGateway)
+	if err != nil {
+		appLogger.Fatal("無法初始化支付閘道:", zap.Error(err))
+	}
+
+	// 連接訂單服務，授權前確認訂單的用戶與總金額
+	orderConn, err := grpc.Dial(cfg.OrderGrpcAddr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		appLogger.Fatal("無法連接訂單服務:", zap.Error(err))
+	}
+	defer orderConn.Close()
+
+	// 初始化消息代理 (KAFKA_BROKERS=memory:// 時使用記憶體代理)
+	broker, err := messaging.NewBroker(cfg.KafkaBrokers, appLogger.Logger)
+	if err != nil {
+		appLogger.Fatal("無法初始化消息代理:", zap.Error(err))
+	}
+	defer broker.Close()
+
+	// 創建支付服務
+	paymentRepo := repository.NewPaymentRepo(pgClient)
+	paymentService := service.NewPaymentService(paymentRepo, paymentGateway, service.NewOrderClient(orderpb.NewOrderServiceClient(orderConn)))
+
+	// 啟動外寄事件轉發器，將支付事件發布到Kafka
+	relay := outbox.NewRelay(paymentRepo.Outbox(), broker, appLogger.Logger)
+	go relay.Run(context.Background())
+
+	// 訂閱訂單事件，參與訂單與支付的 saga
+	go subscribeToOrderEvents(broker, paymentService)
+
+	// 初始化JWT驗證，設定 JWT_JWKS_URL 時以認證服務公布的公鑰驗證
+	authOptions := []middleware.AuthOption{middleware.WithDenylist(middleware.NewMongoTokenDenylist(mongoClient.DB))}
+	if cfg.JWKSURL != "" {
+		authOptions = append(authOptions, middleware.WithKeyProvider(jwks.NewRemoteKeySet(cfg.JWKSURL)))
+	}
+
+	// 初始化HTTP路由
+	router := gin.Default()
+	handler.NewPaymentHandler(paymentService).RegisterRoutes(router, middleware.JWTAuthMiddleware(cfg.JWTSecret, authOptions...))
+	router.GET("/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	httpServer := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.ServerPort),
+		Handler: router,
+	}
+	go func() {
+		appLogger.Info("支付服務HTTP啟動", zap.Int("port", cfg.ServerPort))
+		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			appLogger.Fatal("無法啟動HTTP伺服器:", zap.Error(err))
+		}
+	}()
+
+	// 啟動gRPC伺服器
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GrpcPort))
+	if err != nil {
+		appLogger.Fatal("無法監聽端口:", zap.Error(err))
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterPaymentServiceServer(grpcServer, handler.NewPaymentGRPCServer(paymentService))
+	go func() {
+		appLogger.Info("支付服務gRPC啟動", zap.Int("port", cfg.GrpcPort))
+		if err := grpcServer.Serve(lis); err != nil {
+			appLogger.Fatal("無法啟動gRPC伺服器:", zap.Error(err))
+		}
+	}()
+
+	// 等待中斷信號以優雅地關閉伺服器
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	<-quit
+	appLogger.Info("正在關閉支付服務...")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	grpcServer.GracefulStop()
+	if err := httpServer.Shutdown(ctx); err != nil {
+		appLogger.Fatal("HTTP伺服器強制關閉:", zap.Error(err))
+	}
+}
+
+// subscribeToOrderEvents 訂閱訂單事件：訂單建立時授權並請款，訂單取消時撤銷或退款
+func subscribeToOrderEvents(consumer messaging.Broker, paymentService *service.PaymentService) {
+	mux := events.NewMux(nil)
+
+	// 訂單帶有付款方式時自動付款，結果以 PaymentSucceeded 或 PaymentFailed 發布
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.OrderCreated) error {
+		if event.PaymentMethod == "" {
+			return nil
+		}
+
+		_, err := paymentService.Authorize(ctx, service.AuthorizeInput{
+			OrderID:       event.OrderID,
+			UserID:        event.UserID,
+			Amount:        event.TotalPrice,
+			PaymentMethod: event.PaymentMethod,
+			Capture:       true,
+			// 以訂單ID作為冪等鍵，重複投遞的事件不會重複扣款
+			IdempotencyKey: events.OrderPaymentKey(event.OrderID),
+		})
+		switch {
+		case err == nil, errors.Is(err, service.ErrPaymentDeclined), errors.Is(err, service.ErrPaymentFailed):
+			// 遭拒與失敗已寫入支付並發布 PaymentFailed
+			return nil
+		case errors.Is(err, service.ErrInvalidAmount):
+			return messaging.Fatal(err)
+		}
+		return err
+	})
+
+	// 訂單取消後撤銷或退還該訂單的所有支付
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.OrderStatusChanged) error {
+		if event.ToStatus != "CANCELLED" {
+			return nil
+		}
+		return paymentService.ReverseOrderPayments(ctx, event.OrderID, event.Reason)
+	})
+
+	// 訂單已取消但仍收到付款時的補償
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.PaymentReversalRequested) error {
+		_, err := paymentService.Reverse(ctx, event.PaymentID, event.Reason)
+		if errors.Is(err, service.ErrPaymentNotFound) {
+			return messaging.Fatal(err)
+		}
+		return err
+	})
+
+	// 啟動消費
+	ctx := context.Background()
+	err := messaging.SubscribeEvents(ctx, consumer, events.TopicOrderEvents, "payment-service", mux)
+	if err != nil {
+		log.Fatalf("無法消費消息: %v", err)
+	}
 }
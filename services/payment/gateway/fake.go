@@ -0,0 +1,150 @@
+package gateway
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// FakeGatewayName 本機假閘道的名稱
+const FakeGatewayName = "fake"
+
+// 假閘道使用的測試卡號，其他卡號一律核准
+const (
+	CardApprove           = "4242424242424242"
+	CardDecline           = "4000000000000002"
+	CardInsufficientFunds = "4000000000009995"
+	CardTimeout           = "4000000000000119"
+)
+
+// 假閘道使用的測試付款方式，對應到同樣行為的測試卡號，其他付款方式一律核准
+var fakePaymentMethods = map[string]string{
+	"pm_card_visa":               CardApprove,
+	"pm_card_declined":           CardDecline,
+	"pm_card_insufficient_funds": CardInsufficientFunds,
+	"pm_card_timeout":            CardTimeout,
+}
+
+// fakeAuthorization 假閘道內的授權紀錄，請款與退款金額以授權幣別的最小單位記錄
+type fakeAuthorization struct {
+	amount   money.Money
+	captured int64
+	refunded int64
+	voided   bool
+}
+
+// FakeGateway 本機使用的確定性假閘道，行為由測試卡號決定，授權只保存在記憶體中
+type FakeGateway struct {
+	mu             sync.Mutex
+	seq            int
+	authorizations map[string]*fakeAuthorization
+	// TimeoutDelay 逾時卡號在回報 ErrTimeout 前等待的時間
+	TimeoutDelay time.Duration
+}
+
+// NewFakeGateway 創建假閘道
+func NewFakeGateway() *FakeGateway {
+	return &FakeGateway{
+		authorizations: make(map[string]*fakeAuthorization),
+		TimeoutDelay:   3 * time.Second,
+	}
+}
+
+// Name 實作 PaymentGateway 介面
+func (g *FakeGateway) Name() string {
+	return FakeGatewayName
+}
+
+// Authorize 依卡號核准、拒絕或逾時
+func (g *FakeGateway) Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error) {
+	if !req.Amount.IsPositive() || req.Amount.Validate() != nil {
+		return nil, ErrInvalidAmount
+	}
+
+	number := req.Card.Number
+	if req.PaymentMethod != "" {
+		number = fakePaymentMethods[req.PaymentMethod]
+	}
+
+	switch number {
+	case CardDecline:
+		return nil, ErrCardDeclined
+	case CardInsufficientFunds:
+		return nil, ErrInsufficientFunds
+	case CardTimeout:
+		select {
+		case <-time.After(g.TimeoutDelay):
+		case <-ctx.Done():
+		}
+		return nil, ErrTimeout
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.seq++
+	reference := fmt.Sprintf("fake_auth_%06d", g.seq)
+	g.authorizations[reference] = &fakeAuthorization{amount: req.Amount}
+
+	return &Result{Reference: reference, Amount: req.Amount}, nil
+}
+
+// Capture 對授權請款
+func (g *FakeGateway) Capture(ctx context.Context, reference string, amount money.Money) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	auth, ok := g.authorizations[reference]
+	if !ok {
+		return nil, ErrUnknownAuthorization
+	}
+	if auth.voided || auth.captured > 0 {
+		return nil, ErrInvalidState
+	}
+	if !amount.SameCurrency(auth.amount) || !amount.IsPositive() || amount.Amount > auth.amount.Amount {
+		return nil, ErrInvalidAmount
+	}
+
+	auth.captured = amount.Amount
+	return &Result{Reference: reference, Amount: amount}, nil
+}
+
+// Void 撤銷尚未請款的授權
+func (g *FakeGateway) Void(ctx context.Context, reference string) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	auth, ok := g.authorizations[reference]
+	if !ok {
+		return nil, ErrUnknownAuthorization
+	}
+	if auth.captured > 0 {
+		return nil, ErrInvalidState
+	}
+
+	auth.voided = true
+	return &Result{Reference: reference, Amount: auth.amount}, nil
+}
+
+// Refund 退還已請款的金額
+func (g *FakeGateway) Refund(ctx context.Context, reference string, amount money.Money) (*Result, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	auth, ok := g.authorizations[reference]
+	if !ok {
+		return nil, ErrUnknownAuthorization
+	}
+	if auth.captured == 0 {
+		return nil, ErrInvalidState
+	}
+	if !amount.SameCurrency(auth.amount) || !amount.IsPositive() || amount.Amount > auth.captured-auth.refunded {
+		return nil, ErrInvalidAmount
+	}
+
+	auth.refunded += amount.Amount
+	return &Result{Reference: reference, Amount: amount}, nil
+}
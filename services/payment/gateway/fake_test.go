@@ -0,0 +1,173 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+func TestFakeGatewayAuthorize(t *testing.T) {
+	amount := money.New(12345, "TWD")
+
+	tests := []struct {
+		name          string
+		card          string
+		paymentMethod string
+		amount        money.Money
+		wantErr       error
+	}{
+		{name: "approve card", card: CardApprove, amount: amount},
+		{name: "unknown card approves", card: "5555555555554444", amount: amount},
+		{name: "declined card", card: CardDecline, amount: amount, wantErr: ErrCardDeclined},
+		{name: "insufficient funds card", card: CardInsufficientFunds, amount: amount, wantErr: ErrInsufficientFunds},
+		{name: "timeout card", card: CardTimeout, amount: amount, wantErr: ErrTimeout},
+		{name: "saved visa", paymentMethod: "pm_card_visa", amount: amount},
+		{name: "saved declined", paymentMethod: "pm_card_declined", amount: amount, wantErr: ErrCardDeclined},
+		{name: "saved insufficient funds", paymentMethod: "pm_card_insufficient_funds", amount: amount, wantErr: ErrInsufficientFunds},
+		{name: "saved timeout", paymentMethod: "pm_card_timeout", amount: amount, wantErr: ErrTimeout},
+		// 付款方式優先於卡號
+		{name: "payment method overrides card", card: CardDecline, paymentMethod: "pm_card_visa", amount: amount},
+		{name: "zero amount", card: CardApprove, amount: money.New(0, "TWD"), wantErr: ErrInvalidAmount},
+		{name: "unknown currency", card: CardApprove, amount: money.New(100, "XXX"), wantErr: ErrInvalidAmount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := NewFakeGateway()
+			gw.TimeoutDelay = time.Millisecond
+
+			result, err := gw.Authorize(context.Background(), AuthorizeRequest{
+				Amount:        tt.amount,
+				Card:          Card{Number: tt.card},
+				PaymentMethod: tt.paymentMethod,
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Authorize() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+			if result.Reference == "" || result.Amount != tt.amount {
+				t.Errorf("Authorize() = %+v, want a reference for %v", result, tt.amount)
+			}
+		})
+	}
+}
+
+func TestFakeGatewayLifecycle(t *testing.T) {
+	ctx := context.Background()
+	amount := money.New(10000, "TWD")
+
+	tests := []struct {
+		name string
+		// run 在已授權的 reference 上執行操作，回傳最後一步的錯誤
+		run     func(gw *FakeGateway, ref string) error
+		wantErr error
+	}{
+		{
+			name: "capture then partial refunds",
+			run: func(gw *FakeGateway, ref string) error {
+				if _, err := gw.Capture(ctx, ref, amount); err != nil {
+					return err
+				}
+				if _, err := gw.Refund(ctx, ref, money.New(4000, "TWD")); err != nil {
+					return err
+				}
+				_, err := gw.Refund(ctx, ref, money.New(6000, "TWD"))
+				return err
+			},
+		},
+		{
+			name: "refund more than captured",
+			run: func(gw *FakeGateway, ref string) error {
+				if _, err := gw.Capture(ctx, ref, money.New(5000, "TWD")); err != nil {
+					return err
+				}
+				_, err := gw.Refund(ctx, ref, money.New(5001, "TWD"))
+				return err
+			},
+			wantErr: ErrInvalidAmount,
+		},
+		{
+			name: "capture more than authorized",
+			run: func(gw *FakeGateway, ref string) error {
+				_, err := gw.Capture(ctx, ref, money.New(10001, "TWD"))
+				return err
+			},
+			wantErr: ErrInvalidAmount,
+		},
+		{
+			name: "capture in another currency",
+			run: func(gw *FakeGateway, ref string) error {
+				_, err := gw.Capture(ctx, ref, money.New(100, "USD"))
+				return err
+			},
+			wantErr: ErrInvalidAmount,
+		},
+		{
+			name: "capture twice",
+			run: func(gw *FakeGateway, ref string) error {
+				if _, err := gw.Capture(ctx, ref, amount); err != nil {
+					return err
+				}
+				_, err := gw.Capture(ctx, ref, amount)
+				return err
+			},
+			wantErr: ErrInvalidState,
+		},
+		{
+			name: "capture after void",
+			run: func(gw *FakeGateway, ref string) error {
+				if _, err := gw.Void(ctx, ref); err != nil {
+					return err
+				}
+				_, err := gw.Capture(ctx, ref, amount)
+				return err
+			},
+			wantErr: ErrInvalidState,
+		},
+		{
+			name: "void after capture",
+			run: func(gw *FakeGateway, ref string) error {
+				if _, err := gw.Capture(ctx, ref, amount); err != nil {
+					return err
+				}
+				_, err := gw.Void(ctx, ref)
+				return err
+			},
+			wantErr: ErrInvalidState,
+		},
+		{
+			name: "refund before capture",
+			run: func(gw *FakeGateway, ref string) error {
+				_, err := gw.Refund(ctx, ref, amount)
+				return err
+			},
+			wantErr: ErrInvalidState,
+		},
+		{
+			name: "unknown reference",
+			run: func(gw *FakeGateway, ref string) error {
+				_, err := gw.Capture(ctx, "fake_auth_missing", amount)
+				return err
+			},
+			wantErr: ErrUnknownAuthorization,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw := NewFakeGateway()
+			auth, err := gw.Authorize(ctx, AuthorizeRequest{Amount: amount, Card: Card{Number: CardApprove}})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.run(gw, auth.Reference); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package gateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+var (
+	// ErrCardDeclined 發卡行拒絕交易
+	ErrCardDeclined = errors.New("卡片遭拒")
+	// ErrInsufficientFunds 餘額或額度不足
+	ErrInsufficientFunds = errors.New("餘額不足")
+	// ErrTimeout 閘道未在時限內回應，交易結果未知
+	ErrTimeout = errors.New("支付閘道逾時")
+	// ErrUnknownAuthorization 閘道找不到對應的授權
+	ErrUnknownAuthorization = errors.New("授權不存在")
+	// ErrInvalidAmount 金額超出授權或請款金額，或幣別與授權不同
+	ErrInvalidAmount = errors.New("無效的金額")
+	// ErrInvalidState 授權目前的狀態不允許此操作
+	ErrInvalidState = errors.New("授權狀態不允許此操作")
+)
+
+// Card 信用卡資料，僅於授權時傳給閘道，不會寫入資料庫
+type Card struct {
+	Number   string `json:"number" binding:"required"`
+	ExpMonth int    `json:"exp_month" binding:"required,min=1,max=12"`
+	ExpYear  int    `json:"exp_year" binding:"required"`
+	CVC      string `json:"cvc"`
+}
+
+// Last4 回傳卡號末四碼
+func (c Card) Last4() string {
+	if len(c.Number) < 4 {
+		return c.Number
+	}
+	return c.Number[len(c.Number)-4:]
+}
+
+// AuthorizeRequest 授權請求，Card 與 PaymentMethod 擇一提供
+type AuthorizeRequest struct {
+	PaymentID string
+	Amount    money.Money
+	Card      Card
+	// PaymentMethod 閘道端已保存的付款方式，例如結帳時選擇的卡片
+	PaymentMethod string
+}
+
+// Result 閘道操作結果
+type Result struct {
+	// Reference 閘道端的授權編號，後續請款、撤銷與退款都以此識別
+	Reference string
+	// Amount 本次操作處理的金額
+	Amount money.Money
+}
+
+// PaymentGateway 支付閘道介面
+//
+// 拒絕交易以 ErrCardDeclined 或 ErrInsufficientFunds 回報；ErrTimeout 表示
+// 結果未知，呼叫端不應假設交易已成功或失敗。
+type PaymentGateway interface {
+	// Name 閘道名稱，會記錄在支付資料上
+	Name() string
+	// Authorize 預先授權指定金額
+	Authorize(ctx context.Context, req AuthorizeRequest) (*Result, error)
+	// Capture 對授權請款，金額不可超過授權金額
+	Capture(ctx context.Context, reference string, amount money.Money) (*Result, error)
+	// Void 撤銷尚未請款的授權
+	Void(ctx context.Context, reference string) (*Result, error)
+	// Refund 退還已請款的金額，可多次部分退款
+	Refund(ctx context.Context, reference string, amount money.Money) (*Result, error)
+}
+
+// New 依名稱建立支付閘道
+func New(name string) (PaymentGateway, error) {
+	switch name {
+	case "", FakeGatewayName:
+		return NewFakeGateway(), nil
+	}
+	return nil, fmt.Errorf("不支援的支付閘道: %s", name)
+}
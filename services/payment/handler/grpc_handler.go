@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/arrontsai/ecommerce/pkg/money/moneypb"
+	"github.com/arrontsai/ecommerce/services/payment/gateway"
+	"github.com/arrontsai/ecommerce/services/payment/model"
+	"github.com/arrontsai/ecommerce/services/payment/proto/pb"
+	"github.com/arrontsai/ecommerce/services/payment/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// PaymentGRPCServer 實現支付服務的gRPC接口
+type PaymentGRPCServer struct {
+	pb.UnimplementedPaymentServiceServer
+	paymentService *service.PaymentService
+}
+
+// NewPaymentGRPCServer 創建支付gRPC服務
+func NewPaymentGRPCServer(paymentService *service.PaymentService) *PaymentGRPCServer {
+	return &PaymentGRPCServer{paymentService: paymentService}
+}
+
+// AuthorizePayment 實現授權支付的gRPC方法
+//
+// 與REST接口相同，只能為 user_id 自己待處理的訂單以訂單總金額授權。
+func (s *PaymentGRPCServer) AuthorizePayment(ctx context.Context, req *pb.AuthorizePaymentRequest) (*pb.PaymentResponse, error) {
+	if req.OrderId == "" || (req.Card == nil && req.PaymentMethod == "") {
+		return nil, status.Error(codes.InvalidArgument, "訂單 ID 與付款資料不能為空")
+	}
+
+	input := service.AuthorizeInput{
+		OrderID:        req.OrderId,
+		UserID:         req.UserId,
+		Amount:         req.Amount.ToMoney(),
+		PaymentMethod:  req.PaymentMethod,
+		Capture:        req.Capture,
+		IdempotencyKey: service.ClientIdempotencyKey(req.UserId, req.IdempotencyKey),
+	}
+	if req.Card != nil {
+		input.Card = gateway.Card{
+			Number:   req.Card.Number,
+			ExpMonth: int(req.Card.ExpMonth),
+			ExpYear:  int(req.Card.ExpYear),
+			CVC:      req.Card.Cvc,
+		}
+	}
+
+	payment, err := s.paymentService.AuthorizeOrder(ctx, input)
+	// 遭拒或閘道失敗屬於支付結果，回傳支付讓呼叫端依狀態處理
+	if err != nil && payment == nil {
+		return nil, paymentStatusError(err)
+	}
+
+	return toPaymentResponse(payment), nil
+}
+
+// CapturePayment 實現請款的gRPC方法
+func (s *PaymentGRPCServer) CapturePayment(ctx context.Context, req *pb.CapturePaymentRequest) (*pb.PaymentResponse, error) {
+	payment, err := s.paymentService.Capture(ctx, req.PaymentId, req.Amount.ToMoney())
+	if err != nil {
+		return nil, paymentStatusError(err)
+	}
+	return toPaymentResponse(payment), nil
+}
+
+// VoidPayment 實現撤銷授權的gRPC方法
+func (s *PaymentGRPCServer) VoidPayment(ctx context.Context, req *pb.VoidPaymentRequest) (*pb.PaymentResponse, error) {
+	payment, err := s.paymentService.Void(ctx, req.PaymentId)
+	if err != nil {
+		return nil, paymentStatusError(err)
+	}
+	return toPaymentResponse(payment), nil
+}
+
+// RefundPayment 實現退款的gRPC方法
+func (s *PaymentGRPCServer) RefundPayment(ctx context.Context, req *pb.RefundPaymentRequest) (*pb.PaymentResponse, error) {
+	payment, err := s.paymentService.Refund(ctx, req.PaymentId, req.Amount.ToMoney())
+	if err != nil {
+		return nil, paymentStatusError(err)
+	}
+	return toPaymentResponse(payment), nil
+}
+
+// GetPayment 實現獲取支付的gRPC方法
+func (s *PaymentGRPCServer) GetPayment(ctx context.Context, req *pb.GetPaymentRequest) (*pb.PaymentResponse, error) {
+	payment, err := s.paymentService.GetPayment(ctx, req.PaymentId)
+	if err != nil {
+		return nil, paymentStatusError(err)
+	}
+	return toPaymentResponse(payment), nil
+}
+
+// paymentStatusError 將支付服務錯誤轉換為gRPC狀態錯誤
+func paymentStatusError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound),
+		errors.Is(err, service.ErrOrderNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrIdempotencyKeyReused):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, service.ErrOrderNotPayable):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, service.ErrInvalidAmount):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, model.ErrIllegalTransition),
+		errors.Is(err, gateway.ErrInvalidState),
+		errors.Is(err, gateway.ErrInvalidAmount),
+		errors.Is(err, gateway.ErrUnknownAuthorization):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, gateway.ErrTimeout):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+	return status.Errorf(codes.Internal, "支付操作失敗: %v", err)
+}
+
+// toPaymentResponse 將支付模型轉換為gRPC響應格式
+func toPaymentResponse(payment *model.Payment) *pb.PaymentResponse {
+	return &pb.PaymentResponse{
+		PaymentId:      payment.ID,
+		OrderId:        payment.OrderID,
+		UserId:         payment.UserID,
+		Amount:         moneypb.FromMoney(payment.Amount),
+		CapturedAmount: moneypb.FromMoney(payment.CapturedAmount),
+		RefundedAmount: moneypb.FromMoney(payment.RefundedAmount),
+		Currency:       payment.Currency(),
+		Status:         string(payment.Status),
+		Gateway:        payment.Gateway,
+		GatewayRef:     payment.GatewayRef,
+		CardLast4:      payment.CardLast4,
+		FailureCode:    payment.FailureCode,
+		FailureMessage: payment.FailureMessage,
+		CreatedAt:      timestamppb.New(payment.CreatedAt),
+		UpdatedAt:      timestamppb.New(payment.UpdatedAt),
+	}
+}
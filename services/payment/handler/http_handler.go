@@ -0,0 +1,234 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/services/payment/gateway"
+	"github.com/arrontsai/ecommerce/services/payment/model"
+	"github.com/arrontsai/ecommerce/services/payment/service"
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentHandler 處理支付的HTTP請求
+type PaymentHandler struct {
+	paymentService *service.PaymentService
+}
+
+// NewPaymentHandler 創建支付HTTP處理器
+func NewPaymentHandler(paymentService *service.PaymentService) *PaymentHandler {
+	return &PaymentHandler{paymentService: paymentService}
+}
+
+// AuthorizePaymentRequest 授權支付請求，card 與 payment_method 擇一提供；
+// 授權金額為訂單總金額，amount 可省略，提供時需與訂單總金額相同，
+// 以幣別的最小單位表示，例如 {"amount": 12345, "currency": "TWD"}
+type AuthorizePaymentRequest struct {
+	OrderID       string        `json:"order_id" binding:"required"`
+	Amount        money.Money   `json:"amount"`
+	Card          *gateway.Card `json:"card" binding:"required_without=PaymentMethod"`
+	PaymentMethod string        `json:"payment_method"`
+	Capture       bool          `json:"capture"`
+}
+
+// AmountRequest 請款或退款請求，金額為 0 時處理全額，幣別需與支付相同
+type AmountRequest struct {
+	Amount money.Money `json:"amount"`
+}
+
+// AuthorizePayment 處理用戶訂單的支付授權，可透過 Idempotency-Key 標頭避免重複授權
+//
+// 冪等鍵以用戶區隔，相同的鍵用於其他訂單或金額時回傳 409。
+func (h *PaymentHandler) AuthorizePayment(c *gin.Context) {
+	var req AuthorizePaymentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	userID := c.GetString("user_id")
+	input := service.AuthorizeInput{
+		OrderID:        req.OrderID,
+		UserID:         userID,
+		Amount:         req.Amount,
+		PaymentMethod:  req.PaymentMethod,
+		Capture:        req.Capture,
+		IdempotencyKey: service.ClientIdempotencyKey(userID, c.GetHeader("Idempotency-Key")),
+	}
+	if req.Card != nil {
+		input.Card = *req.Card
+	}
+
+	payment, err := h.paymentService.AuthorizeOrder(c.Request.Context(), input)
+	if err != nil {
+		if payment != nil {
+			c.JSON(paymentErrorStatus(err), gin.H{"error": err.Error(), "payment": payment})
+			return
+		}
+		c.JSON(paymentErrorStatus(err), gin.H{"error": "支付授權失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "支付授權成功", "payment": payment})
+}
+
+// GetPayment 處理獲取支付
+func (h *PaymentHandler) GetPayment(c *gin.Context) {
+	payment, err := h.paymentService.GetPayment(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": "獲取支付失敗: " + err.Error()})
+		return
+	}
+	if !canView(c, payment) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "獲取支付失敗: " + service.ErrPaymentNotFound.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payment": payment})
+}
+
+// ListOrderPayments 處理獲取訂單的所有支付
+func (h *PaymentHandler) ListOrderPayments(c *gin.Context) {
+	payments, err := h.paymentService.ListOrderPayments(c.Request.Context(), c.Param("order_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取支付失敗: " + err.Error()})
+		return
+	}
+
+	visible := make([]model.Payment, 0, len(payments))
+	for i := range payments {
+		if canView(c, &payments[i]) {
+			visible = append(visible, payments[i])
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"payments": visible})
+}
+
+// CapturePayment 處理請款
+func (h *PaymentHandler) CapturePayment(c *gin.Context) {
+	// 請求內容可省略，省略時處理全額
+	var req AmountRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+			return
+		}
+	}
+
+	if !h.authorizeAccess(c, "請款失敗: ") {
+		return
+	}
+
+	payment, err := h.paymentService.Capture(c.Request.Context(), c.Param("id"), req.Amount)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": "請款失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "請款成功", "payment": payment})
+}
+
+// VoidPayment 處理撤銷授權
+func (h *PaymentHandler) VoidPayment(c *gin.Context) {
+	if !h.authorizeAccess(c, "撤銷授權失敗: ") {
+		return
+	}
+
+	payment, err := h.paymentService.Void(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": "撤銷授權失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "撤銷授權成功", "payment": payment})
+}
+
+// RefundPayment 處理退款
+func (h *PaymentHandler) RefundPayment(c *gin.Context) {
+	// 請求內容可省略，省略時處理全額
+	var req AmountRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+			return
+		}
+	}
+
+	if !h.authorizeAccess(c, "退款失敗: ") {
+		return
+	}
+
+	payment, err := h.paymentService.Refund(c.Request.Context(), c.Param("id"), req.Amount)
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": "退款失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "退款成功", "payment": payment})
+}
+
+// RegisterRoutes 註冊支付路由
+func (h *PaymentHandler) RegisterRoutes(router *gin.Engine, authMiddleware gin.HandlerFunc) {
+	payments := router.Group("/api/payments", authMiddleware)
+	{
+		payments.POST("", h.AuthorizePayment)
+		payments.GET("/:id", h.GetPayment)
+		payments.GET("/order/:order_id", h.ListOrderPayments)
+	}
+
+	// 用戶只能在授權時一併請款，請款、撤銷與退款由具有 payments:write 權限的角色處理
+	admin := router.Group("/api/payments", authMiddleware, middleware.RequirePermissions(middleware.PermPaymentsWrite))
+	{
+		admin.POST("/:id/capture", h.CapturePayment)
+		admin.POST("/:id/void", h.VoidPayment)
+		admin.POST("/:id/refund", h.RefundPayment)
+	}
+}
+
+// authorizeAccess 在變更支付前確認呼叫者可以存取該支付，無權存取時與不存在一樣回應 404
+func (h *PaymentHandler) authorizeAccess(c *gin.Context, errPrefix string) bool {
+	payment, err := h.paymentService.GetPayment(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(paymentErrorStatus(err), gin.H{"error": errPrefix + err.Error()})
+		return false
+	}
+	if !canView(c, payment) {
+		c.JSON(http.StatusNotFound, gin.H{"error": errPrefix + service.ErrPaymentNotFound.Error()})
+		return false
+	}
+	return true
+}
+
+// canView 只有支付的用戶本人或具有 orders:read:any 權限的角色可以查看支付
+func canView(c *gin.Context, payment *model.Payment) bool {
+	return payment.UserID == c.GetString("user_id") || middleware.HasPermission(c, middleware.PermOrdersReadAny)
+}
+
+// paymentErrorStatus 將支付服務錯誤對應到HTTP狀態碼
+func paymentErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrPaymentNotFound),
+		errors.Is(err, service.ErrOrderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInvalidAmount):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrOrderNotPayable),
+		errors.Is(err, service.ErrIdempotencyKeyReused):
+		return http.StatusConflict
+	case errors.Is(err, service.ErrPaymentDeclined):
+		return http.StatusPaymentRequired
+	case errors.Is(err, model.ErrIllegalTransition):
+		return http.StatusConflict
+	case errors.Is(err, gateway.ErrTimeout):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, service.ErrPaymentFailed),
+		errors.Is(err, gateway.ErrUnknownAuthorization),
+		errors.Is(err, gateway.ErrInvalidState),
+		errors.Is(err, gateway.ErrInvalidAmount):
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
@@ -0,0 +1,112 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/google/uuid"
+)
+
+// PaymentStatus 支付狀態枚舉
+type PaymentStatus string
+
+const (
+	StatusPending           PaymentStatus = "PENDING"            // 已送出授權請求，尚未取得閘道回應
+	StatusAuthorized        PaymentStatus = "AUTHORIZED"         // 已授權，尚未請款
+	StatusCaptured          PaymentStatus = "CAPTURED"           // 已請款
+	StatusVoided            PaymentStatus = "VOIDED"             // 授權已撤銷
+	StatusPartiallyRefunded PaymentStatus = "PARTIALLY_REFUNDED" // 部分退款
+	StatusRefunded          PaymentStatus = "REFUNDED"           // 全額退款
+	StatusDeclined          PaymentStatus = "DECLINED"           // 授權遭拒
+	StatusFailed            PaymentStatus = "FAILED"             // 閘道錯誤或逾時
+)
+
+// ErrIllegalTransition 支付狀態轉換不在允許的轉換圖中
+var ErrIllegalTransition = errors.New("不允許的支付狀態轉換")
+
+// statusTransitions 支付狀態轉換圖
+var statusTransitions = map[PaymentStatus][]PaymentStatus{
+	StatusPending:           {StatusAuthorized, StatusCaptured, StatusDeclined, StatusFailed},
+	StatusAuthorized:        {StatusCaptured, StatusVoided},
+	StatusCaptured:          {StatusPartiallyRefunded, StatusRefunded},
+	StatusPartiallyRefunded: {StatusPartiallyRefunded, StatusRefunded},
+	StatusVoided:            {},
+	StatusRefunded:          {},
+	StatusDeclined:          {},
+	StatusFailed:            {},
+}
+
+// IsValid 檢查是否為已定義的支付狀態
+func (s PaymentStatus) IsValid() bool {
+	_, ok := statusTransitions[s]
+	return ok
+}
+
+// CanTransitionTo 檢查是否允許從目前狀態轉換到指定狀態
+func (s PaymentStatus) CanTransitionTo(next PaymentStatus) bool {
+	for _, allowed := range statusTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition 驗證狀態轉換，不允許時回傳包裝 ErrIllegalTransition 的錯誤
+func ValidateTransition(from, to PaymentStatus) error {
+	if !from.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
+
+// EventProducer 支付服務發布事件時使用的生產者名稱
+const EventProducer = "payment-service"
+
+// Payment 支付模型，請款與退款金額的幣別與授權金額相同
+type Payment struct {
+	ID             string        `json:"id"`
+	OrderID        string        `json:"order_id"`
+	UserID         string        `json:"user_id"`
+	IdempotencyKey string        `json:"idempotency_key,omitempty"`
+	Amount         money.Money   `json:"amount"`
+	CapturedAmount money.Money   `json:"captured_amount"`
+	RefundedAmount money.Money   `json:"refunded_amount"`
+	Status         PaymentStatus `json:"status"`
+	Gateway        string        `json:"gateway"`
+	GatewayRef     string        `json:"gateway_ref,omitempty"`
+	CardLast4      string        `json:"card_last4,omitempty"`
+	FailureCode    string        `json:"failure_code,omitempty"`
+	FailureMessage string        `json:"failure_message,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+	UpdatedAt      time.Time     `json:"updated_at"`
+}
+
+// NewPayment 創建待授權的支付
+func NewPayment(orderID, userID string, amount money.Money, gateway string) *Payment {
+	now := time.Now()
+	return &Payment{
+		ID:             uuid.NewString(),
+		OrderID:        orderID,
+		UserID:         userID,
+		Amount:         amount,
+		CapturedAmount: money.Zero(amount.Currency),
+		RefundedAmount: money.Zero(amount.Currency),
+		Status:         StatusPending,
+		Gateway:        gateway,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// Currency 支付的幣別
+func (p *Payment) Currency() string {
+	return p.Amount.Currency
+}
+
+// RefundableAmount 尚可退款的金額
+func (p *Payment) RefundableAmount() money.Money {
+	return money.New(p.CapturedAmount.Amount-p.RefundedAmount.Amount, p.Currency())
+}
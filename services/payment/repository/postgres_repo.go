@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/pkg/outbox"
+	"github.com/arrontsai/ecommerce/services/payment/model"
+	"github.com/jmoiron/sqlx"
+)
+
+// ErrStaleUpdate 支付在讀取後已被其他請求更新
+var ErrStaleUpdate = errors.New("支付已被其他請求更新")
+
+const paymentColumns = `payment_id, order_id, user_id, COALESCE(idempotency_key, '') AS idempotency_key,
+	amount, captured_amount, refunded_amount, currency, status, gateway,
+	gateway_ref, card_last4, failure_code, failure_message, created_at, updated_at`
+
+// OutboxTable 支付服務的外寄事件資料表，與訂單服務共用資料庫時互不干擾
+const OutboxTable = "payment_outbox"
+
+// paymentRow 對應 payments 資料表的一列，金額以幣別的最小單位儲存
+type paymentRow struct {
+	ID             string              `db:"payment_id"`
+	OrderID        string              `db:"order_id"`
+	UserID         string              `db:"user_id"`
+	IdempotencyKey string              `db:"idempotency_key"`
+	Amount         int64               `db:"amount"`
+	CapturedAmount int64               `db:"captured_amount"`
+	RefundedAmount int64               `db:"refunded_amount"`
+	Currency       string              `db:"currency"`
+	Status         model.PaymentStatus `db:"status"`
+	Gateway        string              `db:"gateway"`
+	GatewayRef     string              `db:"gateway_ref"`
+	CardLast4      string              `db:"card_last4"`
+	FailureCode    string              `db:"failure_code"`
+	FailureMessage string              `db:"failure_message"`
+	CreatedAt      time.Time           `db:"created_at"`
+	UpdatedAt      time.Time           `db:"updated_at"`
+}
+
+func newPaymentRow(payment *model.Payment) paymentRow {
+	return paymentRow{
+		ID:             payment.ID,
+		OrderID:        payment.OrderID,
+		UserID:         payment.UserID,
+		IdempotencyKey: payment.IdempotencyKey,
+		Amount:         payment.Amount.Amount,
+		CapturedAmount: payment.CapturedAmount.Amount,
+		RefundedAmount: payment.RefundedAmount.Amount,
+		Currency:       payment.Currency(),
+		Status:         payment.Status,
+		Gateway:        payment.Gateway,
+		GatewayRef:     payment.GatewayRef,
+		CardLast4:      payment.CardLast4,
+		FailureCode:    payment.FailureCode,
+		FailureMessage: payment.FailureMessage,
+		CreatedAt:      payment.CreatedAt,
+		UpdatedAt:      payment.UpdatedAt,
+	}
+}
+
+func (row paymentRow) toModel() model.Payment {
+	return model.Payment{
+		ID:             row.ID,
+		OrderID:        row.OrderID,
+		UserID:         row.UserID,
+		IdempotencyKey: row.IdempotencyKey,
+		Amount:         money.New(row.Amount, row.Currency),
+		CapturedAmount: money.New(row.CapturedAmount, row.Currency),
+		RefundedAmount: money.New(row.RefundedAmount, row.Currency),
+		Status:         row.Status,
+		Gateway:        row.Gateway,
+		GatewayRef:     row.GatewayRef,
+		CardLast4:      row.CardLast4,
+		FailureCode:    row.FailureCode,
+		FailureMessage: row.FailureMessage,
+		CreatedAt:      row.CreatedAt,
+		UpdatedAt:      row.UpdatedAt,
+	}
+}
+
+type PaymentRepository struct {
+	db     *sqlx.DB
+	outbox *outbox.PostgresStore
+}
+
+func NewPaymentRepo(db *sqlx.DB) *PaymentRepository {
+	return &PaymentRepository{db: db, outbox: outbox.NewPostgresStoreWithTable(db, OutboxTable)}
+}
+
+// Outbox 回傳支付服務的外寄事件儲存，供事件轉發器使用
+func (r *PaymentRepository) Outbox() *outbox.PostgresStore {
+	return r.outbox
+}
+
+// CreatePayment 寫入新支付；冪等鍵已存在時不寫入並回傳 false
+func (r *PaymentRepository) CreatePayment(ctx context.Context, payment *model.Payment) (bool, error) {
+	result, err := r.db.NamedExecContext(ctx,
+		`INSERT INTO payments (payment_id, order_id, user_id, idempotency_key, amount, captured_amount,
+			refunded_amount, currency, status, gateway, gateway_ref, card_last4, failure_code,
+			failure_message, created_at, updated_at)
+		VALUES (:payment_id, :order_id, :user_id, NULLIF(:idempotency_key, ''), :amount, :captured_amount,
+			:refunded_amount, :currency, :status, :gateway, :gateway_ref, :card_last4, :failure_code,
+			:failure_message, :created_at, :updated_at)
+		ON CONFLICT (idempotency_key) DO NOTHING`, newPaymentRow(payment))
+	if err != nil {
+		return false, fmt.Errorf("插入支付失敗: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// GetPayment 依支付 ID 查詢，不存在時回傳 nil
+func (r *PaymentRepository) GetPayment(ctx context.Context, paymentID string) (*model.Payment, error) {
+	return r.getOne(ctx, `SELECT `+paymentColumns+` FROM payments WHERE payment_id = $1`, paymentID)
+}
+
+// GetPaymentByIdempotencyKey 依冪等鍵查詢，不存在時回傳 nil
+func (r *PaymentRepository) GetPaymentByIdempotencyKey(ctx context.Context, key string) (*model.Payment, error) {
+	return r.getOne(ctx, `SELECT `+paymentColumns+` FROM payments WHERE idempotency_key = $1`, key)
+}
+
+// ListPaymentsByOrder 查詢訂單的所有支付，依建立時間排序
+func (r *PaymentRepository) ListPaymentsByOrder(ctx context.Context, orderID string) ([]model.Payment, error) {
+	var rows []paymentRow
+	err := r.db.SelectContext(ctx, &rows,
+		`SELECT `+paymentColumns+` FROM payments WHERE order_id = $1 ORDER BY created_at, payment_id`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢訂單支付失敗: %w", err)
+	}
+
+	payments := make([]model.Payment, len(rows))
+	for i, row := range rows {
+		payments[i] = row.toModel()
+	}
+	return payments, nil
+}
+
+// UpdatePayment 更新支付狀態與金額，僅在目前狀態仍為 from 時成功；
+// 事件與更新在同一交易中寫入外寄事件表
+func (r *PaymentRepository) UpdatePayment(ctx context.Context, payment *model.Payment, from model.PaymentStatus, evts ...events.Event) error {
+	payment.UpdatedAt = time.Now()
+
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("開始交易失敗: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx,
+		`UPDATE payments SET status = $1, captured_amount = $2, refunded_amount = $3, gateway_ref = $4,
+			failure_code = $5, failure_message = $6, updated_at = $7
+		WHERE payment_id = $8 AND status = $9`,
+		payment.Status, payment.CapturedAmount.Amount, payment.RefundedAmount.Amount, payment.GatewayRef,
+		payment.FailureCode, payment.FailureMessage, payment.UpdatedAt, payment.ID, from,
+	)
+	if err != nil {
+		return fmt.Errorf("更新支付失敗: %w", err)
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrStaleUpdate
+	}
+
+	for _, event := range evts {
+		encoded, err := events.Encode(ctx, model.EventProducer, event)
+		if err != nil {
+			return err
+		}
+		if err := r.outbox.Add(ctx, tx, encoded.Topic, encoded.Key, encoded.Data); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("提交交易失敗: %w", err)
+	}
+	return nil
+}
+
+func (r *PaymentRepository) getOne(ctx context.Context, query string, args ...interface{}) (*model.Payment, error) {
+	var row paymentRow
+	if err := r.db.GetContext(ctx, &row, query, args...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("查詢支付失敗: %w", err)
+	}
+	payment := row.toModel()
+	return &payment, nil
+}
@@ -0,0 +1,51 @@
+package service
+
+import (
+	"context"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+	orderpb "github.com/arrontsai/ecommerce/services/order/proto/pb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Order 授權支付所需的訂單資訊
+type Order struct {
+	ID         string
+	UserID     string
+	Status     string
+	TotalPrice money.Money
+}
+
+// Orders 查詢訂單，不存在時回傳 nil
+type Orders interface {
+	GetOrder(ctx context.Context, orderID string) (*Order, error)
+}
+
+// OrderClient 透過訂單服務的gRPC接口查詢訂單
+type OrderClient struct {
+	client orderpb.OrderServiceClient
+}
+
+// NewOrderClient 創建以訂單服務查詢訂單的 Orders
+func NewOrderClient(client orderpb.OrderServiceClient) *OrderClient {
+	return &OrderClient{client: client}
+}
+
+// GetOrder 實作 Orders
+func (c *OrderClient) GetOrder(ctx context.Context, orderID string) (*Order, error) {
+	resp, err := c.client.GetOrder(ctx, &orderpb.GetOrderRequest{OrderId: orderID})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &Order{
+		ID:         resp.OrderId,
+		UserID:     resp.UserId,
+		Status:     resp.Status,
+		TotalPrice: resp.TotalAmount.ToMoney(),
+	}, nil
+}
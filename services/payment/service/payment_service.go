@@ -1,42 +1,385 @@
 package service
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
-	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/services/payment/gateway"
+	"github.com/arrontsai/ecommerce/services/payment/model"
+	"github.com/arrontsai/ecommerce/services/payment/repository"
+)
+
+var (
+	// ErrPaymentNotFound 支付不存在
+	ErrPaymentNotFound = errors.New("支付不存在")
+	// ErrPaymentDeclined 授權遭發卡行拒絕
+	ErrPaymentDeclined = errors.New("支付遭拒")
+	// ErrPaymentFailed 閘道錯誤或逾時導致授權失敗
+	ErrPaymentFailed = errors.New("支付失敗")
+	// ErrInvalidAmount 金額無效、幣別與支付不同或超出可處理的金額
+	ErrInvalidAmount = errors.New("無效的支付金額")
+	// ErrOrderNotFound 訂單不存在或不屬於該用戶
+	ErrOrderNotFound = errors.New("訂單不存在")
+	// ErrOrderNotPayable 訂單已付款、出貨或取消，不能再付款
+	ErrOrderNotPayable = errors.New("訂單目前不能付款")
+	// ErrIdempotencyKeyReused 冪等鍵已用於其他訂單、用戶或金額的支付
+	ErrIdempotencyKeyReused = errors.New("冪等鍵已用於其他支付")
 )
 
+// payableStatus 只有待處理的訂單可以付款
+const payableStatus = "PENDING"
+
+// ClientIdempotencyKey 以用戶ID區隔用戶提供的冪等鍵，不同用戶的鍵不會相撞，
+// 也不會與 events.OrderPaymentKey 等服務內部的鍵相同
+func ClientIdempotencyKey(userID, key string) string {
+	if key == "" {
+		return ""
+	}
+	return "user:" + userID + ":" + key
+}
+
+// AuthorizeInput 授權支付的輸入，金額未指定幣別時使用 money.DefaultCurrency
+type AuthorizeInput struct {
+	OrderID string
+	UserID  string
+	Amount  money.Money
+	Card    gateway.Card
+	// PaymentMethod 閘道端已保存的付款方式，提供時不需要卡片資料
+	PaymentMethod string
+	// Capture 授權成功後立即請款
+	Capture bool
+	// IdempotencyKey 相同的鍵只會授權一次，重複請求回傳第一次的結果；
+	// 用戶提供的鍵需以 ClientIdempotencyKey 區隔
+	IdempotencyKey string
+}
+
 // PaymentService 處理支付相關業務邏輯
 type PaymentService struct {
-	// 支付閘道整合
-	ApiKey     string
-	MerchantID string
-	IsTestMode bool
+	repo    *repository.PaymentRepository
+	gateway gateway.PaymentGateway
+	orders  Orders
 }
 
 // NewPaymentService 創建支付服務實例
-func NewPaymentService(apiKey, merchantID string, isTestMode bool) *PaymentService {
+func NewPaymentService(repo *repository.PaymentRepository, gw gateway.PaymentGateway, orders Orders) *PaymentService {
 	return &PaymentService{
-		ApiKey:     apiKey,
-		MerchantID: merchantID,
-		IsTestMode: isTestMode,
-	}
-}
-
-// ProcessPayment 處理支付請求
-func ProcessPayment(amount float64) (bool, error) {
-	// 支付處理邏輯
-	log.Printf("處理支付請求: $%.2f", amount)
-	
-	// 模擬支付處理延遲
-	time.Sleep(500 * time.Millisecond)
-	
-	// 簡單模擬 - 金額大於 0 且小於 10000 則支付成功
-	if amount > 0 && amount < 10000 {
-		log.Println("支付成功")
-		return true, nil
-	}
-	
-	log.Println("支付失敗")
-	return false, fmt.Errorf("支付失敗")
+		repo:    repo,
+		gateway: gw,
+		orders:  orders,
+	}
+}
+
+// AuthorizeOrder 為用戶自己的待處理訂單授權支付，金額一律使用訂單總金額
+//
+// 不存在或屬於其他用戶的訂單都回傳 ErrOrderNotFound；in.Amount 有指定時
+// 必須與訂單總金額相同。
+func (s *PaymentService) AuthorizeOrder(ctx context.Context, in AuthorizeInput) (*model.Payment, error) {
+	order, err := s.orders.GetOrder(ctx, in.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("查詢訂單失敗: %w", err)
+	}
+	if order == nil || order.UserID != in.UserID {
+		return nil, ErrOrderNotFound
+	}
+	if order.Status != payableStatus {
+		return nil, fmt.Errorf("%w: 訂單狀態為 %s", ErrOrderNotPayable, order.Status)
+	}
+	if !in.Amount.IsZero() && in.Amount != order.TotalPrice {
+		return nil, fmt.Errorf("%w: 訂單總金額為 %s", ErrInvalidAmount, order.TotalPrice)
+	}
+
+	in.Amount = order.TotalPrice
+	return s.Authorize(ctx, in)
+}
+
+// Authorize 建立支付並向閘道授權
+//
+// 支付會先以 PENDING 狀態寫入，再依閘道結果更新為 AUTHORIZED、DECLINED 或
+// FAILED，因此即使閘道逾時也會留下紀錄。遭拒時回傳支付與包裝
+// ErrPaymentDeclined 的錯誤。冪等鍵對應的支付屬於其他訂單、用戶或金額時
+// 回傳 ErrIdempotencyKeyReused。
+func (s *PaymentService) Authorize(ctx context.Context, in AuthorizeInput) (*model.Payment, error) {
+	if in.Amount.Currency == "" {
+		in.Amount.Currency = money.DefaultCurrency
+	}
+	if err := in.Amount.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidAmount, err)
+	}
+	if !in.Amount.IsPositive() {
+		return nil, ErrInvalidAmount
+	}
+
+	if in.IdempotencyKey != "" {
+		existing, err := s.repo.GetPaymentByIdempotencyKey(ctx, in.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			if !sameRequest(existing, in) {
+				return nil, ErrIdempotencyKeyReused
+			}
+			// 上次授權後尚未請款即中斷時，重試會完成請款
+			if in.Capture && existing.Status == model.StatusAuthorized {
+				return s.Capture(ctx, existing.ID, money.Money{})
+			}
+			return existing, outcomeError(existing)
+		}
+	}
+
+	payment := model.NewPayment(in.OrderID, in.UserID, in.Amount, s.gateway.Name())
+	payment.IdempotencyKey = in.IdempotencyKey
+	if in.PaymentMethod == "" {
+		payment.CardLast4 = in.Card.Last4()
+	}
+
+	created, err := s.repo.CreatePayment(ctx, payment)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		// 並行請求使用相同冪等鍵，回傳先寫入的支付
+		existing, err := s.repo.GetPaymentByIdempotencyKey(ctx, in.IdempotencyKey)
+		if err != nil {
+			return nil, err
+		}
+		if !sameRequest(existing, in) {
+			return nil, ErrIdempotencyKeyReused
+		}
+		return existing, outcomeError(existing)
+	}
+
+	result, gwErr := s.gateway.Authorize(ctx, gateway.AuthorizeRequest{
+		PaymentID:     payment.ID,
+		Amount:        payment.Amount,
+		Card:          in.Card,
+		PaymentMethod: in.PaymentMethod,
+	})
+
+	// 請求可能已被取消，閘道結果仍須寫回
+	persistCtx := context.WithoutCancel(ctx)
+
+	if gwErr != nil {
+		payment.Status, payment.FailureCode = failureStatus(gwErr)
+		payment.FailureMessage = gwErr.Error()
+		if err := s.repo.UpdatePayment(persistCtx, payment, model.StatusPending, failedEvents(payment)...); err != nil {
+			return nil, err
+		}
+		log.Printf("支付 %s 授權失敗: %v", payment.ID, gwErr)
+		return payment, outcomeError(payment)
+	}
+
+	payment.Status = model.StatusAuthorized
+	payment.GatewayRef = result.Reference
+	if err := s.repo.UpdatePayment(persistCtx, payment, model.StatusPending); err != nil {
+		return nil, err
+	}
+
+	if in.Capture {
+		return s.Capture(ctx, payment.ID, money.Money{})
+	}
+	return payment, nil
+}
+
+// sameRequest 冪等鍵找到的支付是否對應同一訂單、用戶與金額
+func sameRequest(payment *model.Payment, in AuthorizeInput) bool {
+	return payment.OrderID == in.OrderID && payment.UserID == in.UserID && payment.Amount == in.Amount
+}
+
+// Capture 對已授權的支付請款，amount 為 0 時請款全額
+func (s *PaymentService) Capture(ctx context.Context, paymentID string, amount money.Money) (*model.Payment, error) {
+	payment, err := s.GetPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.ValidateTransition(payment.Status, model.StatusCaptured); err != nil {
+		return nil, err
+	}
+
+	if amount.IsZero() {
+		amount = payment.Amount
+	}
+	if !amount.SameCurrency(payment.Amount) || amount.IsNegative() || amount.Amount > payment.Amount.Amount {
+		return nil, ErrInvalidAmount
+	}
+
+	if _, err := s.gateway.Capture(ctx, payment.GatewayRef, amount); err != nil {
+		return nil, fmt.Errorf("請款失敗: %w", err)
+	}
+
+	from := payment.Status
+	payment.Status = model.StatusCaptured
+	payment.CapturedAmount = amount
+	if err := s.repo.UpdatePayment(context.WithoutCancel(ctx), payment, from, succeededEvents(payment)...); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// Void 撤銷尚未請款的授權
+func (s *PaymentService) Void(ctx context.Context, paymentID string) (*model.Payment, error) {
+	payment, err := s.GetPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if err := model.ValidateTransition(payment.Status, model.StatusVoided); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.gateway.Void(ctx, payment.GatewayRef); err != nil {
+		return nil, fmt.Errorf("撤銷授權失敗: %w", err)
+	}
+
+	from := payment.Status
+	payment.Status = model.StatusVoided
+	if err := s.repo.UpdatePayment(context.WithoutCancel(ctx), payment, from); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// Refund 退還已請款的金額，amount 為 0 時退還所有剩餘金額
+func (s *PaymentService) Refund(ctx context.Context, paymentID string, amount money.Money) (*model.Payment, error) {
+	payment, err := s.GetPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	refundable := payment.RefundableAmount()
+	if amount.IsZero() {
+		amount = refundable
+	}
+	if !amount.SameCurrency(refundable) || !amount.IsPositive() || amount.Amount > refundable.Amount {
+		return nil, ErrInvalidAmount
+	}
+
+	next := model.StatusPartiallyRefunded
+	if amount == refundable {
+		next = model.StatusRefunded
+	}
+	if err := model.ValidateTransition(payment.Status, next); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.gateway.Refund(ctx, payment.GatewayRef, amount); err != nil {
+		return nil, fmt.Errorf("退款失敗: %w", err)
+	}
+
+	from := payment.Status
+	payment.Status = next
+	payment.RefundedAmount = money.New(payment.RefundedAmount.Amount+amount.Amount, payment.Currency())
+	if err := s.repo.UpdatePayment(context.WithoutCancel(ctx), payment, from); err != nil {
+		return nil, err
+	}
+
+	return payment, nil
+}
+
+// Reverse 撤銷或退還支付：已授權的撤銷授權，已請款的退還剩餘金額，其他狀態不需處理
+func (s *PaymentService) Reverse(ctx context.Context, paymentID, reason string) (*model.Payment, error) {
+	payment, err := s.GetPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch payment.Status {
+	case model.StatusAuthorized:
+		log.Printf("撤銷支付 %s 的授權: %s", payment.ID, reason)
+		return s.Void(ctx, payment.ID)
+	case model.StatusCaptured, model.StatusPartiallyRefunded:
+		log.Printf("退還支付 %s: %s", payment.ID, reason)
+		return s.Refund(ctx, payment.ID, money.Money{})
+	}
+	return payment, nil
+}
+
+// ReverseOrderPayments 撤銷或退還訂單的所有支付，用於訂單取消後的補償
+func (s *PaymentService) ReverseOrderPayments(ctx context.Context, orderID, reason string) error {
+	payments, err := s.repo.ListPaymentsByOrder(ctx, orderID)
+	if err != nil {
+		return err
+	}
+
+	for _, payment := range payments {
+		if _, err := s.Reverse(ctx, payment.ID, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetPayment 依支付 ID 獲取支付
+func (s *PaymentService) GetPayment(ctx context.Context, paymentID string) (*model.Payment, error) {
+	payment, err := s.repo.GetPayment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, ErrPaymentNotFound
+	}
+	return payment, nil
+}
+
+// ListOrderPayments 獲取訂單的所有支付
+func (s *PaymentService) ListOrderPayments(ctx context.Context, orderID string) ([]model.Payment, error) {
+	return s.repo.ListPaymentsByOrder(ctx, orderID)
+}
+
+// succeededEvents 訂單支付請款成功時發布 PaymentSucceeded
+func succeededEvents(payment *model.Payment) []events.Event {
+	if payment.OrderID == "" {
+		return nil
+	}
+	return []events.Event{events.PaymentSucceeded{
+		PaymentID:      payment.ID,
+		OrderID:        payment.OrderID,
+		UserID:         payment.UserID,
+		Amount:         payment.CapturedAmount,
+		IdempotencyKey: payment.IdempotencyKey,
+	}}
+}
+
+// failedEvents 訂單支付授權失敗時發布 PaymentFailed
+func failedEvents(payment *model.Payment) []events.Event {
+	if payment.OrderID == "" {
+		return nil
+	}
+	return []events.Event{events.PaymentFailed{
+		PaymentID:      payment.ID,
+		OrderID:        payment.OrderID,
+		UserID:         payment.UserID,
+		Amount:         payment.Amount,
+		FailureCode:    payment.FailureCode,
+		FailureMessage: payment.FailureMessage,
+		IdempotencyKey: payment.IdempotencyKey,
+	}}
+}
+
+// failureStatus 將閘道錯誤對應到支付狀態與失敗代碼
+func failureStatus(err error) (model.PaymentStatus, string) {
+	switch {
+	case errors.Is(err, gateway.ErrCardDeclined):
+		return model.StatusDeclined, "card_declined"
+	case errors.Is(err, gateway.ErrInsufficientFunds):
+		return model.StatusDeclined, "insufficient_funds"
+	case errors.Is(err, gateway.ErrTimeout):
+		return model.StatusFailed, "gateway_timeout"
+	}
+	return model.StatusFailed, "gateway_error"
+}
+
+// outcomeError 依支付的最終狀態回傳對應錯誤，讓重複請求得到相同結果
+func outcomeError(payment *model.Payment) error {
+	switch payment.Status {
+	case model.StatusDeclined:
+		return fmt.Errorf("%w: %s", ErrPaymentDeclined, payment.FailureMessage)
+	case model.StatusFailed:
+		return fmt.Errorf("%w: %s", ErrPaymentFailed, payment.FailureMessage)
+	}
+	return nil
 }
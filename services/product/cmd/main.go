@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -13,12 +15,18 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/arrontsai/ecommerce/pkg/config"
 	"github.com/arrontsai/ecommerce/pkg/database"
+	"github.com/arrontsai/ecommerce/pkg/jwks"
+	"github.com/arrontsai/ecommerce/pkg/events"
+	"github.com/arrontsai/ecommerce/pkg/exchange"
 	"github.com/arrontsai/ecommerce/pkg/logger"
+	"github.com/arrontsai/ecommerce/pkg/messaging"
 	"github.com/arrontsai/ecommerce/pkg/middleware"
 	"github.com/arrontsai/ecommerce/services/product/handler"
+	"github.com/arrontsai/ecommerce/services/product/proto/pb"
 	"github.com/arrontsai/ecommerce/services/product/repository"
 	"github.com/arrontsai/ecommerce/services/product/service"
 	"go.uber.org/zap"
+	"google.golang.org/grpc"
 )
 
 func main() {
@@ -45,14 +53,44 @@ func main() {
 	// Initialize repositories
 	productRepo := repository.NewMongoProductRepository(mongoClient.DB)
 	categoryRepo := repository.NewMongoCategoryRepository(mongoClient.DB)
+	inventoryRepo := repository.NewMongoInventoryRepository(mongoClient.DB)
+
+	// Exchange rates price products in currencies without a list price
+	// (EXCHANGE_RATES=file, http or none)
+	rates, err := exchange.New(exchange.Config{
+		Driver:   cfg.ExchangeRates,
+		File:     cfg.ExchangeRatesFile,
+		URL:      cfg.ExchangeRatesURL,
+		TTL:      time.Duration(cfg.ExchangeRatesTTL) * time.Second,
+		MaxStale: time.Duration(cfg.ExchangeRatesMaxStale) * time.Second,
+	})
+	if err != nil {
+		appLogger.Fatal("Failed to initialize exchange rates", zap.Error(err))
+	}
 
 	// Initialize services
 	productService := service.NewProductService(productRepo, categoryRepo)
+	priceService := service.NewPriceService(rates)
 	categoryService := service.NewCategoryService(categoryRepo, productRepo)
+	inventoryService := service.NewInventoryService(inventoryRepo)
 
 	// Initialize handlers
-	productHandler := handler.NewProductHandler(productService)
+	productHandler := handler.NewProductHandler(productService, priceService)
 	categoryHandler := handler.NewCategoryHandler(categoryService)
+	inventoryHandler := handler.NewInventoryHandler(inventoryService)
+
+	// Initialize message broker (KAFKA_BROKERS=memory:// selects the in-memory broker)
+	broker, err := messaging.NewBroker(cfg.KafkaBrokers, appLogger.Logger)
+	if err != nil {
+		appLogger.Fatal("Failed to initialize message broker", zap.Error(err))
+	}
+	defer broker.Close()
+
+	// Commit or release reservations as orders are paid or cancelled
+	go subscribeToOrderEvents(broker, inventoryService, appLogger.Logger)
+
+	// Release reservations whose TTL has passed
+	go sweepExpiredReservations(inventoryService, appLogger.Logger)
 
 	// Initialize Gin router
 	router := gin.Default()
@@ -61,12 +99,17 @@ func main() {
 	router.Use(gin.Recovery())
 	router.Use(gin.Logger())
 
-	// Create JWT auth middleware
-	jwtMiddleware := middleware.JWTAuthMiddleware(cfg.JWTSecret)
+	// Create JWT auth middleware, verifying with the auth service JWKS when configured
+	authOptions := []middleware.AuthOption{middleware.WithDenylist(middleware.NewMongoTokenDenylist(mongoClient.DB))}
+	if cfg.JWKSURL != "" {
+		authOptions = append(authOptions, middleware.WithKeyProvider(jwks.NewRemoteKeySet(cfg.JWKSURL)))
+	}
+	jwtMiddleware := middleware.JWTAuthMiddleware(cfg.JWTSecret, authOptions...)
 
 	// Register routes
 	productHandler.RegisterRoutes(router, jwtMiddleware)
 	categoryHandler.RegisterRoutes(router, jwtMiddleware)
+	inventoryHandler.RegisterRoutes(router, jwtMiddleware)
 
 	// Health check endpoint
 	router.GET("/health", func(c *gin.Context) {
@@ -87,6 +130,22 @@ func main() {
 		}
 	}()
 
+	// Start the gRPC server for the order service
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", cfg.GrpcPort))
+	if err != nil {
+		appLogger.Fatal("Failed to listen for gRPC", zap.Error(err))
+	}
+	grpcServer := grpc.NewServer()
+	pb.RegisterInventoryServiceServer(grpcServer, handler.NewInventoryGRPCServer(inventoryService))
+	pb.RegisterCatalogServiceServer(grpcServer, handler.NewCatalogGRPCServer(productService, priceService))
+
+	go func() {
+		appLogger.Info("Starting gRPC server", zap.Int("port", cfg.GrpcPort))
+		if err := grpcServer.Serve(lis); err != nil {
+			appLogger.Fatal("Failed to start gRPC server", zap.Error(err))
+		}
+	}()
+
 	// Wait for interrupt signal to gracefully shut down the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -97,7 +156,8 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 
-	// Shutdown the server
+	// Shutdown the servers
+	grpcServer.GracefulStop()
 	if err := server.Shutdown(ctx); err != nil {
 		appLogger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
@@ -105,3 +165,52 @@ func main() {
 	appLogger.Info("Server exiting")
 }
 
+// subscribeToOrderEvents commits reservations of paid orders and releases those of cancelled orders
+func subscribeToOrderEvents(broker messaging.Broker, inventoryService service.InventoryService, logger *zap.Logger) {
+	mux := events.NewMux(nil)
+
+	events.Handle(mux, func(ctx context.Context, env *events.Envelope, event events.OrderStatusChanged) error {
+		var err error
+		switch event.ToStatus {
+		case "PAID":
+			_, err = inventoryService.Commit(ctx, event.OrderID)
+		case "CANCELLED":
+			_, err = inventoryService.Release(ctx, event.OrderID, event.Reason)
+		default:
+			return nil
+		}
+
+		switch {
+		case err == nil:
+			logger.Info("Reservation updated", zap.String("order_id", event.OrderID), zap.String("status", event.ToStatus))
+			return nil
+		case errors.Is(err, service.ErrReservationNotFound):
+			// Orders created before reservations existed have nothing to apply
+			return nil
+		case errors.Is(err, service.ErrReservationReleased), errors.Is(err, service.ErrReservationCommitted):
+			return messaging.Fatal(err)
+		}
+		return err
+	})
+
+	if err := messaging.SubscribeEvents(context.Background(), broker, events.TopicOrderEvents, "product-service", mux); err != nil {
+		logger.Fatal("Failed to consume order events", zap.Error(err))
+	}
+}
+
+// sweepExpiredReservations periodically releases reservations whose TTL has passed
+func sweepExpiredReservations(inventoryService service.InventoryService, logger *zap.Logger) {
+	ticker := time.NewTicker(30 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		released, err := inventoryService.ReleaseExpired(context.Background(), time.Now())
+		if err != nil {
+			logger.Error("Failed to release expired reservations", zap.Error(err))
+			continue
+		}
+		if released > 0 {
+			logger.Info("Released expired reservations", zap.Int("count", released))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/arrontsai/ecommerce/pkg/money"
+	"github.com/arrontsai/ecommerce/pkg/money/moneypb"
+	"github.com/arrontsai/ecommerce/services/product/proto/pb"
+	"github.com/arrontsai/ecommerce/services/product/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// CatalogGRPCServer implements the catalog gRPC service
+type CatalogGRPCServer struct {
+	pb.UnimplementedCatalogServiceServer
+	productService service.ProductService
+	priceService   service.PriceService
+}
+
+// NewCatalogGRPCServer creates a new CatalogGRPCServer
+func NewCatalogGRPCServer(productService service.ProductService, priceService service.PriceService) *CatalogGRPCServer {
+	return &CatalogGRPCServer{
+		productService: productService,
+		priceService:   priceService,
+	}
+}
+
+// GetProducts returns the current price and available stock of products,
+// priced in the requested currency if there is one
+func (s *CatalogGRPCServer) GetProducts(ctx context.Context, req *pb.GetProductsRequest) (*pb.GetProductsResponse, error) {
+	if req.Currency != "" && !money.IsKnownCurrency(req.Currency) {
+		return nil, status.Errorf(codes.InvalidArgument, "不支援的幣別: %s", req.Currency)
+	}
+
+	products, err := s.productService.GetProductsByIDs(ctx, req.ProductIds)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	resp := &pb.GetProductsResponse{Products: make([]*pb.ProductSummary, 0, len(products))}
+	for _, product := range products {
+		price, converted := product.Price, false
+		if req.Currency != "" {
+			price, converted, err = s.priceService.PriceIn(ctx, product, req.Currency)
+			if err != nil {
+				if errors.Is(err, service.ErrPriceUnavailable) {
+					return nil, status.Error(codes.FailedPrecondition, err.Error())
+				}
+				return nil, status.Error(codes.Unavailable, err.Error())
+			}
+		}
+
+		resp.Products = append(resp.Products, &pb.ProductSummary{
+			ProductId:      product.ID,
+			Name:           product.Name,
+			Price:          moneypb.FromMoney(price),
+			CategoryId:     product.CategoryID,
+			Available:      int32(max(product.Available(), 0)),
+			PriceConverted: converted,
+			TaxCategory:    product.TaxCategory,
+			Weight:         int32(product.Weight),
+			Length:         int32(product.Dimensions.Length),
+			Width:          int32(product.Dimensions.Width),
+			Height:         int32(product.Dimensions.Height),
+		})
+	}
+	return resp, nil
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/arrontsai/ecommerce/pkg/middleware"
 	"github.com/arrontsai/ecommerce/pkg/models"
 	"github.com/arrontsai/ecommerce/services/product/service"
 )
@@ -115,11 +116,14 @@ func (h *CategoryHandler) RegisterRoutes(router *gin.Engine, authMiddleware gin.
 	{
 		categories.GET("", h.GetCategories)
 		categories.GET("/:id", h.GetCategory)
-		
-		// Protected routes
-		categories.POST("", authMiddleware, h.CreateCategory)
-		categories.PUT("/:id", authMiddleware, h.UpdateCategory)
-		categories.DELETE("/:id", authMiddleware, h.DeleteCategory)
+	}
+
+	// Catalog mutations are restricted to roles with catalog:write
+	admin := router.Group("/api/categories", authMiddleware, middleware.RequirePermissions(middleware.PermCatalogWrite))
+	{
+		admin.POST("", h.CreateCategory)
+		admin.PUT("/:id", h.UpdateCategory)
+		admin.DELETE("/:id", h.DeleteCategory)
 	}
 }
 
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/product/proto/pb"
+	"github.com/arrontsai/ecommerce/services/product/service"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// InventoryGRPCServer implements the inventory gRPC service
+type InventoryGRPCServer struct {
+	pb.UnimplementedInventoryServiceServer
+	inventoryService service.InventoryService
+}
+
+// NewInventoryGRPCServer creates a new InventoryGRPCServer
+func NewInventoryGRPCServer(inventoryService service.InventoryService) *InventoryGRPCServer {
+	return &InventoryGRPCServer{
+		inventoryService: inventoryService,
+	}
+}
+
+// ReserveStock reserves stock for an order
+func (s *InventoryGRPCServer) ReserveStock(ctx context.Context, req *pb.ReserveStockRequest) (*pb.ReservationResponse, error) {
+	if req.OrderId == "" || len(req.Items) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "訂單 ID 與預留項目不能為空")
+	}
+
+	items := make([]models.ReservationItem, 0, len(req.Items))
+	for _, item := range req.Items {
+		if item.ProductId == "" || item.Quantity <= 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "無效的預留項目: %s", item.ProductId)
+		}
+		items = append(items, models.ReservationItem{
+			ProductID: item.ProductId,
+			Quantity:  int(item.Quantity),
+		})
+	}
+
+	reservation, err := s.inventoryService.Reserve(ctx, models.ReserveStockRequest{
+		OrderID:    req.OrderId,
+		Items:      items,
+		TTLSeconds: int(req.TtlSeconds),
+	})
+	if err != nil {
+		return nil, reservationStatusError(err)
+	}
+
+	return toReservationResponse(reservation), nil
+}
+
+// CommitReservation deducts reserved stock
+func (s *InventoryGRPCServer) CommitReservation(ctx context.Context, req *pb.ReservationRequest) (*pb.ReservationResponse, error) {
+	reservation, err := s.inventoryService.Commit(ctx, req.OrderId)
+	if err != nil {
+		return nil, reservationStatusError(err)
+	}
+
+	return toReservationResponse(reservation), nil
+}
+
+// ReleaseReservation returns reserved stock
+func (s *InventoryGRPCServer) ReleaseReservation(ctx context.Context, req *pb.ReservationRequest) (*pb.ReservationResponse, error) {
+	reservation, err := s.inventoryService.Release(ctx, req.OrderId, req.Reason)
+	if err != nil {
+		return nil, reservationStatusError(err)
+	}
+
+	return toReservationResponse(reservation), nil
+}
+
+// GetReservation gets the reservation of an order
+func (s *InventoryGRPCServer) GetReservation(ctx context.Context, req *pb.ReservationRequest) (*pb.ReservationResponse, error) {
+	reservation, err := s.inventoryService.GetReservation(ctx, req.OrderId)
+	if err != nil {
+		return nil, reservationStatusError(err)
+	}
+
+	return toReservationResponse(reservation), nil
+}
+
+// GetStock gets the available stock of products
+func (s *InventoryGRPCServer) GetStock(ctx context.Context, req *pb.GetStockRequest) (*pb.GetStockResponse, error) {
+	available, err := s.inventoryService.AvailableStock(ctx, req.ProductIds)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "查詢庫存失敗: %v", err)
+	}
+
+	stocks := make([]*pb.StockLevel, 0, len(available))
+	for productID, quantity := range available {
+		stocks = append(stocks, &pb.StockLevel{ProductId: productID, Available: int32(quantity)})
+	}
+	return &pb.GetStockResponse{Stocks: stocks}, nil
+}
+
+// reservationStatusError maps inventory service errors to gRPC status errors
+func reservationStatusError(err error) error {
+	switch {
+	case errors.Is(err, service.ErrReservationNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, service.ErrInsufficientStock),
+		errors.Is(err, service.ErrReservationReleased),
+		errors.Is(err, service.ErrReservationCommitted):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return status.Errorf(codes.Internal, "庫存預留操作失敗: %v", err)
+}
+
+// toReservationResponse converts a reservation to its gRPC representation
+func toReservationResponse(reservation *models.Reservation) *pb.ReservationResponse {
+	items := make([]*pb.ReservationItem, 0, len(reservation.Items))
+	for _, item := range reservation.Items {
+		items = append(items, &pb.ReservationItem{
+			ProductId: item.ProductID,
+			Quantity:  int32(item.Quantity),
+		})
+	}
+
+	return &pb.ReservationResponse{
+		OrderId:   reservation.OrderID,
+		Status:    string(reservation.Status),
+		Items:     items,
+		ExpiresAt: timestamppb.New(reservation.ExpiresAt),
+		Reason:    reservation.Reason,
+	}
+}
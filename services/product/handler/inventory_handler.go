@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/arrontsai/ecommerce/pkg/middleware"
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/product/service"
+	"github.com/gin-gonic/gin"
+)
+
+// InventoryHandler handles stock reservation HTTP requests
+type InventoryHandler struct {
+	inventoryService service.InventoryService
+}
+
+// NewInventoryHandler creates a new InventoryHandler
+func NewInventoryHandler(inventoryService service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{
+		inventoryService: inventoryService,
+	}
+}
+
+// ReleaseRequest represents the body of a release request
+type ReleaseRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReserveStock handles reserving stock for an order
+func (h *InventoryHandler) ReserveStock(c *gin.Context) {
+	var req models.ReserveStockRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
+
+	reservation, err := h.inventoryService.Reserve(c.Request.Context(), req)
+	if err != nil {
+		c.JSON(reservationErrorStatus(err), gin.H{"error": "預留庫存失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"message": "庫存預留成功", "reservation": reservation})
+}
+
+// GetReservation handles getting the reservation of an order
+func (h *InventoryHandler) GetReservation(c *gin.Context) {
+	orderID := c.Param("order_id")
+	if orderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "訂單 ID 不能為空"})
+		return
+	}
+
+	reservation, err := h.inventoryService.GetReservation(c.Request.Context(), orderID)
+	if err != nil {
+		c.JSON(reservationErrorStatus(err), gin.H{"error": "獲取預留失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reservation": reservation})
+}
+
+// CommitReservation handles deducting reserved stock
+func (h *InventoryHandler) CommitReservation(c *gin.Context) {
+	orderID := c.Param("order_id")
+	if orderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "訂單 ID 不能為空"})
+		return
+	}
+
+	reservation, err := h.inventoryService.Commit(c.Request.Context(), orderID)
+	if err != nil {
+		c.JSON(reservationErrorStatus(err), gin.H{"error": "確認預留失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "預留確認成功", "reservation": reservation})
+}
+
+// ReleaseReservation handles returning reserved stock
+func (h *InventoryHandler) ReleaseReservation(c *gin.Context) {
+	orderID := c.Param("order_id")
+	if orderID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "訂單 ID 不能為空"})
+		return
+	}
+
+	// The body is optional
+	var req ReleaseRequest
+	_ = c.ShouldBindJSON(&req)
+
+	reservation, err := h.inventoryService.Release(c.Request.Context(), orderID, req.Reason)
+	if err != nil {
+		c.JSON(reservationErrorStatus(err), gin.H{"error": "釋放預留失敗: " + err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "預留釋放成功", "reservation": reservation})
+}
+
+// RegisterRoutes registers the reservation routes
+func (h *InventoryHandler) RegisterRoutes(router *gin.Engine, authMiddleware gin.HandlerFunc) {
+	// Reservations are normally managed by the order service over gRPC
+	reservations := router.Group("/api/reservations", authMiddleware, middleware.RequirePermissions(middleware.PermInventoryWrite))
+	{
+		reservations.POST("", h.ReserveStock)
+		reservations.GET("/:order_id", h.GetReservation)
+		reservations.POST("/:order_id/commit", h.CommitReservation)
+		reservations.POST("/:order_id/release", h.ReleaseReservation)
+	}
+}
+
+// reservationErrorStatus maps inventory service errors to HTTP status codes
+func reservationErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrReservationNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrInsufficientStock),
+		errors.Is(err, service.ErrReservationReleased),
+		errors.Is(err, service.ErrReservationCommitted):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
@@ -1,10 +1,12 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
+	"github.com/arrontsai/ecommerce/pkg/middleware"
 	"github.com/arrontsai/ecommerce/pkg/models"
 	"github.com/arrontsai/ecommerce/services/product/service"
 )
@@ -12,12 +14,14 @@ import (
 // ProductHandler handles product HTTP requests
 type ProductHandler struct {
 	productService service.ProductService
+	priceService   service.PriceService
 }
 
 // NewProductHandler creates a new ProductHandler
-func NewProductHandler(productService service.ProductService) *ProductHandler {
+func NewProductHandler(productService service.ProductService, priceService service.PriceService) *ProductHandler {
 	return &ProductHandler{
 		productService: productService,
+		priceService:   priceService,
 	}
 }
 
@@ -28,6 +32,10 @@ func (h *ProductHandler) CreateProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
 		return
 	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
 
 	product, err := h.productService.CreateProduct(c.Request.Context(), req)
 	if err != nil {
@@ -51,6 +59,10 @@ func (h *ProductHandler) GetProduct(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取產品失敗: " + err.Error()})
 		return
 	}
+	if err := h.localize(c, product); err != nil {
+		c.JSON(priceErrorStatus(err), gin.H{"error": "獲取產品失敗: " + err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"product": product})
 }
@@ -74,6 +86,10 @@ func (h *ProductHandler) GetProducts(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取產品失敗: " + err.Error()})
 		return
 	}
+	if err := h.localize(c, products...); err != nil {
+		c.JSON(priceErrorStatus(err), gin.H{"error": "獲取產品失敗: " + err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"products": products,
@@ -111,6 +127,10 @@ func (h *ProductHandler) GetProductsByCategory(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "獲取產品失敗: " + err.Error()})
 		return
 	}
+	if err := h.localize(c, products...); err != nil {
+		c.JSON(priceErrorStatus(err), gin.H{"error": "獲取產品失敗: " + err.Error()})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"products": products,
@@ -137,6 +157,10 @@ func (h *ProductHandler) UpdateProduct(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
 		return
 	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "無效的請求數據: " + err.Error()})
+		return
+	}
 
 	// Update product
 	product, err := h.productService.UpdateProduct(c.Request.Context(), id, req)
@@ -168,16 +192,47 @@ func (h *ProductHandler) DeleteProduct(c *gin.Context) {
 
 // RegisterRoutes registers the product routes
 func (h *ProductHandler) RegisterRoutes(router *gin.Engine, authMiddleware gin.HandlerFunc) {
-	products := router.Group("/api/products")
+	// Prices are shown in the currency selected by ?currency= or X-Currency
+	products := router.Group("/api/products", middleware.SelectCurrency())
 	{
 		products.GET("", h.GetProducts)
 		products.GET("/:id", h.GetProduct)
 		products.GET("/category/:category_id", h.GetProductsByCategory)
-		
-		// Protected routes
-		products.POST("", authMiddleware, h.CreateProduct)
-		products.PUT("/:id", authMiddleware, h.UpdateProduct)
-		products.DELETE("/:id", authMiddleware, h.DeleteProduct)
+	}
+
+	// Catalog mutations are restricted to roles with catalog:write
+	admin := router.Group("/api/products", authMiddleware, middleware.RequirePermissions(middleware.PermCatalogWrite))
+	{
+		admin.POST("", h.CreateProduct)
+		admin.PUT("/:id", h.UpdateProduct)
+		admin.DELETE("/:id", h.DeleteProduct)
 	}
 }
 
+
+// localize replaces the price of the products with their price in the
+// currency selected by the request, if any
+func (h *ProductHandler) localize(c *gin.Context, products ...*models.Product) error {
+	currency := middleware.SelectedCurrency(c)
+	if currency == "" {
+		return nil
+	}
+
+	for _, product := range products {
+		price, converted, err := h.priceService.PriceIn(c.Request.Context(), product, currency)
+		if err != nil {
+			return err
+		}
+		product.Price = price
+		product.PriceConverted = converted
+	}
+	return nil
+}
+
+// priceErrorStatus maps pricing errors to HTTP status codes
+func priceErrorStatus(err error) int {
+	if errors.Is(err, service.ErrPriceUnavailable) {
+		return http.StatusUnprocessableEntity
+	}
+	return http.StatusServiceUnavailable
+}
@@ -0,0 +1,189 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// InventoryRepository defines the interface for stock reservation operations
+//
+// Stock holds live inside the product document, so every hold, commit and
+// release is a single-document atomic update. Each operation is idempotent
+// per order, which makes multi-item reservations safe to retry.
+type InventoryRepository interface {
+	HoldStock(ctx context.Context, productID, orderID string, quantity int, expiresAt time.Time) (bool, error)
+	CommitHold(ctx context.Context, productID, orderID string, quantity int) error
+	ReleaseHold(ctx context.Context, productID, orderID string, quantity int) error
+	CreateReservation(ctx context.Context, reservation *models.Reservation) (bool, error)
+	FindReservation(ctx context.Context, orderID string) (*models.Reservation, error)
+	UpdateReservationStatus(ctx context.Context, orderID string, from []models.ReservationStatus, to models.ReservationStatus, reason string) (bool, error)
+	FindExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*models.Reservation, error)
+	AvailableStock(ctx context.Context, productIDs []string) (map[string]int, error)
+}
+
+// MongoInventoryRepository implements InventoryRepository using MongoDB
+type MongoInventoryRepository struct {
+	products     *mongo.Collection
+	reservations *mongo.Collection
+}
+
+// NewMongoInventoryRepository creates a new MongoInventoryRepository
+func NewMongoInventoryRepository(db *mongo.Database) InventoryRepository {
+	return &MongoInventoryRepository{
+		products:     db.Collection("products"),
+		reservations: db.Collection("reservations"),
+	}
+}
+
+// HoldStock holds stock for an order if enough is available
+//
+// It returns false when the product does not exist or does not have enough
+// available stock. Holding again for the same order is a no-op.
+func (r *MongoInventoryRepository) HoldStock(ctx context.Context, productID, orderID string, quantity int, expiresAt time.Time) (bool, error) {
+	filter := bson.M{
+		"_id":            productID,
+		"holds.order_id": bson.M{"$ne": orderID},
+		// inventory - reserved >= quantity, evaluated atomically with the update
+		"$expr": bson.M{"$gte": bson.A{
+			bson.M{"$subtract": bson.A{"$inventory", bson.M{"$ifNull": bson.A{"$reserved", 0}}}},
+			quantity,
+		}},
+	}
+	update := bson.M{
+		"$inc":  bson.M{"reserved": quantity},
+		"$push": bson.M{"holds": models.StockHold{OrderID: orderID, Quantity: quantity, ExpiresAt: expiresAt}},
+		"$set":  bson.M{"updated_at": time.Now()},
+	}
+
+	result, err := r.products.UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
+	}
+	if result.MatchedCount > 0 {
+		return true, nil
+	}
+
+	// The update did not match: either the hold already exists or stock is short
+	count, err := r.products.CountDocuments(ctx, bson.M{"_id": productID, "holds.order_id": orderID})
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// CommitHold deducts held stock from the inventory and removes the hold
+func (r *MongoInventoryRepository) CommitHold(ctx context.Context, productID, orderID string, quantity int) error {
+	_, err := r.products.UpdateOne(ctx,
+		bson.M{"_id": productID, "holds": bson.M{"$elemMatch": bson.M{"order_id": orderID, "quantity": quantity}}},
+		bson.M{
+			"$inc":  bson.M{"inventory": -quantity, "reserved": -quantity},
+			"$pull": bson.M{"holds": bson.M{"order_id": orderID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	return err
+}
+
+// ReleaseHold returns held stock to the available inventory and removes the hold
+func (r *MongoInventoryRepository) ReleaseHold(ctx context.Context, productID, orderID string, quantity int) error {
+	_, err := r.products.UpdateOne(ctx,
+		bson.M{"_id": productID, "holds": bson.M{"$elemMatch": bson.M{"order_id": orderID, "quantity": quantity}}},
+		bson.M{
+			"$inc":  bson.M{"reserved": -quantity},
+			"$pull": bson.M{"holds": bson.M{"order_id": orderID}},
+			"$set":  bson.M{"updated_at": time.Now()},
+		},
+	)
+	return err
+}
+
+// CreateReservation inserts a reservation, returning false if one already exists for the order
+func (r *MongoInventoryRepository) CreateReservation(ctx context.Context, reservation *models.Reservation) (bool, error) {
+	_, err := r.reservations.InsertOne(ctx, reservation)
+	if err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// FindReservation finds the reservation of an order
+func (r *MongoInventoryRepository) FindReservation(ctx context.Context, orderID string) (*models.Reservation, error) {
+	var reservation models.Reservation
+	err := r.reservations.FindOne(ctx, bson.M{"_id": orderID}).Decode(&reservation)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// UpdateReservationStatus changes the reservation status if it is currently one of from
+func (r *MongoInventoryRepository) UpdateReservationStatus(ctx context.Context, orderID string, from []models.ReservationStatus, to models.ReservationStatus, reason string) (bool, error) {
+	set := bson.M{"status": to, "updated_at": time.Now()}
+	if reason != "" {
+		set["reason"] = reason
+	}
+
+	result, err := r.reservations.UpdateOne(ctx,
+		bson.M{"_id": orderID, "status": bson.M{"$in": from}},
+		bson.M{"$set": set},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// FindExpiredReservations finds pending reservations whose TTL has passed
+func (r *MongoInventoryRepository) FindExpiredReservations(ctx context.Context, now time.Time, limit int) ([]*models.Reservation, error) {
+	opts := options.Find().
+		SetLimit(int64(limit)).
+		SetSort(bson.D{{Key: "expires_at", Value: 1}})
+
+	cursor, err := r.reservations.Find(ctx,
+		bson.M{"status": models.ReservationPending, "expires_at": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var reservations []*models.Reservation
+	if err := cursor.All(ctx, &reservations); err != nil {
+		return nil, err
+	}
+
+	return reservations, nil
+}
+
+// AvailableStock returns the unreserved stock of the products that exist
+func (r *MongoInventoryRepository) AvailableStock(ctx context.Context, productIDs []string) (map[string]int, error) {
+	cursor, err := r.products.Find(ctx,
+		bson.M{"_id": bson.M{"$in": productIDs}},
+		options.Find().SetProjection(bson.M{"inventory": 1, "reserved": 1}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+
+	available := make(map[string]int, len(products))
+	for _, product := range products {
+		available[product.ID] = max(product.Inventory-product.Reserved, 0)
+	}
+	return available, nil
+}
@@ -16,6 +16,7 @@ type ProductRepository interface {
 	Create(ctx context.Context, product *models.Product) error
 	FindByID(ctx context.Context, id string) (*models.Product, error)
 	FindBySKU(ctx context.Context, sku string) (*models.Product, error)
+	FindByIDs(ctx context.Context, ids []string) ([]*models.Product, error)
 	FindAll(ctx context.Context, page, pageSize int) ([]*models.Product, error)
 	FindByCategory(ctx context.Context, categoryID string, page, pageSize int) ([]*models.Product, error)
 	Update(ctx context.Context, product *models.Product) error
@@ -78,6 +79,22 @@ func (r *MongoProductRepository) FindBySKU(ctx context.Context, sku string) (*mo
 	return &product, nil
 }
 
+// FindByIDs finds the products with the given IDs, skipping IDs that do not exist
+func (r *MongoProductRepository) FindByIDs(ctx context.Context, ids []string) ([]*models.Product, error) {
+	cursor, err := r.collection.Find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(ctx)
+
+	var products []*models.Product
+	if err := cursor.All(ctx, &products); err != nil {
+		return nil, err
+	}
+
+	return products, nil
+}
+
 // FindAll finds all products with pagination
 func (r *MongoProductRepository) FindAll(ctx context.Context, page, pageSize int) ([]*models.Product, error) {
 	// Calculate skip
@@ -133,9 +150,25 @@ func (r *MongoProductRepository) FindByCategory(ctx context.Context, categoryID
 }
 
 // Update updates a product in the database
+//
+// Reserved stock and holds are owned by the inventory repository and are not
+// overwritten, so concurrent reservations are never lost.
 func (r *MongoProductRepository) Update(ctx context.Context, product *models.Product) error {
 	product.UpdatedAt = time.Now()
-	_, err := r.collection.ReplaceOne(ctx, bson.M{"_id": product.ID}, product)
+	_, err := r.collection.UpdateOne(ctx, bson.M{"_id": product.ID}, bson.M{"$set": bson.M{
+		"name":         product.Name,
+		"description":  product.Description,
+		"price":        product.Price,
+		"prices":       product.Prices,
+		"sku":          product.SKU,
+		"category_id":  product.CategoryID,
+		"tax_category": product.TaxCategory,
+		"weight":       product.Weight,
+		"dimensions":   product.Dimensions,
+		"inventory":    product.Inventory,
+		"images":       product.Images,
+		"updated_at":   product.UpdatedAt,
+	}})
 	return err
 }
 
@@ -154,4 +187,3 @@ func (r *MongoProductRepository) CountAll(ctx context.Context) (int64, error) {
 func (r *MongoProductRepository) CountByCategory(ctx context.Context, categoryID string) (int64, error) {
 	return r.collection.CountDocuments(ctx, bson.M{"category_id": categoryID})
 }
-
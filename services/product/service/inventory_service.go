@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/services/product/repository"
+)
+
+// DefaultReservationTTL is how long stock is held when the caller does not specify a TTL
+const DefaultReservationTTL = 15 * time.Minute
+
+var (
+	// ErrInsufficientStock is returned when a product does not have enough available stock
+	ErrInsufficientStock = errors.New("庫存不足")
+	// ErrReservationNotFound is returned when an order has no reservation
+	ErrReservationNotFound = errors.New("預留不存在")
+	// ErrReservationReleased is returned when committing a reservation that was already released
+	ErrReservationReleased = errors.New("預留已釋放")
+	// ErrReservationCommitted is returned when releasing a reservation that was already committed
+	ErrReservationCommitted = errors.New("預留已確認")
+)
+
+// InventoryService defines the interface for stock reservation operations
+type InventoryService interface {
+	Reserve(ctx context.Context, req models.ReserveStockRequest) (*models.Reservation, error)
+	Commit(ctx context.Context, orderID string) (*models.Reservation, error)
+	Release(ctx context.Context, orderID, reason string) (*models.Reservation, error)
+	GetReservation(ctx context.Context, orderID string) (*models.Reservation, error)
+	ReleaseExpired(ctx context.Context, now time.Time) (int, error)
+	AvailableStock(ctx context.Context, productIDs []string) (map[string]int, error)
+}
+
+// DefaultInventoryService implements InventoryService
+type DefaultInventoryService struct {
+	inventoryRepo repository.InventoryRepository
+}
+
+// NewInventoryService creates a new InventoryService
+func NewInventoryService(inventoryRepo repository.InventoryRepository) InventoryService {
+	return &DefaultInventoryService{
+		inventoryRepo: inventoryRepo,
+	}
+}
+
+// Reserve holds stock for every item of an order
+//
+// Reserving is idempotent per order: a repeated call returns the existing
+// reservation. Either all items are held or none are.
+func (s *DefaultInventoryService) Reserve(ctx context.Context, req models.ReserveStockRequest) (*models.Reservation, error) {
+	ttl := DefaultReservationTTL
+	if req.TTLSeconds > 0 {
+		ttl = time.Duration(req.TTLSeconds) * time.Second
+	}
+
+	// Record the reservation first so a crash mid-way can be swept and released
+	reservation := models.NewReservation(req.OrderID, mergeReservationItems(req.Items), ttl)
+	created, err := s.inventoryRepo.CreateReservation(ctx, reservation)
+	if err != nil {
+		return nil, err
+	}
+	if !created {
+		existing, err := s.inventoryRepo.FindReservation(ctx, req.OrderID)
+		if err != nil {
+			return nil, err
+		}
+		if existing == nil {
+			return nil, ErrReservationNotFound
+		}
+		if existing.Status == models.ReservationReleased {
+			return nil, fmt.Errorf("%w: %s", ErrReservationReleased, existing.Reason)
+		}
+		return existing, nil
+	}
+
+	for _, item := range reservation.Items {
+		held, err := s.inventoryRepo.HoldStock(ctx, item.ProductID, reservation.OrderID, item.Quantity, reservation.ExpiresAt)
+		if err != nil {
+			s.release(ctx, reservation, "預留失敗")
+			return nil, err
+		}
+		if !held {
+			reason := fmt.Sprintf("產品 %s 庫存不足", item.ProductID)
+			s.release(ctx, reservation, reason)
+			return nil, fmt.Errorf("%w: %s", ErrInsufficientStock, item.ProductID)
+		}
+	}
+
+	return reservation, nil
+}
+
+// Commit deducts the reserved stock after the order has been paid
+func (s *DefaultInventoryService) Commit(ctx context.Context, orderID string) (*models.Reservation, error) {
+	reservation, err := s.GetReservation(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch reservation.Status {
+	case models.ReservationReleased:
+		return nil, ErrReservationReleased
+	case models.ReservationPending:
+		ok, err := s.inventoryRepo.UpdateReservationStatus(ctx, orderID,
+			[]models.ReservationStatus{models.ReservationPending}, models.ReservationCommitted, "")
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			// Raced with a release or another commit, re-evaluate from the stored state
+			return s.Commit(ctx, orderID)
+		}
+		reservation.Status = models.ReservationCommitted
+	}
+
+	// Applying holds is idempotent, so a retried commit finishes any leftovers
+	for _, item := range reservation.Items {
+		if err := s.inventoryRepo.CommitHold(ctx, item.ProductID, orderID, item.Quantity); err != nil {
+			return nil, err
+		}
+	}
+
+	return reservation, nil
+}
+
+// Release returns the reserved stock after the order was cancelled
+func (s *DefaultInventoryService) Release(ctx context.Context, orderID, reason string) (*models.Reservation, error) {
+	reservation, err := s.GetReservation(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch reservation.Status {
+	case models.ReservationCommitted:
+		return nil, ErrReservationCommitted
+	case models.ReservationPending:
+		ok, err := s.inventoryRepo.UpdateReservationStatus(ctx, orderID,
+			[]models.ReservationStatus{models.ReservationPending}, models.ReservationReleased, reason)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return s.Release(ctx, orderID, reason)
+		}
+		reservation.Status = models.ReservationReleased
+		reservation.Reason = reason
+	}
+
+	for _, item := range reservation.Items {
+		if err := s.inventoryRepo.ReleaseHold(ctx, item.ProductID, orderID, item.Quantity); err != nil {
+			return nil, err
+		}
+	}
+
+	return reservation, nil
+}
+
+// GetReservation gets the reservation of an order
+func (s *DefaultInventoryService) GetReservation(ctx context.Context, orderID string) (*models.Reservation, error) {
+	reservation, err := s.inventoryRepo.FindReservation(ctx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if reservation == nil {
+		return nil, ErrReservationNotFound
+	}
+
+	return reservation, nil
+}
+
+// ReleaseExpired releases pending reservations whose TTL has passed
+func (s *DefaultInventoryService) ReleaseExpired(ctx context.Context, now time.Time) (int, error) {
+	expired, err := s.inventoryRepo.FindExpiredReservations(ctx, now, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	released := 0
+	for _, reservation := range expired {
+		if _, err := s.Release(ctx, reservation.OrderID, "預留逾時"); err != nil {
+			if errors.Is(err, ErrReservationCommitted) {
+				continue
+			}
+			return released, err
+		}
+		released++
+	}
+
+	return released, nil
+}
+
+// AvailableStock returns the stock that can still be reserved per product
+//
+// Unknown products are left out of the result.
+func (s *DefaultInventoryService) AvailableStock(ctx context.Context, productIDs []string) (map[string]int, error) {
+	if len(productIDs) == 0 {
+		return map[string]int{}, nil
+	}
+	return s.inventoryRepo.AvailableStock(ctx, productIDs)
+}
+
+// release marks a reservation released and returns whatever was already held
+func (s *DefaultInventoryService) release(ctx context.Context, reservation *models.Reservation, reason string) {
+	_, _ = s.inventoryRepo.UpdateReservationStatus(ctx, reservation.OrderID,
+		[]models.ReservationStatus{models.ReservationPending}, models.ReservationReleased, reason)
+	for _, item := range reservation.Items {
+		_ = s.inventoryRepo.ReleaseHold(ctx, item.ProductID, reservation.OrderID, item.Quantity)
+	}
+	reservation.Status = models.ReservationReleased
+	reservation.Reason = reason
+}
+
+// mergeReservationItems combines duplicate products into a single line
+func mergeReservationItems(items []models.ReservationItem) []models.ReservationItem {
+	merged := make([]models.ReservationItem, 0, len(items))
+	index := make(map[string]int, len(items))
+	for _, item := range items {
+		if i, ok := index[item.ProductID]; ok {
+			merged[i].Quantity += item.Quantity
+			continue
+		}
+		index[item.ProductID] = len(merged)
+		merged = append(merged, item)
+	}
+	return merged
+}
@@ -0,0 +1,53 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/arrontsai/ecommerce/pkg/exchange"
+	"github.com/arrontsai/ecommerce/pkg/models"
+	"github.com/arrontsai/ecommerce/pkg/money"
+)
+
+// ErrPriceUnavailable is returned when a product has no price in the currency
+// and its base price cannot be converted to it
+var ErrPriceUnavailable = errors.New("無法以此幣別計價")
+
+// PriceService defines the interface for pricing products in a currency
+type PriceService interface {
+	// PriceIn returns the price of the product in the currency and whether it
+	// was converted from the base price instead of being a list price
+	PriceIn(ctx context.Context, product *models.Product, currency string) (money.Money, bool, error)
+}
+
+// DefaultPriceService implements PriceService
+//
+// A list price for the currency is used when the product has one; otherwise
+// the base price is converted at the rate of the exchange rate provider.
+type DefaultPriceService struct {
+	rates exchange.ExchangeRateProvider
+}
+
+// NewPriceService creates a new PriceService
+func NewPriceService(rates exchange.ExchangeRateProvider) PriceService {
+	return &DefaultPriceService{
+		rates: rates,
+	}
+}
+
+// PriceIn prices a product in a currency
+func (s *DefaultPriceService) PriceIn(ctx context.Context, product *models.Product, currency string) (money.Money, bool, error) {
+	if price, ok := product.ListPrice(currency); ok {
+		return price, false, nil
+	}
+
+	price, err := exchange.Convert(ctx, s.rates, product.Price, currency)
+	if err != nil {
+		if errors.Is(err, exchange.ErrRateNotFound) {
+			return money.Money{}, false, fmt.Errorf("%w: %s: %v", ErrPriceUnavailable, currency, err)
+		}
+		return money.Money{}, false, err
+	}
+	return price, true, nil
+}
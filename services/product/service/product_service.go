@@ -12,6 +12,7 @@ import (
 type ProductService interface {
 	CreateProduct(ctx context.Context, req models.ProductRequest) (*models.Product, error)
 	GetProductByID(ctx context.Context, id string) (*models.Product, error)
+	GetProductsByIDs(ctx context.Context, ids []string) ([]*models.Product, error)
 	GetProducts(ctx context.Context, page, pageSize int) ([]*models.Product, int64, error)
 	GetProductsByCategory(ctx context.Context, categoryID string, page, pageSize int) ([]*models.Product, int64, error)
 	UpdateProduct(ctx context.Context, id string, req models.ProductRequest) (*models.Product, error)
@@ -45,6 +46,10 @@ func (s *DefaultProductService) CreateProduct(ctx context.Context, req models.Pr
 
 	// Create the product
 	product := models.NewProduct(req.Name, req.Description, req.Price, req.SKU, req.CategoryID, req.Inventory, req.Images)
+	product.Prices = req.Prices
+	product.TaxCategory = req.TaxCategory
+	product.Weight = req.Weight
+	product.Dimensions = req.Dimensions
 
 	// Save the product
 	if err := s.productRepo.Create(ctx, product); err != nil {
@@ -68,6 +73,14 @@ func (s *DefaultProductService) GetProductByID(ctx context.Context, id string) (
 	return product, nil
 }
 
+// GetProductsByIDs gets the products with the given IDs, skipping IDs that do not exist
+func (s *DefaultProductService) GetProductsByIDs(ctx context.Context, ids []string) ([]*models.Product, error) {
+	if len(ids) == 0 {
+		return []*models.Product{}, nil
+	}
+	return s.productRepo.FindByIDs(ctx, ids)
+}
+
 // GetProducts gets all products with pagination
 func (s *DefaultProductService) GetProducts(ctx context.Context, page, pageSize int) ([]*models.Product, int64, error) {
 	// Get the total count